@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"sync"
+
+	"live-im-proxy/bus"
+)
+
+// Counter 按租户/渠道统计的计数
+type Counter struct {
+	TenantID      string `json:"tenant_id"`
+	Channel       string `json:"channel"`
+	Events        int64  `json:"events"`
+	RepliesSent   int64  `json:"replies_sent"`
+	RepliesFailed int64  `json:"replies_failed"`
+	LeadsPushed   int64  `json:"leads_pushed"`
+	LastEventAt   int64  `json:"last_event_at"`
+
+	ReplyTiers map[string]int64 `json:"reply_tiers,omitempty"` // 已发送的机器人回答按来源层级计数
+}
+
+// Collector 实时统计收集器，订阅事件总线
+type Collector struct {
+	mu       sync.RWMutex
+	counters map[string]*Counter
+}
+
+// NewCollector 创建统计收集器
+func NewCollector() *Collector {
+	return &Collector{
+		counters: make(map[string]*Counter),
+	}
+}
+
+// Subscribe 订阅事件总线
+func (c *Collector) Subscribe(b *bus.Bus) *bus.Subscription {
+	return b.Subscribe("analytics", 0, c.handle,
+		bus.TopicEventReceived, bus.TopicReplySent, bus.TopicReplyFailed, bus.TopicLeadPushed)
+}
+
+// Snapshot 获取当前统计
+func (c *Collector) Snapshot() []Counter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]Counter, 0, len(c.counters))
+	for _, counter := range c.counters {
+		item := *counter
+		if counter.ReplyTiers != nil {
+			item.ReplyTiers = make(map[string]int64, len(counter.ReplyTiers))
+			for tier, count := range counter.ReplyTiers {
+				item.ReplyTiers[tier] = count
+			}
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// handle 处理总线消息
+func (c *Collector) handle(msg *bus.Message) {
+	evt := msg.Event
+	if evt == nil {
+		return
+	}
+
+	key := evt.TenantID + "/" + evt.Channel
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counter, ok := c.counters[key]
+	if !ok {
+		counter = &Counter{TenantID: evt.TenantID, Channel: evt.Channel}
+		c.counters[key] = counter
+	}
+
+	switch msg.Topic {
+	case bus.TopicEventReceived:
+		counter.Events++
+		counter.LastEventAt = msg.Timestamp
+	case bus.TopicReplySent:
+		counter.RepliesSent++
+		if msg.Reply != nil && msg.Reply.Tier != "" {
+			if counter.ReplyTiers == nil {
+				counter.ReplyTiers = make(map[string]int64)
+			}
+			counter.ReplyTiers[msg.Reply.Tier]++
+		}
+	case bus.TopicReplyFailed:
+		counter.RepliesFailed++
+	case bus.TopicLeadPushed:
+		counter.LeadsPushed++
+	}
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"live-im-proxy/bus"
+)
+
+// Logger 审计日志，将总线消息以JSON行格式追加写入文件
+type Logger struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewLogger 创建审计日志
+func NewLogger(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	return &Logger{file: f}, nil
+}
+
+// Subscribe 订阅事件总线的全部主题
+func (l *Logger) Subscribe(b *bus.Bus) *bus.Subscription {
+	return b.SubscribeReliable("audit", 1024, l.handle)
+}
+
+// Close 关闭审计日志文件
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// handle 写入一条审计记录
+func (l *Logger) handle(msg *bus.Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("❌ 序列化审计记录失败: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		log.Printf("❌ 写入审计日志失败: %v", err)
+	}
+}
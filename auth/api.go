@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// createRequest 创建密钥请求
+type createRequest struct {
+	TenantID  string  `json:"tenant_id"`
+	Name      string  `json:"name"`
+	Role      Role    `json:"role"`
+	RateLimit float64 `json:"rate_limit"`
+	Burst     int     `json:"burst"`
+}
+
+// RegisterHandlers 注册API密钥管理接口（需要 admin 角色）
+//
+//	GET    /api/admin/keys?tenant_id=   密钥列表
+//	POST   /api/admin/keys              创建密钥 {"tenant_id","name","role","rate_limit","burst"}，明文密钥只返回一次
+//	GET    /api/admin/keys/{id}         密钥详情
+//	DELETE /api/admin/keys/{id}         吊销密钥
+func (s *Store) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/api/admin/keys", s.handleKeys)
+	mux.HandleFunc("/api/admin/keys/", s.handleKey)
+}
+
+// handleKeys 密钥列表和创建
+func (s *Store) handleKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"data":    s.List(r.URL.Query().Get("tenant_id")),
+		})
+	case "POST":
+		var req createRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误", http.StatusBadRequest)
+			return
+		}
+
+		createdBy := ""
+		if caller, ok := FromContext(r.Context()); ok {
+			createdBy = caller.ID
+		}
+		raw, key, err := s.Create(req.TenantID, req.Name, req.Role, req.RateLimit, req.Burst, createdBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"success": true,
+			"message": "API密钥已创建，请妥善保存，之后无法再次查看",
+			"data": map[string]interface{}{
+				"key":  raw,
+				"info": key,
+			},
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleKey 单个密钥的查询和吊销
+func (s *Store) handleKey(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/admin/keys/")
+	key, ok := s.Get(id)
+	if !ok || !CanAccessTenant(r, key.TenantID) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": key})
+	case "DELETE":
+		key, err := s.Revoke(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"message": "API密钥已吊销",
+			"data":    key,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role 角色，权限依次递增
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // 只读：状态、会话记录、审计
+	RoleOperator Role = "operator" // 运营：启停渠道、人工接管、黑白名单
+	RoleAdmin    Role = "admin"    // 管理：API密钥管理
+)
+
+// AllTenants 可访问所有租户的密钥
+const AllTenants = "*"
+
+// keyPrefix API密钥前缀，格式 lbk_<id>_<secret>
+const keyPrefix = "lbk_"
+
+var (
+	// ErrInvalidKey 密钥无效或已吊销
+	ErrInvalidKey = errors.New("无效的API密钥")
+	// ErrNotFound 密钥不存在
+	ErrNotFound = errors.New("API密钥不存在")
+)
+
+// rank 角色等级
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Allows 是否具备 required 角色的权限
+func (r Role) Allows(required Role) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
+// Valid 是否为已知角色
+func (r Role) Valid() bool {
+	return r.rank() > 0
+}
+
+// Key API密钥，只保存哈希
+type Key struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	Hash       string     `json:"hash,omitempty"`
+	RateLimit  float64    `json:"rate_limit,omitempty"` // 每秒请求数，0 使用默认值
+	Burst      int        `json:"burst,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Revoked 是否已吊销
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// CanAccessTenant 是否可以访问租户
+func (k *Key) CanAccessTenant(tenantID string) bool {
+	return k.TenantID == AllTenants || k.TenantID == tenantID
+}
+
+// public 去掉哈希的副本，用于接口返回
+func (k *Key) public() Key {
+	copied := *k
+	copied.Hash = ""
+	return copied
+}
+
+// Store API密钥存储，path不为空时持久化到JSON文件（只含哈希）
+type Store struct {
+	path      string
+	keys      map[string]*Key
+	bootstrap *Key
+	mu        sync.RWMutex
+}
+
+// NewStore 创建密钥存储
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		keys: make(map[string]*Key),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取API密钥存储失败: %v", err)
+	}
+
+	var keys []*Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("解析API密钥存储失败: %v", err)
+	}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+	}
+	return s, nil
+}
+
+// SetBootstrapKey 设置引导管理员密钥（不落盘，可访问所有租户），用于创建第一批密钥
+func (s *Store) SetBootstrapKey(raw string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if raw == "" {
+		s.bootstrap = nil
+		return
+	}
+	s.bootstrap = &Key{
+		ID:       "bootstrap",
+		TenantID: AllTenants,
+		Name:     "ADMIN_API_KEY",
+		Role:     RoleAdmin,
+		Hash:     hashSecret(raw),
+	}
+}
+
+// Create 创建密钥，返回明文密钥（只在创建时返回一次）
+func (s *Store) Create(tenantID, name string, role Role, rateLimit float64, burst int, createdBy string) (string, Key, error) {
+	if tenantID == "" {
+		return "", Key{}, fmt.Errorf("缺少 tenant_id")
+	}
+	if !role.Valid() {
+		return "", Key{}, fmt.Errorf("未知的角色 %q，可选 admin、operator、viewer", role)
+	}
+	if rateLimit < 0 || burst < 0 {
+		return "", Key{}, fmt.Errorf("rate_limit 和 burst 不能为负数")
+	}
+
+	id, err := randomString(6, hex.EncodeToString)
+	if err != nil {
+		return "", Key{}, err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", Key{}, err
+	}
+	raw := keyPrefix + id + "_" + secret
+
+	key := &Key{
+		ID:        id,
+		TenantID:  tenantID,
+		Name:      name,
+		Role:      role,
+		Hash:      hashSecret(raw),
+		RateLimit: rateLimit,
+		Burst:     burst,
+		CreatedAt: time.Now(),
+		CreatedBy: createdBy,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return "", Key{}, err
+	}
+	return raw, key.public(), nil
+}
+
+// Revoke 吊销密钥
+func (s *Store) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	if !key.Revoked() {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := s.save(); err != nil {
+			key.RevokedAt = nil
+			return Key{}, err
+		}
+	}
+	return key.public(), nil
+}
+
+// Get 获取密钥
+func (s *Store) Get(id string) (Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, false
+	}
+	return key.public(), true
+}
+
+// Active 密钥是否仍可使用（未吊销），用于长连接定期复核
+func (s *Store) Active(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.bootstrap != nil && id == s.bootstrap.ID {
+		return true
+	}
+	key, ok := s.keys[id]
+	return ok && !key.Revoked()
+}
+
+// List 列出密钥，tenantID为空时返回全部
+func (s *Store) List(tenantID string) []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		if tenantID != "" && key.TenantID != tenantID {
+			continue
+		}
+		keys = append(keys, key.public())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Empty 是否没有任何可用密钥（包括引导密钥）
+func (s *Store) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.bootstrap != nil {
+		return false
+	}
+	for _, key := range s.keys {
+		if !key.Revoked() {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticate 校验明文密钥
+func (s *Store) Authenticate(raw string) (Key, error) {
+	hash := hashSecret(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bootstrap != nil && subtle.ConstantTimeCompare([]byte(hash), []byte(s.bootstrap.Hash)) == 1 {
+		return s.bootstrap.public(), nil
+	}
+
+	id, ok := parseKeyID(raw)
+	if !ok {
+		return Key{}, ErrInvalidKey
+	}
+	key, ok := s.keys[id]
+	if !ok || key.Revoked() || subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+
+	// 最近使用时间只在内存中更新，避免每次请求写盘
+	now := time.Now()
+	key.LastUsedAt = &now
+	return key.public(), nil
+}
+
+// save 写入文件，调用方需持有锁
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建API密钥存储目录失败: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入API密钥存储失败: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// parseKeyID 从明文密钥中解析ID
+func parseKeyID(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, keyPrefix) {
+		return "", false
+	}
+	id, _, ok := strings.Cut(strings.TrimPrefix(raw, keyPrefix), "_")
+	return id, ok && id != ""
+}
+
+// hashSecret 密钥哈希：密钥本身是高熵随机串，SHA-256 即可
+func hashSecret(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encode(buf), nil
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"live-im-proxy/limiter"
+	"live-im-proxy/secrets"
+)
+
+// 默认每个密钥的限流参数
+const (
+	DefaultRateLimit = 10
+	DefaultBurst     = 20
+)
+
+// maxBody 校验租户时读取的请求体上限
+const maxBody = 1 << 20
+
+type contextKey struct{}
+
+// Authenticator 管理API鉴权：校验API密钥、角色和租户范围，并按密钥限流
+type Authenticator struct {
+	store   *Store
+	limiter *limiter.KeyedLimiter
+}
+
+// NewAuthenticator 创建鉴权中间件
+func NewAuthenticator(store *Store) *Authenticator {
+	if store.Empty() {
+		log.Printf("⚠️ 未配置 ADMIN_API_KEY 且没有可用的API密钥，管理接口将拒绝所有请求")
+	}
+	return &Authenticator{
+		store:   store,
+		limiter: limiter.NewKeyedLimiter(DefaultRateLimit, DefaultBurst, 10*time.Minute),
+	}
+}
+
+// Close 停止限流器的空闲回收
+func (a *Authenticator) Close() {
+	a.limiter.Close()
+}
+
+// RequiredRole 接口所需角色：密钥管理需要 admin，只读请求需要 viewer，其余需要 operator
+func RequiredRole(r *http.Request) Role {
+	if strings.HasPrefix(r.URL.Path, "/api/admin/") {
+		return RoleAdmin
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return RoleViewer
+	}
+	return RoleOperator
+}
+
+// Middleware 鉴权中间件，密钥通过 Authorization: Bearer 或 X-API-Key 传递；
+// 租户密钥的 tenant_id（查询参数或JSON请求体）会被限定为密钥所属租户
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw := extractKey(r)
+		if raw == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "缺少API密钥", http.StatusUnauthorized)
+			return
+		}
+
+		key, err := a.store.Authenticate(raw)
+		if err != nil {
+			log.Printf("🔒 API鉴权失败: %s %s, key=%s", r.Method, r.URL.Path, secrets.Redact(raw))
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if required := RequiredRole(r); !key.Role.Allows(required) {
+			http.Error(w, "权限不足，需要 "+string(required)+" 角色", http.StatusForbidden)
+			return
+		}
+
+		if key.RateLimit > 0 {
+			burst := key.Burst
+			if burst <= 0 {
+				burst = int(key.RateLimit) + 1
+			}
+			a.limiter.SetKeyLimit(key.ID, limiter.Limit(key.RateLimit), burst)
+		}
+		if !a.limiter.Allow(key.ID) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "请求过于频繁", http.StatusTooManyRequests)
+			return
+		}
+
+		if key.TenantID != AllTenants {
+			if !scopeQuery(r, key.TenantID) || !scopeBody(r, key.TenantID) {
+				http.Error(w, "无权访问该租户", http.StatusForbidden)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext 获取请求的API密钥
+func FromContext(ctx context.Context) (Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(Key)
+	return key, ok
+}
+
+// CanAccessTenant 请求是否可以访问租户，未经过鉴权中间件的请求不做限制
+func CanAccessTenant(r *http.Request, tenantID string) bool {
+	key, ok := FromContext(r.Context())
+	return !ok || key.CanAccessTenant(tenantID)
+}
+
+// extractKey 读取请求中的API密钥
+func extractKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// scopeQuery 限定查询参数中的租户，未指定时补上密钥所属租户
+func scopeQuery(r *http.Request, tenantID string) bool {
+	q := r.URL.Query()
+	switch q.Get("tenant_id") {
+	case tenantID:
+		return true
+	case "":
+		q.Set("tenant_id", tenantID)
+		r.URL.RawQuery = q.Encode()
+		return true
+	default:
+		return false
+	}
+}
+
+// scopeBody 限定请求体（JSON 或表单）中的租户，未指定时补上密钥所属租户
+func scopeBody(r *http.Request, tenantID string) bool {
+	if r.Body == nil {
+		return true
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch contentType {
+	case "application/json":
+		return scopeJSON(r, tenantID)
+	case "application/x-www-form-urlencoded":
+		return scopeForm(r, tenantID)
+	case "multipart/form-data":
+		return scopeMultipart(r, tenantID)
+	}
+	return true
+}
+
+// scopeJSON 限定JSON请求体中的租户
+func scopeJSON(r *http.Request, tenantID string) bool {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	r.Body.Close()
+	if err != nil {
+		return false
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil && fields != nil {
+		var requested string
+		if raw, ok := fields["tenant_id"]; ok {
+			if json.Unmarshal(raw, &requested) != nil || (requested != "" && requested != tenantID) {
+				return false
+			}
+		}
+		if requested == "" {
+			fields["tenant_id"], _ = json.Marshal(tenantID)
+			data, _ = json.Marshal(fields)
+		}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return true
+}
+
+// scopeForm 限定 URL 编码表单中的租户，处理后 r.FormValue 读到的即为限定后的租户
+func scopeForm(r *http.Request, tenantID string) bool {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	r.Body.Close()
+	if err != nil {
+		return false
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil || !scopeValues(values, tenantID) {
+		return false
+	}
+
+	data = []byte(values.Encode())
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	return true
+}
+
+// scopeMultipart 限定 multipart 表单中的租户；表单在此解析，后续 r.FormValue 直接读取解析结果
+func scopeMultipart(r *http.Request, tenantID string) bool {
+	if err := r.ParseMultipartForm(maxBody); err != nil {
+		return false
+	}
+	if !scopeValues(r.MultipartForm.Value, tenantID) {
+		return false
+	}
+	scopeValues(r.PostForm, tenantID)
+	scopeValues(r.Form, tenantID)
+	return true
+}
+
+// scopeValues 表单中的租户必须是 tenantID，未指定时补上
+func scopeValues(values url.Values, tenantID string) bool {
+	for _, requested := range values["tenant_id"] {
+		if requested != "" && requested != tenantID {
+			return false
+		}
+	}
+	values.Set("tenant_id", tenantID)
+	return true
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScopeBodyJSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(`{"platform":"douyin"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if !scopeBody(r, "tenant-a") {
+		t.Fatal("未指定租户时应放行")
+	}
+	data, _ := io.ReadAll(r.Body)
+	if !strings.Contains(string(data), `"tenant_id":"tenant-a"`) {
+		t.Fatalf("body = %s, 应补上密钥所属租户", data)
+	}
+
+	r = httptest.NewRequest("POST", "/api/v1/sessions", strings.NewReader(`{"tenant_id":"tenant-b"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if scopeBody(r, "tenant-a") {
+		t.Fatal("指定其他租户时应拒绝")
+	}
+}
+
+func TestScopeBodyForm(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/channel/douyin/start", strings.NewReader("open_id=o1&room_id=r1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if !scopeBody(r, "tenant-a") {
+		t.Fatal("未指定租户时应放行")
+	}
+	if got := r.FormValue("tenant_id"); got != "tenant-a" {
+		t.Fatalf("tenant_id = %q, 应补上密钥所属租户", got)
+	}
+	if got := r.FormValue("open_id"); got != "o1" {
+		t.Fatalf("open_id = %q, 其他字段应保留", got)
+	}
+
+	r = httptest.NewRequest("POST", "/api/channel/douyin/start", strings.NewReader("open_id=o1&tenant_id=tenant-a&tenant_id=tenant-b"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if scopeBody(r, "tenant-a") {
+		t.Fatal("表单中包含其他租户时应拒绝")
+	}
+}
+
+func TestScopeBodyMultipart(t *testing.T) {
+	multipartRequest := func(tenantID string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("open_id", "o1")
+		if tenantID != "" {
+			mw.WriteField("tenant_id", tenantID)
+		}
+		mw.Close()
+		r := httptest.NewRequest("POST", "/api/channel/douyin/start", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	r := multipartRequest("")
+	if !scopeBody(r, "tenant-a") {
+		t.Fatal("未指定租户时应放行")
+	}
+	if got := r.FormValue("tenant_id"); got != "tenant-a" {
+		t.Fatalf("tenant_id = %q, 应补上密钥所属租户", got)
+	}
+
+	if scopeBody(multipartRequest("tenant-b"), "tenant-a") {
+		t.Fatal("指定其他租户时应拒绝")
+	}
+}
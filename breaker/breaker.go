@@ -0,0 +1,107 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	StateClosed   = "closed"    // 正常放行
+	StateOpen     = "open"      // 熔断中，拒绝请求
+	StateHalfOpen = "half_open" // 冷却结束，放行一次试探请求
+)
+
+// Config 熔断配置
+type Config struct {
+	Failures int           // 连续失败该次数后熔断，0表示不熔断
+	Cooldown time.Duration // 熔断后经过该时长放行一次试探请求
+}
+
+// DefaultConfig 默认配置：连续失败5次后熔断30秒
+func DefaultConfig() Config {
+	return Config{
+		Failures: 5,
+		Cooldown: 30 * time.Second,
+	}
+}
+
+// Breaker 熔断器：下游连续失败时暂停调用，冷却后放行一次试探请求，
+// 试探成功恢复正常，失败继续熔断
+type Breaker struct {
+	mu       sync.Mutex
+	config   Config
+	state    string
+	failures int       // 连续失败次数
+	openedAt time.Time // 最近一次熔断的时间
+}
+
+// New 创建熔断器
+func New(config Config) *Breaker {
+	return &Breaker{config: config, state: StateClosed}
+}
+
+// SetConfig 更新配置，关闭熔断时立即恢复放行
+func (b *Breaker) SetConfig(config Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+	if config.Failures <= 0 {
+		b.state = StateClosed
+		b.failures = 0
+	}
+}
+
+// Allow 是否放行请求；冷却结束后只放行一次试探请求，结果返回前其余请求仍被拒绝。
+// 放行的请求必须调用 Success 或 Failure 报告结果
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.config.Cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		return true
+	case StateHalfOpen:
+		return false
+	}
+	return true
+}
+
+// Success 报告请求成功，recovered 表示熔断后试探成功、恢复放行
+func (b *Breaker) Success() (recovered bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recovered = b.state != StateClosed
+	b.state = StateClosed
+	b.failures = 0
+	return recovered
+}
+
+// Failure 报告请求失败，opened 表示本次失败触发熔断
+func (b *Breaker) Failure() (opened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.Failures <= 0 || b.state == StateOpen {
+		return false
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.config.Failures {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+		return true
+	}
+	return false
+}
+
+// State 当前状态
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.config.Cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
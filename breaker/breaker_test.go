@@ -0,0 +1,92 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterFailures(t *testing.T) {
+	b := New(Config{Failures: 3, Cooldown: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatal("未达到失败次数前应放行")
+		}
+		if b.Failure() {
+			t.Fatalf("第%d次失败不应熔断", i+1)
+		}
+	}
+	b.Success()
+	for i := 0; i < 2; i++ {
+		b.Failure()
+	}
+	if b.State() != StateClosed {
+		t.Fatal("成功后应重新计算连续失败次数")
+	}
+
+	if !b.Failure() {
+		t.Fatal("连续失败3次应熔断")
+	}
+	if b.State() != StateOpen || b.Allow() {
+		t.Fatal("熔断中应拒绝请求")
+	}
+	if b.Failure() {
+		t.Fatal("熔断中的失败不应重复报告熔断")
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b := New(Config{Failures: 1, Cooldown: 20 * time.Millisecond})
+	b.Failure()
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("冷却结束后应为 half_open，实际 %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("冷却结束后应放行一次试探请求")
+	}
+	if b.Allow() {
+		t.Fatal("试探请求返回前应拒绝其余请求")
+	}
+
+	if !b.Failure() {
+		t.Fatal("试探失败应重新熔断")
+	}
+	if b.Allow() {
+		t.Fatal("试探失败后应继续熔断")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("再次冷却结束后应放行试探请求")
+	}
+	if !b.Success() {
+		t.Fatal("试探成功应报告恢复")
+	}
+	if b.State() != StateClosed || !b.Allow() {
+		t.Fatal("恢复后应正常放行")
+	}
+	if b.Success() {
+		t.Fatal("正常状态下的成功不应报告恢复")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := New(Config{Failures: 2, Cooldown: time.Hour})
+	b.Failure()
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("熔断中应拒绝请求")
+	}
+
+	b.SetConfig(Config{Cooldown: time.Hour})
+	if b.State() != StateClosed || !b.Allow() {
+		t.Fatal("关闭熔断后应立即恢复放行")
+	}
+	for i := 0; i < 10; i++ {
+		if b.Failure() {
+			t.Fatal("Failures 为0时不应熔断")
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package broadcast
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/openapi"
+)
+
+// RegisterRoutes 注册定时消息API
+//
+//	GET /api/v1/broadcasts?tenant_id=   各计划在本副本的运行状态
+func (s *Scheduler) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/broadcasts", Tag: "broadcasts",
+		Summary:  "直播间定时消息计划的运行状态（本副本）",
+		Query:    []openapi.Param{{Name: "tenant_id", Description: "按租户过滤"}},
+		Response: []Status{}, HandlerFunc: s.handleList,
+	})
+}
+
+// handleList 计划状态，租户密钥只能看到本租户的计划
+func (s *Scheduler) handleList(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]Status, 0)
+	for _, status := range s.Statuses(r.URL.Query().Get("tenant_id")) {
+		if auth.CanAccessTenant(r, status.TenantID) {
+			statuses = append(statuses, status)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": statuses})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
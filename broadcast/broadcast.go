@@ -0,0 +1,300 @@
+package broadcast
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"live-im-proxy/bus"
+)
+
+// 计划状态
+const (
+	StateActive   = "active"   // 按计划发送
+	StateOffline  = "offline"  // 直播间未在本副本运行或已断开，暂停发送
+	StateQuiet    = "quiet"    // 直播间一段时间没有互动，暂停发送
+	StateDisabled = "disabled" // 计划未启用
+)
+
+// Schedule 直播间定时公屏消息计划
+type Schedule struct {
+	ID         string
+	TenantID   string
+	Channel    string
+	RoomID     string
+	Interval   time.Duration // 发送间隔，0表示只按 At 发送
+	At         []string      // 每天的发送时间，格式 "20:00"
+	Messages   []string      // 轮流发送的消息文案
+	QuietAfter time.Duration // 直播间超过该时长没有互动时暂停，0表示不检查
+	Enabled    bool
+}
+
+// Config 定时消息配置
+type Config struct {
+	Timezone  string // At 使用的时区，默认 Asia/Shanghai
+	Schedules []Schedule
+}
+
+// Room 可发送公屏消息的直播间
+type Room interface {
+	SendMessage(content string) error
+	IsConnected() bool
+}
+
+// RoomFinder 查找本副本上监听该直播间的渠道
+type RoomFinder func(channelType, roomID string) (Room, bool)
+
+// Status 计划运行状态
+type Status struct {
+	ID          string     `json:"id"`
+	TenantID    string     `json:"tenant_id"`
+	Channel     string     `json:"channel"`
+	RoomID      string     `json:"room_id"`
+	State       string     `json:"state"`
+	Sent        int64      `json:"sent"`
+	NextVariant int        `json:"next_variant"` // 下一条发送的文案序号
+	LastMessage string     `json:"last_message,omitempty"`
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// state 计划运行状态，配置热加载时按ID保留
+type state struct {
+	status   Status
+	since    time.Time       // 计划开始计时的时间，首次按间隔发送以此为起点
+	lastSent time.Time       // 最近一次按间隔发送的时间
+	fired    map[string]bool // 当天已触发的 At 时间，键为 "2006-01-02 15:04"
+}
+
+// Scheduler 直播间定时消息：按间隔或每天固定时间轮流发送文案，
+// 直播间离线或冷场时暂停。每个副本只向自己运行的直播间发送
+type Scheduler struct {
+	mu       sync.Mutex
+	config   Config
+	location *time.Location
+	rooms    RoomFinder
+	states   map[string]*state
+	activity map[string]time.Time // 直播间最近一次互动时间
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewScheduler 创建定时消息调度器
+func NewScheduler(config Config, rooms RoomFinder) *Scheduler {
+	s := &Scheduler{
+		rooms:    rooms,
+		states:   make(map[string]*state),
+		activity: make(map[string]time.Time),
+		done:     make(chan struct{}),
+	}
+	s.SetConfig(config)
+	go s.loop()
+	return s
+}
+
+// SetConfig 更新配置，已有计划的发送进度和统计保留
+func (s *Scheduler) SetConfig(config Config) {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil || config.Timezone == "" {
+		location = time.FixedZone("CST", 8*3600)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.location = location
+
+	now := time.Now()
+	next := make(map[string]*state, len(config.Schedules))
+	for _, schedule := range config.Schedules {
+		st, ok := s.states[schedule.ID]
+		if !ok {
+			st = &state{since: now, fired: make(map[string]bool)}
+		}
+		st.status.ID = schedule.ID
+		st.status.TenantID = schedule.TenantID
+		st.status.Channel = schedule.Channel
+		st.status.RoomID = schedule.RoomID
+		if len(schedule.Messages) > 0 {
+			st.status.NextVariant %= len(schedule.Messages)
+		}
+		if !schedule.Enabled {
+			st.status.State = StateDisabled
+		} else if st.status.State == StateDisabled {
+			st.status.State = ""
+		}
+		next[schedule.ID] = st
+	}
+	s.states = next
+}
+
+// Close 停止调度
+func (s *Scheduler) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// Subscribe 订阅事件总线，记录直播间最近一次互动时间
+func (s *Scheduler) Subscribe(b *bus.Bus) *bus.Subscription {
+	return b.Subscribe("broadcast", 0, s.handle, bus.TopicEventReceived)
+}
+
+func (s *Scheduler) handle(msg *bus.Message) {
+	evt := msg.Event
+	if evt == nil || evt.RoomID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activity[evt.Channel+"/"+evt.RoomID] = time.Now()
+}
+
+func (s *Scheduler) loop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+// send 一条待发送的消息
+type send struct {
+	schedule Schedule
+	room     Room
+	content  string
+	state    *state
+}
+
+// tick 检查各计划是否到期，到期且直播间活跃时发送下一条文案
+func (s *Scheduler) tick(now time.Time) {
+	var sends []send
+
+	s.mu.Lock()
+	local := now.In(s.location)
+	today := local.Format("2006-01-02")
+	clock := local.Format("15:04")
+	for _, schedule := range s.config.Schedules {
+		st := s.states[schedule.ID]
+		if st == nil || len(schedule.Messages) == 0 {
+			continue
+		}
+
+		due := schedule.Interval > 0 && now.Sub(latest(st.since, st.lastSent)) >= schedule.Interval
+		for _, at := range schedule.At {
+			key := today + " " + at
+			if clock >= at && !st.fired[key] {
+				// 当天错过的时间（如启动晚于该时间超过1分钟）不补发
+				st.fired[key] = true
+				if clock == at {
+					due = true
+				}
+			}
+		}
+		for key := range st.fired {
+			if key[:10] != today {
+				delete(st.fired, key)
+			}
+		}
+
+		room, current := s.roomState(schedule, now)
+		st.status.State = current
+		if !due {
+			continue
+		}
+		if schedule.Interval > 0 {
+			st.lastSent = now
+		}
+		if current != StateActive {
+			continue
+		}
+		content := schedule.Messages[st.status.NextVariant%len(schedule.Messages)]
+		st.status.NextVariant = (st.status.NextVariant + 1) % len(schedule.Messages)
+		sends = append(sends, send{schedule: schedule, room: room, content: content, state: st})
+	}
+	s.mu.Unlock()
+
+	for _, m := range sends {
+		err := m.room.SendMessage(m.content)
+
+		s.mu.Lock()
+		at := time.Now()
+		if err != nil {
+			m.state.status.LastError = err.Error()
+			m.state.status.LastErrorAt = &at
+		} else {
+			m.state.status.Sent++
+			m.state.status.LastMessage = m.content
+			m.state.status.LastSentAt = &at
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			log.Printf("❌ 定时消息发送失败: id=%s, room=%s, err=%v", m.schedule.ID, m.schedule.RoomID, err)
+		} else {
+			log.Printf("📣 定时消息: id=%s, room=%s, 内容=%s", m.schedule.ID, m.schedule.RoomID, m.content)
+		}
+	}
+}
+
+// roomState 直播间当前是否可发送，需在锁内调用
+func (s *Scheduler) roomState(schedule Schedule, now time.Time) (Room, string) {
+	if !schedule.Enabled {
+		return nil, StateDisabled
+	}
+	room, ok := s.rooms(schedule.Channel, schedule.RoomID)
+	if !ok || !room.IsConnected() {
+		return nil, StateOffline
+	}
+	if schedule.QuietAfter > 0 {
+		last, seen := s.activity[schedule.Channel+"/"+schedule.RoomID]
+		if !seen || now.Sub(last) >= schedule.QuietAfter {
+			return room, StateQuiet
+		}
+	}
+	return room, StateActive
+}
+
+// Statuses 各计划的运行状态，tenantID 为空时返回全部
+func (s *Scheduler) Statuses(tenantID string) []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Status, 0, len(s.states))
+	for _, st := range s.states {
+		if tenantID != "" && st.status.TenantID != tenantID {
+			continue
+		}
+		status := st.status
+		if status.State == "" {
+			status.State = StateOffline
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].ID < result[b].ID })
+	return result
+}
+
+// ParseClock 校验 "HH:MM" 格式的发送时间
+func ParseClock(value string) error {
+	if _, err := time.Parse("15:04", value); err != nil || len(value) != 5 {
+		return fmt.Errorf("时间格式应为 HH:MM: %q", value)
+	}
+	return nil
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,54 @@
+package broadcast
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRoom 记录发送内容，err 不为空时发送失败
+type fakeRoom struct {
+	sent []string
+	err  error
+}
+
+func (r *fakeRoom) SendMessage(content string) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent = append(r.sent, content)
+	return nil
+}
+
+func (r *fakeRoom) IsConnected() bool { return true }
+
+func TestTickRotatesMessages(t *testing.T) {
+	room := &fakeRoom{}
+	s := NewScheduler(Config{Schedules: []Schedule{{
+		ID:       "s1",
+		Channel:  "douyin",
+		RoomID:   "room",
+		Interval: time.Minute,
+		Messages: []string{"第一条", "第二条"},
+		Enabled:  true,
+	}}}, func(channelType, roomID string) (Room, bool) { return room, true })
+	defer s.Close()
+
+	now := time.Now()
+	s.tick(now.Add(30 * time.Second))
+	if len(room.sent) != 0 {
+		t.Fatalf("未到发送间隔不应发送: %v", room.sent)
+	}
+	s.tick(now.Add(time.Minute))
+	s.tick(now.Add(2 * time.Minute))
+	if len(room.sent) != 2 || room.sent[0] != "第一条" || room.sent[1] != "第二条" {
+		t.Fatalf("sent = %v, 应按间隔轮流发送文案", room.sent)
+	}
+
+	room.err = errors.New("发送回复失败")
+	s.tick(now.Add(3 * time.Minute))
+	status := s.Statuses("")[0]
+	if status.Sent != 2 || status.LastError == "" || status.LastMessage != "第二条" {
+		t.Fatalf("status = %+v, 发送失败应记录错误且不计入已发送", status)
+	}
+}
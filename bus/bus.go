@@ -0,0 +1,292 @@
+package bus
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/reply"
+)
+
+// 事件主题
+const (
+	TopicEventReceived  = "event.received"  // 渠道收到事件
+	TopicReplyGenerated = "reply.generated" // 已生成回复
+	TopicReplyFiltered  = "reply.filtered"  // 回复被内容安全改写/拦截/标记
+	TopicReplySent      = "reply.sent"      // 回复发送成功
+	TopicReplyFailed    = "reply.failed"    // 回复发送失败
+	TopicLeadPushed     = "lead.pushed"     // 线索已推送到CRM
+
+	// TopicAll 订阅全部主题
+	TopicAll = "*"
+)
+
+// DefaultBuffer 订阅者默认缓冲大小
+const DefaultBuffer = 256
+
+// Message 总线消息
+type Message struct {
+	Topic     string       `json:"topic"`
+	Event     *event.Event `json:"event,omitempty"`
+	Reply     *reply.Reply `json:"reply,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Data      interface{}  `json:"data,omitempty"` // 主题相关的附加数据
+	Timestamp int64        `json:"timestamp"`
+}
+
+// Handler 消息处理函数
+type Handler func(msg *Message)
+
+// Subscription 订阅
+type Subscription struct {
+	bus       *Bus
+	name      string
+	topics    []string
+	ch        chan *Message
+	handler   Handler
+	reliable  bool // 缓冲写满时发布方等待，不丢弃消息
+	done      chan struct{}
+	stopped   chan struct{} // 消费goroutine已退出
+	once      sync.Once
+	delivered int64
+	dropped   int64
+	blocked   int64
+}
+
+// SubscriptionStats 订阅统计
+type SubscriptionStats struct {
+	Name      string   `json:"name"`
+	Topics    []string `json:"topics"`
+	Pending   int      `json:"pending"`
+	Reliable  bool     `json:"reliable"`
+	Delivered int64    `json:"delivered"`
+	Dropped   int64    `json:"dropped"`
+	Blocked   int64    `json:"blocked"` // 可靠订阅者缓冲写满、发布方等待的次数
+}
+
+// Bus 进程内事件总线
+// 每个订阅者有独立缓冲。普通订阅者缓冲写满时丢弃该订阅者的新消息并计数，
+// 慢订阅者不会拖慢管道和其他订阅者；可靠订阅者（CRM、会话记录、审计）缓冲写满时
+// 发布方等待，不丢消息，关闭总线时先处理完缓冲中的消息。
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []*Subscription
+	closed bool
+}
+
+// New 创建事件总线
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe 订阅主题，handler在独立goroutine中按顺序执行
+// buffer<=0时使用DefaultBuffer，topics为空时订阅全部主题
+func (b *Bus) Subscribe(name string, buffer int, handler Handler, topics ...string) *Subscription {
+	return b.subscribe(name, buffer, handler, false, topics)
+}
+
+// SubscribeReliable 订阅主题，缓冲写满时发布方等待而不丢弃消息，用于不能丢数据的订阅者；
+// 订阅者持续过慢时会拖慢发布方
+func (b *Bus) SubscribeReliable(name string, buffer int, handler Handler, topics ...string) *Subscription {
+	return b.subscribe(name, buffer, handler, true, topics)
+}
+
+// subscribe 创建订阅并启动消费goroutine
+func (b *Bus) subscribe(name string, buffer int, handler Handler, reliable bool, topics []string) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	if len(topics) == 0 {
+		topics = []string{TopicAll}
+	}
+
+	sub := &Subscription{
+		bus:      b,
+		name:     name,
+		topics:   topics,
+		ch:       make(chan *Message, buffer),
+		handler:  handler,
+		reliable: reliable,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(sub.done)
+		close(sub.stopped)
+		return sub
+	}
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	go sub.run()
+	log.Printf("🔔 事件总线订阅: %s -> %v", name, topics)
+	return sub
+}
+
+// Publish 发布消息
+func (b *Bus) Publish(msg *Message) {
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+
+	// 可靠订阅者可能让发布方等待，不在锁内投递，避免阻塞订阅和关闭
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return
+	}
+	subs := append([]*Subscription(nil), b.subs...)
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		if !sub.matches(msg.Topic) {
+			continue
+		}
+		if sub.reliable {
+			sub.deliver(msg)
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		case <-sub.done:
+		default:
+			// 慢订阅者：丢弃消息，每100条提示一次
+			if n := atomic.AddInt64(&sub.dropped, 1); n%100 == 1 {
+				log.Printf("⚠️ 订阅者 %s 消费过慢，已丢弃 %d 条消息", sub.name, n)
+			}
+		}
+	}
+}
+
+// Stats 获取所有订阅者的统计
+func (b *Bus) Stats() []SubscriptionStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := make([]SubscriptionStats, 0, len(b.subs))
+	for _, sub := range b.subs {
+		stats = append(stats, SubscriptionStats{
+			Name:      sub.name,
+			Topics:    sub.topics,
+			Pending:   len(sub.ch),
+			Reliable:  sub.reliable,
+			Delivered: atomic.LoadInt64(&sub.delivered),
+			Dropped:   atomic.LoadInt64(&sub.dropped),
+			Blocked:   atomic.LoadInt64(&sub.blocked),
+		})
+	}
+	return stats
+}
+
+// Close 关闭总线，停止所有订阅者，等待可靠订阅者处理完缓冲中的消息
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.stop()
+	}
+	for _, sub := range subs {
+		<-sub.stopped
+	}
+}
+
+// Unsubscribe 取消订阅
+func (s *Subscription) Unsubscribe() {
+	b := s.bus
+	b.mu.Lock()
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	s.stop()
+}
+
+// deliver 投递给可靠订阅者，缓冲写满时等待；订阅已停止时丢弃并计数
+func (s *Subscription) deliver(msg *Message) {
+	select {
+	case s.ch <- msg:
+		return
+	default:
+	}
+	if n := atomic.AddInt64(&s.blocked, 1); n%100 == 1 {
+		log.Printf("⚠️ 订阅者 %s 消费过慢，发布方等待中（累计 %d 次）", s.name, n)
+	}
+	select {
+	case s.ch <- msg:
+	case <-s.done:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// stop 停止订阅者goroutine
+func (s *Subscription) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+// matches 判断是否订阅了该主题
+func (s *Subscription) matches(topic string) bool {
+	for _, t := range s.topics {
+		if t == TopicAll || t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// run 消费消息，可靠订阅者停止前处理完缓冲中的消息
+func (s *Subscription) run() {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.done:
+			if s.reliable {
+				s.drain()
+			}
+			return
+		case msg := <-s.ch:
+			s.handle(msg)
+		}
+	}
+}
+
+// drain 处理缓冲中剩余的消息
+func (s *Subscription) drain() {
+	for {
+		select {
+		case msg := <-s.ch:
+			s.handle(msg)
+		default:
+			return
+		}
+	}
+}
+
+// handle 执行handler，避免单个订阅者panic影响总线
+func (s *Subscription) handle(msg *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ 订阅者 %s 处理 %s 时panic: %v", s.name, msg.Topic, r)
+		}
+	}()
+	s.handler(msg)
+	atomic.AddInt64(&s.delivered, 1)
+}
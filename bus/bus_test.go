@@ -0,0 +1,189 @@
+package bus
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder 记录订阅者收到的消息主题
+type recorder struct {
+	mu     sync.Mutex
+	topics []string
+}
+
+func (r *recorder) handle(msg *Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topics = append(r.topics, msg.Topic)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.topics...)
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTopicMatchingAndFanOut(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	all, replies, second := &recorder{}, &recorder{}, &recorder{}
+	b.Subscribe("all", 0, all.handle)
+	b.Subscribe("replies", 0, replies.handle, TopicReplySent)
+	b.Subscribe("second", 0, second.handle, TopicReplySent)
+
+	b.Publish(&Message{Topic: TopicEventReceived})
+	b.Publish(&Message{Topic: TopicReplySent})
+
+	waitFor(t, func() bool { return len(all.get()) == 2 && len(replies.get()) == 1 && len(second.get()) == 1 })
+	if got := replies.get(); got[0] != TopicReplySent {
+		t.Fatalf("订阅者收到未订阅的主题: %v", got)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := len(replies.get()); n != 1 {
+		t.Fatalf("订阅者收到 %d 条消息，期望 1", n)
+	}
+}
+
+// blockingHandler 在 release 关闭前阻塞，started 通知已开始处理第一条消息
+func blockingHandler(r *recorder, started chan struct{}, release chan struct{}) Handler {
+	var once sync.Once
+	return func(msg *Message) {
+		once.Do(func() { close(started) })
+		<-release
+		r.handle(msg)
+	}
+}
+
+func TestSlowSubscriberDropsWhenBufferFull(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	r := &recorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	b.Subscribe("slow", 1, blockingHandler(r, started, release))
+
+	b.Publish(&Message{Topic: TopicEventReceived})
+	<-started
+	for i := 0; i < 4; i++ {
+		b.Publish(&Message{Topic: TopicEventReceived})
+	}
+	close(release)
+
+	waitFor(t, func() bool { return len(r.get()) == 2 })
+	stats := b.Stats()
+	if len(stats) != 1 || stats[0].Name != "slow" || stats[0].Dropped != 3 {
+		t.Fatalf("应丢弃3条消息: %+v", stats)
+	}
+}
+
+func TestReliableSubscriberWaitsInsteadOfDropping(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	r := &recorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	b.SubscribeReliable("crm", 1, blockingHandler(r, started, release))
+
+	b.Publish(&Message{Topic: TopicEventReceived})
+	<-started
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 4; i++ {
+			b.Publish(&Message{Topic: TopicEventReceived})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("可靠订阅者缓冲写满时发布方应等待")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-published
+
+	waitFor(t, func() bool { return len(r.get()) == 5 })
+	stats := b.Stats()
+	if stats[0].Dropped != 0 || !stats[0].Reliable || stats[0].Blocked == 0 {
+		t.Fatalf("可靠订阅者不应丢消息: %+v", stats[0])
+	}
+}
+
+func TestCloseDrainsReliableSubscribers(t *testing.T) {
+	b := New()
+
+	r := &recorder{}
+	started, release := make(chan struct{}), make(chan struct{})
+	b.SubscribeReliable("history", 8, blockingHandler(r, started, release))
+
+	for i := 0; i < 3; i++ {
+		b.Publish(&Message{Topic: TopicEventReceived})
+	}
+	<-started
+
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+	close(release)
+	<-closed
+
+	if n := len(r.get()); n != 3 {
+		t.Fatalf("关闭前应处理完缓冲中的消息，实际处理 %d 条", n)
+	}
+
+	b.Publish(&Message{Topic: TopicEventReceived})
+	if sub := b.Subscribe("late", 0, r.handle); sub == nil {
+		t.Fatal("关闭后订阅应返回已停止的订阅")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := len(r.get()); n != 3 {
+		t.Fatalf("关闭后不应再投递消息，实际处理 %d 条", n)
+	}
+	if len(b.Stats()) != 0 {
+		t.Fatal("关闭后不应保留订阅者")
+	}
+}
+
+func TestUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	b := New()
+	defer b.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	sub := b.SubscribeReliable("audit", 1, blockingHandler(&recorder{}, started, release))
+
+	b.Publish(&Message{Topic: TopicEventReceived})
+	<-started
+	b.Publish(&Message{Topic: TopicEventReceived})
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(&Message{Topic: TopicEventReceived})
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Unsubscribe()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("取消订阅后发布方不应继续等待")
+	}
+}
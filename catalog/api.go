@@ -0,0 +1,238 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/openapi"
+)
+
+// maxImport 导入请求体上限
+const maxImport = 4 << 20
+
+// Service 商品目录管理API
+type Service struct {
+	store         *Store
+	defaultTenant string
+}
+
+// NewService 创建商品目录管理服务
+func NewService(store *Store, defaultTenant string) *Service {
+	return &Service{store: store, defaultTenant: defaultTenant}
+}
+
+// ImportRequest JSON格式的批量导入请求
+type ImportRequest struct {
+	TenantID string    `json:"tenant_id,omitempty" doc:"未指定时使用默认租户"`
+	Replace  bool      `json:"replace,omitempty" doc:"删除租户下未出现在本次导入中的商品"`
+	Products []Product `json:"products"`
+}
+
+// RegisterRoutes 注册商品目录API
+//
+//	GET    /api/v1/products           商品列表 ?tenant_id=
+//	POST   /api/v1/products           新建商品
+//	POST   /api/v1/products/import    批量导入，JSON 或 CSV（Content-Type: text/csv，?tenant_id=&replace=true）
+//	GET    /api/v1/products/{id}      商品详情
+//	PUT    /api/v1/products/{id}      修改商品
+//	DELETE /api/v1/products/{id}      删除商品
+func (s *Service) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/products", Tag: "products",
+		Summary:  "商品列表",
+		Query:    []openapi.Param{{Name: "tenant_id", Description: "按租户过滤"}},
+		Response: []Product{}, HandlerFunc: s.handleList,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/products", Tag: "products",
+		Summary: "新建商品", Request: Product{}, Response: Product{},
+		Status: http.StatusCreated, HandlerFunc: s.handleCreate,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/products/import", Tag: "products",
+		Summary: "批量导入商品，按ID或同名商品更新；也支持 text/csv 请求体（表头 name,aliases,price,original_price,stock,promotions,faq）",
+		Query: []openapi.Param{
+			{Name: "tenant_id", Description: "CSV导入的租户"},
+			{Name: "replace", Description: "CSV导入时为 true 表示删除未出现的商品"},
+		},
+		Request: ImportRequest{}, Response: ImportResult{}, HandlerFunc: s.handleImport,
+	})
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/products/{id}", Tag: "products",
+		Summary: "商品详情", Response: Product{}, HandlerFunc: s.handleGet,
+	})
+	router.Handle(openapi.Route{
+		Method: "PUT", Path: "/api/v1/products/{id}", Tag: "products",
+		Summary: "修改商品（整体覆盖）", Request: Product{}, Response: Product{}, HandlerFunc: s.handleUpdate,
+	})
+	router.Handle(openapi.Route{
+		Method: "DELETE", Path: "/api/v1/products/{id}", Tag: "products",
+		Summary: "删除商品", HandlerFunc: s.handleDelete,
+	})
+}
+
+// tenant 请求中的租户，未指定时使用默认租户
+func (s *Service) tenant(tenantID string) string {
+	if tenantID == "" {
+		return s.defaultTenant
+	}
+	return tenantID
+}
+
+// lookup 读取路径中的商品ID，不存在或无权访问时返回404
+func (s *Service) lookup(w http.ResponseWriter, r *http.Request) (Product, bool) {
+	product, ok := s.store.Get(openapi.PathParam(r, "id"))
+	if !ok || !auth.CanAccessTenant(r, product.TenantID) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return Product{}, false
+	}
+	return product, true
+}
+
+// handleList 商品列表，租户密钥只能看到本租户的商品
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	products := make([]Product, 0)
+	for _, product := range s.store.List(r.URL.Query().Get("tenant_id")) {
+		if auth.CanAccessTenant(r, product.TenantID) {
+			products = append(products, product)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": products})
+}
+
+// handleCreate 新建商品
+func (s *Service) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var product Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	product.TenantID = s.tenant(product.TenantID)
+	if !auth.CanAccessTenant(r, product.TenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+	if err := product.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.store.Create(product)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("🛒 商品已创建: tenant=%s, name=%s", created.TenantID, created.Name)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"success": true, "data": created})
+}
+
+// handleImport 批量导入商品
+func (s *Service) handleImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImport)
+
+	var req ImportRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		products, err := ParseCSV(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		req = ImportRequest{TenantID: q.Get("tenant_id"), Replace: q.Get("replace") == "true", Products: products}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := s.tenant(req.TenantID)
+	if !auth.CanAccessTenant(r, tenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+	if len(req.Products) == 0 && !req.Replace {
+		http.Error(w, "没有要导入的商品", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Products {
+		if err := req.Products[i].Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("第%d个商品: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := s.store.Import(tenantID, req.Products, req.Replace)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("🛒 商品已导入: tenant=%s, 新建=%d, 更新=%d, 删除=%d", tenantID, result.Created, result.Updated, result.Deleted)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": result})
+}
+
+// handleGet 商品详情
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	product, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": product})
+}
+
+// handleUpdate 修改商品
+func (s *Service) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	existing, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	var product Product
+	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := product.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.store.Update(existing.ID, product)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("🛒 商品已修改: tenant=%s, name=%s", updated.TenantID, updated.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": updated})
+}
+
+// handleDelete 删除商品
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	product, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := s.store.Delete(product.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("🗑️ 商品已删除: tenant=%s, name=%s", product.TenantID, product.Name)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// writeError 存储错误转换为HTTP状态码
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,392 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/ids"
+)
+
+// ErrNotFound 商品不存在
+var ErrNotFound = errors.New("商品不存在")
+
+// FAQ 商品常见问题，评论包含问题或任一关键词时直接用答案回复
+type FAQ struct {
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Keywords []string `json:"keywords,omitempty" doc:"命中任一关键词即视为该问题"`
+}
+
+// Product 商品信息
+type Product struct {
+	ID            string    `json:"id"`
+	TenantID      string    `json:"tenant_id"`
+	Name          string    `json:"name"`
+	Aliases       []string  `json:"aliases,omitempty" doc:"评论中的其他叫法，如简称、款式"`
+	Price         float64   `json:"price,omitempty" doc:"现价（元）"`
+	OriginalPrice float64   `json:"original_price,omitempty" doc:"原价（元），高于现价时展示"`
+	Stock         *int64    `json:"stock,omitempty" doc:"库存，未设置表示不展示库存"`
+	Promotions    []string  `json:"promotions,omitempty"`
+	FAQ           []FAQ     `json:"faq,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Validate 校验商品必填字段
+func (p *Product) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("缺少参数: name")
+	}
+	if p.Price < 0 || p.OriginalPrice < 0 {
+		return fmt.Errorf("价格不能为负数")
+	}
+	if p.Stock != nil && *p.Stock < 0 {
+		return fmt.Errorf("库存不能为负数")
+	}
+	for i, faq := range p.FAQ {
+		if strings.TrimSpace(faq.Question) == "" || strings.TrimSpace(faq.Answer) == "" {
+			return fmt.Errorf("faq[%d] 问题和答案不能为空", i)
+		}
+	}
+	return nil
+}
+
+// terms 用于识别商品的名称和别名
+func (p *Product) terms() []string {
+	terms := make([]string, 0, len(p.Aliases)+1)
+	for _, term := range append([]string{p.Name}, p.Aliases...) {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Store 按租户保存商品目录，设置了路径时以JSON文件持久化
+type Store struct {
+	path     string
+	products map[string]*Product
+	onChange func(tenantID string)
+	mu       sync.RWMutex
+}
+
+// NewStore 创建商品目录，path 为空时只保存在内存
+func NewStore(path string) (*Store, error) {
+	s := &Store{
+		path:     path,
+		products: make(map[string]*Product),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取商品目录失败: %v", err)
+	}
+
+	var products []*Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("解析商品目录失败: %v", err)
+	}
+	for _, product := range products {
+		s.products[product.ID] = product
+	}
+	return s, nil
+}
+
+// SetOnChange 设置商品目录变更回调，参数为变更的租户；回调在持有锁时同步调用，不能再访问商品目录
+func (s *Store) SetOnChange(fn func(tenantID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// changed 通知商品目录变更，需在写锁内调用
+func (s *Store) changed(tenantID string) {
+	if s.onChange != nil {
+		s.onChange(tenantID)
+	}
+}
+
+// List 租户的商品列表，按名称排序；tenantID 为空时返回全部
+func (s *Store) List(tenantID string) []Product {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Product, 0, len(s.products))
+	for _, product := range s.products {
+		if tenantID == "" || product.TenantID == tenantID {
+			result = append(result, *product)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TenantID != result[j].TenantID {
+			return result[i].TenantID < result[j].TenantID
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Get 按ID查询商品
+func (s *Store) Get(id string) (Product, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	product, ok := s.products[id]
+	if !ok {
+		return Product{}, false
+	}
+	return *product, true
+}
+
+// Create 新建商品
+func (s *Store) Create(product Product) (Product, error) {
+	if err := product.Validate(); err != nil {
+		return Product{}, err
+	}
+	product.ID = ids.New()
+	product.UpdatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.products[product.ID] = &product
+	s.changed(product.TenantID)
+	return product, s.save()
+}
+
+// Update 覆盖已有商品，ID和租户不可修改
+func (s *Store) Update(id string, product Product) (Product, error) {
+	if err := product.Validate(); err != nil {
+		return Product{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.products[id]
+	if !ok {
+		return Product{}, ErrNotFound
+	}
+	product.ID = existing.ID
+	product.TenantID = existing.TenantID
+	product.UpdatedAt = time.Now()
+	s.products[id] = &product
+	s.changed(product.TenantID)
+	return product, s.save()
+}
+
+// Delete 删除商品
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	product, ok := s.products[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.products, id)
+	s.changed(product.TenantID)
+	return s.save()
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// Import 批量导入租户商品：按ID或同名商品更新，其余新建；
+// replace 为 true 时删除租户下未出现在导入数据中的商品
+func (s *Store) Import(tenantID string, products []Product, replace bool) (ImportResult, error) {
+	for i := range products {
+		if err := products[i].Validate(); err != nil {
+			return ImportResult{}, fmt.Errorf("第%d个商品: %v", i+1, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byName := make(map[string]*Product)
+	for _, product := range s.products {
+		if product.TenantID == tenantID {
+			byName[product.Name] = product
+		}
+	}
+
+	var result ImportResult
+	now := time.Now()
+	kept := make(map[string]bool, len(products))
+	for i := range products {
+		product := products[i]
+		existing, ok := s.products[product.ID]
+		if !ok || existing.TenantID != tenantID {
+			existing, ok = byName[product.Name]
+		}
+		if ok {
+			product.ID = existing.ID
+			result.Updated++
+		} else {
+			product.ID = ids.New()
+			result.Created++
+		}
+		product.TenantID = tenantID
+		product.UpdatedAt = now
+		s.products[product.ID] = &product
+		byName[product.Name] = &product
+		kept[product.ID] = true
+	}
+
+	if replace {
+		for id, product := range s.products {
+			if product.TenantID == tenantID && !kept[id] {
+				delete(s.products, id)
+				result.Deleted++
+			}
+		}
+	}
+	s.changed(tenantID)
+	return result, s.save()
+}
+
+// Match 识别评论提到的商品：取评论中出现的最长名称或别名；
+// 没有提到任何商品且租户只有一个商品时（单品直播间）视为该商品
+func (s *Store) Match(tenantID, content string) (Product, bool) {
+	content = strings.ToLower(content)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var best, only *Product
+	bestLen, count := 0, 0
+	for _, product := range s.products {
+		if product.TenantID != tenantID {
+			continue
+		}
+		count++
+		only = product
+		for _, term := range product.terms() {
+			if len(term) > bestLen && strings.Contains(content, term) {
+				best, bestLen = product, len(term)
+			}
+		}
+	}
+	switch {
+	case best != nil:
+		return *best, true
+	case count == 1:
+		return *only, true
+	}
+	return Product{}, false
+}
+
+// Answer 商品FAQ中与评论匹配的答案
+func (p *Product) Answer(content string) (string, bool) {
+	content = strings.ToLower(content)
+	for _, faq := range p.FAQ {
+		if strings.Contains(content, strings.ToLower(faq.Question)) {
+			return faq.Answer, true
+		}
+		for _, keyword := range faq.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(content, keyword) {
+				return faq.Answer, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Facts 商品事实描述，作为AI回复的上下文
+func (p *Product) Facts() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "商品：%s\n", p.Name)
+	if p.Price > 0 {
+		fmt.Fprintf(&b, "价格：%s", p.PriceText())
+		if p.OriginalPrice > p.Price {
+			fmt.Fprintf(&b, "（原价%s）", formatPrice(p.OriginalPrice))
+		}
+		b.WriteString("\n")
+	}
+	if p.Stock != nil {
+		fmt.Fprintf(&b, "库存：%s\n", p.StockText())
+	}
+	if len(p.Promotions) > 0 {
+		fmt.Fprintf(&b, "优惠：%s\n", strings.Join(p.Promotions, "；"))
+	}
+	for _, faq := range p.FAQ {
+		fmt.Fprintf(&b, "问：%s 答：%s\n", faq.Question, faq.Answer)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// PriceText 现价文案，如 "¥99"
+func (p *Product) PriceText() string {
+	return formatPrice(p.Price)
+}
+
+// StockText 库存文案
+func (p *Product) StockText() string {
+	switch {
+	case p.Stock == nil:
+		return ""
+	case *p.Stock == 0:
+		return "已售罄"
+	case *p.Stock <= lowStock:
+		return fmt.Sprintf("仅剩%d件", *p.Stock)
+	}
+	return "现货充足"
+}
+
+// lowStock 库存不超过该值时提示剩余件数
+const lowStock = 20
+
+// Render 替换模板中的商品占位符：{name} {price} {original_price} {stock} {promotions}
+func (p *Product) Render(template string) string {
+	return strings.NewReplacer(
+		"{name}", p.Name,
+		"{price}", p.PriceText(),
+		"{original_price}", formatPrice(p.OriginalPrice),
+		"{stock}", p.StockText(),
+		"{promotions}", strings.Join(p.Promotions, "，"),
+	).Replace(template)
+}
+
+func formatPrice(price float64) string {
+	return "¥" + strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// save 持久化到文件，需在写锁内调用
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	products := make([]*Product, 0, len(s.products))
+	for _, product := range s.products {
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+
+	data, err := json.MarshalIndent(products, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建商品目录存储目录失败: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入商品目录失败: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,162 @@
+package catalog
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := "\ufeffName,aliases,price,original_price,stock,promotions,faq\n" +
+		"保温杯,杯子|水杯,¥99,129,5,满199减20|送杯套,能装开水吗=可以，耐热100度|保温多久=12小时\n" +
+		",空行跳过,,,,,\n" +
+		"帆布包,,59,,,,\n"
+
+	products, err := ParseCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("应解析出2个商品，实际 %d", len(products))
+	}
+	cup := products[0]
+	if cup.Name != "保温杯" || cup.Price != 99 || cup.OriginalPrice != 129 || len(cup.Aliases) != 2 || len(cup.Promotions) != 2 {
+		t.Fatalf("商品字段解析错误: %+v", cup)
+	}
+	if cup.Stock == nil || *cup.Stock != 5 {
+		t.Fatalf("stock = %v, 应为5", cup.Stock)
+	}
+	if len(cup.FAQ) != 2 || cup.FAQ[1].Question != "保温多久" || cup.FAQ[1].Answer != "12小时" {
+		t.Fatalf("faq 解析错误: %+v", cup.FAQ)
+	}
+	if products[1].Stock != nil {
+		t.Fatal("stock 留空时不应展示库存")
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	cases := map[string]string{
+		"title,price\n杯子,99\n": "name 列",
+		"name,price\n杯子,九十九\n": "第2行 price",
+		"name,stock\n杯子,很多\n":  "第2行 stock",
+		"name,faq\n杯子,能装开水吗\n": "第2行 faq",
+		"":                     "缺少表头",
+	}
+	for data, want := range cases {
+		if _, err := ParseCSV(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCSV(%q) err = %v, 应包含 %q", data, err, want)
+		}
+	}
+}
+
+func TestImportUpdatesByNameAndReplaces(t *testing.T) {
+	s, err := NewStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed []string
+	s.SetOnChange(func(tenantID string) { changed = append(changed, tenantID) })
+
+	cup, err := s.Create(Product{TenantID: "tenant-a", Name: "保温杯", Price: 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(Product{TenantID: "tenant-a", Name: "旧款", Price: 10})
+	s.Create(Product{TenantID: "tenant-b", Name: "保温杯", Price: 88})
+
+	result, err := s.Import("tenant-a", []Product{{Name: "保温杯", Price: 89}, {Name: "帆布包", Price: 59}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 1 || result.Updated != 1 || result.Deleted != 1 {
+		t.Fatalf("导入结果错误: %+v", result)
+	}
+	if updated, ok := s.Get(cup.ID); !ok || updated.Price != 89 {
+		t.Fatalf("同名商品应按原ID更新: %+v", updated)
+	}
+	if list := s.List("tenant-b"); len(list) != 1 || list[0].Price != 88 {
+		t.Fatalf("导入不应影响其他租户: %+v", list)
+	}
+	if len(changed) != 4 || changed[3] != "tenant-a" {
+		t.Fatalf("变更回调应按租户通知: %v", changed)
+	}
+
+	if _, err := s.Import("tenant-a", []Product{{Name: ""}}, false); err == nil {
+		t.Fatal("缺少名称的商品应导入失败")
+	}
+}
+
+func TestMatchAndAnswer(t *testing.T) {
+	s, _ := NewStore("")
+	s.Create(Product{TenantID: "tenant", Name: "保温杯", Aliases: []string{"杯子"}, FAQ: []FAQ{{Question: "能装开水吗", Answer: "可以", Keywords: []string{"开水"}}}})
+	s.Create(Product{TenantID: "tenant", Name: "保温杯套装", Price: 129})
+
+	if p, ok := s.Match("tenant", "保温杯套装多少钱"); !ok || p.Name != "保温杯套装" {
+		t.Fatalf("应匹配最长的商品名称: %+v", p)
+	}
+	p, ok := s.Match("tenant", "这个杯子装开水行吗")
+	if !ok || p.Name != "保温杯" {
+		t.Fatalf("应按别名匹配: %+v", p)
+	}
+	if answer, ok := p.Answer("这个杯子装开水行吗"); !ok || answer != "可以" {
+		t.Fatalf("应按关键词命中FAQ: %q", answer)
+	}
+	if _, ok := s.Match("tenant", "主播好"); ok {
+		t.Fatal("多个商品时没有提到商品不应匹配")
+	}
+
+	s.Create(Product{TenantID: "single", Name: "帆布包"})
+	if p, ok := s.Match("single", "主播好"); !ok || p.Name != "帆布包" {
+		t.Fatal("单品直播间应视为唯一的商品")
+	}
+}
+
+func TestStockTextAndRender(t *testing.T) {
+	stock := func(n int64) *int64 { return &n }
+	cases := []struct {
+		stock *int64
+		want  string
+	}{
+		{nil, ""},
+		{stock(0), "已售罄"},
+		{stock(3), "仅剩3件"},
+		{stock(100), "现货充足"},
+	}
+	for _, c := range cases {
+		p := Product{Stock: c.stock}
+		if got := p.StockText(); got != c.want {
+			t.Errorf("StockText() = %q, 应为 %q", got, c.want)
+		}
+	}
+
+	p := Product{Name: "保温杯", Price: 99.5, Promotions: []string{"满199减20", "送杯套"}}
+	if got := p.Render("{name} {price} {promotions}"); got != "保温杯 ¥99.5 满199减20，送杯套" {
+		t.Fatalf("Render = %q", got)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := s.Create(Product{TenantID: "tenant", Name: "保温杯", Price: 99})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := reloaded.Get(created.ID); !ok || p.Name != "保温杯" {
+		t.Fatalf("重启后应保留商品: %+v", p)
+	}
+	if err := reloaded.Delete(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Delete(created.ID); err != ErrNotFound {
+		t.Fatalf("err = %v, 删除不存在的商品应返回 ErrNotFound", err)
+	}
+}
@@ -0,0 +1,106 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvColumns 支持的CSV列，首行为表头，列顺序不限，name 必填
+//
+//	name,aliases,price,original_price,stock,promotions,faq
+//	保温杯,杯子|水杯,99,129,50,满199减20|送杯套,能装开水吗=可以，耐热100度|保温多久=12小时
+//
+// aliases、promotions 用 "|" 分隔多项，faq 每项为 "问题=答案"；stock 留空表示不展示库存
+var csvColumns = []string{"id", "name", "aliases", "price", "original_price", "stock", "promotions", "faq"}
+
+// ParseCSV 解析CSV格式的商品列表
+func ParseCSV(r io.Reader) ([]Product, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV缺少表头")
+	}
+
+	index := make(map[string]int)
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, fmt.Errorf("CSV缺少 name 列，支持的列: %s", strings.Join(csvColumns, ","))
+	}
+
+	products := make([]Product, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		line := n + 2
+		field := func(column string) string {
+			if i, ok := index[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if field("name") == "" {
+			continue
+		}
+
+		product := Product{
+			ID:         field("id"),
+			Name:       field("name"),
+			Aliases:    splitList(field("aliases")),
+			Promotions: splitList(field("promotions")),
+		}
+		if product.Price, err = parsePrice(field("price")); err != nil {
+			return nil, fmt.Errorf("第%d行 price: %v", line, err)
+		}
+		if product.OriginalPrice, err = parsePrice(field("original_price")); err != nil {
+			return nil, fmt.Errorf("第%d行 original_price: %v", line, err)
+		}
+		if value := field("stock"); value != "" {
+			stock, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d行 stock: 不是整数: %q", line, value)
+			}
+			product.Stock = &stock
+		}
+		for _, item := range splitList(field("faq")) {
+			question, answer, ok := strings.Cut(item, "=")
+			if !ok {
+				return nil, fmt.Errorf("第%d行 faq: 格式应为 问题=答案: %q", line, item)
+			}
+			product.FAQ = append(product.FAQ, FAQ{Question: strings.TrimSpace(question), Answer: strings.TrimSpace(answer)})
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+// splitList 按 "|" 拆分多项，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parsePrice(value string) (float64, error) {
+	value = strings.TrimLeft(value, "¥￥")
+	if value == "" {
+		return 0, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("不是数字: %q", value)
+	}
+	return price, nil
+}
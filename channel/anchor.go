@@ -0,0 +1,69 @@
+package channel
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// anchorTTL 可作为公屏消息回复对象的评论时效，更早的评论已滚出直播间评论区
+const anchorTTL = 5 * time.Minute
+
+// errNoRecentComment 直播间最近没有评论，公屏消息没有可回复的评论
+var errNoRecentComment = errors.New("直播间最近没有评论，无法发送公屏消息")
+
+// anchor 一条可回复的评论
+type anchor struct {
+	commentID string
+	at        time.Time
+}
+
+// anchorSet 直播间各用户最近的评论，以及整个直播间最近的评论。
+// 超过时效的记录在新增时清理
+type anchorSet struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	latest anchor
+	users  map[string]anchor
+}
+
+// newAnchorSet 创建评论记录
+func newAnchorSet(ttl time.Duration) *anchorSet {
+	return &anchorSet{ttl: ttl, users: make(map[string]anchor)}
+}
+
+// add 记录用户的评论
+func (s *anchorSet) add(userID, commentID string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := anchor{commentID: commentID, at: at}
+	if !at.Before(s.latest.at) {
+		s.latest = a
+	}
+	if userID == "" {
+		return
+	}
+	if _, ok := s.users[userID]; !ok {
+		for id, old := range s.users {
+			if at.Sub(old.at) >= s.ttl {
+				delete(s.users, id)
+			}
+		}
+	}
+	s.users[userID] = a
+}
+
+// get 公屏消息回复的评论：优先 userID 时效内最近的评论，其次直播间时效内最近的评论
+func (s *anchorSet) get(userID string, now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.users[userID]; ok && userID != "" && now.Sub(a.at) < s.ttl {
+		return a.commentID, true
+	}
+	if s.latest.commentID != "" && now.Sub(s.latest.at) < s.ttl {
+		return s.latest.commentID, true
+	}
+	return "", false
+}
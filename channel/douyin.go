@@ -1,20 +1,45 @@
 package channel
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"live-im-proxy/event"
+	"live-im-proxy/lease"
+	"live-im-proxy/limiter"
 	"live-im-proxy/pipeline"
+	"live-im-proxy/secrets"
 )
 
+// douyinAPILimiter 抖音开放平台调用限流（可选），多实例部署时应使用共享配额
+var douyinAPILimiter limiter.KeyedRateLimiter
+
+// SetDouyinRateLimiter 设置抖音开放平台调用限流器
+func SetDouyinRateLimiter(l limiter.KeyedRateLimiter) {
+	douyinAPILimiter = l
+}
+
+// douyinAPIBase 抖音开放平台接口地址
+var douyinAPIBase = "https://open.douyin.com"
+
+// douyinAppID/douyinAppSecret 抖音开放平台应用凭证
+var douyinAppID, douyinAppSecret string
+
+// SetDouyinApp 设置抖音开放平台应用凭证
+func SetDouyinApp(appID, appSecret string) {
+	douyinAppID = appID
+	douyinAppSecret = appSecret
+}
+
 // DouyinChannel 抖音渠道
 type DouyinChannel struct {
 	pipeline     *pipeline.Pipeline
@@ -26,7 +51,13 @@ type DouyinChannel struct {
 	connected    bool
 	conn         *websocket.Conn
 	done         chan struct{}
-	processedIDs map[string]bool // 记录已处理的评论ID，用于去重
+	seen         *seenSet                    // 已处理的评论和私信，用于去重并跳过会话启动前的积压消息
+	replyCounts  map[string]map[string]int64 // 各视频已处理的楼层回复数，有新回复时才拉取楼层
+	anchors      *anchorSet                  // 直播间最近的评论，公屏消息以回复评论的方式发出
+	accountID    string           // 授权账号 open_id
+	tenantID     string
+	sessionKey   string
+	channelStats
 }
 
 // NewDouyinChannel 创建抖音渠道
@@ -35,52 +66,95 @@ func NewDouyinChannel(pipeline *pipeline.Pipeline) (*DouyinChannel, error) {
 		pipeline:     pipeline,
 		connected:    false,
 		done:         make(chan struct{}),
-		processedIDs: make(map[string]bool),
+		seen:         newSeenSet(time.Now(), seenTTL, seenMax),
+		replyCounts:  make(map[string]map[string]int64),
+		anchors:      newAnchorSet(anchorTTL),
 	}, nil
 }
 
+// BindSession 绑定所属会话，事件带上会话的租户和会话键
+func (d *DouyinChannel) BindSession(spec lease.SessionSpec) {
+	d.accountID = spec.AccountID
+	d.tenantID = spec.TenantID
+	d.sessionKey = spec.Key
+}
+
+// emit 标记事件来源后送入管道
+func (d *DouyinChannel) emit(evt *event.Event) error {
+	if d.tenantID != "" {
+		evt.TenantID = d.tenantID
+	}
+	if d.sessionKey != "" {
+		evt.SetMetadata(pipeline.MetadataSessionKey, d.sessionKey)
+	}
+	d.count("events")
+	if err := d.pipeline.ProcessEvent(evt); err != nil {
+		d.fail(err)
+		return err
+	}
+	return nil
+}
+
 // Start 启动渠道，传入access_token
 func (d *DouyinChannel) Start(roomID, accessToken string) error {
+	if accessToken == "" {
+		return fmt.Errorf("直播间监听需要access_token，请先完成抖音授权")
+	}
 	d.roomID = roomID
-	d.appID = "dy123456789"      // 从环境变量获取（抖音开放平台的AppID）
-	d.appSecret = "dy_secret_***" // 从环境变量获取
+	d.appID = douyinAppID
+	d.appSecret = douyinAppSecret
 	d.accessToken = accessToken // OAuth授权后获取的真实token
 
 	log.Printf("🎵 抖音渠道启动，房间ID: %s", roomID)
 
-	// 优先尝试API轮询方式（方案A）
-	if d.accessToken != "" {
-		log.Printf("🔄 使用API轮询方式监听直播间评论和私信")
-		go d.pollLiveComments()
-		go d.pollPrivateMessages() // 启动私信监听
-		d.connected = true
-		return nil
-	}
+	log.Printf("🔄 使用API轮询方式监听直播间评论和私信")
+	go d.pollLiveComments()
+	go d.pollPrivateMessages() // 启动私信监听
+	d.connected = true
 
-	// 尝试连接WebSocket（备用方案）
+	// 礼物、点赞、进场和关注只能通过互动消息推送获取，连接失败时只处理评论和私信
 	if err := d.connectWebSocket(); err != nil {
-		log.Printf("❌ WebSocket连接失败，使用模拟模式: %v", err)
-		// 如果WebSocket连接失败，回退到模拟模式
-		go d.simulateEvents()
-		return nil
+		log.Printf("⚠️ 直播间互动消息推送连接失败，礼物和进场消息不处理: %v", err)
+	} else {
+		go d.readMessages()
 	}
-
-	d.connected = true
-	go d.readMessages()
 	return nil
 }
 
-// StartVideo 启动短视频监听，传入access_token
+// StartVideo 启动短视频评论监听，videoID 为 lease.AllVideos 时监听账号最近发布的视频
 func (d *DouyinChannel) StartVideo(videoID, accessToken string) error {
+	if videoID == "" {
+		return fmt.Errorf("缺少视频ID")
+	}
+	if accessToken == "" {
+		return fmt.Errorf("短视频评论监听需要access_token")
+	}
 	d.videoID = videoID
-	d.appID = "dy123456789"      // 从环境变量获取
-	d.appSecret = "dy_secret_***" // 从环境变量获取
+	d.appID = douyinAppID
+	d.appSecret = douyinAppSecret
 	d.accessToken = accessToken // OAuth授权后获取的真实token
 
 	log.Printf("🎬 抖音短视频启动，视频ID: %s", videoID)
 
 	// 启动短视频评论轮询
 	go d.pollVideoComments()
+	d.connected = true
+	return nil
+}
+
+// StartDirectMessages 仅监听账号私信，传入access_token
+func (d *DouyinChannel) StartDirectMessages(accessToken string) error {
+	if accessToken == "" {
+		return fmt.Errorf("私信监听需要access_token")
+	}
+	d.appID = douyinAppID
+	d.appSecret = douyinAppSecret
+	d.accessToken = accessToken
+
+	log.Printf("💬 抖音私信监听启动")
+
+	go d.pollPrivateMessages()
+	d.connected = true
 	return nil
 }
 
@@ -134,8 +208,8 @@ func (d *DouyinChannel) readMessages() {
 		default:
 			_, message, err := d.conn.ReadMessage()
 			if err != nil {
+				// 评论和私信由API轮询处理，推送断开不标记渠道断开
 				log.Printf("❌ 读取WebSocket消息失败: %v", err)
-				d.connected = false
 				return
 			}
 
@@ -169,10 +243,18 @@ func (d *DouyinChannel) parseMessage(data []byte) error {
 		userID, _ := msg["user_id"].(string)
 		nickname, _ := msg["nickname"].(string)
 
-		evt := event.NewEvent("comment", "douyin", d.roomID, userID, nickname)
+		evt := event.NewEvent(event.TypeComment, "douyin", d.roomID, userID, nickname)
 		evt.SetContent(content)
+		if commentID, _ := msg["comment_id"].(string); commentID != "" {
+			// 轮询和推送可能收到同一条评论
+			if !d.seen.add(event.IdempotencyKey("douyin", "comment", commentID), 0) {
+				return nil
+			}
+			evt.SetComment(event.CommentPayload{CommentID: commentID})
+			d.anchors.add(userID, commentID, time.Now())
+		}
 
-		if err := d.pipeline.ProcessEvent(evt); err != nil {
+		if err := d.emit(evt); err != nil {
 			return err
 		}
 
@@ -183,8 +265,9 @@ func (d *DouyinChannel) parseMessage(data []byte) error {
 		userID, _ := msg["user_id"].(string)
 		nickname, _ := msg["nickname"].(string)
 
-		evt := event.NewEvent("enter", "douyin", d.roomID, userID, nickname)
-		if err := d.pipeline.ProcessEvent(evt); err != nil {
+		evt := event.NewEvent(event.TypeEnter, "douyin", d.roomID, userID, nickname)
+		evt.SetEnter(event.EnterPayload{MemberCount: jsonInt(msg["member_count"])})
+		if err := d.emit(evt); err != nil {
 			return err
 		}
 
@@ -195,25 +278,85 @@ func (d *DouyinChannel) parseMessage(data []byte) error {
 		userID, _ := msg["user_id"].(string)
 		nickname, _ := msg["nickname"].(string)
 
-		evt := event.NewEvent("follow", "douyin", d.roomID, userID, nickname)
-		if err := d.pipeline.ProcessEvent(evt); err != nil {
+		evt := event.NewEvent(event.TypeFollow, "douyin", d.roomID, userID, nickname)
+		evt.SetFollow(event.FollowPayload{FollowerCount: jsonInt(msg["follower_count"])})
+		if err := d.emit(evt); err != nil {
 			return err
 		}
 
 		log.Printf("📨 抖音关注: %s", nickname)
+
+	case "like":
+		// 处理点赞消息
+		userID, _ := msg["user_id"].(string)
+		nickname, _ := msg["nickname"].(string)
+		count := jsonInt(msg["count"])
+		if count <= 0 {
+			count = 1
+		}
+
+		evt := event.NewEvent(event.TypeLike, "douyin", d.roomID, userID, nickname)
+		evt.SetLike(event.LikePayload{Count: count})
+		if err := d.emit(evt); err != nil {
+			return err
+		}
+
+	case "gift":
+		// 处理礼物消息
+		userID, _ := msg["user_id"].(string)
+		nickname, _ := msg["nickname"].(string)
+		giftID, _ := msg["gift_id"].(string)
+		giftName, _ := msg["gift_name"].(string)
+		count := jsonInt(msg["count"])
+		if count <= 0 {
+			count = 1
+		}
+
+		evt := event.NewEvent(event.TypeGift, "douyin", d.roomID, userID, nickname)
+		evt.SetGift(event.GiftPayload{
+			GiftID:   giftID,
+			GiftName: giftName,
+			Count:    count,
+			Value:    jsonInt(msg["value"]),
+		})
+		if err := d.emit(evt); err != nil {
+			return err
+		}
+
+		log.Printf("📨 抖音礼物: %s 送出 %s x%d", nickname, giftName, count)
 	}
 
 	return nil
 }
 
-// SendMessage 发送消息
+// jsonInt 读取 JSON 解码出的数值字段
+func jsonInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+// SendMessage 发送直播间公屏消息。开放平台没有公屏发言接口，消息作为直播间最近一条评论的回复发出
 func (d *DouyinChannel) SendMessage(content string) error {
+	return d.SendRoomReply("", content)
+}
+
+// SendRoomReply 以回复评论的方式向直播间发送消息，优先回复 userID 最近的评论，
+// 该用户最近没有评论时回复直播间最近一条评论
+func (d *DouyinChannel) SendRoomReply(userID, content string) error {
 	if !d.connected {
 		return fmt.Errorf("渠道未连接")
 	}
-
-	log.Printf("📤 抖音发送消息: %s", content)
-	return nil
+	commentID, ok := d.anchors.get(userID, time.Now())
+	if !ok {
+		return errNoRecentComment
+	}
+	return d.SendLiveCommentReply(d.roomID, commentID, content)
 }
 
 // SendVideoCommentReply 发送短视频评论回复
@@ -239,8 +382,13 @@ func (d *DouyinChannel) SendVideoCommentReply(videoID, commentID, content string
 	})
 	
 	if err != nil {
-		return fmt.Errorf("发送回复失败: %v", err)
+		err = fmt.Errorf("发送回复失败: %v", err)
+		d.fail(err)
+		d.countTarget(videoID, "reply_errors")
+		return err
 	}
+	d.count("replies")
+	d.countTarget(videoID, "replies")
 	
 	log.Printf("✅ 抖音短视频回复发送成功")
 	return nil
@@ -269,20 +417,31 @@ func (d *DouyinChannel) SendLiveCommentReply(roomID, commentID, content string)
 	})
 	
 	if err != nil {
-		return fmt.Errorf("发送回复失败: %v", err)
+		err = fmt.Errorf("发送回复失败: %v", err)
+		d.fail(err)
+		return err
 	}
+	d.count("replies")
 	
 	log.Printf("✅ 抖音直播间回复发送成功")
 	return nil
 }
 
-// pollVideoComments 轮询短视频评论
+// 短视频评论监听参数
+const (
+	recentVideoCount = 10               // 监听账号最近发布的视频数
+	videoListRefresh = 30 * time.Minute // 最近视频列表刷新间隔
+)
+
+// pollVideoComments 轮询短视频评论，视频ID为 lease.AllVideos 时轮询账号最近发布的视频
 func (d *DouyinChannel) pollVideoComments() {
-	ticker := time.NewTicker(10 * time.Second) // 每10秒轮询一次
+	ticker := time.NewTicker(pollingFor("douyin").VideoComments)
 	defer ticker.Stop()
 
 	log.Printf("🔄 开始轮询短视频评论，视频ID: %s", d.videoID)
 
+	var videos []string
+	var refreshedAt time.Time
 	for {
 		select {
 		case <-d.done:
@@ -294,111 +453,312 @@ func (d *DouyinChannel) pollVideoComments() {
 				continue
 			}
 
-			// 获取视频评论
-			comments, err := d.getVideoComments()
-			if err != nil {
-				log.Printf("❌ 获取视频评论失败: %v", err)
-				continue
+			if d.videoID != lease.AllVideos {
+				videos = []string{d.videoID}
+			} else if time.Since(refreshedAt) >= videoListRefresh {
+				recent, err := d.recentVideos(recentVideoCount)
+				if err != nil {
+					log.Printf("❌ 获取视频列表失败: %v", err)
+					d.fail(err)
+				} else {
+					videos = recent
+					refreshedAt = time.Now()
+					d.retainVideos(videos)
+					log.Printf("🎬 监听账号最近发布的 %d 个视频", len(videos))
+				}
 			}
 
-			if len(comments) == 0 {
-				log.Printf("📭 暂无新评论")
-				continue
+			for _, videoID := range videos {
+				d.pollVideo(videoID)
 			}
+		}
+	}
+}
 
-			// 处理新评论（去重）
-			newCount := 0
-			for _, comment := range comments {
-				// 检查是否已处理过
-				if d.processedIDs[comment.ID] {
-					continue
-				}
-
-				// 标记为已处理
-				d.processedIDs[comment.ID] = true
-				newCount++
+// pollVideo 拉取单个视频的一级评论，以及有新回复的楼层
+func (d *DouyinChannel) pollVideo(videoID string) {
+	comments, err := d.getVideoComments(videoID)
+	if err != nil {
+		log.Printf("❌ 获取视频评论失败: video=%s, err=%v", videoID, err)
+		d.fail(err)
+		d.countTarget(videoID, "errors")
+		return
+	}
 
-				// 创建事件
-				evt := event.NewEvent("video_comment", "douyin", "", comment.UserID, comment.Nickname)
-				evt.SetVideoID(d.videoID)
-				evt.SetContent(comment.Content)
-				evt.SetExtra("comment_id", comment.ID)
+	// 首次轮询只记录各楼层的回复数，不拉取已有的追问；只保留仍在评论列表中的楼层
+	counts, seeded := d.replyCounts[videoID]
+	next := make(map[string]int64, len(comments))
+	newCount := 0
+	for _, comment := range comments {
+		if d.acceptVideoComment(videoID, comment, comment.ID) {
+			newCount++
+		}
 
-				// 处理事件
-				if err := d.pipeline.ProcessEvent(evt); err != nil {
-					log.Printf("❌ 处理视频评论事件失败: %v", err)
-				} else {
-					log.Printf("📨 抖音视频评论: %s - %s", comment.Nickname, comment.Content)
-				}
+		// 楼层下有新回复时拉取整层，追问同样需要回复
+		next[comment.ID] = counts[comment.ID]
+		if !seeded {
+			next[comment.ID] = comment.ReplyCount
+			continue
+		}
+		if comment.ReplyCount <= counts[comment.ID] {
+			continue
+		}
+		replies, err := d.getCommentReplies(videoID, comment.ID)
+		if err != nil {
+			log.Printf("❌ 获取评论回复失败: video=%s, comment=%s, err=%v", videoID, comment.ID, err)
+			d.fail(err)
+			continue
+		}
+		next[comment.ID] = comment.ReplyCount
+		for _, reply := range replies {
+			if d.acceptVideoComment(videoID, reply, comment.ID) {
+				newCount++
 			}
+		}
+	}
+	d.replyCounts[videoID] = next
 
-			if newCount > 0 {
-				log.Printf("✅ 本次轮询发现 %d 条新评论", newCount)
-			}
+	if newCount > 0 {
+		log.Printf("✅ 视频 %s 本次轮询发现 %d 条新评论", videoID, newCount)
+	}
+}
+
+// retainVideos 只保留仍在监听的视频的楼层回复数
+func (d *DouyinChannel) retainVideos(videos []string) {
+	keep := make(map[string]bool, len(videos))
+	for _, videoID := range videos {
+		keep[videoID] = true
+	}
+	for videoID := range d.replyCounts {
+		if !keep[videoID] {
+			delete(d.replyCounts, videoID)
 		}
 	}
 }
 
-// getVideoComments 获取视频评论
-func (d *DouyinChannel) getVideoComments() ([]VideoComment, error) {
-	// 调用抖音官方API获取评论
+// acceptVideoComment 处理一条新评论，rootID 为所在楼层的一级评论，回复发在该楼层下
+func (d *DouyinChannel) acceptVideoComment(videoID string, comment VideoComment, rootID string) bool {
+	commentKey := event.IdempotencyKey("douyin", "comment", comment.ID)
+	if !d.seen.add(commentKey, comment.Time) {
+		return false
+	}
+
+	// 账号自己发出的回复不再触发回复
+	if d.accountID != "" && comment.UserID == d.accountID {
+		return false
+	}
+	d.countTarget(videoID, "comments")
+
+	evt := event.NewEvent(event.TypeVideoComment, "douyin", "", comment.UserID, comment.Nickname)
+	evt.SetVideoID(videoID)
+	evt.SetContent(comment.Content)
+	payload := event.CommentPayload{CommentID: rootID}
+	if comment.ID != rootID {
+		payload.ReplyToCommentID = comment.ID
+	}
+	evt.SetComment(payload)
+
+	if err := d.emit(evt); err != nil {
+		log.Printf("❌ 处理视频评论事件失败: %v", err)
+	} else {
+		log.Printf("📨 抖音视频评论: %s - %s", comment.Nickname, comment.Content)
+	}
+	return true
+}
+
+// 评论列表分页参数
+const (
+	commentPageSize = 20 // 每页条数
+	maxCommentPages = 5  // 每次轮询每个列表最多拉取的页数，避免热门视频单次轮询请求过多
+)
+
+// getVideoComments 获取视频一级评论
+func (d *DouyinChannel) getVideoComments(videoID string) ([]VideoComment, error) {
 	// 注意：实际API路径可能需要根据抖音开放平台文档调整
-	resp, err := d.callDouyinAPI("GET", "/video/comment/list", map[string]interface{}{
-		"item_id": d.videoID, // 抖音API使用item_id而不是video_id
-		"count":   20,
-		"cursor":  0, // 分页游标
+	return d.listComments("/video/comment/list", map[string]interface{}{
+		"item_id": videoID, // 抖音API使用item_id而不是video_id
 	})
-	
+}
+
+// getCommentReplies 获取评论楼层下的回复
+func (d *DouyinChannel) getCommentReplies(videoID, commentID string) ([]VideoComment, error) {
+	return d.listComments("/video/comment/reply/list", map[string]interface{}{
+		"item_id":    videoID,
+		"comment_id": commentID,
+	})
+}
+
+// listComments 按游标翻页拉取评论列表，最多 maxCommentPages 页；
+// 后续页失败时返回已拉取的评论，下次轮询再补齐
+func (d *DouyinChannel) listComments(endpoint string, params map[string]interface{}) ([]VideoComment, error) {
+	var all []VideoComment
+	var cursor int64
+	for page := 0; page < maxCommentPages; page++ {
+		params["count"] = commentPageSize
+		params["cursor"] = cursor
+		comments, next, hasMore, err := d.fetchCommentPage(endpoint, params)
+		if err != nil {
+			if page == 0 {
+				return nil, err
+			}
+			log.Printf("⚠️ 评论列表翻页失败，本次只处理前 %d 页: %v", page, err)
+			break
+		}
+		all = append(all, comments...)
+		if !hasMore || next == cursor {
+			break
+		}
+		cursor = next
+	}
+	return all, nil
+}
+
+// fetchCommentPage 拉取一页评论
+func (d *DouyinChannel) fetchCommentPage(endpoint string, params map[string]interface{}) ([]VideoComment, int64, bool, error) {
+	resp, err := d.callDouyinAPI("GET", endpoint, params)
 	if err != nil {
-		return nil, fmt.Errorf("调用抖音API失败: %v", err)
+		return nil, 0, false, fmt.Errorf("调用抖音API失败: %v", err)
 	}
+	return decodeComments(resp)
+}
 
-	// 解析响应（根据抖音实际API响应格式调整）
+// decodeComments 解析评论列表响应（根据抖音实际API响应格式调整），返回评论、下一页游标和是否还有更多
+func decodeComments(resp []byte) ([]VideoComment, int64, bool, error) {
 	var result struct {
-		ErrNo   int    `json:"err_no"`
-		ErrMsg  string `json:"err_msg"`
-		LogID   string `json:"log_id"`
-		Data    struct {
+		ErrNo  int    `json:"err_no"`
+		ErrMsg string `json:"err_msg"`
+		LogID  string `json:"log_id"`
+		Data   struct {
 			List []struct {
-				CommentID    string `json:"comment_id"`
-				UserID       string `json:"user_id"`
-				Nickname     string `json:"nickname"`
-				Avatar       string `json:"avatar"`
-				CommentText  string `json:"comment_text"`
-				CreateTime   int64  `json:"create_time"`
+				CommentID         string `json:"comment_id"`
+				UserID            string `json:"user_id"`
+				Nickname          string `json:"nickname"`
+				Avatar            string `json:"avatar"`
+				CommentText       string `json:"comment_text"`
+				CreateTime        int64  `json:"create_time"`
+				ReplyCommentTotal int64  `json:"reply_comment_total"`
 			} `json:"list"`
-			Cursor int64 `json:"cursor"`
-			HasMore bool `json:"has_more"`
+			Cursor  int64 `json:"cursor"`
+			HasMore bool  `json:"has_more"`
 		} `json:"data"`
 	}
-	
+
 	if err := json.Unmarshal(resp, &result); err != nil {
-		return nil, fmt.Errorf("解析API响应失败: %v", err)
+		return nil, 0, false, fmt.Errorf("解析API响应失败: %v", err)
 	}
 
 	// 检查API错误
 	if result.ErrNo != 0 {
-		return nil, fmt.Errorf("抖音API错误: %d - %s", result.ErrNo, result.ErrMsg)
+		return nil, 0, false, fmt.Errorf("抖音API错误: %d - %s", result.ErrNo, result.ErrMsg)
 	}
 
-	// 转换为VideoComment格式
 	comments := make([]VideoComment, 0, len(result.Data.List))
 	for _, item := range result.Data.List {
 		comments = append(comments, VideoComment{
-			ID:       item.CommentID,
-			UserID:   item.UserID,
-			Nickname: item.Nickname,
-			Content:  item.CommentText,
-			Time:     item.CreateTime,
+			ID:         item.CommentID,
+			UserID:     item.UserID,
+			Nickname:   item.Nickname,
+			Content:    item.CommentText,
+			Time:       item.CreateTime,
+			ReplyCount: item.ReplyCommentTotal,
 		})
 	}
 
-	return comments, nil
+	return comments, result.Data.Cursor, result.Data.HasMore, nil
+}
+
+// DouyinVideo 账号发布的视频
+type DouyinVideo struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	CreateTime   int64  `json:"create_time"`
+	IsTop        bool   `json:"is_top"` // 是否置顶
+	CommentCount int64  `json:"comment_count"`
+}
+
+// VideoPage 视频列表分页
+type VideoPage struct {
+	Videos  []DouyinVideo `json:"videos"`
+	Cursor  int64         `json:"cursor"`
+	HasMore bool          `json:"has_more"`
+}
+
+// ListVideos 分页获取授权账号发布的视频（video.list 权限）
+func (d *DouyinChannel) ListVideos(cursor int64, count int) (VideoPage, error) {
+	if d.accountID == "" {
+		return VideoPage{}, fmt.Errorf("未设置账号open_id")
+	}
+
+	// 注意：实际API路径可能需要根据抖音开放平台文档调整
+	resp, err := d.callDouyinAPI("GET", "/video/list", map[string]interface{}{
+		"open_id": d.accountID,
+		"cursor":  cursor,
+		"count":   count,
+	})
+	if err != nil {
+		return VideoPage{}, fmt.Errorf("调用抖音API失败: %v", err)
+	}
+
+	var result struct {
+		ErrNo  int    `json:"err_no"`
+		ErrMsg string `json:"err_msg"`
+		Data   struct {
+			List []struct {
+				ItemID     string `json:"item_id"`
+				Title      string `json:"title"`
+				CreateTime int64  `json:"create_time"`
+				IsTop      bool   `json:"is_top"`
+				Statistics struct {
+					CommentCount int64 `json:"comment_count"`
+				} `json:"statistics"`
+			} `json:"list"`
+			Cursor  int64 `json:"cursor"`
+			HasMore bool  `json:"has_more"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return VideoPage{}, fmt.Errorf("解析API响应失败: %v", err)
+	}
+	if result.ErrNo != 0 {
+		return VideoPage{}, fmt.Errorf("抖音API错误: %d - %s", result.ErrNo, result.ErrMsg)
+	}
+
+	page := VideoPage{Cursor: result.Data.Cursor, HasMore: result.Data.HasMore}
+	for _, item := range result.Data.List {
+		page.Videos = append(page.Videos, DouyinVideo{
+			ID:           item.ItemID,
+			Title:        item.Title,
+			CreateTime:   item.CreateTime,
+			IsTop:        item.IsTop,
+			CommentCount: item.Statistics.CommentCount,
+		})
+	}
+	return page, nil
+}
+
+// ListDouyinVideos 分页获取授权账号发布的视频，供不依赖会话的任务使用
+func ListDouyinVideos(openID, accessToken string, cursor int64, count int) (VideoPage, error) {
+	d := &DouyinChannel{accountID: openID, accessToken: accessToken}
+	return d.ListVideos(cursor, count)
+}
+
+// recentVideos 账号最近发布的视频ID
+func (d *DouyinChannel) recentVideos(n int) ([]string, error) {
+	page, err := d.ListVideos(0, n)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(page.Videos))
+	for _, v := range page.Videos {
+		ids = append(ids, v.ID)
+	}
+	return ids, nil
 }
 
 // pollLiveComments 轮询直播间评论
 func (d *DouyinChannel) pollLiveComments() {
-	ticker := time.NewTicker(5 * time.Second) // 每5秒轮询一次（直播间评论更频繁）
+	// 默认每5秒轮询一次（直播间评论更频繁）
+	ticker := time.NewTicker(pollingFor("douyin").LiveComments)
 	defer ticker.Stop()
 
 	log.Printf("🔄 开始轮询直播间评论，房间ID: %s", d.roomID)
@@ -418,6 +778,7 @@ func (d *DouyinChannel) pollLiveComments() {
 			comments, err := d.getLiveComments()
 			if err != nil {
 				log.Printf("❌ 获取直播间评论失败: %v", err)
+				d.fail(err)
 				continue
 			}
 
@@ -428,23 +789,21 @@ func (d *DouyinChannel) pollLiveComments() {
 			// 处理新评论（去重）
 			newCount := 0
 			for _, comment := range comments {
-				// 检查是否已处理过
-				commentKey := fmt.Sprintf("live_%s_%s", d.roomID, comment.ID)
-				if d.processedIDs[commentKey] {
+				// 检查是否已处理过，会话启动前的积压评论不处理
+				commentKey := event.IdempotencyKey("douyin", "comment", comment.ID)
+				if !d.seen.add(commentKey, comment.Time) {
 					continue
 				}
-
-				// 标记为已处理
-				d.processedIDs[commentKey] = true
 				newCount++
 
 				// 创建事件
-				evt := event.NewEvent("comment", "douyin", d.roomID, comment.UserID, comment.Nickname)
+				evt := event.NewEvent(event.TypeComment, "douyin", d.roomID, comment.UserID, comment.Nickname)
 				evt.SetContent(comment.Content)
-				evt.SetExtra("comment_id", comment.ID)
+				evt.SetComment(event.CommentPayload{CommentID: comment.ID})
+				d.anchors.add(comment.UserID, comment.ID, time.Now())
 
 				// 处理事件
-				if err := d.pipeline.ProcessEvent(evt); err != nil {
+				if err := d.emit(evt); err != nil {
 					log.Printf("❌ 处理直播间评论事件失败: %v", err)
 				} else {
 					log.Printf("📨 抖音直播间评论: %s - %s", comment.Nickname, comment.Content)
@@ -520,9 +879,19 @@ func (d *DouyinChannel) callDouyinAPI(method, endpoint string, data map[string]i
 	if d.accessToken == "" {
 		return nil, fmt.Errorf("缺少access_token")
 	}
+
+	// 等待抖音API配额
+	if douyinAPILimiter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := douyinAPILimiter.Wait(ctx, "app")
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("抖音API限流: %v", err)
+		}
+	}
 	
 	// 构建请求URL
-	reqURL := fmt.Sprintf("https://open.douyin.com%s", endpoint)
+	reqURL := douyinAPIBase + endpoint
 	
 	// 构建请求
 	var req *http.Request
@@ -551,7 +920,7 @@ func (d *DouyinChannel) callDouyinAPI(method, endpoint string, data map[string]i
 	req.Header.Set("Authorization", "Bearer "+d.accessToken)
 	req.Header.Set("Content-Type", "application/json")
 	
-	log.Printf("🌐 调用抖音API: %s %s", method, reqURL)
+	log.Printf("🌐 调用抖音API: %s %s", method, secrets.RedactURL(reqURL))
 	
 	// 发送请求
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -567,18 +936,19 @@ func (d *DouyinChannel) callDouyinAPI(method, endpoint string, data map[string]i
 		return nil, fmt.Errorf("读取响应失败: %v", err)
 	}
 	
-	log.Printf("📥 抖音API响应: %s", string(body))
+	log.Printf("📥 抖音API响应: %s", secrets.RedactText(string(body)))
 	
 	return body, nil
 }
 
 // VideoComment 视频评论结构
 type VideoComment struct {
-	ID       string `json:"id"`
-	UserID   string `json:"user_id"`
-	Nickname string `json:"nickname"`
-	Content  string `json:"content"`
-	Time     int64  `json:"time"`
+	ID         string `json:"id"`
+	UserID     string `json:"user_id"`
+	Nickname   string `json:"nickname"`
+	Content    string `json:"content"`
+	Time       int64  `json:"time"`
+	ReplyCount int64  `json:"reply_count,omitempty"` // 楼层回复数（仅一级评论）
 }
 
 // IsConnected 检查是否已连接
@@ -596,7 +966,7 @@ func (d *DouyinChannel) GetStatus() string {
 
 // pollPrivateMessages 轮询私信消息
 func (d *DouyinChannel) pollPrivateMessages() {
-	ticker := time.NewTicker(10 * time.Second) // 每10秒轮询一次私信
+	ticker := time.NewTicker(pollingFor("douyin").PrivateMessages)
 	defer ticker.Stop()
 
 	log.Printf("🔄 开始轮询私信消息")
@@ -616,6 +986,7 @@ func (d *DouyinChannel) pollPrivateMessages() {
 			messages, err := d.getPrivateMessages()
 			if err != nil {
 				log.Printf("❌ 获取私信消息失败: %v", err)
+				d.fail(err)
 				continue
 			}
 
@@ -626,24 +997,25 @@ func (d *DouyinChannel) pollPrivateMessages() {
 			// 处理新私信（去重）
 			newCount := 0
 			for _, msg := range messages {
-				// 检查是否已处理过
-				msgKey := fmt.Sprintf("pm_%s_%s", msg.UserID, msg.ID)
-				if d.processedIDs[msgKey] {
+				// 检查是否已处理过，会话启动前的积压私信不处理
+				msgKey := event.IdempotencyKey("douyin", "message", msg.ID)
+				if !d.seen.add(msgKey, msg.Time) {
 					continue
 				}
-
-				// 标记为已处理
-				d.processedIDs[msgKey] = true
 				newCount++
 
 				// 创建事件
-				evt := event.NewEvent("private_message", "douyin", "", msg.UserID, msg.Nickname)
+				evt := event.NewEvent(event.TypePrivateMessage, "douyin", "", msg.UserID, msg.Nickname)
 				evt.SetContent(msg.Content)
-				evt.SetExtra("message_id", msg.ID)
-				evt.SetExtra("conversation_id", msg.ConversationID)
+				evt.SetPrivateMessage(event.PrivateMessagePayload{
+					MessageID:      msg.ID,
+					ConversationID: msg.ConversationID,
+					MessageType:    msg.Type,
+					MediaURL:       msg.MediaURL,
+				})
 
 				// 处理事件
-				if err := d.pipeline.ProcessEvent(evt); err != nil {
+				if err := d.emit(evt); err != nil {
 					log.Printf("❌ 处理私信事件失败: %v", err)
 				} else {
 					log.Printf("📨 抖音私信: %s - %s", msg.Nickname, msg.Content)
@@ -665,7 +1037,8 @@ type PrivateMessage struct {
 	Nickname       string `json:"nickname"`
 	Content        string `json:"content"`
 	Time           int64  `json:"time"`
-	Type           string `json:"type"` // text, image, video等
+	Type           string `json:"type"`                // text, image, video等
+	MediaURL       string `json:"media_url,omitempty"` // 图片、视频等媒体地址
 }
 
 // getPrivateMessages 获取私信消息
@@ -695,6 +1068,7 @@ func (d *DouyinChannel) getPrivateMessages() ([]PrivateMessage, error) {
 				Avatar         string `json:"avatar"`
 				Content        string `json:"content"`
 				MessageType    string `json:"message_type"`
+				MediaURL       string `json:"media_url"`
 				CreateTime     int64  `json:"create_time"`
 			} `json:"list"`
 			Cursor  int64 `json:"cursor"`
@@ -722,6 +1096,7 @@ func (d *DouyinChannel) getPrivateMessages() ([]PrivateMessage, error) {
 			Content:        item.Content,
 			Time:           item.CreateTime,
 			Type:           item.MessageType,
+			MediaURL:       item.MediaURL,
 		})
 	}
 
@@ -748,59 +1123,12 @@ func (d *DouyinChannel) SendPrivateMessage(conversationID, userID, content strin
 	})
 
 	if err != nil {
-		return fmt.Errorf("发送私信失败: %v", err)
+		err = fmt.Errorf("发送私信失败: %v", err)
+		d.fail(err)
+		return err
 	}
+	d.count("replies")
 
 	log.Printf("✅ 抖音私信回复发送成功")
 	return nil
 }
-
-// simulateEvents 模拟事件（实际项目中应该连接真实的 WebSocket）
-func (d *DouyinChannel) simulateEvents() {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	eventTypes := []string{"enter", "comment", "like", "follow"}
-	users := []string{"张先生", "李女士", "王总", "刘小姐", "陈老板"}
-	comments := []string{
-		"这个产品价格是多少？",
-		"质量怎么样？我想了解一下",
-		"怎么购买？有优惠吗？",
-		"发货快吗？大概几天到？",
-		"支持退货吗？有质保吗？",
-		"性价比高吗？推荐购买吗？",
-		"有现货吗？需要等多久？",
-		"微信多少？想详细咨询",
-		"客服在吗？有问题想问",
-		"这个好用吗？值得买吗？",
-	}
-
-	for {
-		select {
-		case <-ticker.C:
-			// 模拟模式下也继续运行
-			// if !d.connected {
-			// 	return
-			// }
-
-			// 随机生成事件
-			eventType := eventTypes[time.Now().Unix()%int64(len(eventTypes))]
-			user := users[time.Now().Unix()%int64(len(users))]
-			userID := fmt.Sprintf("douyin_%d", time.Now().Unix())
-
-			evt := event.NewEvent(eventType, "douyin", d.roomID, userID, user)
-
-			if eventType == "comment" {
-				comment := comments[time.Now().Unix()%int64(len(comments))]
-				evt.SetContent(comment)
-			}
-
-			// 处理事件
-			if err := d.pipeline.ProcessEvent(evt); err != nil {
-				log.Printf("❌ 处理事件失败: %v", err)
-			}
-
-			log.Printf("📨 抖音事件: %s - %s", eventType, user)
-		}
-	}
-}
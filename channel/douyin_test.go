@@ -0,0 +1,156 @@
+package channel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDouyin 创建连接到模拟开放平台的抖音直播间渠道，返回收到的评论回复请求
+func newTestDouyin(t *testing.T) (*DouyinChannel, *[]map[string]interface{}) {
+	t.Helper()
+	var replies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live/comment/reply" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("解析请求失败: %v", err)
+		}
+		replies = append(replies, body)
+		w.Write([]byte(`{"err_no":0}`))
+	}))
+	t.Cleanup(server.Close)
+
+	base := douyinAPIBase
+	douyinAPIBase = server.URL
+	t.Cleanup(func() { douyinAPIBase = base })
+
+	d, err := NewDouyinChannel(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.roomID = "room-1"
+	d.accessToken = "token"
+	d.connected = true
+	return d, &replies
+}
+
+func TestSendRoomReplyRepliesToUserComment(t *testing.T) {
+	d, replies := newTestDouyin(t)
+	now := time.Now()
+	d.anchors.add("gifter", "c1", now.Add(-time.Minute))
+	d.anchors.add("other", "c2", now)
+
+	if err := d.SendRoomReply("gifter", "感谢 张三 送出的 玫瑰"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SendRoomReply("silent", "欢迎 李四"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SendMessage("关注主播不迷路"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"c1", "c2", "c2"}
+	if len(*replies) != len(want) {
+		t.Fatalf("发出 %d 条回复, 应为 %d", len(*replies), len(want))
+	}
+	for i, body := range *replies {
+		if body["room_id"] != "room-1" || body["comment_id"] != want[i] {
+			t.Fatalf("第%d条回复 = %v, 应回复评论 %s", i+1, body, want[i])
+		}
+	}
+	if (*replies)[0]["content"] != "感谢 张三 送出的 玫瑰" {
+		t.Fatalf("content = %v", (*replies)[0]["content"])
+	}
+}
+
+func TestSendRoomReplyWithoutRecentComment(t *testing.T) {
+	d, replies := newTestDouyin(t)
+	if err := d.SendMessage("关注主播不迷路"); !errors.Is(err, errNoRecentComment) {
+		t.Fatalf("err = %v, 直播间没有评论时应返回 errNoRecentComment", err)
+	}
+
+	d.anchors.add("user", "old", time.Now().Add(-anchorTTL))
+	if err := d.SendRoomReply("user", "感谢"); !errors.Is(err, errNoRecentComment) {
+		t.Fatalf("err = %v, 超过时效的评论不应作为回复对象", err)
+	}
+	if len(*replies) != 0 {
+		t.Fatalf("不应发出回复: %v", *replies)
+	}
+}
+
+func TestAnchorSetPrunesExpiredUsers(t *testing.T) {
+	s := newAnchorSet(time.Minute)
+	start := time.Now()
+	s.add("a", "c1", start)
+	s.add("b", "c2", start.Add(2*time.Minute))
+	if _, ok := s.users["a"]; ok {
+		t.Fatal("新增用户时应清理过期的评论记录")
+	}
+}
+
+// newCommentServer 模拟评论列表接口，每页一条评论，total 为评论总数
+func newCommentServer(t *testing.T, total int) (*DouyinChannel, *[]string) {
+	t.Helper()
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cursor := r.URL.Query().Get("cursor")
+		cursors = append(cursors, cursor)
+		var n int
+		fmt.Sscan(cursor, &n)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"err_no": 0,
+			"data": map[string]interface{}{
+				"list":     []map[string]interface{}{{"comment_id": fmt.Sprintf("c%d", n), "comment_text": "多少钱"}},
+				"cursor":   n + 1,
+				"has_more": n+1 < total,
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	base := douyinAPIBase
+	douyinAPIBase = server.URL
+	t.Cleanup(func() { douyinAPIBase = base })
+
+	d, err := NewDouyinChannel(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.accessToken = "token"
+	return d, &cursors
+}
+
+func TestVideoCommentsFollowCursor(t *testing.T) {
+	d, cursors := newCommentServer(t, 3)
+	comments, err := d.getVideoComments("video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 3 || comments[2].ID != "c2" {
+		t.Fatalf("应按游标拉取全部评论: %+v", comments)
+	}
+	if got := strings.Join(*cursors, ","); got != "0,1,2" {
+		t.Fatalf("cursors = %s", got)
+	}
+}
+
+func TestCommentRepliesStopAtPageCap(t *testing.T) {
+	d, cursors := newCommentServer(t, 100)
+	replies, err := d.getCommentReplies("video", "c0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != maxCommentPages || len(*cursors) != maxCommentPages {
+		t.Fatalf("每次轮询最多拉取 %d 页，实际 %d 条评论、%d 次请求", maxCommentPages, len(replies), len(*cursors))
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"live-im-proxy/event"
 	"live-im-proxy/pipeline"
+	"live-im-proxy/reply"
 )
 
 // KuaishouChannel 快手渠道
@@ -14,6 +15,7 @@ type KuaishouChannel struct {
 	pipeline *pipeline.Pipeline
 	roomID   string
 	connected bool
+	channelStats
 }
 
 // NewKuaishouChannel 创建快手渠道
@@ -44,14 +46,12 @@ func (k *KuaishouChannel) Stop() error {
 	return nil
 }
 
-// SendMessage 发送消息
+// SendMessage 快手渠道目前只有模拟事件，未接入发送接口
 func (k *KuaishouChannel) SendMessage(content string) error {
 	if !k.connected {
 		return fmt.Errorf("渠道未连接")
 	}
-
-	log.Printf("📤 快手发送消息: %s", content)
-	return nil
+	return reply.ErrRoomMessageUnsupported
 }
 
 // IsConnected 检查是否已连接
@@ -69,7 +69,7 @@ func (k *KuaishouChannel) GetStatus() string {
 
 // simulateEvents 模拟事件
 func (k *KuaishouChannel) simulateEvents() {
-	ticker := time.NewTicker(8 * time.Second)
+	ticker := time.NewTicker(pollingFor("kuaishou").Simulate)
 	defer ticker.Stop()
 
 	eventTypes := []string{"enter", "comment", "like", "follow"}
@@ -100,8 +100,10 @@ func (k *KuaishouChannel) simulateEvents() {
 				evt.SetContent(comment)
 			}
 
+			k.count("events")
 			if err := k.pipeline.ProcessEvent(evt); err != nil {
 				log.Printf("❌ 处理事件失败: %v", err)
+				k.fail(err)
 			}
 
 			log.Printf("📨 快手事件: %s - %s", eventType, user)
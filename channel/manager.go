@@ -6,14 +6,19 @@ import (
 	"log"
 	"net/http"
 	"sync"
+	"time"
 
+	"live-im-proxy/lease"
+	"live-im-proxy/monitor"
 	"live-im-proxy/pipeline"
 )
 
 // Manager 渠道管理器
 type Manager struct {
 	pipeline      *pipeline.Pipeline
-	channels      map[string]Channel
+	sessions      map[string]Channel
+	specs         map[string]lease.SessionSpec
+	monitor       *monitor.Hub
 	mu            sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
@@ -23,109 +28,270 @@ type Manager struct {
 type Channel interface {
 	Start(roomID, accessToken string) error
 	Stop() error
+	// SendMessage 发送直播间公屏消息，渠道不支持时返回 reply.ErrRoomMessageUnsupported
 	SendMessage(content string) error
 	IsConnected() bool
 	GetStatus() string
 }
 
+// Polling 渠道轮询间隔，为0的项使用渠道内置默认值
+type Polling struct {
+	LiveComments    time.Duration
+	PrivateMessages time.Duration
+	VideoComments   time.Duration
+	Simulate        time.Duration
+}
+
+// defaultPolling 渠道内置默认轮询间隔
+var defaultPolling = map[string]Polling{
+	"douyin":      {LiveComments: 5 * time.Second, PrivateMessages: 10 * time.Second, VideoComments: 10 * time.Second},
+	"kuaishou":    {Simulate: 8 * time.Second},
+	"wechat":      {Simulate: 10 * time.Second},
+	"xiaohongshu": {Simulate: 12 * time.Second},
+}
+
+var (
+	pollingMu     sync.RWMutex
+	pollingConfig = make(map[string]Polling)
+)
+
+// SetPolling 设置渠道轮询间隔，对之后启动的渠道生效
+func SetPolling(channelType string, p Polling) {
+	pollingMu.Lock()
+	defer pollingMu.Unlock()
+	pollingConfig[channelType] = p
+}
+
+// pollingFor 读取渠道轮询间隔，未配置的项使用默认值
+func pollingFor(channelType string) Polling {
+	pollingMu.RLock()
+	p := pollingConfig[channelType]
+	pollingMu.RUnlock()
+
+	def := defaultPolling[channelType]
+	if p.LiveComments <= 0 {
+		p.LiveComments = def.LiveComments
+	}
+	if p.PrivateMessages <= 0 {
+		p.PrivateMessages = def.PrivateMessages
+	}
+	if p.VideoComments <= 0 {
+		p.VideoComments = def.VideoComments
+	}
+	if p.Simulate <= 0 {
+		p.Simulate = def.Simulate
+	}
+	return p
+}
+
 // NewManager 创建新的渠道管理器
 func NewManager(pipeline *pipeline.Pipeline) *Manager {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Manager{
 		pipeline: pipeline,
-		channels: make(map[string]Channel),
+		sessions:     make(map[string]Channel),
+		specs:        make(map[string]lease.SessionSpec),
 		ctx:      ctx,
 		cancel:   cancel,
 	}
 }
 
-// StartChannel 启动渠道
-func (m *Manager) StartChannel(channelType string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	var ch Channel
-	var err error
-
+// newChannel 按类型创建渠道实例
+func (m *Manager) newChannel(channelType string) (Channel, error) {
 	switch channelType {
 	case "douyin":
-		ch, err = NewDouyinChannel(m.pipeline)
+		return NewDouyinChannel(m.pipeline)
 	case "kuaishou":
-		ch, err = NewKuaishouChannel(m.pipeline)
+		return NewKuaishouChannel(m.pipeline)
 	case "wechat":
-		ch, err = NewWechatChannel(m.pipeline)
+		return NewWechatChannel(m.pipeline)
 	case "xiaohongshu":
-		ch, err = NewXiaohongshuChannel(m.pipeline)
+		return NewXiaohongshuChannel(m.pipeline)
 	default:
-		return fmt.Errorf("不支持的渠道类型: %s", channelType)
+		return nil, fmt.Errorf("不支持的渠道类型: %s", channelType)
+	}
+}
+
+// StartSession 在本副本上运行会话，由租约协调器在获得租约后调用
+func (m *Manager) StartSession(spec lease.SessionSpec) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessions[spec.Key]; exists {
+		return nil
 	}
 
+	ch, err := m.newChannel(spec.Platform)
 	if err != nil {
 		return err
 	}
 
-	m.channels[channelType] = ch
+	if b, ok := ch.(sessionBinder); ok {
+		b.BindSession(spec)
+	}
+	if err := startSession(ch, spec); err != nil {
+		return err
+	}
 
-	// 启动渠道
-	go func() {
-		roomID := getRoomID(channelType)
-		accessToken := "" // 临时空token，等待OAuth授权
-		if err := ch.Start(roomID, accessToken); err != nil {
-			log.Printf("❌ 渠道 %s 启动失败: %v", channelType, err)
-		}
-	}()
+	// 该会话产生的事件经由本渠道回复，多个账号的会话互不串号
+	if sender, ok := ch.(pipeline.ReplySender); ok {
+		m.pipeline.RegisterReplySender(spec.Key, sender)
+	}
 
-	log.Printf("✅ 渠道 %s 启动成功", channelType)
+	m.sessions[spec.Key] = ch
+	m.specs[spec.Key] = spec
+	log.Printf("✅ 会话启动成功: %s", spec.Key)
 	return nil
 }
 
-// StopAll 停止所有渠道
-func (m *Manager) StopAll() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// sessionBinder 需要知道所属会话（账号、租户）的渠道
+type sessionBinder interface {
+	BindSession(spec lease.SessionSpec)
+}
 
-	for name, ch := range m.channels {
-		if err := ch.Stop(); err != nil {
-			log.Printf("❌ 停止渠道 %s 失败: %v", name, err)
+// videoStarter 支持短视频评论会话的渠道
+type videoStarter interface {
+	StartVideo(videoID, accessToken string) error
+}
+
+// directMessageStarter 支持单独私信会话的渠道
+type directMessageStarter interface {
+	StartDirectMessages(accessToken string) error
+}
+
+// startSession 按会话类型启动渠道
+func startSession(ch Channel, spec lease.SessionSpec) error {
+	switch spec.SessionKind() {
+	case lease.KindLive:
+		return ch.Start(spec.RoomID, spec.AccessToken)
+	case lease.KindVideo:
+		if v, ok := ch.(videoStarter); ok {
+			return v.StartVideo(spec.VideoID, spec.AccessToken)
+		}
+	case lease.KindDM:
+		if dm, ok := ch.(directMessageStarter); ok {
+			return dm.StartDirectMessages(spec.AccessToken)
 		}
 	}
+	return fmt.Errorf("渠道 %s 不支持 %s 会话", spec.Platform, spec.SessionKind())
+}
 
-	m.cancel()
+// SupportsKind 渠道是否支持该类型的会话
+func SupportsKind(channelType, kind string) bool {
+	switch kind {
+	case "", lease.KindLive:
+		_, ok := defaultPolling[channelType]
+		return ok
+	case lease.KindVideo, lease.KindDM:
+		return channelType == "douyin"
+	}
+	return false
 }
 
-// GetChannelStatus 获取渠道状态
-func (m *Manager) GetChannelStatus() map[string]string {
+// StopSession 停止本副本上运行的会话，由租约协调器在失去租约后调用
+func (m *Manager) StopSession(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, exists := m.sessions[key]
+	if !exists {
+		return
+	}
+	if err := ch.Stop(); err != nil {
+		log.Printf("❌ 停止会话 %s 失败: %v", key, err)
+	}
+	m.pipeline.UnregisterReplySender(key)
+	delete(m.sessions, key)
+	delete(m.specs, key)
+	log.Printf("🛑 会话已停止: %s", key)
+}
+
+// GetSessionStatus 获取本副本运行的会话状态，tenantID 为空时返回全部租户
+func (m *Manager) GetSessionStatus(tenantID string) map[string]string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	status := make(map[string]string)
-	for name, ch := range m.channels {
-		status[name] = ch.GetStatus()
+	for key, ch := range m.sessions {
+		if tenantID != "" && m.specs[key].TenantID != tenantID {
+			continue
+		}
+		status[key] = ch.GetStatus()
 	}
 
 	return status
 }
 
-// WebSocketHandler WebSocket 处理器
-func (m *Manager) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	// 这里可以实现 WebSocket 连接处理
-	// 用于实时监控和调试
-	fmt.Fprintf(w, "WebSocket 连接已建立")
+// SessionStatus 本副本上运行的会话状态，实现 lease.Runner
+func (m *Manager) SessionStatus(key string) (lease.SessionStatus, bool) {
+	m.mu.RLock()
+	ch, exists := m.sessions[key]
+	m.mu.RUnlock()
+	if !exists {
+		return lease.SessionStatus{}, false
+	}
+
+	status := lease.SessionStatus{Key: key, State: lease.StateRunning}
+	if r, ok := ch.(statsReporter); ok {
+		stats := r.Stats()
+		status.Counters = stats.Counters
+		status.Breakdown = stats.Breakdown
+		if stats.LastError != "" {
+			status.LastError = stats.LastError
+			lastErrorAt := stats.LastErrorAt
+			status.LastErrorAt = &lastErrorAt
+		}
+	}
+	// 已断开且有错误记录的会话视为异常
+	if !ch.IsConnected() && status.LastError != "" {
+		status.State = lease.StateError
+	}
+	return status, true
 }
 
-// getRoomID 获取房间ID（从环境变量或配置）
-func getRoomID(channelType string) string {
-	// 实际项目中应该从环境变量或配置文件获取
-	switch channelType {
-	case "douyin":
-		return "123456789"
-	case "kuaishou":
-		return "987654321"
-	case "wechat":
-		return "456789123"
-	case "xiaohongshu":
-		return "789123456"
-	default:
-		return "000000000"
+// LiveRoom 本副本上监听该直播间的渠道，用于向直播间公屏发消息
+func (m *Manager) LiveRoom(channelType, roomID string) (Channel, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for key, spec := range m.specs {
+		if spec.Platform == channelType && spec.SessionKind() == lease.KindLive && spec.RoomID == roomID {
+			return m.sessions[key], true
+		}
+	}
+	return nil, false
+}
+
+// StopAll 停止所有渠道
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, ch := range m.sessions {
+		if err := ch.Stop(); err != nil {
+			log.Printf("❌ 停止会话 %s 失败: %v", key, err)
+		}
+	}
+
+	m.cancel()
+}
+
+// SetMonitor 设置实时监控中心
+func (m *Manager) SetMonitor(hub *monitor.Hub) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.monitor = hub
+}
+
+// WebSocketHandler WebSocket 处理器，用于实时监控和调试
+func (m *Manager) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	m.mu.RLock()
+	hub := m.monitor
+	m.mu.RUnlock()
+
+	if hub == nil {
+		http.Error(w, "实时监控未启用", http.StatusServiceUnavailable)
+		return
 	}
+	hub.ServeWS(w, r)
 }
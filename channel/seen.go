@@ -0,0 +1,77 @@
+package channel
+
+import (
+	"sync"
+	"time"
+)
+
+// 评论和私信去重参数
+const (
+	seenTTL = 24 * time.Hour // 去重记录保留时长，创建超过该时长的消息不再处理
+	seenMax = 10000          // 去重记录条数上限
+)
+
+// seenSet 已处理的评论和私信ID。
+// 创建时间早于会话启动的消息视为积压，只记录不处理，避免会话启动时回复历史评论；
+// 记录超过保留时长或条数上限时按记录顺序淘汰最早的，淘汰后重新拉取到的旧消息同样按创建时间跳过
+type seenSet struct {
+	mu      sync.Mutex
+	since   time.Time
+	ttl     time.Duration
+	max     int
+	entries map[string]time.Time // 消息ID -> 记录时间
+	order   []string             // 按记录时间排列的消息ID，最早的在前
+}
+
+// newSeenSet 创建去重记录，since 之前创建的消息不处理
+func newSeenSet(since time.Time, ttl time.Duration, max int) *seenSet {
+	return &seenSet{
+		since:   since,
+		ttl:     ttl,
+		max:     max,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// add 记录消息ID，created 为消息创建时间（秒级时间戳，0 表示未知）；
+// 消息已处理过、早于会话启动或创建超过保留时长时返回false
+func (s *seenSet) add(id string, created int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if created > 0 {
+		at := time.Unix(created, 0)
+		if at.Before(s.since.Truncate(time.Second)) || now.Sub(at) >= s.ttl {
+			return false
+		}
+	}
+	if _, ok := s.entries[id]; ok {
+		return false
+	}
+	s.entries[id] = now
+	s.order = append(s.order, id)
+	s.evict(now)
+	return true
+}
+
+// len 当前记录数
+func (s *seenSet) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// evict 从最早的记录开始淘汰过期和超出条数上限的记录，需在锁内调用
+func (s *seenSet) evict(now time.Time) {
+	for len(s.order) > 0 {
+		oldest := s.order[0]
+		full := s.max > 0 && len(s.entries) > s.max
+		if !full && now.Sub(s.entries[oldest]) < s.ttl {
+			return
+		}
+		delete(s.entries, oldest)
+		s.order[0] = ""
+		s.order = s.order[1:]
+	}
+}
@@ -0,0 +1,61 @@
+package channel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSeenSetSkipsBacklogAndDuplicates(t *testing.T) {
+	start := time.Now()
+	s := newSeenSet(start, time.Hour, 100)
+
+	if s.add("backlog", start.Add(-time.Minute).Unix()) {
+		t.Fatal("会话启动前的评论不应处理")
+	}
+	if !s.add("new", start.Unix()) {
+		t.Fatal("会话启动后的评论应处理")
+	}
+	if s.add("new", start.Unix()) {
+		t.Fatal("重复的评论不应处理")
+	}
+	if !s.add("unknown-time", 0) {
+		t.Fatal("没有创建时间的消息按ID去重后处理")
+	}
+}
+
+func TestSeenSetSkipsExpiredMessages(t *testing.T) {
+	s := newSeenSet(time.Now().Add(-2*time.Hour), time.Hour, 100)
+	if s.add("old", time.Now().Add(-90*time.Minute).Unix()) {
+		t.Fatal("创建超过保留时长的消息不应处理")
+	}
+}
+
+func TestSeenSetEvictsOldest(t *testing.T) {
+	start := time.Now()
+	s := newSeenSet(start, time.Hour, 3)
+	for i := 0; i < 5; i++ {
+		s.add(fmt.Sprintf("comment-%d", i), start.Unix())
+		time.Sleep(time.Millisecond)
+	}
+	if s.len() != 3 {
+		t.Fatalf("len = %d, 应不超过条数上限", s.len())
+	}
+	if s.add("comment-4", start.Unix()) {
+		t.Fatal("最近的记录不应被淘汰")
+	}
+}
+
+func TestSeenSetDropsExpiredInOrder(t *testing.T) {
+	s := newSeenSet(time.Now().Add(-time.Hour), 20*time.Millisecond, 0)
+	s.add("first", 0)
+	s.add("second", 0)
+	time.Sleep(30 * time.Millisecond)
+	s.add("third", 0)
+	if s.len() != 1 {
+		t.Fatalf("len = %d, 过期记录应按记录顺序淘汰", s.len())
+	}
+	if !s.add("first", 0) {
+		t.Fatal("淘汰后的记录应可重新记录")
+	}
+}
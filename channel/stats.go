@@ -0,0 +1,84 @@
+package channel
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats 渠道运行统计
+type Stats struct {
+	Counters    map[string]int64
+	Breakdown   map[string]map[string]int64 // 按目标（如视频ID）细分的计数
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// statsReporter 能上报运行统计的渠道
+type statsReporter interface {
+	Stats() Stats
+}
+
+// channelStats 渠道运行计数，嵌入各渠道结构体
+type channelStats struct {
+	statsMu     sync.Mutex
+	counters    map[string]int64
+	breakdown   map[string]map[string]int64
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// count 计数加一
+func (s *channelStats) count(name string) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]int64)
+	}
+	s.counters[name]++
+}
+
+// countTarget 按目标计数加一
+func (s *channelStats) countTarget(target, name string) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.breakdown == nil {
+		s.breakdown = make(map[string]map[string]int64)
+	}
+	if s.breakdown[target] == nil {
+		s.breakdown[target] = make(map[string]int64)
+	}
+	s.breakdown[target][name]++
+}
+
+// fail 记录错误并计数
+func (s *channelStats) fail(err error) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]int64)
+	}
+	s.counters["errors"]++
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+// Stats 运行统计快照
+func (s *channelStats) Stats() Stats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	counters := make(map[string]int64, len(s.counters))
+	for name, n := range s.counters {
+		counters[name] = n
+	}
+	var breakdown map[string]map[string]int64
+	if len(s.breakdown) > 0 {
+		breakdown = make(map[string]map[string]int64, len(s.breakdown))
+		for target, named := range s.breakdown {
+			breakdown[target] = make(map[string]int64, len(named))
+			for name, n := range named {
+				breakdown[target][name] = n
+			}
+		}
+	}
+	return Stats{Counters: counters, Breakdown: breakdown, LastError: s.lastError, LastErrorAt: s.lastErrorAt}
+}
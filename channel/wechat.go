@@ -7,6 +7,7 @@ import (
 
 	"live-im-proxy/event"
 	"live-im-proxy/pipeline"
+	"live-im-proxy/reply"
 )
 
 // WechatChannel 微信视频号渠道
@@ -14,6 +15,7 @@ type WechatChannel struct {
 	pipeline *pipeline.Pipeline
 	roomID   string
 	connected bool
+	channelStats
 }
 
 // NewWechatChannel 创建微信渠道
@@ -44,14 +46,12 @@ func (w *WechatChannel) Stop() error {
 	return nil
 }
 
-// SendMessage 发送消息
+// SendMessage 视频号直播未接入发送接口
 func (w *WechatChannel) SendMessage(content string) error {
 	if !w.connected {
 		return fmt.Errorf("渠道未连接")
 	}
-
-	log.Printf("📤 微信发送消息: %s", content)
-	return nil
+	return reply.ErrRoomMessageUnsupported
 }
 
 // IsConnected 检查是否已连接
@@ -69,7 +69,7 @@ func (w *WechatChannel) GetStatus() string {
 
 // simulateEvents 模拟事件
 func (w *WechatChannel) simulateEvents() {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(pollingFor("wechat").Simulate)
 	defer ticker.Stop()
 
 	eventTypes := []string{"enter", "comment", "like", "follow"}
@@ -100,8 +100,10 @@ func (w *WechatChannel) simulateEvents() {
 				evt.SetContent(comment)
 			}
 
+			w.count("events")
 			if err := w.pipeline.ProcessEvent(evt); err != nil {
 				log.Printf("❌ 处理事件失败: %v", err)
+				w.fail(err)
 			}
 
 			log.Printf("📨 微信事件: %s - %s", eventType, user)
@@ -7,6 +7,7 @@ import (
 
 	"live-im-proxy/event"
 	"live-im-proxy/pipeline"
+	"live-im-proxy/reply"
 )
 
 // XiaohongshuChannel 小红书渠道
@@ -14,6 +15,7 @@ type XiaohongshuChannel struct {
 	pipeline *pipeline.Pipeline
 	roomID   string
 	connected bool
+	channelStats
 }
 
 // NewXiaohongshuChannel 创建小红书渠道
@@ -44,14 +46,12 @@ func (x *XiaohongshuChannel) Stop() error {
 	return nil
 }
 
-// SendMessage 发送消息
+// SendMessage 小红书直播未接入发送接口
 func (x *XiaohongshuChannel) SendMessage(content string) error {
 	if !x.connected {
 		return fmt.Errorf("渠道未连接")
 	}
-
-	log.Printf("📤 小红书发送消息: %s", content)
-	return nil
+	return reply.ErrRoomMessageUnsupported
 }
 
 // IsConnected 检查是否已连接
@@ -69,7 +69,7 @@ func (x *XiaohongshuChannel) GetStatus() string {
 
 // simulateEvents 模拟事件
 func (x *XiaohongshuChannel) simulateEvents() {
-	ticker := time.NewTicker(12 * time.Second)
+	ticker := time.NewTicker(pollingFor("xiaohongshu").Simulate)
 	defer ticker.Stop()
 
 	eventTypes := []string{"enter", "comment", "like", "follow"}
@@ -101,8 +101,10 @@ func (x *XiaohongshuChannel) simulateEvents() {
 				evt.SetContent(comment)
 			}
 
+			x.count("events")
 			if err := x.pipeline.ProcessEvent(evt); err != nil {
 				log.Printf("❌ 处理事件失败: %v", err)
+				x.fail(err)
 			}
 
 			log.Printf("📨 小红书事件: %s - %s", eventType, user)
@@ -0,0 +1,1035 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"live-im-proxy/breaker"
+	"live-im-proxy/broadcast"
+	"live-im-proxy/discovery"
+	"live-im-proxy/gifts"
+	"live-im-proxy/handoff"
+	"live-im-proxy/intent"
+	"live-im-proxy/knowledge"
+	"live-im-proxy/lease"
+	"live-im-proxy/pipeline"
+	"live-im-proxy/policy"
+	"live-im-proxy/replycache"
+	"live-im-proxy/safety"
+	"live-im-proxy/spam"
+	"live-im-proxy/welcome"
+)
+
+// ChannelTypes 支持的渠道类型
+var ChannelTypes = []string{"douyin", "kuaishou", "wechat", "xiaohongshu"}
+
+// RoomMessageChannels 能向直播间发送公屏消息的渠道，定时消息只能配置在这些渠道上
+var RoomMessageChannels = []string{"douyin"}
+
+// Config 服务配置，来源优先级：环境变量 > 配置文件 > 默认值
+type Config struct {
+	Server        Server    `json:"server"`
+	Redis         Redis     `json:"redis"`
+	Lease         Lease     `json:"lease"`
+	Providers     Providers `json:"providers"`
+	DefaultTenant string    `json:"default_tenant"`
+	Tenants       []Tenant  `json:"tenants"`
+	Channels      []Channel `json:"channels"`
+	Limits        Limits    `json:"limits"`
+	Reply         Reply     `json:"reply"`
+	Discovery     Discovery `json:"discovery"`
+	Broadcast     Broadcast `json:"broadcast"`
+}
+
+// Server HTTP服务与本副本配置
+type Server struct {
+	Port          string `json:"port"`
+	AuditLogPath  string `json:"audit_log_path"`
+	HistoryPath   string `json:"history_path"`
+	HistoryDays   int    `json:"history_days"`   // 会话记录保留天数，0使用默认30天
+	AccountPath   string `json:"account_path"`   // 授权账号持久化文件，令牌加密保存
+	AdminOrigin   string `json:"admin_origin"`   // 管理后台地址，授权回调页只向该来源通知结果，/ws 接受该来源的浏览器连接
+	APIKeyPath    string `json:"api_key_path"`   // API密钥持久化文件（只保存哈希）
+	CatalogPath   string `json:"catalog_path"`   // 商品目录持久化文件
+	KnowledgePath string `json:"knowledge_path"` // 知识库持久化文件
+	HandoffPath   string `json:"handoff_path"`   // 转人工会话状态文件，多进程部署时放在共享目录
+	SpamListPath  string `json:"spam_list_path"` // 反垃圾黑白名单持久化文件
+	AdminAPIKey   string `json:"-"`              // 引导管理员密钥，只能通过环境变量 ADMIN_API_KEY 设置
+	ReplicaID     string `json:"replica_id"`
+	ReplicaCount  int    `json:"replica_count"`
+}
+
+// Redis 共享存储，URL 为空时不使用，限流和租约只在本实例内生效
+type Redis struct {
+	URL string `json:"url"`
+}
+
+// Lease 会话租约存储
+type Lease struct {
+	Backend string `json:"backend"` // memory, file, redis
+	Dir     string `json:"dir"`     // file 后端的目录
+}
+
+// Providers 外部服务
+type Providers struct {
+	Coze     Coze     `json:"coze"`
+	NocoBase NocoBase `json:"nocobase"`
+	Douyin   Douyin   `json:"douyin"`
+}
+
+// Coze AI引擎
+type Coze struct {
+	API   string `json:"api"`
+	Token string `json:"token"`
+	BotID string `json:"bot_id"`
+}
+
+// NocoBase CRM
+type NocoBase struct {
+	API   string `json:"api"`
+	Token string `json:"token"`
+}
+
+// Douyin 抖音开放平台应用
+type Douyin struct {
+	AppID       string `json:"app_id"`
+	AppSecret   string `json:"app_secret"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// Tenant 租户
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Channel 渠道配置
+type Channel struct {
+	Type    string  `json:"type"`
+	Enabled bool    `json:"enabled"` // 启动时自动监听配置的直播间
+	Rooms   []Room  `json:"rooms"`
+	Polling Polling `json:"polling"`
+}
+
+// Room 直播间
+type Room struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"` // 授权账号 open_id
+}
+
+// SessionSource 配置文件中的直播间对应的会话来源
+const SessionSource = "config"
+
+// SessionSpecs 启用的渠道中的直播间，作为会话提交给租约协调器，多副本时每个直播间只在一个副本上运行。
+// token 按 account_id 查询默认租户下已授权账号的 access_token，账号尚未授权的直播间放入 pending，授权后重新提交
+func (c *Config) SessionSpecs(token func(accountID string) (string, bool)) (specs []lease.SessionSpec, pending []Room) {
+	for _, ch := range c.Channels {
+		if !ch.Enabled {
+			continue
+		}
+		for _, room := range ch.Rooms {
+			spec := lease.SessionSpec{
+				Key:       lease.SessionKeyFor(c.DefaultTenant, ch.Type, lease.KindLive, room.AccountID, room.ID),
+				TenantID:  c.DefaultTenant,
+				Platform:  ch.Type,
+				Kind:      lease.KindLive,
+				AccountID: room.AccountID,
+				RoomID:    room.ID,
+				Source:    SessionSource,
+			}
+			if room.AccountID != "" {
+				accessToken, ok := token(room.AccountID)
+				if !ok {
+					pending = append(pending, room)
+					continue
+				}
+				spec.AccessToken = accessToken
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs, pending
+}
+
+// Polling 轮询间隔，未配置的项使用渠道内置默认值
+type Polling struct {
+	LiveComments    Duration `json:"live_comments"`
+	PrivateMessages Duration `json:"private_messages"`
+	VideoComments   Duration `json:"video_comments"`
+	Simulate        Duration `json:"simulate"` // 模拟事件间隔
+}
+
+// Limits 外部接口限流（全部副本合计）
+type Limits struct {
+	Coze   Rate `json:"coze"`   // 每个租户
+	Douyin Rate `json:"douyin"` // 抖音开放平台应用
+}
+
+// Rate 速率与突发
+type Rate struct {
+	Rate  float64 `json:"rate"` // 每秒
+	Burst int     `json:"burst"`
+}
+
+// Reply 回复规则，SIGHUP 时可热加载
+type Reply struct {
+	Safety    safety.Config    `json:"safety"`
+	Policy    policy.Config    `json:"policy"`
+	Spam      Spam             `json:"spam"`
+	Handoff   Handoff          `json:"handoff"`
+	Gifts     Gifts            `json:"gifts"`
+	Welcome   Welcome          `json:"welcome"`
+	Knowledge knowledge.Config `json:"knowledge"`
+	Cache     Cache            `json:"cache"`
+	Fallback  Fallback         `json:"fallback"`
+}
+
+// Spam 入站反垃圾规则
+type Spam struct {
+	FloodWindow     Duration          `json:"flood_window"`
+	FloodMax        int               `json:"flood_max"`
+	DuplicateWindow Duration          `json:"duplicate_window"`
+	AdPatterns      []string          `json:"ad_patterns"`
+	Actions         map[string]string `json:"actions"`
+}
+
+// Handoff 人机切换规则
+type Handoff struct {
+	Keywords       []string `json:"keywords"`
+	Intents        []string `json:"intents"`         // 转人工的意图：complaint、after_sales、price、purchase、logistics
+	ScoreThreshold int      `json:"score_threshold"` // 会话评分阈值，评分随每条新消息减半后累加
+	IdleTimeout    Duration `json:"idle_timeout"`
+	Notice         string   `json:"notice"`
+}
+
+// Gifts 礼物和点赞感谢规则
+type Gifts struct {
+	Thank         bool     `json:"thank"`          // 是否发送礼物感谢
+	MinValue      int64    `json:"min_value"`      // 单次赠送总价值不低于该值才感谢
+	Window        Duration `json:"window"`         // 感谢合并窗口
+	MaxNames      int      `json:"max_names"`      // 一条感谢最多点名的条目数
+	Template      string   `json:"template"`       // 礼物感谢模板，{gifts} 为礼物列表
+	LikeWindow    Duration `json:"like_window"`    // 点赞按用户聚合的窗口
+	LikeThreshold int64    `json:"like_threshold"` // 窗口内点赞数达到该值才感谢，0表示不感谢
+	LikeTemplate  string   `json:"like_template"`  // 点赞感谢模板，{names} 为昵称列表
+}
+
+// Welcome 进场欢迎和关注感谢规则
+type Welcome struct {
+	Enabled        bool     `json:"enabled"`
+	Window         Duration `json:"window"`          // 合并窗口
+	MinInterval    Duration `json:"min_interval"`    // 同一直播间两条欢迎的最小间隔
+	UserCooldown   Duration `json:"user_cooldown"`   // 同一用户重复进场不再欢迎的时长
+	MaxNames       int      `json:"max_names"`       // 一条消息最多点名的用户数
+	Template       string   `json:"template"`        // 普通用户欢迎模板，{names} 为昵称列表
+	VIPTemplate    string   `json:"vip_template"`    // 老朋友和高意向用户的欢迎模板
+	ScoreThreshold int      `json:"score_threshold"` // 历史线索评分达到该值视为高意向用户
+	ThankFollows   bool     `json:"thank_follows"`   // 是否感谢新关注
+	FollowTemplate string   `json:"follow_template"` // 关注感谢模板
+}
+
+// Cache 回复缓存规则
+type Cache struct {
+	Enabled    bool     `json:"enabled"`
+	TTL        Duration `json:"ttl"`         // 相同问题复用回复的时长
+	MaxEntries int      `json:"max_entries"` // 缓存条数上限
+}
+
+// Fallback 回复降级链的延迟预算和AI熔断规则
+type Fallback struct {
+	Budget          Duration            `json:"budget"`           // 生成一条回复的延迟预算，超时降级到规则回复
+	Channels        map[string]Duration `json:"channels"`         // 按渠道覆盖延迟预算
+	BreakerFailures int                 `json:"breaker_failures"` // AI连续失败该次数后熔断，0表示不熔断
+	BreakerCooldown Duration            `json:"breaker_cooldown"` // 熔断后经过该时长试探恢复
+}
+
+// Discovery 短视频自动发现策略，SIGHUP 时可热加载
+type Discovery struct {
+	Enabled     bool     `json:"enabled"`
+	Interval    Duration `json:"interval"`     // 扫描间隔
+	MaxAgeDays  int      `json:"max_age_days"` // 只监听最近N天发布的视频，0表示不限
+	MinComments int64    `json:"min_comments"` // 评论数不少于该值才监听
+	Pinned      bool     `json:"pinned"`       // 置顶视频始终监听
+	MaxVideos   int      `json:"max_videos"`   // 每个账号最多监听的视频数
+	MaxPages    int      `json:"max_pages"`    // 每次扫描最多翻页数（每页20个）
+}
+
+// Broadcast 直播间定时公屏消息，SIGHUP 时可热加载
+type Broadcast struct {
+	Timezone  string              `json:"timezone"` // at 使用的时区
+	Schedules []BroadcastSchedule `json:"schedules"`
+}
+
+// BroadcastSchedule 单个直播间的定时消息计划
+type BroadcastSchedule struct {
+	ID         string   `json:"id"`
+	TenantID   string   `json:"tenant_id"` // 为空时归入默认租户
+	Channel    string   `json:"channel"`
+	RoomID     string   `json:"room_id"`
+	Interval   Duration `json:"interval"`    // 发送间隔，0表示只按 at 发送
+	At         []string `json:"at"`          // 每天的发送时间，如 ["20:00", "21:30"]
+	Messages   []string `json:"messages"`    // 轮流发送的文案
+	QuietAfter Duration `json:"quiet_after"` // 直播间超过该时长没有互动时暂停，0表示不检查
+	Enabled    bool     `json:"enabled"`
+}
+
+// Duration 支持 "10s"、"5m" 形式或以秒为单位的数字
+type Duration time.Duration
+
+// UnmarshalJSON 解析时长
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("无效的时长 %q", s)
+		}
+		*d = Duration(v)
+		return nil
+	}
+	var sec float64
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return fmt.Errorf("无效的时长 %s", data)
+	}
+	*d = Duration(sec * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON 输出为 "10s" 形式
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std 转为 time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Default 默认配置
+func Default() *Config {
+	spamConfig := spam.DefaultConfig()
+	handoffConfig := handoff.DefaultConfig()
+	giftsConfig := gifts.DefaultConfig()
+	welcomeConfig := welcome.DefaultConfig()
+	cacheConfig := replycache.DefaultConfig()
+	fallbackConfig := pipeline.DefaultFallbackConfig()
+	discoveryConfig := discovery.DefaultConfig()
+
+	c := &Config{
+		Server: Server{
+			Port:         "8080",
+			ReplicaCount: 1,
+		},
+		Lease: Lease{Backend: "memory", Dir: "./data/lease"},
+		Providers: Providers{
+			Coze:   Coze{API: "https://api.coze.com/open/v1"},
+			Douyin: Douyin{RedirectURI: "http://localhost:8080/oauth/callback"},
+		},
+		DefaultTenant: "tenant-1",
+		Tenants:       []Tenant{{ID: "tenant-1"}},
+		Limits: Limits{
+			Coze:   Rate{Rate: 10, Burst: 20},
+			Douyin: Rate{Rate: 20, Burst: 40},
+		},
+		Reply: Reply{
+			Safety: safety.DefaultConfig(),
+			Policy: policy.DefaultConfig(),
+			Spam: Spam{
+				FloodWindow:     Duration(spamConfig.FloodWindow),
+				FloodMax:        spamConfig.FloodMax,
+				DuplicateWindow: Duration(spamConfig.DuplicateWindow),
+				AdPatterns:      spamConfig.AdPatterns,
+				Actions:         spamConfig.Actions,
+			},
+			Handoff: Handoff{
+				Keywords:       handoffConfig.Keywords,
+				Intents:        handoffConfig.Intents,
+				ScoreThreshold: handoffConfig.ScoreThreshold,
+				IdleTimeout:    Duration(handoffConfig.IdleTimeout),
+				Notice:         handoffConfig.Notice,
+			},
+			Gifts: Gifts{
+				Thank:         giftsConfig.ThankGifts,
+				MinValue:      giftsConfig.MinValue,
+				Window:        Duration(giftsConfig.ThankWindow),
+				MaxNames:      giftsConfig.MaxNames,
+				Template:      giftsConfig.GiftTemplate,
+				LikeWindow:    Duration(giftsConfig.LikeWindow),
+				LikeThreshold: giftsConfig.LikeThreshold,
+				LikeTemplate:  giftsConfig.LikeTemplate,
+			},
+			Welcome: Welcome{
+				Enabled:        welcomeConfig.Enabled,
+				Window:         Duration(welcomeConfig.Window),
+				MinInterval:    Duration(welcomeConfig.MinInterval),
+				UserCooldown:   Duration(welcomeConfig.UserCooldown),
+				MaxNames:       welcomeConfig.MaxNames,
+				Template:       welcomeConfig.Template,
+				VIPTemplate:    welcomeConfig.VIPTemplate,
+				ScoreThreshold: welcomeConfig.ScoreThreshold,
+				ThankFollows:   welcomeConfig.ThankFollows,
+				FollowTemplate: welcomeConfig.FollowTemplate,
+			},
+			Knowledge: knowledge.DefaultConfig(),
+			Cache: Cache{
+				Enabled:    cacheConfig.Enabled,
+				TTL:        Duration(cacheConfig.TTL),
+				MaxEntries: cacheConfig.MaxEntries,
+			},
+			Fallback: Fallback{
+				Budget:          Duration(fallbackConfig.Budget),
+				Channels:        map[string]Duration{},
+				BreakerFailures: fallbackConfig.Breaker.Failures,
+				BreakerCooldown: Duration(fallbackConfig.Breaker.Cooldown),
+			},
+		},
+		Discovery: Discovery{
+			Enabled:     discoveryConfig.Enabled,
+			Interval:    Duration(discoveryConfig.Interval),
+			MaxAgeDays:  int(discoveryConfig.MaxAge / (24 * time.Hour)),
+			MinComments: discoveryConfig.MinComments,
+			Pinned:      discoveryConfig.IncludePinned,
+			MaxVideos:   discoveryConfig.MaxVideos,
+			MaxPages:    discoveryConfig.MaxPages,
+		},
+		Broadcast: Broadcast{Timezone: "Asia/Shanghai"},
+	}
+	for _, channelType := range ChannelTypes {
+		c.Channels = append(c.Channels, Channel{Type: channelType})
+	}
+	return c
+}
+
+// Load 加载配置：path 为空时只使用默认值和环境变量，支持 .yaml/.yml/.json
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %v", err)
+		}
+		if err := decode(path, data, c); err != nil {
+			return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+		}
+	}
+
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	c.fillDefaults()
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decode 解析配置文件，YAML 先转为 JSON 以复用各模块的 json 标签
+func decode(path string, data []byte, c *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		if raw == nil {
+			return nil
+		}
+		converted, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		data = converted
+	case ".json":
+	default:
+		return fmt.Errorf("不支持的配置文件格式，请使用 .yaml 或 .json")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// applyEnv 环境变量覆盖，兼容原有的环境变量配置方式
+func (c *Config) applyEnv() error {
+	overrides := []struct {
+		key    string
+		target *string
+	}{
+		{"PORT", &c.Server.Port},
+		{"AUDIT_LOG_PATH", &c.Server.AuditLogPath},
+		{"HISTORY_PATH", &c.Server.HistoryPath},
+		{"ACCOUNT_PATH", &c.Server.AccountPath},
+		{"ADMIN_ORIGIN", &c.Server.AdminOrigin},
+		{"API_KEY_PATH", &c.Server.APIKeyPath},
+		{"CATALOG_PATH", &c.Server.CatalogPath},
+		{"KNOWLEDGE_PATH", &c.Server.KnowledgePath},
+		{"HANDOFF_PATH", &c.Server.HandoffPath},
+		{"SPAM_LIST_PATH", &c.Server.SpamListPath},
+		{"ADMIN_API_KEY", &c.Server.AdminAPIKey},
+		{"REPLICA_ID", &c.Server.ReplicaID},
+		{"REDIS_URL", &c.Redis.URL},
+		{"LEASE_BACKEND", &c.Lease.Backend},
+		{"LEASE_DIR", &c.Lease.Dir},
+		{"COZE_API", &c.Providers.Coze.API},
+		{"COZE_TOKEN", &c.Providers.Coze.Token},
+		{"COZE_BOT_ID", &c.Providers.Coze.BotID},
+		{"NB_API", &c.Providers.NocoBase.API},
+		{"NB_TOKEN", &c.Providers.NocoBase.Token},
+		{"DOUYIN_APP_ID", &c.Providers.Douyin.AppID},
+		{"DOUYIN_APP_SECRET", &c.Providers.Douyin.AppSecret},
+		{"REDIRECT_URI", &c.Providers.Douyin.RedirectURI},
+	}
+	for _, o := range overrides {
+		if value := os.Getenv(o.key); value != "" {
+			*o.target = value
+		}
+	}
+
+	if value := os.Getenv("REPLICA_COUNT"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("环境变量 REPLICA_COUNT 不是有效整数: %q", value)
+		}
+		c.Server.ReplicaCount = n
+	}
+
+	if tenantID := os.Getenv("TENANT_ID"); tenantID != "" {
+		c.DefaultTenant = tenantID
+		if c.Tenant(tenantID) == nil {
+			c.Tenants = append(c.Tenants, Tenant{ID: tenantID})
+		}
+	}
+
+	// 独立的回复规则文件（早于统一配置文件引入），设置时覆盖对应部分
+	if path := os.Getenv("SAFETY_CONFIG"); path != "" {
+		safetyConfig, err := safety.LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		c.Reply.Safety = safetyConfig
+	}
+	if path := os.Getenv("REPLY_POLICY_CONFIG"); path != "" {
+		policyConfig, err := policy.LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		c.Reply.Policy = policyConfig
+	}
+	return nil
+}
+
+// fillDefaults 补全配置文件中省略的项
+func (c *Config) fillDefaults() {
+	if c.Server.ReplicaID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "replica"
+		}
+		c.Server.ReplicaID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+}
+
+// Validate 校验配置，返回所有问题而不只是第一个
+func (c *Config) Validate() error {
+	var errs []string
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		fail("server.port", "无效的端口 %q", c.Server.Port)
+	}
+	if c.Server.HistoryDays < 0 {
+		fail("server.history_days", "不能为负数")
+	}
+	if c.Server.ReplicaCount < 1 {
+		fail("server.replica_count", "必须大于0")
+	}
+
+	if c.Redis.URL != "" {
+		if u, err := url.Parse(c.Redis.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			fail("redis.url", "必须是 redis:// 或 rediss:// 地址")
+		}
+	}
+
+	switch c.Lease.Backend {
+	case "memory":
+	case "file":
+		if c.Lease.Dir == "" {
+			fail("lease.dir", "file 后端必须配置目录")
+		}
+	case "redis":
+		if c.Redis.URL == "" {
+			fail("lease.backend", "redis 后端必须配置 redis.url")
+		}
+	default:
+		fail("lease.backend", "未知的后端 %q，可选 memory、file、redis", c.Lease.Backend)
+	}
+
+	endpoints := []struct{ field, raw string }{
+		{"providers.coze.api", c.Providers.Coze.API},
+		{"providers.nocobase.api", c.Providers.NocoBase.API},
+		{"providers.douyin.redirect_uri", c.Providers.Douyin.RedirectURI},
+		{"server.admin_origin", c.Server.AdminOrigin},
+	}
+	for _, e := range endpoints {
+		if e.raw == "" {
+			continue
+		}
+		if u, err := url.Parse(e.raw); err != nil || u.Scheme == "" || u.Host == "" {
+			fail(e.field, "无效的地址 %q", e.raw)
+		}
+	}
+
+	tenants := make(map[string]bool)
+	for i, t := range c.Tenants {
+		switch {
+		case t.ID == "":
+			fail(fmt.Sprintf("tenants[%d].id", i), "不能为空")
+		case tenants[t.ID]:
+			fail(fmt.Sprintf("tenants[%d].id", i), "重复的租户 %q", t.ID)
+		}
+		tenants[t.ID] = true
+	}
+	if !tenants[c.DefaultTenant] {
+		fail("default_tenant", "租户 %q 未在 tenants 中定义", c.DefaultTenant)
+	}
+
+	channels := make(map[string]bool)
+	for i, ch := range c.Channels {
+		prefix := fmt.Sprintf("channels[%d]", i)
+		if !isChannelType(ch.Type) {
+			fail(prefix+".type", "不支持的渠道类型 %q，可选 %s", ch.Type, strings.Join(ChannelTypes, "、"))
+		}
+		if channels[ch.Type] {
+			fail(prefix+".type", "重复的渠道 %q", ch.Type)
+		}
+		channels[ch.Type] = true
+
+		rooms := make(map[string]bool)
+		for j, room := range ch.Rooms {
+			switch {
+			case room.ID == "":
+				fail(fmt.Sprintf("%s.rooms[%d].id", prefix, j), "不能为空")
+			case rooms[room.ID]:
+				fail(fmt.Sprintf("%s.rooms[%d].id", prefix, j), "重复的直播间 %q", room.ID)
+			case ch.Type == "douyin" && room.AccountID == "":
+				// 抖音直播间只能用授权账号的 access_token 监听
+				fail(fmt.Sprintf("%s.rooms[%d].account_id", prefix, j), "抖音直播间必须绑定授权账号的 open_id")
+			}
+			rooms[room.ID] = true
+		}
+
+		intervals := []struct {
+			field string
+			d     Duration
+		}{
+			{"live_comments", ch.Polling.LiveComments},
+			{"private_messages", ch.Polling.PrivateMessages},
+			{"video_comments", ch.Polling.VideoComments},
+			{"simulate", ch.Polling.Simulate},
+		}
+		for _, i := range intervals {
+			if i.d != 0 && i.d.Std() < time.Second {
+				fail(prefix+".polling."+i.field, "不能小于1s")
+			}
+		}
+
+		if ch.Type == "douyin" && ch.Enabled && (c.Providers.Douyin.AppID == "" || c.Providers.Douyin.AppSecret == "") {
+			fail("providers.douyin", "启用抖音渠道必须配置 app_id 和 app_secret")
+		}
+	}
+
+	rates := []struct {
+		field string
+		r     Rate
+	}{
+		{"limits.coze", c.Limits.Coze},
+		{"limits.douyin", c.Limits.Douyin},
+	}
+	for _, r := range rates {
+		if r.r.Rate <= 0 {
+			fail(r.field+".rate", "必须大于0")
+		}
+		if r.r.Burst < 1 {
+			fail(r.field+".burst", "必须大于等于1")
+		}
+	}
+
+	if err := c.Reply.Safety.Validate(); err != nil {
+		fail("reply.safety", "%v", err)
+	}
+	if err := c.Reply.Policy.Validate(); err != nil {
+		fail("reply.policy", "%v", err)
+	}
+	if c.Reply.Spam.FloodWindow <= 0 || c.Reply.Spam.DuplicateWindow <= 0 {
+		fail("reply.spam", "flood_window 和 duplicate_window 必须大于0")
+	}
+	if c.Reply.Spam.FloodMax < 1 {
+		fail("reply.spam.flood_max", "必须大于等于1")
+	}
+	for _, p := range c.Reply.Spam.AdPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			fail("reply.spam.ad_patterns", "无效的正则 %q: %v", p, err)
+		}
+	}
+	reasons := make([]string, 0, len(c.Reply.Spam.Actions))
+	for reason := range c.Reply.Spam.Actions {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		action := c.Reply.Spam.Actions[reason]
+		if action != spam.ActionDrop && action != spam.ActionTag {
+			fail("reply.spam.actions."+reason, "未知的处置动作 %q，可选 drop、tag", action)
+		}
+	}
+	for _, name := range c.Reply.Handoff.Intents {
+		if !intent.Known(name) {
+			fail("reply.handoff.intents", "未知的意图 %q", name)
+		}
+	}
+	if c.Reply.Handoff.ScoreThreshold < 0 {
+		fail("reply.handoff.score_threshold", "不能为负数")
+	}
+	if c.Reply.Handoff.IdleTimeout < 0 {
+		fail("reply.handoff.idle_timeout", "不能为负数")
+	}
+	if c.Reply.Gifts.MinValue < 0 || c.Reply.Gifts.LikeThreshold < 0 {
+		fail("reply.gifts", "min_value 和 like_threshold 不能为负数")
+	}
+	if c.Reply.Gifts.Window <= 0 || c.Reply.Gifts.LikeWindow <= 0 {
+		fail("reply.gifts", "window 和 like_window 必须大于0")
+	}
+	if c.Reply.Gifts.MaxNames < 1 {
+		fail("reply.gifts.max_names", "必须大于等于1")
+	}
+	if c.Reply.Gifts.Thank && !strings.Contains(c.Reply.Gifts.Template, "{gifts}") {
+		fail("reply.gifts.template", "需要包含 {gifts} 占位符")
+	}
+	if c.Reply.Gifts.LikeThreshold > 0 && !strings.Contains(c.Reply.Gifts.LikeTemplate, "{names}") {
+		fail("reply.gifts.like_template", "需要包含 {names} 占位符")
+	}
+	if c.Reply.Welcome.Window < 0 || c.Reply.Welcome.MinInterval < 0 || c.Reply.Welcome.UserCooldown < 0 {
+		fail("reply.welcome", "window、min_interval 和 user_cooldown 不能为负数")
+	}
+	if c.Reply.Welcome.MaxNames < 1 {
+		fail("reply.welcome.max_names", "必须大于等于1")
+	}
+	if c.Reply.Welcome.ScoreThreshold < 0 {
+		fail("reply.welcome.score_threshold", "不能为负数")
+	}
+	if c.Reply.Welcome.Enabled {
+		templates := []struct{ field, value string }{
+			{"template", c.Reply.Welcome.Template},
+			{"vip_template", c.Reply.Welcome.VIPTemplate},
+			{"follow_template", c.Reply.Welcome.FollowTemplate},
+		}
+		for _, t := range templates {
+			if !strings.Contains(t.value, "{names}") {
+				fail("reply.welcome."+t.field, "需要包含 {names} 占位符")
+			}
+		}
+	}
+
+	knowledgeConfig := c.Reply.Knowledge
+	if knowledgeConfig.AnswerThreshold < 0 || knowledgeConfig.AnswerThreshold > 1 {
+		fail("reply.knowledge.answer_threshold", "必须在0到1之间")
+	}
+	if knowledgeConfig.ContextThreshold < 0 || knowledgeConfig.ContextThreshold > knowledgeConfig.AnswerThreshold {
+		fail("reply.knowledge.context_threshold", "必须在0到 answer_threshold 之间")
+	}
+	if knowledgeConfig.ContextSize < 0 {
+		fail("reply.knowledge.context_size", "不能为负数")
+	}
+
+	if c.Reply.Cache.Enabled && c.Reply.Cache.TTL <= 0 {
+		fail("reply.cache.ttl", "必须大于0")
+	}
+	if c.Reply.Cache.MaxEntries < 0 {
+		fail("reply.cache.max_entries", "不能为负数")
+	}
+
+	if c.Reply.Fallback.Budget <= 0 {
+		fail("reply.fallback.budget", "必须大于0")
+	}
+	budgetChannels := make([]string, 0, len(c.Reply.Fallback.Channels))
+	for channel := range c.Reply.Fallback.Channels {
+		budgetChannels = append(budgetChannels, channel)
+	}
+	sort.Strings(budgetChannels)
+	for _, channel := range budgetChannels {
+		switch {
+		case !isChannelType(channel):
+			fail("reply.fallback.channels", "不支持的渠道类型 %q，可选 %s", channel, strings.Join(ChannelTypes, "、"))
+		case c.Reply.Fallback.Channels[channel] <= 0:
+			fail("reply.fallback.channels."+channel, "必须大于0")
+		}
+	}
+	if c.Reply.Fallback.BreakerFailures < 0 {
+		fail("reply.fallback.breaker_failures", "不能为负数")
+	}
+	if c.Reply.Fallback.BreakerFailures > 0 && c.Reply.Fallback.BreakerCooldown <= 0 {
+		fail("reply.fallback.breaker_cooldown", "必须大于0")
+	}
+
+	if c.Discovery.Interval.Std() < time.Minute {
+		fail("discovery.interval", "不能小于1m")
+	}
+	if c.Discovery.MaxAgeDays < 0 {
+		fail("discovery.max_age_days", "不能为负数")
+	}
+	if c.Discovery.MinComments < 0 {
+		fail("discovery.min_comments", "不能为负数")
+	}
+	if c.Discovery.MaxVideos < 1 {
+		fail("discovery.max_videos", "必须大于等于1")
+	}
+	if c.Discovery.MaxPages < 1 {
+		fail("discovery.max_pages", "必须大于等于1")
+	}
+
+	if _, err := time.LoadLocation(c.Broadcast.Timezone); err != nil {
+		fail("broadcast.timezone", "无效的时区 %q", c.Broadcast.Timezone)
+	}
+	scheduleIDs := make(map[string]bool)
+	for i, s := range c.Broadcast.Schedules {
+		field := fmt.Sprintf("broadcast.schedules[%d]", i)
+		if s.ID == "" {
+			fail(field+".id", "不能为空")
+		} else if scheduleIDs[s.ID] {
+			fail(field+".id", "重复的计划ID %q", s.ID)
+		}
+		scheduleIDs[s.ID] = true
+		if s.TenantID != "" && c.Tenant(s.TenantID) == nil {
+			fail(field+".tenant_id", "未知租户 %q", s.TenantID)
+		}
+		if !isChannelType(s.Channel) {
+			fail(field+".channel", "未知渠道 %q", s.Channel)
+		} else if !contains(RoomMessageChannels, s.Channel) {
+			fail(field+".channel", "渠道 %q 不支持发送直播间公屏消息，支持的渠道: %s", s.Channel, strings.Join(RoomMessageChannels, ", "))
+		}
+		if s.RoomID == "" {
+			fail(field+".room_id", "不能为空")
+		}
+		if len(s.Messages) == 0 {
+			fail(field+".messages", "至少需要一条文案")
+		}
+		if s.Interval != 0 && s.Interval.Std() < time.Minute {
+			fail(field+".interval", "不能小于1m")
+		}
+		if s.Interval == 0 && len(s.At) == 0 {
+			fail(field, "interval 和 at 至少配置一项")
+		}
+		for _, at := range s.At {
+			if err := broadcast.ParseClock(at); err != nil {
+				fail(field+".at", "%v", err)
+			}
+		}
+		if s.QuietAfter < 0 {
+			fail(field+".quiet_after", "不能为负数")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("配置校验失败:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+// Tenant 按ID查找租户
+func (c *Config) Tenant(id string) *Tenant {
+	for i := range c.Tenants {
+		if c.Tenants[i].ID == id {
+			return &c.Tenants[i]
+		}
+	}
+	return nil
+}
+
+// Channel 按类型查找渠道
+func (c *Config) Channel(channelType string) *Channel {
+	for i := range c.Channels {
+		if c.Channels[i].Type == channelType {
+			return &c.Channels[i]
+		}
+	}
+	return nil
+}
+
+// ChannelTypes 已配置的渠道类型
+func (c *Config) ChannelTypes() []string {
+	types := make([]string, 0, len(c.Channels))
+	for _, ch := range c.Channels {
+		types = append(types, ch.Type)
+	}
+	return types
+}
+
+// StructuralChanges 返回需要重启才能生效的变更项，热加载时用于提示
+func (c *Config) StructuralChanges(next *Config) []string {
+	var changed []string
+	sections := []struct {
+		name       string
+		prev, curr interface{}
+	}{
+		{"server", c.Server, next.Server},
+		{"redis", c.Redis, next.Redis},
+		{"lease", c.Lease, next.Lease},
+		{"providers", c.Providers, next.Providers},
+		{"tenants", []interface{}{c.DefaultTenant, c.Tenants}, []interface{}{next.DefaultTenant, next.Tenants}},
+		{"channels", c.Channels, next.Channels},
+		{"limits", c.Limits, next.Limits},
+	}
+	for _, s := range sections {
+		if !reflect.DeepEqual(s.prev, s.curr) {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
+
+// SpamConfig 转为反垃圾检测配置
+func (r Reply) SpamConfig() spam.Config {
+	return spam.Config{
+		FloodWindow:     r.Spam.FloodWindow.Std(),
+		FloodMax:        r.Spam.FloodMax,
+		DuplicateWindow: r.Spam.DuplicateWindow.Std(),
+		AdPatterns:      r.Spam.AdPatterns,
+		Actions:         r.Spam.Actions,
+	}
+}
+
+// HandoffConfig 转为人机切换配置
+func (r Reply) HandoffConfig() handoff.Config {
+	return handoff.Config{
+		Keywords:       r.Handoff.Keywords,
+		Intents:        r.Handoff.Intents,
+		ScoreThreshold: r.Handoff.ScoreThreshold,
+		IdleTimeout:    r.Handoff.IdleTimeout.Std(),
+		Notice:         r.Handoff.Notice,
+	}
+}
+
+// GiftsConfig 转为礼物和点赞处理配置
+func (r Reply) GiftsConfig() gifts.Config {
+	return gifts.Config{
+		ThankGifts:    r.Gifts.Thank,
+		MinValue:      r.Gifts.MinValue,
+		ThankWindow:   r.Gifts.Window.Std(),
+		MaxNames:      r.Gifts.MaxNames,
+		GiftTemplate:  r.Gifts.Template,
+		LikeWindow:    r.Gifts.LikeWindow.Std(),
+		LikeThreshold: r.Gifts.LikeThreshold,
+		LikeTemplate:  r.Gifts.LikeTemplate,
+	}
+}
+
+// WelcomeConfig 转为进场欢迎配置
+func (r Reply) WelcomeConfig() welcome.Config {
+	return welcome.Config{
+		Enabled:        r.Welcome.Enabled,
+		Window:         r.Welcome.Window.Std(),
+		MinInterval:    r.Welcome.MinInterval.Std(),
+		UserCooldown:   r.Welcome.UserCooldown.Std(),
+		MaxNames:       r.Welcome.MaxNames,
+		Template:       r.Welcome.Template,
+		VIPTemplate:    r.Welcome.VIPTemplate,
+		ScoreThreshold: r.Welcome.ScoreThreshold,
+		ThankFollows:   r.Welcome.ThankFollows,
+		FollowTemplate: r.Welcome.FollowTemplate,
+	}
+}
+
+// CacheConfig 转为回复缓存配置
+func (r Reply) CacheConfig() replycache.Config {
+	return replycache.Config{
+		Enabled:    r.Cache.Enabled,
+		TTL:        r.Cache.TTL.Std(),
+		MaxEntries: r.Cache.MaxEntries,
+	}
+}
+
+// FallbackConfig 转为回复降级链配置
+func (r Reply) FallbackConfig() pipeline.FallbackConfig {
+	channels := make(map[string]time.Duration, len(r.Fallback.Channels))
+	for channel, budget := range r.Fallback.Channels {
+		channels[channel] = budget.Std()
+	}
+	return pipeline.FallbackConfig{
+		Budget:   r.Fallback.Budget.Std(),
+		Channels: channels,
+		Breaker: breaker.Config{
+			Failures: r.Fallback.BreakerFailures,
+			Cooldown: r.Fallback.BreakerCooldown.Std(),
+		},
+	}
+}
+
+// BroadcastConfig 转为定时消息配置，未指定租户的计划归入 defaultTenant
+func (b Broadcast) BroadcastConfig(defaultTenant string) broadcast.Config {
+	config := broadcast.Config{Timezone: b.Timezone}
+	for _, s := range b.Schedules {
+		tenantID := s.TenantID
+		if tenantID == "" {
+			tenantID = defaultTenant
+		}
+		config.Schedules = append(config.Schedules, broadcast.Schedule{
+			ID:         s.ID,
+			TenantID:   tenantID,
+			Channel:    s.Channel,
+			RoomID:     s.RoomID,
+			Interval:   s.Interval.Std(),
+			At:         s.At,
+			Messages:   s.Messages,
+			QuietAfter: s.QuietAfter.Std(),
+			Enabled:    s.Enabled,
+		})
+	}
+	return config
+}
+
+// DiscoveryConfig 转为视频自动发现配置
+func (d Discovery) DiscoveryConfig() discovery.Config {
+	return discovery.Config{
+		Enabled:       d.Enabled,
+		Interval:      d.Interval.Std(),
+		MaxAge:        time.Duration(d.MaxAgeDays) * 24 * time.Hour,
+		MinComments:   d.MinComments,
+		IncludePinned: d.Pinned,
+		MaxVideos:     d.MaxVideos,
+		MaxPages:      d.MaxPages,
+	}
+}
+
+func isChannelType(channelType string) bool {
+	return contains(ChannelTypes, channelType)
+}
+
+// contains 列表中是否包含该值
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
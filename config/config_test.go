@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBroadcastChannel(t *testing.T) {
+	schedule := BroadcastSchedule{
+		ID:       "coupon",
+		Channel:  "douyin",
+		RoomID:   "room",
+		Interval: Duration(10 * time.Minute),
+		Messages: []string{"新人券已上架"},
+		Enabled:  true,
+	}
+
+	c := Default()
+	c.Broadcast.Schedules = []BroadcastSchedule{schedule}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("抖音直播间的定时消息应通过校验: %v", err)
+	}
+
+	schedule.Channel = "kuaishou"
+	c.Broadcast.Schedules = []BroadcastSchedule{schedule}
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "broadcast.schedules[0].channel") || !strings.Contains(err.Error(), "不支持发送直播间公屏消息") {
+		t.Fatalf("err = %v, 不支持公屏消息的渠道应拒绝定时消息", err)
+	}
+}
+
+func TestValidateDouyinRoomRequiresAccount(t *testing.T) {
+	c := Default()
+	ch := c.Channel("douyin")
+	ch.Rooms = []Room{{ID: "room", AccountID: "open-1"}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("绑定授权账号的抖音直播间应通过校验: %v", err)
+	}
+
+	ch.Rooms = []Room{{ID: "room"}}
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "channels[0].rooms[0].account_id") {
+		t.Fatalf("err = %v, 未绑定授权账号的抖音直播间应校验失败", err)
+	}
+}
+
+func TestSessionSpecs(t *testing.T) {
+	c := Default()
+	c.Channel("douyin").Enabled = true
+	c.Channel("douyin").Rooms = []Room{
+		{ID: "authorized", AccountID: "open-1"},
+		{ID: "waiting", AccountID: "open-2"},
+	}
+	c.Channel("kuaishou").Rooms = []Room{{ID: "disabled"}}
+
+	tokens := map[string]string{"open-1": "token-1"}
+	specs, pending := c.SessionSpecs(func(accountID string) (string, bool) {
+		token, ok := tokens[accountID]
+		return token, ok
+	})
+
+	if len(specs) != 1 {
+		t.Fatalf("只应提交已授权账号的直播间: %+v", specs)
+	}
+	spec := specs[0]
+	if spec.RoomID != "authorized" || spec.AccountID != "open-1" || spec.AccessToken != "token-1" || spec.TenantID != c.DefaultTenant || spec.Source != SessionSource {
+		t.Fatalf("会话定义不正确: %+v", spec)
+	}
+	if len(pending) != 1 || pending[0].ID != "waiting" {
+		t.Fatalf("账号未授权的直播间应等待授权: %+v", pending)
+	}
+}
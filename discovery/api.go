@@ -0,0 +1,43 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"live-im-proxy/openapi"
+)
+
+// RegisterRoutes 注册视频自动发现API
+//
+//	GET  /api/v1/discovery        本副本最近一次扫描结果
+//	POST /api/v1/discovery/run    立即扫描所有授权账号
+func (j *Job) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/discovery", Tag: "discovery",
+		Summary: "视频自动发现：本副本最近一次扫描结果", Response: []Result{}, HandlerFunc: j.handleResults,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/discovery/run", Tag: "discovery",
+		Summary: "立即扫描所有授权账号的视频", Response: []Result{}, HandlerFunc: j.handleRun,
+	})
+}
+
+// handleResults 最近一次扫描结果
+func (j *Job) handleResults(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": j.Results()})
+}
+
+// handleRun 立即扫描
+func (j *Job) handleRun(w http.ResponseWriter, r *http.Request) {
+	results := j.Run(r.Context())
+	if results == nil {
+		results = []Result{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": results})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,296 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"live-im-proxy/channel"
+	"live-im-proxy/lease"
+	"live-im-proxy/oauth"
+)
+
+// SourceDiscovery 自动发现创建的会话来源，只有这类会话会被自动下线
+const SourceDiscovery = "discovery"
+
+// pageSize 每页拉取的视频数
+const pageSize = 20
+
+// Config 自动发现策略
+type Config struct {
+	Enabled       bool
+	Interval      time.Duration // 扫描间隔
+	MaxAge        time.Duration // 只监听该时长内发布的视频，0表示不限
+	MinComments   int64         // 评论数不少于该值才监听
+	IncludePinned bool          // 置顶视频不受发布时间和评论数限制
+	MaxVideos     int           // 每个账号最多监听的视频数
+	MaxPages      int           // 每次扫描最多翻页数
+}
+
+// DefaultConfig 默认配置：最近7天发布的视频和置顶视频，每个账号最多20个
+func DefaultConfig() Config {
+	return Config{
+		Interval:      30 * time.Minute,
+		MaxAge:        7 * 24 * time.Hour,
+		IncludePinned: true,
+		MaxVideos:     20,
+		MaxPages:      5,
+	}
+}
+
+// Lister 分页获取账号发布的视频
+type Lister func(openID, accessToken string, cursor int64, count int) (channel.VideoPage, error)
+
+// Result 单个账号最近一次扫描结果
+type Result struct {
+	OpenID     string    `json:"open_id"`
+	Scanned    int       `json:"scanned"`    // 扫描的视频数
+	Selected   []string  `json:"selected"`   // 符合策略的视频
+	Registered []string  `json:"registered"` // 本次新建监听的视频
+	Retired    []string  `json:"retired"`    // 本次下线的视频
+	Error      string    `json:"error,omitempty"`
+	RunAt      time.Time `json:"run_at"`
+}
+
+// Job 短视频自动发现任务
+//
+// 定期翻页获取每个授权账号的视频，按策略为新视频创建短视频评论会话，
+// 不再符合策略的自动发现会话会被删除；手动创建的会话不受影响。
+// 新会话只处理登记之后的评论，视频已有的评论和楼层追问不会补发回复。
+// 多副本部署时通过任务锁保证同一时间只有一个副本执行扫描。
+type Job struct {
+	coordinator *lease.Coordinator
+	accounts    *oauth.AccountStore
+	list        Lister
+
+	mu      sync.Mutex
+	config  Config
+	results map[string]Result
+	running sync.Mutex
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewJob 创建自动发现任务，发现的会话归入账号所属租户
+func NewJob(coordinator *lease.Coordinator, accounts *oauth.AccountStore, config Config) *Job {
+	return &Job{
+		coordinator: coordinator,
+		accounts:    accounts,
+		list:        channel.ListDouyinVideos,
+		config:      config,
+		results:     make(map[string]Result),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// SetConfig 更新策略，扫描间隔在下一轮生效
+func (j *Job) SetConfig(config Config) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.config = config
+}
+
+// Config 当前策略
+func (j *Job) Config() Config {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.config
+}
+
+// Start 启动定时扫描
+func (j *Job) Start() {
+	go j.loop()
+}
+
+// Stop 停止定时扫描
+func (j *Job) Stop() {
+	close(j.done)
+	<-j.stopped
+}
+
+func (j *Job) loop() {
+	defer close(j.stopped)
+
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-timer.C:
+		}
+
+		config := j.Config()
+		if config.Interval <= 0 {
+			config.Interval = DefaultConfig().Interval
+		}
+		if config.Enabled {
+			ctx, cancel := context.WithTimeout(context.Background(), config.Interval)
+			if ok, err := j.coordinator.TryLock(ctx, "discovery", config.Interval); err != nil {
+				log.Printf("❌ 获取视频发现任务锁失败: %v", err)
+			} else if ok {
+				j.Run(ctx)
+			}
+			cancel()
+		}
+		timer.Reset(config.Interval)
+	}
+}
+
+// Run 立即扫描所有授权账号
+func (j *Job) Run(ctx context.Context) []Result {
+	j.running.Lock()
+	defer j.running.Unlock()
+
+	config := j.Config()
+	specs, err := j.coordinator.Sessions(ctx)
+	if err != nil {
+		log.Printf("❌ 视频发现读取会话失败: %v", err)
+		return nil
+	}
+
+	var results []Result
+	for _, account := range j.accounts.List() {
+		if account.Token == nil || account.Token.AccessToken == "" {
+			continue
+		}
+		result := j.runAccount(ctx, config, account, specs)
+		results = append(results, result)
+
+		j.mu.Lock()
+		j.results[account.OpenID] = result
+		j.mu.Unlock()
+	}
+	return results
+}
+
+// runAccount 扫描单个账号：登记新视频，下线不再符合策略的自动发现会话
+func (j *Job) runAccount(ctx context.Context, config Config, account *oauth.Account, specs []lease.SessionSpec) Result {
+	result := Result{OpenID: account.OpenID, RunAt: time.Now()}
+
+	videos, scanned, err := j.discover(config, account)
+	result.Scanned = scanned
+	if err != nil {
+		// 拉取失败时不下线任何会话，避免接口故障导致监听全部中断
+		result.Error = err.Error()
+		log.Printf("❌ 视频发现失败: open_id=%s, err=%v", account.OpenID, err)
+		return result
+	}
+
+	existing := make(map[string]lease.SessionSpec)
+	for _, spec := range specs {
+		if spec.Platform == "douyin" && spec.Kind == lease.KindVideo && spec.AccountID == account.OpenID {
+			existing[spec.VideoID] = spec
+		}
+	}
+
+	selected := make(map[string]bool, len(videos))
+	for _, video := range videos {
+		selected[video.ID] = true
+		result.Selected = append(result.Selected, video.ID)
+		if _, ok := existing[video.ID]; ok {
+			continue
+		}
+		spec := lease.SessionSpec{
+			Key:         lease.SessionKeyFor(account.TenantID, "douyin", lease.KindVideo, account.OpenID, video.ID),
+			TenantID:    account.TenantID,
+			Platform:    "douyin",
+			Kind:        lease.KindVideo,
+			AccountID:   account.OpenID,
+			VideoID:     video.ID,
+			AccessToken: account.Token.AccessToken,
+			Source:      SourceDiscovery,
+		}
+		if err := j.coordinator.Submit(ctx, spec); err != nil {
+			log.Printf("❌ 登记视频监听失败: video=%s, err=%v", video.ID, err)
+			continue
+		}
+		result.Registered = append(result.Registered, video.ID)
+		log.Printf("🎬 发现新视频，开始监听评论: open_id=%s, video=%s, title=%s", account.OpenID, video.ID, video.Title)
+	}
+
+	for videoID, spec := range existing {
+		if spec.Source != SourceDiscovery || selected[videoID] {
+			continue
+		}
+		if err := j.coordinator.Remove(ctx, spec.Key); err != nil && err != lease.ErrNotFound {
+			log.Printf("❌ 下线视频监听失败: video=%s, err=%v", videoID, err)
+			continue
+		}
+		result.Retired = append(result.Retired, videoID)
+		log.Printf("📴 视频不再符合监听策略，停止监听: open_id=%s, video=%s", account.OpenID, videoID)
+	}
+	sort.Strings(result.Retired)
+	return result
+}
+
+// discover 翻页获取视频并按策略筛选，结果置顶优先、其余按发布时间倒序
+func (j *Job) discover(config Config, account *oauth.Account) ([]channel.DouyinVideo, int, error) {
+	var candidates []channel.DouyinVideo
+	scanned := 0
+	cutoff := time.Time{}
+	if config.MaxAge > 0 {
+		cutoff = time.Now().Add(-config.MaxAge)
+	}
+
+	var cursor int64
+	for page := 0; page < config.MaxPages; page++ {
+		result, err := j.list(account.OpenID, account.Token.AccessToken, cursor, pageSize)
+		if err != nil {
+			return nil, scanned, err
+		}
+		scanned += len(result.Videos)
+
+		reachedOld := false
+		for _, video := range result.Videos {
+			if video.IsTop && config.IncludePinned {
+				candidates = append(candidates, video)
+				continue
+			}
+			if !cutoff.IsZero() && time.Unix(video.CreateTime, 0).Before(cutoff) {
+				// 列表按发布时间倒序，置顶以外出现过期视频即可停止翻页
+				if !video.IsTop {
+					reachedOld = true
+				}
+				continue
+			}
+			if video.CommentCount < config.MinComments {
+				continue
+			}
+			candidates = append(candidates, video)
+		}
+
+		if reachedOld || !result.HasMore {
+			break
+		}
+		cursor = result.Cursor
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].IsTop != candidates[b].IsTop {
+			return candidates[a].IsTop
+		}
+		return candidates[a].CreateTime > candidates[b].CreateTime
+	})
+	if config.MaxVideos > 0 && len(candidates) > config.MaxVideos {
+		candidates = candidates[:config.MaxVideos]
+	}
+	return candidates, scanned, nil
+}
+
+// Results 本副本最近一次扫描各账号的结果
+func (j *Job) Results() []Result {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	results := make([]Result, 0, len(j.results))
+	for _, result := range j.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(a, b int) bool { return results[a].OpenID < results[b].OpenID })
+	return results
+}
@@ -0,0 +1,100 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"live-im-proxy/channel"
+	"live-im-proxy/lease"
+	"live-im-proxy/oauth"
+)
+
+// nopRunner 只登记会话，不实际运行
+type nopRunner struct{}
+
+func (nopRunner) StartSession(spec lease.SessionSpec) error { return nil }
+func (nopRunner) StopSession(key string)                    {}
+func (nopRunner) SessionStatus(key string) (lease.SessionStatus, bool) {
+	return lease.SessionStatus{}, false
+}
+
+func newTestJob(t *testing.T, videos *[]channel.DouyinVideo) (*Job, *lease.Coordinator) {
+	t.Helper()
+	accounts, err := oauth.NewAccountStore("", nil, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Put(&oauth.Account{OpenID: "open-1", TenantID: "tenant-a", Token: &oauth.OAuthToken{AccessToken: "token"}}); err != nil {
+		t.Fatal(err)
+	}
+	coordinator := lease.NewCoordinator(lease.NewMemoryStore(), nopRunner{}, "replica", time.Minute)
+	job := NewJob(coordinator, accounts, Config{MaxVideos: 20, MaxPages: 1, IncludePinned: true})
+	job.list = func(openID, accessToken string, cursor int64, count int) (channel.VideoPage, error) {
+		return channel.VideoPage{Videos: *videos}, nil
+	}
+	return job, coordinator
+}
+
+func TestRunRegistersNewVideosUnderAccountTenant(t *testing.T) {
+	now := time.Now().Unix()
+	videos := []channel.DouyinVideo{{ID: "v1", CreateTime: now}, {ID: "v2", CreateTime: now}}
+	job, coordinator := newTestJob(t, &videos)
+
+	results := job.Run(context.Background())
+	if len(results) != 1 || len(results[0].Registered) != 2 {
+		t.Fatalf("results = %+v, 应登记2个视频", results)
+	}
+
+	specs, err := coordinator.Sessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range specs {
+		if spec.TenantID != "tenant-a" || spec.Source != SourceDiscovery || spec.Kind != lease.KindVideo {
+			t.Fatalf("spec = %+v, 应归入账号所属租户并标记为自动发现", spec)
+		}
+		if spec.Key != lease.SessionKeyFor("tenant-a", "douyin", lease.KindVideo, "open-1", spec.VideoID) {
+			t.Fatalf("会话键 %s 应包含租户", spec.Key)
+		}
+	}
+
+	// 已登记的视频不重复登记，已有会话保持原来的启动时间，不会重新处理积压评论
+	results = job.Run(context.Background())
+	if len(results[0].Registered) != 0 {
+		t.Fatalf("Registered = %v, 已登记的视频不应重复登记", results[0].Registered)
+	}
+}
+
+func TestRunRetiresOnlyDiscoveredSessions(t *testing.T) {
+	now := time.Now().Unix()
+	videos := []channel.DouyinVideo{{ID: "v1", CreateTime: now}}
+	job, coordinator := newTestJob(t, &videos)
+	ctx := context.Background()
+
+	manual := lease.SessionSpec{
+		Key:       lease.SessionKeyFor("tenant-a", "douyin", lease.KindVideo, "open-1", "manual"),
+		TenantID:  "tenant-a",
+		Platform:  "douyin",
+		Kind:      lease.KindVideo,
+		AccountID: "open-1",
+		VideoID:   "manual",
+	}
+	if err := coordinator.Submit(ctx, manual); err != nil {
+		t.Fatal(err)
+	}
+	job.Run(ctx)
+
+	videos = nil
+	results := job.Run(ctx)
+	if len(results[0].Retired) != 1 || results[0].Retired[0] != "v1" {
+		t.Fatalf("Retired = %v, 只应下线自动发现的视频", results[0].Retired)
+	}
+	specs, err := coordinator.Sessions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 || specs[0].Key != manual.Key {
+		t.Fatalf("specs = %+v, 手动创建的会话不应被下线", specs)
+	}
+}
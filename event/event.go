@@ -1,23 +1,47 @@
 package event
 
 import (
-	"fmt"
 	"time"
+
+	"live-im-proxy/ids"
+)
+
+// 事件类型
+const (
+	TypeEnter          = "enter"           // 进入直播间
+	TypeComment        = "comment"         // 直播间评论
+	TypeVideoComment   = "video_comment"   // 短视频评论
+	TypeLike           = "like"            // 点赞
+	TypeGift           = "gift"            // 送礼
+	TypeFollow         = "follow"          // 关注
+	TypePrivateMessage = "private_message" // 私信
 )
 
 // Event 表示直播间事件
+//
+// 各类型的关键数据放在对应的类型化载荷中（Comment、Gift 等），
+// Metadata 只用于扩展信息；序列化时载荷字段会同步写入 Metadata 的旧键名，兼容旧的 JSON 消费方
 type Event struct {
-	ID        string                 `json:"id"`
-	Type      string                 `json:"type"`      // enter, comment, like, gift, follow, video_comment, private_message
-	Channel   string                 `json:"channel"`   // douyin, kuaishou, wechat, xiaohongshu
-	RoomID    string                 `json:"room_id"`   // 直播间ID
-	VideoID   string                 `json:"video_id"`  // 短视频ID
-	UserID    string                 `json:"user_id"`
-	Nickname  string                 `json:"nickname"`
-	Avatar    string                 `json:"avatar,omitempty"`
-	Content   string                 `json:"content,omitempty"`
-	Timestamp int64                  `json:"timestamp"`
-	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ID             string                 `json:"id"`                        // 本系统生成的唯一ID，可按时间排序
+	IdempotencyKey string                 `json:"idempotency_key,omitempty"` // 由平台和平台原生消息ID生成，重复投递时不变
+	TenantID       string                 `json:"tenant_id,omitempty"`
+	Type           string                 `json:"type"`     // enter, comment, like, gift, follow, video_comment, private_message
+	Channel        string                 `json:"channel"`  // douyin, kuaishou, wechat, xiaohongshu
+	RoomID         string                 `json:"room_id"`  // 直播间ID
+	VideoID        string                 `json:"video_id"` // 短视频ID
+	UserID         string                 `json:"user_id"`
+	Nickname       string                 `json:"nickname"`
+	Avatar         string                 `json:"avatar,omitempty"`
+	Content        string                 `json:"content,omitempty"`
+	Timestamp      int64                  `json:"timestamp"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+
+	Comment *CommentPayload        `json:"comment,omitempty"`
+	Gift    *GiftPayload           `json:"gift,omitempty"`
+	Like    *LikePayload           `json:"like,omitempty"`
+	Follow  *FollowPayload         `json:"follow,omitempty"`
+	Enter   *EnterPayload          `json:"enter,omitempty"`
+	Message *PrivateMessagePayload `json:"message,omitempty"`
 }
 
 // NewEvent 创建新事件
@@ -64,5 +88,15 @@ func (e *Event) SetVideoID(videoID string) {
 
 // generateID 生成唯一ID
 func generateID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return ids.New()
+}
+
+// IdempotencyKey 由平台、消息类别和平台原生ID生成幂等键，同一条平台消息无论投递几次都相同
+//
+// 类别区分不同的ID空间，如评论(comment)和私信(message)
+func IdempotencyKey(channel, kind, nativeID string) string {
+	if nativeID == "" {
+		return ""
+	}
+	return channel + ":" + kind + ":" + nativeID
 }
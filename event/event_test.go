@@ -0,0 +1,163 @@
+package event
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	if got := IdempotencyKey("douyin", "comment", ""); got != "" {
+		t.Fatalf("没有原生ID时应返回空，实际 %q", got)
+	}
+	comment := IdempotencyKey("douyin", "comment", "123")
+	if comment != IdempotencyKey("douyin", "comment", "123") {
+		t.Fatal("同一条平台消息的幂等键应相同")
+	}
+	if comment == IdempotencyKey("douyin", "message", "123") {
+		t.Fatal("评论和私信的ID空间应区分")
+	}
+	if comment == IdempotencyKey("kuaishou", "comment", "123") {
+		t.Fatal("不同平台的幂等键应区分")
+	}
+}
+
+func TestDerivedIdempotencyKey(t *testing.T) {
+	evt := NewEvent(TypeVideoComment, "douyin", "", "user", "昵称")
+	evt.SetComment(CommentPayload{CommentID: "root"})
+	if evt.IdempotencyKey != "douyin:comment:root" {
+		t.Fatalf("一级评论 IdempotencyKey = %q", evt.IdempotencyKey)
+	}
+
+	// 楼中楼追问用追问本身的评论ID，与所在楼层区分
+	nested := NewEvent(TypeVideoComment, "douyin", "", "user", "昵称")
+	nested.SetComment(CommentPayload{CommentID: "root", ReplyToCommentID: "child"})
+	if nested.IdempotencyKey != "douyin:comment:child" {
+		t.Fatalf("楼中楼追问 IdempotencyKey = %q", nested.IdempotencyKey)
+	}
+
+	msg := NewEvent(TypePrivateMessage, "douyin", "", "user", "昵称")
+	msg.SetPrivateMessage(PrivateMessagePayload{MessageID: "m1", ConversationID: "c1"})
+	if msg.IdempotencyKey != "douyin:message:m1" {
+		t.Fatalf("私信 IdempotencyKey = %q", msg.IdempotencyKey)
+	}
+
+	// 两次投递生成不同的事件ID，幂等键相同
+	again := NewEvent(TypePrivateMessage, "douyin", "", "user", "昵称")
+	again.SetPrivateMessage(PrivateMessagePayload{MessageID: "m1", ConversationID: "c1"})
+	if again.ID == msg.ID || again.DedupKey() != msg.DedupKey() {
+		t.Fatalf("重复投递应生成新事件ID但去重键不变: %q/%q", again.DedupKey(), msg.DedupKey())
+	}
+
+	gift := NewEvent(TypeGift, "douyin", "room", "user", "昵称")
+	if gift.IdempotencyKey != "" || gift.DedupKey() != gift.ID {
+		t.Fatal("没有原生ID的事件去重键应退化为事件ID")
+	}
+}
+
+func TestUnmarshalLegacyDerivesIdempotencyKey(t *testing.T) {
+	data := []byte(`{"id":"x","type":"comment","channel":"douyin","metadata":{"comment_id":"root","reply_to_comment_id":"child"}}`)
+	var evt Event
+	if err := json.Unmarshal(data, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.IdempotencyKey != "douyin:comment:child" {
+		t.Fatalf("旧格式事件 IdempotencyKey = %q, 应按 Metadata 还原", evt.IdempotencyKey)
+	}
+
+	// 序列化后再解析，幂等键保持不变
+	evt.IdempotencyKey = "douyin:comment:original"
+	data, err := json.Marshal(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Event
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.IdempotencyKey != "douyin:comment:original" {
+		t.Fatalf("IdempotencyKey = %q, 已有的幂等键不应被重新生成", decoded.IdempotencyKey)
+	}
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func(evt *Event)
+		field string // 载荷的 JSON 字段名
+	}{
+		{"comment", func(e *Event) { e.Type = TypeComment; e.SetComment(CommentPayload{CommentID: "c1"}) }, "comment"},
+		{"nested", func(e *Event) {
+			e.Type = TypeVideoComment
+			e.SetComment(CommentPayload{CommentID: "root", ReplyToCommentID: "child"})
+		}, "comment"},
+		{"gift", func(e *Event) {
+			e.Type = TypeGift
+			e.SetGift(GiftPayload{GiftID: "g1", GiftName: "玫瑰", Count: 3, Value: 10})
+		}, "gift"},
+		{"like", func(e *Event) { e.Type = TypeLike; e.SetLike(LikePayload{Count: 15}) }, "like"},
+		{"follow", func(e *Event) { e.Type = TypeFollow; e.SetFollow(FollowPayload{FollowerCount: 1200}) }, "follow"},
+		{"enter", func(e *Event) { e.Type = TypeEnter; e.SetEnter(EnterPayload{MemberCount: 88}) }, "enter"},
+		{"message", func(e *Event) {
+			e.Type = TypePrivateMessage
+			e.SetPrivateMessage(PrivateMessagePayload{MessageID: "m1", ConversationID: "conv", MessageType: "image", MediaURL: "https://example.com/a.png"})
+		}, "message"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			evt := NewEvent("", "douyin", "room", "user", "昵称")
+			c.build(evt)
+			want := payloadJSON(t, evt)
+
+			data, err := json.Marshal(evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Event
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if got := payloadJSON(t, &decoded); got != want {
+				t.Fatalf("序列化往返后载荷 = %s, 应为 %s", got, want)
+			}
+
+			// 旧格式只有 Metadata 键名，解析时应还原出相同的载荷
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal(data, &raw); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := raw[c.field]; !ok {
+				t.Fatalf("序列化结果缺少载荷字段 %q", c.field)
+			}
+			delete(raw, c.field)
+			legacy, _ := json.Marshal(raw)
+			var lifted Event
+			if err := json.Unmarshal(legacy, &lifted); err != nil {
+				t.Fatal(err)
+			}
+			if got := payloadJSON(t, &lifted); got != want {
+				t.Fatalf("旧格式还原的载荷 = %s, 应为 %s", got, want)
+			}
+			if lifted.IdempotencyKey != evt.IdempotencyKey {
+				t.Fatalf("IdempotencyKey = %q, 应为 %q", lifted.IdempotencyKey, evt.IdempotencyKey)
+			}
+		})
+	}
+}
+
+// payloadJSON 事件全部载荷字段的 JSON，用于比较
+func payloadJSON(t *testing.T, evt *Event) string {
+	t.Helper()
+	data, err := json.Marshal(struct {
+		Comment *CommentPayload
+		Gift    *GiftPayload
+		Like    *LikePayload
+		Follow  *FollowPayload
+		Enter   *EnterPayload
+		Message *PrivateMessagePayload
+	}{evt.Comment, evt.Gift, evt.Like, evt.Follow, evt.Enter, evt.Message})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,278 @@
+package event
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// CommentPayload 评论载荷（直播间评论、短视频评论）
+type CommentPayload struct {
+	CommentID        string `json:"comment_id"`                    // 回复时挂靠的评论ID，楼中楼为一级评论ID
+	ReplyToCommentID string `json:"reply_to_comment_id,omitempty"` // 楼中楼追问本身的评论ID
+}
+
+// GiftPayload 礼物载荷
+type GiftPayload struct {
+	GiftID   string `json:"gift_id"`
+	GiftName string `json:"gift_name,omitempty"`
+	Count    int64  `json:"count"` // 本次赠送数量
+	Value    int64  `json:"value"` // 单个礼物价值，单位为平台虚拟币（抖音为抖币）
+}
+
+// TotalValue 本次赠送总价值
+func (g *GiftPayload) TotalValue() int64 {
+	return g.Count * g.Value
+}
+
+// LikePayload 点赞载荷
+type LikePayload struct {
+	Count int64 `json:"count"` // 本次点赞数
+}
+
+// FollowPayload 关注载荷
+type FollowPayload struct {
+	FollowerCount int64 `json:"follower_count,omitempty"` // 关注后的粉丝数
+}
+
+// EnterPayload 进入直播间载荷
+type EnterPayload struct {
+	MemberCount int64 `json:"member_count,omitempty"` // 进入后的在线人数
+}
+
+// PrivateMessagePayload 私信载荷
+type PrivateMessagePayload struct {
+	MessageID      string `json:"message_id"`
+	ConversationID string `json:"conversation_id"`
+	MessageType    string `json:"message_type,omitempty"` // text, image, video 等
+	MediaURL       string `json:"media_url,omitempty"`    // 图片、视频等媒体地址
+}
+
+// SetComment 设置评论载荷，并按评论ID生成幂等键
+func (e *Event) SetComment(p CommentPayload) {
+	e.Comment = &p
+	e.deriveIdempotencyKey()
+}
+
+// SetGift 设置礼物载荷
+func (e *Event) SetGift(p GiftPayload) {
+	e.Gift = &p
+}
+
+// SetLike 设置点赞载荷
+func (e *Event) SetLike(p LikePayload) {
+	e.Like = &p
+}
+
+// SetFollow 设置关注载荷
+func (e *Event) SetFollow(p FollowPayload) {
+	e.Follow = &p
+}
+
+// SetEnter 设置进入直播间载荷
+func (e *Event) SetEnter(p EnterPayload) {
+	e.Enter = &p
+}
+
+// SetPrivateMessage 设置私信载荷，并按消息ID生成幂等键
+func (e *Event) SetPrivateMessage(p PrivateMessagePayload) {
+	e.Message = &p
+	e.deriveIdempotencyKey()
+}
+
+// deriveIdempotencyKey 按载荷中的平台原生ID生成幂等键
+// 楼中楼追问的 CommentID 是所在楼层，用追问本身的评论ID区分
+func (e *Event) deriveIdempotencyKey() {
+	switch {
+	case e.Comment != nil && e.Comment.ReplyToCommentID != "":
+		e.IdempotencyKey = IdempotencyKey(e.Channel, "comment", e.Comment.ReplyToCommentID)
+	case e.Comment != nil:
+		e.IdempotencyKey = IdempotencyKey(e.Channel, "comment", e.Comment.CommentID)
+	case e.Message != nil:
+		e.IdempotencyKey = IdempotencyKey(e.Channel, "message", e.Message.MessageID)
+	}
+}
+
+// DedupKey 去重键：有幂等键时使用幂等键，否则退化为事件ID（不跨投递去重）
+func (e *Event) DedupKey() string {
+	if e.IdempotencyKey != "" {
+		return e.IdempotencyKey
+	}
+	return e.ID
+}
+
+// CommentID 评论ID，未设置评论载荷时读取旧的 Metadata 键
+func (e *Event) CommentID() string {
+	if e.Comment != nil {
+		return e.Comment.CommentID
+	}
+	return e.metadataString("comment_id")
+}
+
+// ReplyToCommentID 楼中楼追问本身的评论ID
+func (e *Event) ReplyToCommentID() string {
+	if e.Comment != nil {
+		return e.Comment.ReplyToCommentID
+	}
+	return e.metadataString("reply_to_comment_id")
+}
+
+// IsNestedReply 是否为楼中楼追问
+func (e *Event) IsNestedReply() bool {
+	return e.ReplyToCommentID() != ""
+}
+
+// ConversationID 私信会话ID
+func (e *Event) ConversationID() string {
+	if e.Message != nil {
+		return e.Message.ConversationID
+	}
+	return e.metadataString("conversation_id")
+}
+
+// MessageID 私信消息ID
+func (e *Event) MessageID() string {
+	if e.Message != nil {
+		return e.Message.MessageID
+	}
+	return e.metadataString("message_id")
+}
+
+func (e *Event) metadataString(key string) string {
+	s, _ := e.Metadata[key].(string)
+	return s
+}
+
+// eventJSON 去掉自定义序列化方法的别名，避免递归
+type eventJSON Event
+
+// MarshalJSON 序列化时把载荷字段同步写入 Metadata 的旧键名
+func (e Event) MarshalJSON() ([]byte, error) {
+	legacy := e.legacyMetadata()
+	if len(legacy) > 0 {
+		metadata := make(map[string]interface{}, len(e.Metadata)+len(legacy))
+		for k, v := range e.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range legacy {
+			metadata[k] = v
+		}
+		e.Metadata = metadata
+	}
+	return json.Marshal(eventJSON(e))
+}
+
+// UnmarshalJSON 反序列化时，旧格式只有 Metadata 键名的，按事件类型还原为载荷
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var raw eventJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*e = Event(raw)
+	e.liftMetadata()
+	if e.IdempotencyKey == "" {
+		e.deriveIdempotencyKey()
+	}
+	return nil
+}
+
+// legacyMetadata 载荷对应的旧 Metadata 键值
+func (e *Event) legacyMetadata() map[string]interface{} {
+	m := make(map[string]interface{})
+	if c := e.Comment; c != nil {
+		m["comment_id"] = c.CommentID
+		if c.ReplyToCommentID != "" {
+			m["reply_to_comment_id"] = c.ReplyToCommentID
+		}
+	}
+	if g := e.Gift; g != nil {
+		m["gift_id"] = g.GiftID
+		m["gift_name"] = g.GiftName
+		m["gift_count"] = g.Count
+		m["gift_value"] = g.Value
+	}
+	if l := e.Like; l != nil {
+		m["like_count"] = l.Count
+	}
+	if f := e.Follow; f != nil && f.FollowerCount > 0 {
+		m["follower_count"] = f.FollowerCount
+	}
+	if en := e.Enter; en != nil && en.MemberCount > 0 {
+		m["member_count"] = en.MemberCount
+	}
+	if msg := e.Message; msg != nil {
+		m["message_id"] = msg.MessageID
+		m["conversation_id"] = msg.ConversationID
+		if msg.MessageType != "" {
+			m["message_type"] = msg.MessageType
+		}
+		if msg.MediaURL != "" {
+			m["media_url"] = msg.MediaURL
+		}
+	}
+	return m
+}
+
+// liftMetadata 旧格式事件没有载荷字段时，从 Metadata 还原
+func (e *Event) liftMetadata() {
+	if len(e.Metadata) == 0 {
+		return
+	}
+	switch e.Type {
+	case TypeComment, TypeVideoComment:
+		if e.Comment == nil && e.metadataString("comment_id") != "" {
+			e.Comment = &CommentPayload{
+				CommentID:        e.metadataString("comment_id"),
+				ReplyToCommentID: e.metadataString("reply_to_comment_id"),
+			}
+		}
+	case TypeGift:
+		if e.Gift == nil && e.metadataString("gift_id") != "" {
+			e.Gift = &GiftPayload{
+				GiftID:   e.metadataString("gift_id"),
+				GiftName: e.metadataString("gift_name"),
+				Count:    e.metadataInt("gift_count"),
+				Value:    e.metadataInt("gift_value"),
+			}
+		}
+	case TypeLike:
+		if _, ok := e.Metadata["like_count"]; e.Like == nil && ok {
+			e.Like = &LikePayload{Count: e.metadataInt("like_count")}
+		}
+	case TypeFollow:
+		if _, ok := e.Metadata["follower_count"]; e.Follow == nil && ok {
+			e.Follow = &FollowPayload{FollowerCount: e.metadataInt("follower_count")}
+		}
+	case TypeEnter:
+		if _, ok := e.Metadata["member_count"]; e.Enter == nil && ok {
+			e.Enter = &EnterPayload{MemberCount: e.metadataInt("member_count")}
+		}
+	case TypePrivateMessage:
+		if e.Message == nil && e.metadataString("conversation_id") != "" {
+			e.Message = &PrivateMessagePayload{
+				MessageID:      e.metadataString("message_id"),
+				ConversationID: e.metadataString("conversation_id"),
+				MessageType:    e.metadataString("message_type"),
+				MediaURL:       e.metadataString("media_url"),
+			}
+		}
+	}
+}
+
+// metadataInt 读取整数元数据，兼容 JSON 解码出的 float64 和字符串
+func (e *Event) metadataInt(key string) int64 {
+	switch v := e.Metadata[key].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
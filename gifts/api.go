@@ -0,0 +1,38 @@
+package gifts
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/openapi"
+)
+
+// RegisterRoutes 注册礼物收入API
+//
+//	GET /api/v1/gifts/revenue?tenant_id=   各直播间礼物收入和点赞统计
+func (t *Tracker) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/gifts/revenue", Tag: "gifts",
+		Summary:  "各直播间礼物收入和点赞统计（本副本）",
+		Query:    []openapi.Param{{Name: "tenant_id", Description: "按租户过滤"}},
+		Response: []Revenue{}, HandlerFunc: t.handleRevenue,
+	})
+}
+
+// handleRevenue 礼物收入统计，租户密钥只能看到本租户的直播间
+func (t *Tracker) handleRevenue(w http.ResponseWriter, r *http.Request) {
+	revenue := make([]Revenue, 0)
+	for _, item := range t.Revenue(r.URL.Query().Get("tenant_id")) {
+		if auth.CanAccessTenant(r, item.TenantID) {
+			revenue = append(revenue, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": revenue})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,295 @@
+package gifts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/reply"
+)
+
+// Config 礼物和点赞处理配置
+type Config struct {
+	ThankGifts    bool          // 是否发送礼物感谢
+	MinValue      int64         // 单次赠送总价值不低于该值才感谢，0表示全部感谢
+	ThankWindow   time.Duration // 感谢合并窗口，窗口内的礼物合并为一条消息
+	MaxNames      int           // 一条感谢消息最多点名的条目数
+	GiftTemplate  string        // 礼物感谢模板，{gifts} 为 "昵称 送出的 礼物×数量" 列表
+	LikeWindow    time.Duration // 点赞按用户聚合的窗口
+	LikeThreshold int64         // 窗口内点赞数达到该值的用户会被感谢，0表示不感谢点赞
+	LikeTemplate  string        // 点赞感谢模板，{names} 为昵称列表
+}
+
+// DefaultConfig 默认配置：礼物10秒合并感谢一次，点赞只统计不感谢
+func DefaultConfig() Config {
+	return Config{
+		ThankGifts:   true,
+		ThankWindow:  10 * time.Second,
+		MaxNames:     5,
+		GiftTemplate: "感谢 {gifts}，谢谢支持！",
+		LikeWindow:   time.Minute,
+		LikeTemplate: "感谢 {names} 的点赞～",
+	}
+}
+
+// SendFunc 发送感谢消息，evt 为窗口内最后一条事件，用于定位直播间和发送渠道；
+// 渠道不支持公屏消息时返回 reply.ErrRoomMessageUnsupported
+type SendFunc func(evt *event.Event, content string) error
+
+// Revenue 直播间礼物收入统计
+type Revenue struct {
+	TenantID  string           `json:"tenant_id"`
+	Channel   string           `json:"channel"`
+	RoomID    string           `json:"room_id"`
+	Gifts     int64            `json:"gifts"`   // 礼物个数
+	Value     int64            `json:"value"`   // 礼物总价值（平台虚拟币）
+	Senders   int              `json:"senders"` // 送礼人数
+	Likes     int64            `json:"likes"`   // 点赞总数
+	ByGift    map[string]int64 `json:"by_gift"` // 按礼物名称统计的价值
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// room 单个直播间的状态
+type room struct {
+	revenue Revenue
+	senders map[string]bool
+
+	gifts     []string     // 待感谢的礼物条目
+	lastGift  *event.Event // 待感谢的最后一条礼物事件
+	giftSince time.Time
+
+	likes     map[string]*likeWindow // 按用户聚合的点赞
+	lastLike  *event.Event
+	likeNames []string // 待感谢的点赞用户
+	likeSince time.Time
+}
+
+// likeWindow 用户在窗口内的点赞
+type likeWindow struct {
+	count   int64
+	start   time.Time
+	thanked bool
+}
+
+// Tracker 礼物和点赞处理：统计直播间收入，按窗口合并发送感谢
+type Tracker struct {
+	mu     sync.Mutex
+	config Config
+	send   SendFunc
+	rooms  map[string]*room
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewTracker 创建礼物处理器，send 为空时只统计不感谢
+func NewTracker(config Config, send SendFunc) *Tracker {
+	t := &Tracker{
+		config: config,
+		send:   send,
+		rooms:  make(map[string]*room),
+		done:   make(chan struct{}),
+	}
+	go t.flushLoop()
+	return t
+}
+
+// SetConfig 更新配置
+func (t *Tracker) SetConfig(config Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.config = config
+}
+
+// Close 停止发送感谢
+func (t *Tracker) Close() {
+	t.once.Do(func() {
+		close(t.done)
+	})
+}
+
+// Observe 处理礼物或点赞事件，其他事件忽略
+func (t *Tracker) Observe(evt *event.Event) {
+	if evt.Type != event.TypeGift && evt.Type != event.TypeLike {
+		return
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := evt.TenantID + "/" + evt.Channel + "/" + evt.RoomID
+	r, ok := t.rooms[key]
+	if !ok {
+		r = &room{
+			revenue: Revenue{TenantID: evt.TenantID, Channel: evt.Channel, RoomID: evt.RoomID, ByGift: make(map[string]int64)},
+			senders: make(map[string]bool),
+			likes:   make(map[string]*likeWindow),
+		}
+		t.rooms[key] = r
+	}
+	r.revenue.UpdatedAt = now
+
+	switch evt.Type {
+	case event.TypeGift:
+		t.observeGift(r, evt, now)
+	case event.TypeLike:
+		t.observeLike(r, evt, now)
+	}
+}
+
+// observeGift 累计收入，达到感谢门槛的礼物进入待感谢列表
+func (t *Tracker) observeGift(r *room, evt *event.Event, now time.Time) {
+	gift := evt.Gift
+	if gift == nil {
+		gift = &event.GiftPayload{Count: 1}
+	}
+	count := gift.Count
+	if count <= 0 {
+		count = 1
+	}
+	name := gift.GiftName
+	if name == "" {
+		name = "礼物"
+	}
+	value := count * gift.Value
+
+	r.revenue.Gifts += count
+	r.revenue.Value += value
+	r.revenue.ByGift[name] += value
+	if !r.senders[evt.UserID] {
+		r.senders[evt.UserID] = true
+		r.revenue.Senders++
+	}
+
+	if !t.config.ThankGifts || value < t.config.MinValue {
+		return
+	}
+	item := fmt.Sprintf("%s 送出的 %s", evt.Nickname, name)
+	if count > 1 {
+		item = fmt.Sprintf("%s×%d", item, count)
+	}
+	if len(r.gifts) == 0 {
+		r.giftSince = now
+	}
+	r.gifts = append(r.gifts, item)
+	r.lastGift = evt
+}
+
+// observeLike 按用户聚合窗口内的点赞，达到门槛的用户进入待感谢列表
+func (t *Tracker) observeLike(r *room, evt *event.Event, now time.Time) {
+	count := int64(1)
+	if evt.Like != nil && evt.Like.Count > 0 {
+		count = evt.Like.Count
+	}
+	r.revenue.Likes += count
+
+	w, ok := r.likes[evt.UserID]
+	if !ok || now.Sub(w.start) >= t.config.LikeWindow {
+		w = &likeWindow{start: now}
+		r.likes[evt.UserID] = w
+	}
+	w.count += count
+
+	if t.config.LikeThreshold > 0 && !w.thanked && w.count >= t.config.LikeThreshold {
+		w.thanked = true
+		if len(r.likeNames) == 0 {
+			r.likeSince = now
+		}
+		r.likeNames = append(r.likeNames, evt.Nickname)
+		r.lastLike = evt
+	}
+}
+
+// flushLoop 定期发送合并后的感谢消息并清理过期的点赞窗口
+func (t *Tracker) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case now := <-ticker.C:
+			t.flush(now)
+		}
+	}
+}
+
+// flush 发送到期的感谢消息
+func (t *Tracker) flush(now time.Time) {
+	type message struct {
+		evt     *event.Event
+		content string
+	}
+	var messages []message
+
+	t.mu.Lock()
+	config := t.config
+	for _, r := range t.rooms {
+		if len(r.gifts) > 0 && now.Sub(r.giftSince) >= config.ThankWindow {
+			content := strings.ReplaceAll(config.GiftTemplate, "{gifts}", joinNames(r.gifts, config.MaxNames, "、", "份礼物"))
+			messages = append(messages, message{evt: r.lastGift, content: content})
+			r.gifts, r.lastGift = nil, nil
+		}
+		if len(r.likeNames) > 0 && now.Sub(r.likeSince) >= config.ThankWindow {
+			content := strings.ReplaceAll(config.LikeTemplate, "{names}", joinNames(r.likeNames, config.MaxNames, " ", "位"))
+			messages = append(messages, message{evt: r.lastLike, content: content})
+			r.likeNames, r.lastLike = nil, nil
+		}
+		for userID, w := range r.likes {
+			if now.Sub(w.start) >= config.LikeWindow {
+				delete(r.likes, userID)
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	if t.send == nil {
+		return
+	}
+	for _, m := range messages {
+		err := t.send(m.evt, m.content)
+		switch {
+		case errors.Is(err, reply.ErrRoomMessageUnsupported):
+			log.Printf("⏭️ 跳过感谢: room=%s, %v", m.evt.RoomID, err)
+		case err != nil:
+			log.Printf("❌ 发送感谢失败: room=%s, err=%v", m.evt.RoomID, err)
+		default:
+			log.Printf("🎁 已发送感谢: room=%s, 内容=%s", m.evt.RoomID, m.content)
+		}
+	}
+}
+
+// joinNames 拼接条目，超出上限时显示 "等N位"、"等N份礼物"
+func joinNames(items []string, max int, sep, unit string) string {
+	if max > 0 && len(items) > max {
+		return fmt.Sprintf("%s 等%d%s", strings.Join(items[:max], sep), len(items), unit)
+	}
+	return strings.Join(items, sep)
+}
+
+// Revenue 直播间收入统计，tenantID 为空时返回全部
+func (t *Tracker) Revenue(tenantID string) []Revenue {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]Revenue, 0, len(t.rooms))
+	for _, r := range t.rooms {
+		if tenantID != "" && r.revenue.TenantID != tenantID {
+			continue
+		}
+		revenue := r.revenue
+		revenue.ByGift = make(map[string]int64, len(r.revenue.ByGift))
+		for name, value := range r.revenue.ByGift {
+			revenue.ByGift[name] = value
+		}
+		result = append(result, revenue)
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Value > result[b].Value })
+	return result
+}
@@ -0,0 +1,132 @@
+package handoff
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"live-im-proxy/auth"
+)
+
+// RegisterHandlers 注册人工客服API
+//
+//	GET  /api/handoff/conversations?state=&tenant_id=   会话列表
+//	GET  /api/handoff/conversations/{id}                会话详情
+//	POST /api/handoff/conversations/{id}/takeover       人工接管 {"agent": "..."}
+//	POST /api/handoff/conversations/{id}/reply          人工回复 {"agent": "...", "content": "..."}
+//	POST /api/handoff/conversations/{id}/release        交还机器人
+//	POST /api/handoff/conversations/{id}/close          结束会话
+func (m *Manager) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/api/handoff/conversations", m.handleList)
+	mux.HandleFunc("/api/handoff/conversations/", m.handleConversation)
+}
+
+// agentRequest 客服操作请求
+type agentRequest struct {
+	Agent   string `json:"agent"`
+	Content string `json:"content"`
+}
+
+// handleList 会话列表
+func (m *Manager) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    m.List(q.Get("state"), q.Get("tenant_id")),
+	})
+}
+
+// handleConversation 单个会话的查询和操作
+func (m *Manager) handleConversation(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/handoff/conversations/")
+	parts := strings.SplitN(path, "/", 2)
+	conversationID := parts[0]
+	if conversationID == "" {
+		http.Error(w, "缺少会话ID", http.StatusBadRequest)
+		return
+	}
+
+	// 租户密钥只能访问本租户的会话
+	conv, ok := m.Get(conversationID)
+	if !ok || !auth.CanAccessTenant(r, conv.TenantID) {
+		http.Error(w, "会话不存在", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": conv})
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req agentRequest
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	switch parts[1] {
+	case "takeover":
+		if req.Agent == "" {
+			http.Error(w, "缺少参数: agent", http.StatusBadRequest)
+			return
+		}
+		conv, err = m.Takeover(conversationID, req.Agent)
+	case "reply":
+		if req.Agent == "" {
+			http.Error(w, "缺少参数: agent", http.StatusBadRequest)
+			return
+		}
+		if err = m.Reply(conversationID, req.Agent, req.Content); err == nil {
+			conv, _ = m.Get(conversationID)
+		}
+	case "release":
+		conv, err = m.Release(conversationID)
+	case "close":
+		conv, err = m.CloseConversation(conversationID)
+	default:
+		http.Error(w, "不支持的操作: "+parts[1], http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		status := http.StatusConflict
+		switch {
+		case errors.Is(err, ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, ErrSendFailed):
+			status = http.StatusBadGateway
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": conv})
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,407 @@
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/intent"
+)
+
+// 会话状态
+const (
+	StateBot          = "bot"           // 机器人自动回复
+	StatePendingHuman = "pending_human" // 等待人工接入
+	StateHuman        = "human"         // 人工接管中
+	StateClosed       = "closed"        // 已结束
+)
+
+var (
+	// ErrNotFound 会话不存在
+	ErrNotFound = errors.New("会话不存在")
+	// ErrSendFailed 人工回复未能发出
+	ErrSendFailed = errors.New("人工回复发送失败")
+)
+
+// DefaultKeywords 默认转人工关键词
+var DefaultKeywords = []string{"人工", "客服", "真人", "转人工"}
+
+// DefaultIntents 默认转人工的意图
+var DefaultIntents = []string{intent.Complaint, intent.AfterSales}
+
+// Sender 人工回复发送器，复用管道的私信发送链路
+type Sender interface {
+	SendAgentReply(evt *event.Event, content string) error
+}
+
+// Config 人机切换配置
+type Config struct {
+	Keywords       []string      // 命中即转人工的关键词
+	Intents        []string      // 识别为这些意图的消息转人工，见 intent 包
+	ScoreThreshold int           // 会话评分达到阈值转人工，0表示不启用
+	IdleTimeout    time.Duration // 人工会话无活动多久后自动交还机器人
+	Notice         string        // 转人工时发给用户的提示语
+}
+
+// DefaultConfig 默认配置。
+// 会话评分每收到一条消息先减半再加上该消息的线索评分，普通私信（4分）稳定在7分，
+// 连续询价的私信（7分）依次为7、10、12、13分，阈值12即连续三次询价转人工
+func DefaultConfig() Config {
+	return Config{
+		Keywords:       DefaultKeywords,
+		Intents:        DefaultIntents,
+		ScoreThreshold: 12,
+		IdleTimeout:    10 * time.Minute,
+		Notice:         "正在为您转接人工客服，请稍候～",
+	}
+}
+
+// Conversation 私信会话
+type Conversation struct {
+	ID           string       `json:"conversation_id"`
+	TenantID     string       `json:"tenant_id"`
+	Channel      string       `json:"channel"`
+	UserID       string       `json:"user_id"`
+	Nickname     string       `json:"nickname"`
+	State        string       `json:"state"`
+	Agent        string       `json:"agent,omitempty"`
+	Reason       string       `json:"reason,omitempty"` // 转人工原因
+	Score        int          `json:"score"`            // 会话评分，旧评分随每条新消息减半
+	LastMessage  string       `json:"last_message"`
+	LastActivity time.Time    `json:"last_activity"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	LastEvent    *event.Event `json:"last_event,omitempty"` // 最近一条用户消息，人工回复经它定位会话
+}
+
+// Decision 收到用户私信后的处理决定
+type Decision struct {
+	BotReply bool   // 是否由机器人自动回复
+	Notice   string // 刚触发转人工时需要发送的提示语
+}
+
+// Manager 人机切换管理器。会话状态保存在 Store 中，
+// 使用共享的文件存储时，重启和多副本部署下各副本看到相同的转人工状态
+type Manager struct {
+	config Config
+	sender Sender
+	store  Store
+	mu     sync.RWMutex
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewManager 创建人机切换管理器，并启动空闲会话回收；path 为空时会话状态只保存在内存
+func NewManager(config Config, sender Sender, path string) (*Manager, error) {
+	var store Store = NewMemoryStore()
+	if path != "" {
+		fileStore, err := NewFileStore(path)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	}
+	m := &Manager{
+		config: config,
+		sender: sender,
+		store:  store,
+		done:   make(chan struct{}),
+	}
+	go m.sweep()
+	return m, nil
+}
+
+// SetConfig 更新配置，对已有会话的后续消息生效
+func (m *Manager) SetConfig(config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.config = config
+}
+
+// getConfig 当前配置
+func (m *Manager) getConfig() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// Close 停止空闲会话回收
+func (m *Manager) Close() {
+	m.once.Do(func() {
+		close(m.done)
+	})
+}
+
+// HandleMessage 记录用户私信并判断是否需要转人工，
+// score为本条消息的线索评分，计入会话评分
+func (m *Manager) HandleMessage(evt *event.Event, score int) Decision {
+	conversationID := evt.ConversationID()
+	if conversationID == "" {
+		return Decision{BotReply: true}
+	}
+
+	config := m.getConfig()
+	decision := Decision{BotReply: true}
+	err := m.store.Update(func(conversations map[string]*Conversation) bool {
+		now := time.Now()
+		conv, ok := conversations[conversationID]
+		if !ok || conv.State == StateClosed {
+			conv = &Conversation{
+				ID:       conversationID,
+				TenantID: evt.TenantID,
+				Channel:  evt.Channel,
+				UserID:   evt.UserID,
+				State:    StateBot,
+			}
+			conversations[conversationID] = conv
+		}
+
+		conv.Nickname = evt.Nickname
+		conv.Score = conv.Score/2 + score
+		conv.LastMessage = evt.Content
+		conv.LastActivity = now
+		conv.LastEvent = evt
+
+		if conv.State != StateBot {
+			// 已在等待人工或人工接管中，机器人不再回复
+			decision = Decision{BotReply: false}
+			return true
+		}
+
+		reason := triggerReason(config, evt, conv)
+		if reason == "" {
+			return true
+		}
+
+		conv.State = StatePendingHuman
+		conv.Reason = reason
+		conv.UpdatedAt = now
+		log.Printf("🙋 会话转人工: conversation=%s, user=%s, 原因=%s", conv.ID, conv.Nickname, reason)
+		decision = Decision{BotReply: false, Notice: config.Notice}
+		return true
+	})
+	if err != nil {
+		log.Printf("❌ 更新会话状态失败，由机器人回复: conversation=%s, %v", conversationID, err)
+		return Decision{BotReply: true}
+	}
+	return decision
+}
+
+// triggerReason 检查转人工条件，返回触发原因
+func triggerReason(config Config, evt *event.Event, conv *Conversation) string {
+	for _, keyword := range config.Keywords {
+		if keyword != "" && strings.Contains(evt.Content, keyword) {
+			return "keyword:" + keyword
+		}
+	}
+
+	if name := intent.Of(evt); name != "" {
+		for _, target := range config.Intents {
+			if target == name {
+				return "intent:" + name
+			}
+		}
+	}
+
+	if config.ScoreThreshold > 0 && conv.Score >= config.ScoreThreshold {
+		return fmt.Sprintf("score:%d", conv.Score)
+	}
+
+	return ""
+}
+
+// List 列出会话，state和tenantID为空时不过滤
+func (m *Manager) List(state, tenantID string) []Conversation {
+	result := make([]Conversation, 0)
+	err := m.store.Update(func(conversations map[string]*Conversation) bool {
+		for _, conv := range conversations {
+			if state != "" && conv.State != state {
+				continue
+			}
+			if tenantID != "" && conv.TenantID != tenantID {
+				continue
+			}
+			result = append(result, *conv)
+		}
+		return false
+	})
+	if err != nil {
+		log.Printf("❌ 读取会话状态失败: %v", err)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastActivity.After(result[j].LastActivity)
+	})
+	return result
+}
+
+// Get 获取会话
+func (m *Manager) Get(conversationID string) (Conversation, bool) {
+	var conv Conversation
+	var found bool
+	err := m.store.Update(func(conversations map[string]*Conversation) bool {
+		if c, ok := conversations[conversationID]; ok {
+			conv, found = *c, true
+		}
+		return false
+	})
+	if err != nil {
+		log.Printf("❌ 读取会话状态失败: %v", err)
+	}
+	return conv, found
+}
+
+// Takeover 人工接管会话
+func (m *Manager) Takeover(conversationID, agent string) (Conversation, error) {
+	return m.transition(conversationID, func(conv *Conversation) error {
+		if conv.State == StateClosed {
+			return fmt.Errorf("会话已结束")
+		}
+		if conv.State == StateHuman && conv.Agent != "" && conv.Agent != agent {
+			return fmt.Errorf("会话已由 %s 接管", conv.Agent)
+		}
+		conv.State = StateHuman
+		conv.Agent = agent
+		if conv.Reason == "" {
+			conv.Reason = "manual"
+		}
+		return nil
+	})
+}
+
+// Release 将会话交还给机器人
+func (m *Manager) Release(conversationID string) (Conversation, error) {
+	return m.transition(conversationID, func(conv *Conversation) error {
+		if conv.State == StateClosed {
+			return fmt.Errorf("会话已结束")
+		}
+		conv.toBot()
+		return nil
+	})
+}
+
+// CloseConversation 结束会话，用户再次发消息时重新由机器人接待
+func (m *Manager) CloseConversation(conversationID string) (Conversation, error) {
+	return m.transition(conversationID, func(conv *Conversation) error {
+		conv.State = StateClosed
+		return nil
+	})
+}
+
+// Reply 人工回复，未接管的会话会自动由该客服接管
+func (m *Manager) Reply(conversationID, agent, content string) error {
+	if content == "" {
+		return fmt.Errorf("回复内容不能为空")
+	}
+
+	conv, err := m.transition(conversationID, func(conv *Conversation) error {
+		if conv.State == StateClosed {
+			return fmt.Errorf("会话已结束")
+		}
+		if conv.State == StateHuman && conv.Agent != "" && conv.Agent != agent {
+			return fmt.Errorf("会话已由 %s 接管", conv.Agent)
+		}
+		conv.State = StateHuman
+		conv.Agent = agent
+		conv.LastActivity = time.Now()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if conv.LastEvent == nil {
+		return fmt.Errorf("%w: 会话没有可回复的消息", ErrSendFailed)
+	}
+
+	log.Printf("👩‍💼 人工回复: conversation=%s, agent=%s, 内容=%s", conversationID, agent, content)
+	if err := m.sender.SendAgentReply(conv.LastEvent, content); err != nil {
+		return fmt.Errorf("%w: %v", ErrSendFailed, err)
+	}
+	return nil
+}
+
+// transition 在存储的锁内修改会话状态
+func (m *Manager) transition(conversationID string, fn func(conv *Conversation) error) (Conversation, error) {
+	var result Conversation
+	var err error
+	updateErr := m.store.Update(func(conversations map[string]*Conversation) bool {
+		conv, ok := conversations[conversationID]
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrNotFound, conversationID)
+			return false
+		}
+		if err = fn(conv); err != nil {
+			return false
+		}
+		conv.UpdatedAt = time.Now()
+		log.Printf("🔁 会话状态变更: conversation=%s, state=%s, agent=%s", conv.ID, conv.State, conv.Agent)
+		result = *conv
+		return true
+	})
+	if updateErr != nil {
+		return Conversation{}, updateErr
+	}
+	if err != nil {
+		return Conversation{}, err
+	}
+	return result, nil
+}
+
+// toBot 交还机器人并重置会话评分，避免立即再次触发
+func (c *Conversation) toBot() {
+	c.State = StateBot
+	c.Agent = ""
+	c.Reason = ""
+	c.Score = 0
+}
+
+// conversationTTL 机器人接待和已结束的会话无活动超过该时长后删除，用户再次发消息时重新创建
+const conversationTTL = 24 * time.Hour
+
+// sweep 定期回收空闲会话
+func (m *Manager) sweep() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.expire(now)
+		}
+	}
+}
+
+// expire 将长时间无活动的人工会话交还机器人，并删除过期的会话
+func (m *Manager) expire(now time.Time) {
+	config := m.getConfig()
+	err := m.store.Update(func(conversations map[string]*Conversation) bool {
+		changed := false
+		for id, conv := range conversations {
+			idle := now.Sub(conv.LastActivity)
+			switch conv.State {
+			case StateHuman, StatePendingHuman:
+				if config.IdleTimeout > 0 && idle >= config.IdleTimeout {
+					log.Printf("⏰ 会话空闲超时，交还机器人: conversation=%s", conv.ID)
+					conv.toBot()
+					conv.UpdatedAt = now
+					changed = true
+				}
+			default:
+				if idle >= conversationTTL {
+					delete(conversations, id)
+					changed = true
+				}
+			}
+		}
+		return changed
+	})
+	if err != nil {
+		log.Printf("❌ 回收空闲会话失败: %v", err)
+	}
+}
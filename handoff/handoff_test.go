@@ -0,0 +1,190 @@
+package handoff
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/intent"
+)
+
+// fakeSender 记录人工回复
+type fakeSender struct {
+	replies []string
+	err     error
+}
+
+func (s *fakeSender) SendAgentReply(evt *event.Event, content string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.replies = append(s.replies, content)
+	return nil
+}
+
+func newTestManager(t *testing.T, path string) (*Manager, *fakeSender) {
+	t.Helper()
+	sender := &fakeSender{}
+	m, err := NewManager(DefaultConfig(), sender, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m, sender
+}
+
+func message(content string) *event.Event {
+	evt := event.NewEvent(event.TypePrivateMessage, "douyin", "", "user", "张三")
+	evt.TenantID = "tenant"
+	evt.SetContent(content)
+	evt.SetPrivateMessage(event.PrivateMessagePayload{MessageID: content, ConversationID: "conv"})
+	return evt
+}
+
+func TestHandoffLifecycle(t *testing.T) {
+	m, sender := newTestManager(t, "")
+
+	if d := m.HandleMessage(message("你好"), 4); !d.BotReply || d.Notice != "" {
+		t.Fatalf("decision = %+v, 普通私信应由机器人回复", d)
+	}
+
+	d := m.HandleMessage(message("转人工"), 4)
+	if d.BotReply || d.Notice == "" {
+		t.Fatalf("decision = %+v, 命中关键词应转人工并提示", d)
+	}
+	conv, _ := m.Get("conv")
+	if conv.State != StatePendingHuman || conv.Reason != "keyword:人工" {
+		t.Fatalf("conv = %+v, 应等待人工", conv)
+	}
+	// 提示语只在刚转人工时发送一次
+	if d := m.HandleMessage(message("在吗"), 4); d.BotReply || d.Notice != "" {
+		t.Fatalf("decision = %+v, 等待人工期间机器人不回复也不重复提示", d)
+	}
+
+	if _, err := m.Takeover("conv", "agent-a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Takeover("conv", "agent-b"); err == nil {
+		t.Fatal("已被其他客服接管的会话不应被抢占")
+	}
+	if err := m.Reply("conv", "agent-a", "您好，我是客服小王"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.replies) != 1 {
+		t.Fatalf("replies = %v, 人工回复应经发送器发出", sender.replies)
+	}
+	if conv, _ := m.Get("conv"); conv.State != StateHuman || conv.Agent != "agent-a" {
+		t.Fatalf("conv = %+v, 应由 agent-a 接管", conv)
+	}
+
+	if _, err := m.CloseConversation("conv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reply("conv", "agent-a", "还在吗"); err == nil {
+		t.Fatal("已结束的会话不能回复")
+	}
+	// 结束后用户再次发消息，重新由机器人接待
+	if d := m.HandleMessage(message("你好"), 4); !d.BotReply {
+		t.Fatalf("decision = %+v, 结束的会话应重新由机器人接待", d)
+	}
+	if conv, _ := m.Get("conv"); conv.State != StateBot || conv.Score != 4 {
+		t.Fatalf("conv = %+v, 应为新的机器人会话", conv)
+	}
+}
+
+func TestReplySendFailure(t *testing.T) {
+	m, sender := newTestManager(t, "")
+	m.HandleMessage(message("你好"), 4)
+	sender.err = errors.New("私信接口错误")
+	if err := m.Reply("conv", "agent", "您好"); !errors.Is(err, ErrSendFailed) {
+		t.Fatalf("err = %v, 发送失败应返回 ErrSendFailed", err)
+	}
+	if err := m.Reply("missing", "agent", "您好"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, 不存在的会话应返回 ErrNotFound", err)
+	}
+}
+
+func TestScoreIsBounded(t *testing.T) {
+	m, _ := newTestManager(t, "")
+	for i := 0; i < 20; i++ {
+		if d := m.HandleMessage(message("你好"), 4); !d.BotReply {
+			t.Fatalf("第%d条普通私信触发了转人工", i+1)
+		}
+	}
+	if conv, _ := m.Get("conv"); conv.Score != 7 {
+		t.Fatalf("Score = %d, 普通私信的评分应稳定在7", conv.Score)
+	}
+
+	m2, _ := newTestManager(t, "")
+	var reason string
+	for i := 1; i <= 3; i++ {
+		if d := m2.HandleMessage(message("这个价格能便宜点吗"), 7); !d.BotReply {
+			conv, _ := m2.Get("conv")
+			reason = conv.Reason
+			if i != 3 {
+				t.Fatalf("第%d条询价私信就触发了转人工", i)
+			}
+		}
+	}
+	if reason != "score:12" {
+		t.Fatalf("reason = %q, 连续三次询价应按评分转人工", reason)
+	}
+}
+
+func TestIntentTrigger(t *testing.T) {
+	m, _ := newTestManager(t, "")
+	evt := message("收到的东西坏了")
+	evt.SetMetadata(intent.MetadataKey, intent.AfterSales)
+	if d := m.HandleMessage(evt, 4); d.BotReply {
+		t.Fatal("售后意图应转人工")
+	}
+	if conv, _ := m.Get("conv"); conv.Reason != "intent:after_sales" {
+		t.Fatalf("Reason = %q", conv.Reason)
+	}
+}
+
+func TestIdleTimeoutReturnsToBot(t *testing.T) {
+	m, _ := newTestManager(t, "")
+	m.HandleMessage(message("转人工"), 4)
+	if _, err := m.Takeover("conv", "agent"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.expire(time.Now().Add(5 * time.Minute))
+	if conv, _ := m.Get("conv"); conv.State != StateHuman {
+		t.Fatalf("State = %s, 未超时的人工会话不应交还", conv.State)
+	}
+	m.expire(time.Now().Add(11 * time.Minute))
+	conv, _ := m.Get("conv")
+	if conv.State != StateBot || conv.Agent != "" || conv.Score != 0 {
+		t.Fatalf("conv = %+v, 空闲超时应交还机器人并清零评分", conv)
+	}
+
+	m.expire(time.Now().Add(25 * time.Hour))
+	if _, ok := m.Get("conv"); ok {
+		t.Fatal("长时间无活动的机器人会话应删除")
+	}
+}
+
+func TestFileStoreSharedAndPersistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "handoff.json")
+	a, _ := newTestManager(t, path)
+	b, sender := newTestManager(t, path)
+
+	a.HandleMessage(message("转人工"), 4)
+	if d := b.HandleMessage(message("在吗"), 4); d.BotReply {
+		t.Fatal("其他副本转人工的会话，本副本的机器人不应回复")
+	}
+	if err := b.Reply("conv", "agent", "您好"); err != nil || len(sender.replies) != 1 {
+		t.Fatalf("err = %v, 其他副本应能回复保存的最近消息", err)
+	}
+
+	// 重启后保留人工接管状态
+	restarted, _ := newTestManager(t, path)
+	conv, ok := restarted.Get("conv")
+	if !ok || conv.State != StateHuman || conv.Agent != "agent" || conv.LastEvent == nil || conv.LastEvent.ConversationID() != "conv" {
+		t.Fatalf("conv = %+v, 重启后应保留会话状态", conv)
+	}
+}
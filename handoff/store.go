@@ -0,0 +1,88 @@
+package handoff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Store 会话状态存储
+type Store interface {
+	// Update 在排他锁内读取全部会话，fn 返回 true 时写回
+	Update(fn func(conversations map[string]*Conversation) bool) error
+}
+
+// MemoryStore 只保存在本进程内的会话状态，重启后丢失
+type MemoryStore struct {
+	mu            sync.Mutex
+	conversations map[string]*Conversation
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{conversations: make(map[string]*Conversation)}
+}
+
+// Update 在锁内修改会话
+func (s *MemoryStore) Update(fn func(conversations map[string]*Conversation) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.conversations)
+	return nil
+}
+
+// FileStore 基于文件锁的会话存储，重启后保留转人工状态，同一主机上的多个副本共享。
+// 每次操作都在 flock 排他锁内读取、修改并原子替换状态文件
+type FileStore struct {
+	path string
+}
+
+// NewFileStore 创建文件存储
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("创建会话状态目录失败: %v", err)
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Update 加锁读取会话，fn 返回 true 时写回
+func (f *FileStore) Update(fn func(conversations map[string]*Conversation) bool) error {
+	lock, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("打开锁文件失败: %v", err)
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("加锁失败: %v", err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	conversations := make(map[string]*Conversation)
+	data, err := os.ReadFile(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取会话状态失败: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &conversations); err != nil {
+			return fmt.Errorf("解析会话状态失败: %v", err)
+		}
+	}
+
+	if !fn(conversations) {
+		return nil
+	}
+
+	data, err = json.Marshal(conversations)
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入会话状态失败: %v", err)
+	}
+	return os.Rename(tmp, f.path)
+}
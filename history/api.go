@@ -0,0 +1,65 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler 会话记录查询API
+//
+//	GET /api/conversations/history?tenant_id=&channel=&user_id=&since=&until=&limit=
+//
+// since/until 支持Unix秒或RFC3339格式
+func (s *Store) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	query := Query{
+		TenantID: q.Get("tenant_id"),
+		Channel:  q.Get("channel"),
+		UserID:   q.Get("user_id"),
+		Limit:    100,
+	}
+
+	var err error
+	if query.Since, err = parseTime(q.Get("since")); err != nil {
+		http.Error(w, "since 格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Until, err = parseTime(q.Get("until")); err != nil {
+		http.Error(w, "until 格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit <= 0 {
+			http.Error(w, "limit 格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    s.Find(query),
+	})
+}
+
+// parseTime 解析Unix秒或RFC3339时间
+func parseTime(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
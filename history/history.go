@@ -0,0 +1,394 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"live-im-proxy/bus"
+	"live-im-proxy/event"
+)
+
+// 消息方向
+const (
+	DirectionInbound  = "inbound"  // 用户发来的评论/私信
+	DirectionOutbound = "outbound" // 发出的回复
+)
+
+// DefaultMaxPerUser 每个用户保留的最大记录数
+const DefaultMaxPerUser = 200
+
+// DefaultMaxAge 记录保留时长，超过后从内存和持久化文件中清除
+const DefaultMaxAge = 30 * 24 * time.Hour
+
+// compactInterval 清理过期记录、压缩持久化文件的间隔
+const compactInterval = time.Hour
+
+// Entry 一条会话记录
+type Entry struct {
+	ID             string `json:"id"`
+	EventID        string `json:"event_id"`
+	TenantID       string `json:"tenant_id"`
+	Channel        string `json:"channel"`
+	UserID         string `json:"user_id"`
+	Nickname       string `json:"nickname,omitempty"`
+	Direction      string `json:"direction"`
+	Type           string `json:"type"`             // comment, video_comment, private_message
+	Source         string `json:"source,omitempty"` // 回复来源: bot, agent
+	Content        string `json:"content"`
+	RoomID         string `json:"room_id,omitempty"`
+	VideoID        string `json:"video_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	CommentID      string `json:"comment_id,omitempty"`
+	MessageID      string `json:"message_id,omitempty"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// Query 查询条件，Since/Until为Unix秒，0表示不限
+type Query struct {
+	TenantID string
+	Channel  string
+	UserID   string
+	Since    int64
+	Until    int64
+	Limit    int
+}
+
+// Store 会话记录存储，按 租户/渠道/用户 分组
+// 每个用户最多保留maxPerUser条、maxAge内的记录，持久化文件定期压缩为保留的记录
+type Store struct {
+	mu         sync.RWMutex
+	entries    map[string][]Entry
+	maxPerUser int
+	maxAge     time.Duration
+	path       string
+	file       *os.File
+	lines      int // 持久化文件中的记录行数
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	now        func() time.Time
+}
+
+// NewStore 创建会话记录存储，maxPerUser/maxAge<=0时使用默认值
+// path不为空时以JSON行格式追加持久化，并在启动时加载已有记录
+func NewStore(path string, maxPerUser int, maxAge time.Duration) (*Store, error) {
+	if maxPerUser <= 0 {
+		maxPerUser = DefaultMaxPerUser
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	s := &Store{
+		entries:    make(map[string][]Entry),
+		maxPerUser: maxPerUser,
+		maxAge:     maxAge,
+		path:       path,
+		stopCh:     make(chan struct{}),
+		now:        time.Now,
+	}
+
+	if path != "" {
+		if err := s.load(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.Compact(); err != nil {
+		return nil, err
+	}
+	if path != "" && s.file == nil {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("打开会话记录文件失败: %v", err)
+		}
+		s.file = f
+	}
+
+	go s.compactLoop()
+	return s, nil
+}
+
+// Subscribe 订阅事件总线，记录收到的消息和已发送的回复
+func (s *Store) Subscribe(b *bus.Bus) *bus.Subscription {
+	return b.SubscribeReliable("history", 1024, s.handle, bus.TopicEventReceived, bus.TopicReplySent)
+}
+
+// Close 停止定期压缩并关闭持久化文件
+func (s *Store) Close() error {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// Add 添加一条记录
+func (s *Store) Add(entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.append(entry)
+
+	if s.file != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("❌ 序列化会话记录失败: %v", err)
+			return
+		}
+		if _, err := s.file.Write(append(data, '\n')); err != nil {
+			log.Printf("❌ 写入会话记录失败: %v", err)
+			return
+		}
+		s.lines++
+	}
+}
+
+// Find 按条件查询，结果按时间正序，超过Limit时保留最新的
+func (s *Store) Find(q Query) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Entry
+	for _, entries := range s.entries {
+		for _, entry := range entries {
+			if q.TenantID != "" && entry.TenantID != q.TenantID {
+				continue
+			}
+			if q.Channel != "" && entry.Channel != q.Channel {
+				continue
+			}
+			if q.UserID != "" && entry.UserID != q.UserID {
+				continue
+			}
+			if q.Since > 0 && entry.Timestamp < q.Since {
+				continue
+			}
+			if q.Until > 0 && entry.Timestamp > q.Until {
+				continue
+			}
+			result = append(result, entry)
+		}
+	}
+
+	sortByTime(result)
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[len(result)-q.Limit:]
+	}
+	return result
+}
+
+// Recent 获取某个用户最近n条记录，用于AI上下文
+func (s *Store) Recent(tenantID, channel, userID string, n int) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.entries[key(tenantID, channel, userID)]
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	result := make([]Entry, len(entries))
+	copy(result, entries)
+	return result
+}
+
+// Compact 清除过期记录和无记录的用户；持久化文件中有已清除的记录时，
+// 将保留的记录写入临时文件后原子替换
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.now().Add(-s.maxAge).Unix()
+	kept := 0
+	for k, entries := range s.entries {
+		var fresh []Entry
+		for _, entry := range entries {
+			if entry.Timestamp >= cutoff {
+				fresh = append(fresh, entry)
+			}
+		}
+		if len(fresh) == 0 {
+			delete(s.entries, k)
+			continue
+		}
+		if len(fresh) < len(entries) {
+			s.entries[k] = fresh
+		}
+		kept += len(fresh)
+	}
+
+	if s.path == "" || s.lines <= kept {
+		return nil
+	}
+	return s.rewrite(kept)
+}
+
+// rewrite 在锁内将内存中的记录按时间顺序重写到持久化文件
+func (s *Store) rewrite(kept int) error {
+	all := make([]Entry, 0, kept)
+	for _, entries := range s.entries {
+		all = append(all, entries...)
+	}
+	sortByTime(all)
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("创建会话记录临时文件失败: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range all {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("写入会话记录临时文件失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("写入会话记录临时文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("替换会话记录文件失败: %v", err)
+	}
+
+	// 原文件已被替换，重新打开追加写入
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		s.file = nil
+		return fmt.Errorf("打开会话记录文件失败: %v", err)
+	}
+	log.Printf("🧹 会话记录文件已压缩: %d -> %d 条", s.lines, len(all))
+	s.lines = len(all)
+	return nil
+}
+
+// compactLoop 定期压缩
+func (s *Store) compactLoop() {
+	ticker := time.NewTicker(compactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if err := s.Compact(); err != nil {
+				log.Printf("❌ 压缩会话记录失败: %v", err)
+			}
+		}
+	}
+}
+
+// handle 处理总线消息
+func (s *Store) handle(msg *bus.Message) {
+	evt := msg.Event
+	if evt == nil {
+		return
+	}
+
+	switch msg.Topic {
+	case bus.TopicEventReceived:
+		if evt.Content == "" {
+			return
+		}
+		entry := newEntry(evt, DirectionInbound, evt.Content)
+		entry.ID = evt.ID
+		entry.Timestamp = evt.Timestamp
+		s.Add(entry)
+	case bus.TopicReplySent:
+		if msg.Reply == nil {
+			return
+		}
+		entry := newEntry(evt, DirectionOutbound, msg.Reply.Content)
+		entry.ID = msg.Reply.ID
+		entry.Source = msg.Reply.Source
+		entry.Timestamp = msg.Reply.Timestamp
+		s.Add(entry)
+	}
+}
+
+// append 在锁内追加记录并裁剪
+func (s *Store) append(entry Entry) {
+	k := key(entry.TenantID, entry.Channel, entry.UserID)
+	entries := append(s.entries[k], entry)
+	if len(entries) > s.maxPerUser {
+		entries = entries[len(entries)-s.maxPerUser:]
+	}
+	s.entries[k] = entries
+}
+
+// load 加载已持久化的记录
+func (s *Store) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取会话记录文件失败: %v", err)
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("⚠️ 跳过无法解析的会话记录: %v", err)
+			continue
+		}
+		s.append(entry)
+		count++
+	}
+	s.lines = count
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取会话记录文件失败: %v", err)
+	}
+
+	log.Printf("📚 已加载 %d 条会话记录", count)
+	return nil
+}
+
+// newEntry 根据事件构建记录
+func newEntry(evt *event.Event, direction, content string) Entry {
+	entry := Entry{
+		EventID:   evt.ID,
+		TenantID:  evt.TenantID,
+		Channel:   evt.Channel,
+		UserID:    evt.UserID,
+		Nickname:  evt.Nickname,
+		Direction: direction,
+		Type:      evt.Type,
+		Content:   content,
+		RoomID:    evt.RoomID,
+		VideoID:   evt.VideoID,
+	}
+	entry.ConversationID = evt.ConversationID()
+	entry.CommentID = evt.CommentID()
+	entry.MessageID = evt.MessageID()
+	return entry
+}
+
+// key 分组键
+func key(tenantID, channel, userID string) string {
+	return tenantID + "/" + channel + "/" + userID
+}
+
+// sortByTime 按时间正序排序
+func sortByTime(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp < entries[j].Timestamp
+	})
+}
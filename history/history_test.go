@@ -0,0 +1,155 @@
+package history
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"live-im-proxy/bus"
+	"live-im-proxy/event"
+	"live-im-proxy/reply"
+)
+
+func entry(userID, content string, ts int64) Entry {
+	return Entry{TenantID: "tenant", Channel: "douyin", UserID: userID, Direction: DirectionInbound, Content: content, Timestamp: ts}
+}
+
+// countLines 统计持久化文件的行数
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestRecentKeepsLatestPerUser(t *testing.T) {
+	s, err := NewStore("", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now().Unix()
+	for i, content := range []string{"一", "二", "三", "四"} {
+		s.Add(entry("u1", content, now+int64(i)))
+	}
+	s.Add(entry("u2", "其他用户", now))
+
+	recent := s.Recent("tenant", "douyin", "u1", 10)
+	if len(recent) != 3 || recent[0].Content != "二" || recent[2].Content != "四" {
+		t.Fatalf("每个用户应只保留最新3条: %+v", recent)
+	}
+	if recent := s.Recent("tenant", "douyin", "u1", 1); len(recent) != 1 || recent[0].Content != "四" {
+		t.Fatalf("应返回最近1条: %+v", recent)
+	}
+	if recent := s.Recent("tenant", "kuaishou", "u1", 10); len(recent) != 0 {
+		t.Fatal("不同渠道的记录不应混在一起")
+	}
+}
+
+func TestHandleRecordsInboundAndReplies(t *testing.T) {
+	s, err := NewStore("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	evt := event.NewEvent(event.TypeComment, "douyin", "room", "u1", "昵称")
+	evt.TenantID = "tenant"
+	evt.Content = "多少钱"
+	s.handle(&bus.Message{Topic: bus.TopicEventReceived, Event: evt})
+	s.handle(&bus.Message{Topic: bus.TopicReplySent, Event: evt, Reply: &reply.Reply{ID: "r1", Content: "99元", Source: "bot", Timestamp: evt.Timestamp}})
+
+	recent := s.Recent("tenant", "douyin", "u1", 10)
+	if len(recent) != 2 || recent[0].Direction != DirectionInbound || recent[1].Direction != DirectionOutbound || recent[1].Content != "99元" {
+		t.Fatalf("应记录收到的消息和回复: %+v", recent)
+	}
+}
+
+func TestReloadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := NewStore(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	s.Add(entry("u1", "你好", now))
+	s.Add(entry("u1", "在吗", now+1))
+	s.Close()
+
+	reloaded, err := NewStore(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	recent := reloaded.Recent("tenant", "douyin", "u1", 10)
+	if len(recent) != 2 || recent[1].Content != "在吗" {
+		t.Fatalf("重启后应加载已持久化的记录: %+v", recent)
+	}
+
+	reloaded.Add(entry("u1", "追加", now+2))
+	if n := countLines(t, path); n != 3 {
+		t.Fatalf("重启后应继续追加写入，文件有 %d 行", n)
+	}
+}
+
+func TestCompactDropsExpiredAndTrimmedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := NewStore(path, 2, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour).Unix()
+	s.Add(entry("gone", "过期", old))
+	s.Add(entry("u1", "一", now.Unix()))
+	s.Add(entry("u1", "二", now.Unix()))
+	s.Add(entry("u1", "三", now.Unix()))
+	if n := countLines(t, path); n != 4 {
+		t.Fatalf("压缩前文件应有4行，实际 %d", n)
+	}
+
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Recent("tenant", "douyin", "gone", 10)) != 0 {
+		t.Fatal("过期记录应被清除")
+	}
+	if _, ok := s.entries[key("tenant", "douyin", "gone")]; ok {
+		t.Fatal("没有记录的用户应从内存中移除")
+	}
+	if n := countLines(t, path); n != 2 {
+		t.Fatalf("压缩后文件应只保留2行，实际 %d", n)
+	}
+
+	// 压缩后继续追加到新文件
+	s.Add(entry("u2", "新用户", now.Unix()))
+	if n := countLines(t, path); n != 3 {
+		t.Fatalf("压缩后应继续追加写入，文件有 %d 行", n)
+	}
+
+	s.Close()
+	reloaded, err := NewStore(path, 2, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.Close()
+	if recent := reloaded.Recent("tenant", "douyin", "u1", 10); len(recent) != 2 || recent[0].Content != "二" {
+		t.Fatalf("重启后应加载压缩后的记录: %+v", recent)
+	}
+	if n := countLines(t, path); n != 3 {
+		t.Fatalf("未过期的记录不应被清除，文件有 %d 行", n)
+	}
+}
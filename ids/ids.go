@@ -0,0 +1,94 @@
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encoding Crockford Base32 字母表，不含 I L O U，避免混淆
+const encoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Length ID 长度：10位毫秒时间戳 + 16位随机数
+const Length = 26
+
+var (
+	mu       sync.Mutex
+	lastMS   uint64
+	lastRand [10]byte
+)
+
+// New 生成 ULID 格式的唯一ID
+//
+// 前48位为毫秒时间戳，后80位为随机数，按字典序即按生成时间排序。
+// 同一毫秒内生成的ID在上一个随机数基础上递增，保证进程内单调且不重复。
+func New() string {
+	return NewAt(time.Now())
+}
+
+// NewAt 以指定时间生成ID
+func NewAt(t time.Time) string {
+	ms := uint64(t.UnixMilli())
+
+	mu.Lock()
+	if ms <= lastMS {
+		// 同一毫秒或时钟回拨：沿用上一个时间戳，随机部分加一
+		ms = lastMS
+		increment(&lastRand)
+	} else {
+		lastMS = ms
+		if _, err := rand.Read(lastRand[:]); err != nil {
+			panic("ids: 读取随机数失败: " + err.Error())
+		}
+	}
+	entropy := lastRand
+	mu.Unlock()
+
+	var raw [16]byte
+	binary.BigEndian.PutUint16(raw[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(raw[2:6], uint32(ms))
+	copy(raw[6:], entropy[:])
+	return encode(raw)
+}
+
+// Time 解析ID中的生成时间，非法ID返回零值
+func Time(id string) time.Time {
+	if len(id) != Length {
+		return time.Time{}
+	}
+	var ms uint64
+	for _, c := range strings.ToUpper(id[:10]) {
+		i := strings.IndexRune(encoding, c)
+		if i < 0 {
+			return time.Time{}
+		}
+		ms = ms<<5 | uint64(i)
+	}
+	return time.UnixMilli(int64(ms))
+}
+
+// increment 随机部分按大端整数加一，溢出时回绕（同一毫秒内需生成2^80个ID才会发生）
+func increment(b *[10]byte) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return
+		}
+	}
+}
+
+// encode 128位按5位一组编码为26个字符，首字符只含高3位
+func encode(raw [16]byte) string {
+	hi := binary.BigEndian.Uint64(raw[0:8])
+	lo := binary.BigEndian.Uint64(raw[8:16])
+
+	var out [Length]byte
+	for i := Length - 1; i >= 0; i-- {
+		out[i] = encoding[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
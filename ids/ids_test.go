@@ -0,0 +1,104 @@
+package ids
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewFormat(t *testing.T) {
+	id := New()
+	if len(id) != Length {
+		t.Fatalf("len(%q) = %d, 应为 %d", id, len(id), Length)
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(encoding, c) {
+			t.Fatalf("%q 包含 Crockford Base32 以外的字符 %q", id, c)
+		}
+	}
+}
+
+// resetClock 清除上一次生成的时间戳，使 NewAt 可以使用更早的时间
+func resetClock() {
+	mu.Lock()
+	lastMS = 0
+	mu.Unlock()
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	resetClock()
+	at := time.UnixMilli(1700000000123)
+	id := NewAt(at)
+	if got := Time(id); !got.Equal(at) {
+		t.Fatalf("Time(%q) = %v, 应为 %v", id, got, at)
+	}
+	if got := Time(strings.ToLower(id)); !got.Equal(at) {
+		t.Fatalf("小写ID解析为 %v, 应为 %v", got, at)
+	}
+}
+
+func TestTimeInvalid(t *testing.T) {
+	for _, id := range []string{"", "short", strings.Repeat("U", Length)} {
+		if got := Time(id); !got.IsZero() {
+			t.Fatalf("Time(%q) = %v, 非法ID应返回零值", id, got)
+		}
+	}
+}
+
+func TestNewAtMonotonic(t *testing.T) {
+	resetClock()
+	at := time.Now()
+	prev := NewAt(at)
+	// 同一毫秒和时钟回拨时仍按字典序递增
+	for _, next := range []time.Time{at, at, at.Add(-time.Second)} {
+		id := NewAt(next)
+		if id <= prev {
+			t.Fatalf("%q 应大于上一个ID %q", id, prev)
+		}
+		prev = id
+	}
+	// 时钟回拨期间沿用上一个时间戳
+	if got := Time(prev); !got.Equal(time.UnixMilli(at.UnixMilli())) {
+		t.Fatalf("回拨后的ID时间 = %v, 应沿用 %v", got, at)
+	}
+}
+
+func TestNewConcurrentUnique(t *testing.T) {
+	const workers, perWorker = 8, 1000
+	var mu sync.Mutex
+	seen := make(map[string]bool, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := New()
+				mu.Lock()
+				if seen[id] {
+					mu.Unlock()
+					t.Errorf("重复的ID %q", id)
+					return
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIncrementCarries(t *testing.T) {
+	b := [10]byte{0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff}
+	increment(&b)
+	if b[8] != 0x02 || b[9] != 0x00 {
+		t.Fatalf("increment = %x, 应向高位进位", b)
+	}
+
+	full := [10]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	increment(&full)
+	if full != [10]byte{} {
+		t.Fatalf("increment = %x, 溢出时应回绕为0", full)
+	}
+}
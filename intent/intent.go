@@ -0,0 +1,59 @@
+package intent
+
+import (
+	"strings"
+
+	"live-im-proxy/event"
+)
+
+// 意图
+const (
+	Complaint  = "complaint"   // 投诉、差评
+	AfterSales = "after_sales" // 退换货、售后
+	Price      = "price"       // 询价、优惠
+	Purchase   = "purchase"    // 购买方式、下单
+	Logistics  = "logistics"   // 发货、物流
+)
+
+// MetadataKey 意图写入 event.Metadata 的键
+const MetadataKey = "intent"
+
+// rules 各意图的关键词，按优先级排列：同时命中时投诉和售后优先于询价
+var rules = []struct {
+	intent   string
+	keywords []string
+}{
+	{Complaint, []string{"投诉", "举报", "差评", "骗子", "骗人", "欺骗", "维权", "12315"}},
+	{AfterSales, []string{"退货", "退款", "换货", "售后", "质保", "保修", "坏了", "破损", "少发", "发错"}},
+	{Price, []string{"多少钱", "价格", "价钱", "几块", "怎么卖", "优惠", "便宜", "折扣", "券"}},
+	{Purchase, []string{"怎么买", "购买", "下单", "链接", "小黄车", "拍哪", "在哪买", "有货"}},
+	{Logistics, []string{"发货", "快递", "物流", "几天到", "包邮", "运费"}},
+}
+
+// Known 是否为已定义的意图
+func Known(name string) bool {
+	for _, rule := range rules {
+		if rule.intent == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify 按关键词识别文本的意图，未命中时返回空
+func Classify(content string) string {
+	for _, rule := range rules {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(content, keyword) {
+				return rule.intent
+			}
+		}
+	}
+	return ""
+}
+
+// Of 事件元数据中记录的意图
+func Of(evt *event.Event) string {
+	name, _ := evt.Metadata[MetadataKey].(string)
+	return name
+}
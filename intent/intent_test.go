@@ -0,0 +1,38 @@
+package intent
+
+import (
+	"testing"
+
+	"live-im-proxy/event"
+)
+
+func TestClassify(t *testing.T) {
+	cases := map[string]string{
+		"这个多少钱":       Price,
+		"怎么买？链接在哪":    Purchase,
+		"几天能发货":       Logistics,
+		"收到坏了要退款":     AfterSales,
+		"退款太慢了我要投诉":   Complaint,
+		"主播今天好漂亮":     "",
+		"有优惠吗，坏了能换货吗": AfterSales,
+	}
+	for content, want := range cases {
+		if got := Classify(content); got != want {
+			t.Errorf("Classify(%q) = %q, 应为 %q", content, got, want)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	evt := event.NewEvent(event.TypeComment, "douyin", "room", "user", "昵称")
+	if Of(evt) != "" {
+		t.Fatal("未识别意图的事件应返回空")
+	}
+	evt.SetMetadata(MetadataKey, Price)
+	if Of(evt) != Price {
+		t.Fatalf("Of = %q, 应为 %q", Of(evt), Price)
+	}
+	if !Known(Price) || Known("unknown") {
+		t.Fatal("Known 应只接受已定义的意图")
+	}
+}
@@ -0,0 +1,274 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/openapi"
+)
+
+// maxImport 导入请求体上限
+const maxImport = 4 << 20
+
+// Service 知识库管理API
+type Service struct {
+	base          *Base
+	defaultTenant string
+}
+
+// NewService 创建知识库管理服务
+func NewService(base *Base, defaultTenant string) *Service {
+	return &Service{base: base, defaultTenant: defaultTenant}
+}
+
+// ImportRequest JSON格式的批量导入请求
+type ImportRequest struct {
+	TenantID string  `json:"tenant_id,omitempty" doc:"未指定时使用默认租户"`
+	Replace  bool    `json:"replace,omitempty" doc:"删除租户下未出现在本次导入中的条目"`
+	Entries  []Entry `json:"entries"`
+}
+
+// RegisterRoutes 注册知识库API
+//
+//	GET    /api/v1/knowledge           条目列表 ?tenant_id=
+//	POST   /api/v1/knowledge           新建条目
+//	POST   /api/v1/knowledge/import    批量导入，JSON 或 CSV（Content-Type: text/csv，?tenant_id=&replace=true）
+//	GET    /api/v1/knowledge/search    检索调试 ?q=&tenant_id=&limit=
+//	GET    /api/v1/knowledge/{id}      条目详情
+//	PUT    /api/v1/knowledge/{id}      修改条目
+//	DELETE /api/v1/knowledge/{id}      删除条目
+func (s *Service) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/knowledge", Tag: "knowledge",
+		Summary:  "知识库条目列表",
+		Query:    []openapi.Param{{Name: "tenant_id", Description: "按租户过滤"}},
+		Response: []Entry{}, HandlerFunc: s.handleList,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/knowledge", Tag: "knowledge",
+		Summary: "新建知识库条目", Request: Entry{}, Response: Entry{},
+		Status: http.StatusCreated, HandlerFunc: s.handleCreate,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/knowledge/import", Tag: "knowledge",
+		Summary: "批量导入知识库条目，按ID或相同的标准问题更新；也支持 text/csv 请求体（表头 question,similar,answer）",
+		Query: []openapi.Param{
+			{Name: "tenant_id", Description: "CSV导入的租户"},
+			{Name: "replace", Description: "CSV导入时为 true 表示删除未出现的条目"},
+		},
+		Request: ImportRequest{}, Response: ImportResult{}, HandlerFunc: s.handleImport,
+	})
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/knowledge/search", Tag: "knowledge",
+		Summary: "检索知识库，返回各条目的置信度，用于调整 reply.knowledge 阈值",
+		Query: []openapi.Param{
+			{Name: "q", Description: "问题"},
+			{Name: "tenant_id", Description: "租户，未指定时使用默认租户"},
+			{Name: "limit", Description: "返回条数，默认5"},
+		},
+		Response: []Hit{}, HandlerFunc: s.handleSearch,
+	})
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/knowledge/{id}", Tag: "knowledge",
+		Summary: "知识库条目详情", Response: Entry{}, HandlerFunc: s.handleGet,
+	})
+	router.Handle(openapi.Route{
+		Method: "PUT", Path: "/api/v1/knowledge/{id}", Tag: "knowledge",
+		Summary: "修改知识库条目（整体覆盖）", Request: Entry{}, Response: Entry{}, HandlerFunc: s.handleUpdate,
+	})
+	router.Handle(openapi.Route{
+		Method: "DELETE", Path: "/api/v1/knowledge/{id}", Tag: "knowledge",
+		Summary: "删除知识库条目", HandlerFunc: s.handleDelete,
+	})
+}
+
+// tenant 请求中的租户，未指定时使用默认租户
+func (s *Service) tenant(tenantID string) string {
+	if tenantID == "" {
+		return s.defaultTenant
+	}
+	return tenantID
+}
+
+// lookup 读取路径中的条目ID，不存在或无权访问时返回404
+func (s *Service) lookup(w http.ResponseWriter, r *http.Request) (Entry, bool) {
+	entry, ok := s.base.Get(openapi.PathParam(r, "id"))
+	if !ok || !auth.CanAccessTenant(r, entry.TenantID) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// handleList 条目列表，租户密钥只能看到本租户的条目
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	entries := make([]Entry, 0)
+	for _, entry := range s.base.List(r.URL.Query().Get("tenant_id")) {
+		if auth.CanAccessTenant(r, entry.TenantID) {
+			entries = append(entries, entry)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": entries})
+}
+
+// handleCreate 新建条目
+func (s *Service) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var entry Entry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry.TenantID = s.tenant(entry.TenantID)
+	if !auth.CanAccessTenant(r, entry.TenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+	if err := entry.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.base.Create(entry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("📚 知识条目已创建: tenant=%s, question=%s", created.TenantID, created.Question)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"success": true, "data": created})
+}
+
+// handleImport 批量导入条目
+func (s *Service) handleImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImport)
+
+	var req ImportRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		entries, err := ParseCSV(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		req = ImportRequest{TenantID: q.Get("tenant_id"), Replace: q.Get("replace") == "true", Entries: entries}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID := s.tenant(req.TenantID)
+	if !auth.CanAccessTenant(r, tenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+	if len(req.Entries) == 0 && !req.Replace {
+		http.Error(w, "没有要导入的条目", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Entries {
+		if err := req.Entries[i].Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("第%d条: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := s.base.Import(tenantID, req.Entries, req.Replace)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("📚 知识库已导入: tenant=%s, 新建=%d, 更新=%d, 删除=%d", tenantID, result.Created, result.Updated, result.Deleted)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": result})
+}
+
+// handleSearch 检索调试
+func (s *Service) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("q") == "" {
+		http.Error(w, "缺少参数: q", http.StatusBadRequest)
+		return
+	}
+	tenantID := s.tenant(q.Get("tenant_id"))
+	if !auth.CanAccessTenant(r, tenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 5
+	}
+
+	hits := s.base.Search(tenantID, q.Get("q"), limit)
+	if hits == nil {
+		hits = []Hit{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": hits})
+}
+
+// handleGet 条目详情
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": entry})
+}
+
+// handleUpdate 修改条目
+func (s *Service) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	existing, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	var entry Entry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := entry.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.base.Update(existing.ID, entry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("📚 知识条目已修改: tenant=%s, question=%s", updated.TenantID, updated.Question)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": updated})
+}
+
+// handleDelete 删除条目
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := s.base.Delete(entry.ID); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("🗑️ 知识条目已删除: tenant=%s, question=%s", entry.TenantID, entry.Question)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// writeError 存储错误转换为HTTP状态码
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,68 @@
+package knowledge
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvColumns 支持的CSV列，首行为表头，列顺序不限，question 和 answer 必填
+//
+//	question,similar,answer
+//	发货要几天,什么时候发货|多久能到,下单后48小时内发货，一般3-5天送达
+//
+// similar 用 "|" 分隔多个相似问法
+var csvColumns = []string{"id", "question", "similar", "answer"}
+
+// ParseCSV 解析CSV格式的问答条目
+func ParseCSV(r io.Reader) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV缺少表头")
+	}
+
+	index := make(map[string]int)
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		index[name] = i
+	}
+	for _, required := range []string{"question", "answer"} {
+		if _, ok := index[required]; !ok {
+			return nil, fmt.Errorf("CSV缺少 %s 列，支持的列: %s", required, strings.Join(csvColumns, ","))
+		}
+	}
+
+	entries := make([]Entry, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		field := func(column string) string {
+			if i, ok := index[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		if field("question") == "" && field("answer") == "" {
+			continue
+		}
+
+		var similar []string
+		for _, item := range strings.Split(field("similar"), "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				similar = append(similar, item)
+			}
+		}
+		entries = append(entries, Entry{
+			ID:       field("id"),
+			Question: field("question"),
+			Similar:  similar,
+			Answer:   field("answer"),
+		})
+	}
+	return entries, nil
+}
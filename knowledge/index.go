@@ -0,0 +1,145 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// doc 一个问法（标准问题或相似问法）
+type doc struct {
+	entry  *Entry
+	tf     map[string]int
+	length int
+}
+
+// index 单个租户的 BM25 索引
+type index struct {
+	docs   []doc
+	df     map[string]int
+	avgLen float64
+}
+
+// newIndex 为租户的知识条目建立索引，每个问法作为一篇文档
+func newIndex(entries []*Entry) *index {
+	idx := &index{df: make(map[string]int)}
+	total := 0
+	for _, entry := range entries {
+		for _, question := range entry.questions() {
+			tokens := Tokenize(question)
+			if len(tokens) == 0 {
+				continue
+			}
+			d := doc{entry: entry, tf: make(map[string]int), length: len(tokens)}
+			for _, token := range tokens {
+				d.tf[token]++
+			}
+			for token := range d.tf {
+				idx.df[token]++
+			}
+			idx.docs = append(idx.docs, d)
+			total += len(tokens)
+		}
+	}
+	if len(idx.docs) > 0 {
+		idx.avgLen = float64(total) / float64(len(idx.docs))
+	}
+	return idx
+}
+
+// idf 检索词的逆文档频率，未出现过的词权重最高
+func (idx *index) idf(token string) float64 {
+	n := float64(len(idx.docs))
+	df := float64(idx.df[token])
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// weight 计算置信度时检索词的权重：知识库中没有的词按只出现一次计，
+// 避免条目很少时问题里的闲聊词压低置信度；中文双字词可能跨词（如 "能退"），权重减半
+func (idx *index) weight(token string) float64 {
+	n := float64(len(idx.docs))
+	df := math.Max(float64(idx.df[token]), 1)
+	w := math.Log(1 + (n-df+0.5)/(df+0.5))
+	if utf8.RuneCountInString(token) == 2 && !isASCII(token) {
+		w /= 2
+	}
+	return w
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// search 检索最相关的条目，同一条目的多个问法取最高分
+//
+// Score 为 BM25 得分，只用于排序；Confidence 为问题和问法按检索词权重的双向覆盖率
+// （几何平均，0~1），用于判断能否直接用答案回复
+func (idx *index) search(query string, limit int) []Hit {
+	terms := make(map[string]bool)
+	for _, token := range Tokenize(query) {
+		terms[token] = true
+	}
+	if len(terms) == 0 || len(idx.docs) == 0 {
+		return nil
+	}
+
+	var queryWeight float64
+	for term := range terms {
+		queryWeight += idx.weight(term)
+	}
+
+	best := make(map[string]Hit)
+	for _, d := range idx.docs {
+		var score, matched float64
+		for term := range terms {
+			tf := float64(d.tf[term])
+			if tf == 0 {
+				continue
+			}
+			idf := idx.idf(term)
+			matched += idx.weight(term)
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(d.length)/idx.avgLen))
+		}
+		if score == 0 {
+			continue
+		}
+
+		var docWeight float64
+		for term := range d.tf {
+			docWeight += idx.weight(term)
+		}
+		confidence := math.Sqrt(matched / queryWeight * matched / docWeight)
+
+		hit := best[d.entry.ID]
+		hit.Entry = *d.entry
+		hit.Score = math.Max(hit.Score, score)
+		hit.Confidence = math.Max(hit.Confidence, confidence)
+		best[d.entry.ID] = hit
+	}
+
+	hits := make([]Hit, 0, len(best))
+	for _, hit := range best {
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Confidence != hits[j].Confidence {
+			return hits[i].Confidence > hits[j].Confidence
+		}
+		return hits[i].Score > hits[j].Score
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
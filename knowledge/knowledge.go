@@ -0,0 +1,355 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/ids"
+)
+
+// ErrNotFound 知识条目不存在
+var ErrNotFound = errors.New("知识条目不存在")
+
+// Entry 审核过的问答条目
+type Entry struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Question  string    `json:"question"`
+	Similar   []string  `json:"similar,omitempty" doc:"相似问法，与标准问题一起参与检索"`
+	Answer    string    `json:"answer"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate 校验必填字段
+func (e *Entry) Validate() error {
+	if strings.TrimSpace(e.Question) == "" {
+		return fmt.Errorf("缺少参数: question")
+	}
+	if strings.TrimSpace(e.Answer) == "" {
+		return fmt.Errorf("缺少参数: answer")
+	}
+	return nil
+}
+
+// questions 标准问题和相似问法
+func (e *Entry) questions() []string {
+	return append([]string{e.Question}, e.Similar...)
+}
+
+// Hit 检索结果
+type Hit struct {
+	Entry      Entry   `json:"entry"`
+	Score      float64 `json:"score"`      // BM25 得分
+	Confidence float64 `json:"confidence"` // 匹配置信度 0~1
+}
+
+// Config 知识库检索配置
+type Config struct {
+	AnswerThreshold  float64 `json:"answer_threshold"`  // 置信度不低于该值时直接用答案回复，不调用AI
+	ContextThreshold float64 `json:"context_threshold"` // 置信度不低于该值的条目作为参考资料提供给AI
+	ContextSize      int     `json:"context_size"`      // 提供给AI的参考资料条数上限，0表示不提供
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		AnswerThreshold:  0.7,
+		ContextThreshold: 0.3,
+		ContextSize:      3,
+	}
+}
+
+// Result 一次检索的回复依据
+type Result struct {
+	Answer  *Hit  // 可直接回复的条目
+	Context []Hit // 提供给AI的参考资料
+}
+
+// Base 按租户保存的本地知识库，设置了路径时以JSON文件持久化；
+// 检索索引按租户在首次检索时建立，条目变更后重建
+type Base struct {
+	path     string
+	config   Config
+	entries  map[string]*Entry
+	indexes  map[string]*index
+	onChange func(tenantID string)
+	mu       sync.RWMutex
+}
+
+// NewBase 创建知识库，path 为空时只保存在内存
+func NewBase(path string, config Config) (*Base, error) {
+	b := &Base{
+		path:    path,
+		config:  config,
+		entries: make(map[string]*Entry),
+		indexes: make(map[string]*index),
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取知识库失败: %v", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析知识库失败: %v", err)
+	}
+	for _, entry := range entries {
+		b.entries[entry.ID] = entry
+	}
+	return b, nil
+}
+
+// SetConfig 更新检索配置
+func (b *Base) SetConfig(config Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.config = config
+}
+
+// SetOnChange 设置知识库变更回调，参数为变更的租户；回调在持有锁时同步调用，不能再访问知识库
+func (b *Base) SetOnChange(fn func(tenantID string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// changed 通知知识库变更，需在写锁内调用
+func (b *Base) changed(tenantID string) {
+	if b.onChange != nil {
+		b.onChange(tenantID)
+	}
+}
+
+// List 租户的知识条目，tenantID 为空时返回全部
+func (b *Base) List(tenantID string) []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]Entry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		if tenantID == "" || entry.TenantID == tenantID {
+			result = append(result, *entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TenantID != result[j].TenantID {
+			return result[i].TenantID < result[j].TenantID
+		}
+		return result[i].Question < result[j].Question
+	})
+	return result
+}
+
+// Get 按ID查询条目
+func (b *Base) Get(id string) (Entry, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	entry, ok := b.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// Create 新建条目
+func (b *Base) Create(entry Entry) (Entry, error) {
+	if err := entry.Validate(); err != nil {
+		return Entry{}, err
+	}
+	entry.ID = ids.New()
+	entry.UpdatedAt = time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[entry.ID] = &entry
+	delete(b.indexes, entry.TenantID)
+	b.changed(entry.TenantID)
+	return entry, b.save()
+}
+
+// Update 覆盖已有条目，ID和租户不可修改
+func (b *Base) Update(id string, entry Entry) (Entry, error) {
+	if err := entry.Validate(); err != nil {
+		return Entry{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	existing, ok := b.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	entry.ID = existing.ID
+	entry.TenantID = existing.TenantID
+	entry.UpdatedAt = time.Now()
+	b.entries[id] = &entry
+	delete(b.indexes, entry.TenantID)
+	b.changed(entry.TenantID)
+	return entry, b.save()
+}
+
+// Delete 删除条目
+func (b *Base) Delete(id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, ok := b.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(b.entries, id)
+	delete(b.indexes, entry.TenantID)
+	b.changed(entry.TenantID)
+	return b.save()
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// Import 批量导入租户条目：按ID或相同的标准问题更新，其余新建；
+// replace 为 true 时删除租户下未出现在导入数据中的条目
+func (b *Base) Import(tenantID string, entries []Entry, replace bool) (ImportResult, error) {
+	for i := range entries {
+		if err := entries[i].Validate(); err != nil {
+			return ImportResult{}, fmt.Errorf("第%d条: %v", i+1, err)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	byQuestion := make(map[string]*Entry)
+	for _, entry := range b.entries {
+		if entry.TenantID == tenantID {
+			byQuestion[entry.Question] = entry
+		}
+	}
+
+	var result ImportResult
+	now := time.Now()
+	kept := make(map[string]bool, len(entries))
+	for i := range entries {
+		entry := entries[i]
+		existing, ok := b.entries[entry.ID]
+		if !ok || existing.TenantID != tenantID {
+			existing, ok = byQuestion[entry.Question]
+		}
+		if ok {
+			entry.ID = existing.ID
+			result.Updated++
+		} else {
+			entry.ID = ids.New()
+			result.Created++
+		}
+		entry.TenantID = tenantID
+		entry.UpdatedAt = now
+		b.entries[entry.ID] = &entry
+		byQuestion[entry.Question] = &entry
+		kept[entry.ID] = true
+	}
+
+	if replace {
+		for id, entry := range b.entries {
+			if entry.TenantID == tenantID && !kept[id] {
+				delete(b.entries, id)
+				result.Deleted++
+			}
+		}
+	}
+	delete(b.indexes, tenantID)
+	b.changed(tenantID)
+	return result, b.save()
+}
+
+// Search 检索租户知识库，按置信度排序
+func (b *Base) Search(tenantID, query string, limit int) []Hit {
+	return b.index(tenantID).search(query, limit)
+}
+
+// Lookup 按配置的阈值给出回复依据：最相关条目置信度足够时直接回复，否则提供参考资料
+func (b *Base) Lookup(tenantID, query string) Result {
+	b.mu.RLock()
+	config := b.config
+	b.mu.RUnlock()
+
+	hits := b.Search(tenantID, query, config.ContextSize+1)
+	if len(hits) > 0 && hits[0].Confidence >= config.AnswerThreshold {
+		return Result{Answer: &hits[0]}
+	}
+	var result Result
+	for _, hit := range hits {
+		if len(result.Context) < config.ContextSize && hit.Confidence >= config.ContextThreshold {
+			result.Context = append(result.Context, hit)
+		}
+	}
+	return result
+}
+
+// index 租户的检索索引，不存在时建立
+func (b *Base) index(tenantID string) *index {
+	b.mu.RLock()
+	idx, ok := b.indexes[tenantID]
+	b.mu.RUnlock()
+	if ok {
+		return idx
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if idx, ok := b.indexes[tenantID]; ok {
+		return idx
+	}
+	var entries []*Entry
+	for _, entry := range b.entries {
+		if entry.TenantID == tenantID {
+			copied := *entry
+			entries = append(entries, &copied)
+		}
+	}
+	idx = newIndex(entries)
+	b.indexes[tenantID] = idx
+	return idx
+}
+
+// save 持久化到文件，需在写锁内调用
+func (b *Base) save() error {
+	if b.path == "" {
+		return nil
+	}
+
+	entries := make([]*Entry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("创建知识库存储目录失败: %v", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入知识库失败: %v", err)
+	}
+	return os.Rename(tmp, b.path)
+}
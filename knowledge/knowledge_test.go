@@ -0,0 +1,220 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestBase(t *testing.T, entries ...Entry) *Base {
+	t.Helper()
+	b, err := NewBase("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Import("t1", entries, false); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTokenize(t *testing.T) {
+	got := strings.Join(Tokenize("能退货吗? iPhone15"), ",")
+	if got != "能,能退,退,退货,货,iphone15" {
+		t.Fatalf("Tokenize = %s", got)
+	}
+	if tokens := Tokenize("我的吗"); len(tokens) != 0 {
+		t.Fatalf("停用字和语气词不应单独成词: %v", tokens)
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	b := newTestBase(t,
+		Entry{Question: "发货要几天", Similar: []string{"什么时候发货"}, Answer: "48小时内发货"},
+		Entry{Question: "支持七天无理由退货吗", Answer: "支持"},
+		Entry{Question: "退货运费谁出", Answer: "买家承担"},
+	)
+
+	hits := b.Search("t1", "什么时候发货", 0)
+	if len(hits) == 0 || hits[0].Entry.Answer != "48小时内发货" {
+		t.Fatalf("相似问法应命中发货条目: %+v", hits)
+	}
+	if hits[0].Confidence < 0.99 {
+		t.Fatalf("与相似问法完全一致时置信度应为1，实际 %.2f", hits[0].Confidence)
+	}
+
+	hits = b.Search("t1", "退货运费", 0)
+	if len(hits) != 3 {
+		t.Fatalf("共享检索词的条目都应命中，实际 %d", len(hits))
+	}
+	if hits[0].Entry.Question != "退货运费谁出" || hits[1].Entry.Question != "支持七天无理由退货吗" {
+		t.Fatalf("覆盖更多检索词的条目应排在前面: %+v", hits)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i-1].Score <= hits[i].Score || hits[i-1].Confidence <= hits[i].Confidence {
+			t.Fatalf("排序错误: %+v", hits)
+		}
+	}
+
+	if hits := b.Search("t1", "退货运费", 1); len(hits) != 1 {
+		t.Fatalf("limit 应截断结果，实际 %d", len(hits))
+	}
+	if hits := b.Search("t1", "优惠券", 0); len(hits) != 0 {
+		t.Fatalf("无关问题不应命中: %+v", hits)
+	}
+	if hits := b.Search("t2", "发货要几天", 0); len(hits) != 0 {
+		t.Fatal("不应检索到其他租户的条目")
+	}
+}
+
+func TestLookupThreshold(t *testing.T) {
+	b := newTestBase(t,
+		Entry{Question: "发货要几天", Answer: "48小时内发货"},
+		Entry{Question: "退货运费谁出", Answer: "买家承担"},
+		Entry{Question: "支持七天无理由退货吗", Answer: "支持"},
+	)
+
+	result := b.Lookup("t1", "发货要几天呀")
+	if result.Answer == nil || result.Answer.Entry.Answer != "48小时内发货" {
+		t.Fatalf("置信度足够时应直接回复: %+v", result)
+	}
+
+	result = b.Lookup("t1", "退货的运费怎么算")
+	if result.Answer != nil {
+		t.Fatalf("置信度不足时不应直接回复: %+v", result.Answer)
+	}
+	if len(result.Context) != 1 || result.Context[0].Entry.Question != "退货运费谁出" {
+		t.Fatalf("只有置信度达到 ContextThreshold 的条目作为参考资料: %+v", result.Context)
+	}
+	if c := result.Context[0].Confidence; c >= DefaultConfig().AnswerThreshold || c < DefaultConfig().ContextThreshold {
+		t.Fatalf("参考资料置信度应在阈值之间: %.2f", c)
+	}
+
+	b.SetConfig(Config{AnswerThreshold: 1.1, ContextThreshold: 0.3, ContextSize: 1})
+	result = b.Lookup("t1", "发货要几天")
+	if result.Answer != nil {
+		t.Fatal("提高阈值后不应直接回复")
+	}
+	if len(result.Context) != 1 || result.Context[0].Entry.Answer != "48小时内发货" {
+		t.Fatalf("参考资料应按 ContextSize 截断: %+v", result.Context)
+	}
+
+	b.SetConfig(Config{AnswerThreshold: 1.1, ContextThreshold: 0.3})
+	if result := b.Lookup("t1", "发货要几天"); result.Answer != nil || len(result.Context) != 0 {
+		t.Fatalf("ContextSize 为0时不提供参考资料: %+v", result)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	data := "\ufeffQuestion,similar,answer\n" +
+		"发货要几天,什么时候发货| 多久能到 ,下单后48小时内发货\n" +
+		",,\n" +
+		"退货运费谁出,,买家承担\n"
+
+	entries, err := ParseCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("应解析出2条，实际 %d", len(entries))
+	}
+	if entries[0].Question != "发货要几天" || entries[0].Answer != "下单后48小时内发货" {
+		t.Fatalf("字段解析错误: %+v", entries[0])
+	}
+	if len(entries[0].Similar) != 2 || entries[0].Similar[1] != "多久能到" {
+		t.Fatalf("similar 解析错误: %q", entries[0].Similar)
+	}
+	if entries[1].Similar != nil {
+		t.Fatalf("similar 留空时应为空: %q", entries[1].Similar)
+	}
+
+	for data, want := range map[string]string{
+		"":                         "表头",
+		"question,reply\n问,答\n":    "answer 列",
+		"answer\n答\n":              "question 列",
+		"question,answer\n\"问,答\n": "解析CSV失败",
+	} {
+		if _, err := ParseCSV(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCSV(%q) 错误 = %v, 应包含 %q", data, err, want)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	b := newTestBase(t,
+		Entry{Question: "发货要几天", Answer: "48小时内发货"},
+		Entry{Question: "退货运费谁出", Answer: "买家承担"},
+	)
+	var changed []string
+	b.SetOnChange(func(tenantID string) { changed = append(changed, tenantID) })
+
+	if _, err := b.Import("t1", []Entry{{Question: "缺答案"}}, false); err == nil {
+		t.Fatal("缺少 answer 应报错")
+	}
+
+	result, err := b.Import("t1", []Entry{
+		{Question: "发货要几天", Answer: "24小时内发货"},
+		{Question: "有赠品吗", Answer: "送杯套"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Created: 1, Updated: 1, Deleted: 1}) {
+		t.Fatalf("导入结果错误: %+v", result)
+	}
+	if len(changed) != 1 || changed[0] != "t1" {
+		t.Fatalf("导入应通知变更: %v", changed)
+	}
+
+	hits := b.Search("t1", "发货要几天", 1)
+	if len(hits) != 1 || hits[0].Entry.Answer != "24小时内发货" {
+		t.Fatalf("导入后索引应重建: %+v", hits)
+	}
+	if hits := b.Search("t1", "运费谁出", 0); len(hits) != 0 {
+		t.Fatal("replace 应删除未导入的条目")
+	}
+
+	if _, err := b.Import("t2", []Entry{{Question: "发货要几天", Answer: "当天发货"}}, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.List("t1")) != 2 || len(b.List("t2")) != 1 {
+		t.Fatal("导入不应影响其他租户")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "knowledge.json")
+	b, err := NewBase(path, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := b.Create(Entry{TenantID: "t1", Question: "发货要几天", Similar: []string{"多久能到"}, Answer: "48小时内发货"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := b.Create(Entry{TenantID: "t1", Question: "退货运费谁出", Answer: "买家承担"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(removed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Delete(removed.ID); err != ErrNotFound {
+		t.Fatalf("重复删除应返回 ErrNotFound，实际 %v", err)
+	}
+
+	reloaded, err := NewBase(path, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reloaded.Get(entry.ID)
+	if !ok || got.Answer != entry.Answer || len(got.Similar) != 1 {
+		t.Fatalf("重新加载后条目丢失: %+v", got)
+	}
+	if _, ok := reloaded.Get(removed.ID); ok {
+		t.Fatal("已删除的条目不应被重新加载")
+	}
+	if result := reloaded.Lookup("t1", "多久能到"); result.Answer == nil || result.Answer.Entry.ID != entry.ID {
+		t.Fatalf("重新加载后应能检索: %+v", result)
+	}
+}
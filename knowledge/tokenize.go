@@ -0,0 +1,60 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// particles 语气词和虚词，不单独作为检索词，也不参与组成双字词
+const particles = "的了吗呢啊呀吧哈嘛哦么啦"
+
+// stopwords 常见但区分度低的单字，只作为双字词的一部分参与检索
+const stopwords = "我你他她它是个这那"
+
+// Tokenize 切分检索词：英文和数字按连续字母数字为一个词，
+// 中文按单字加相邻双字切分（不依赖词典，短问题上效果接近分词）
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		for i, r := range han {
+			if !strings.ContainsRune(stopwords, r) {
+				tokens = append(tokens, string(r))
+			}
+			if i+1 < len(han) {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			if strings.ContainsRune(particles, r) {
+				// 语气词断开中文片段
+				flushHan()
+				continue
+			}
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return tokens
+}
@@ -0,0 +1,183 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// FileStore 基于文件锁的租约存储，适用于同一主机上的多个进程
+// 每次操作都在 flock 排他锁内读取、修改并原子替换状态文件
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore 创建文件存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建租约目录失败: %v", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// update 加锁读取状态，fn返回true时写回
+func (f *FileStore) update(fn func(s *state, now time.Time) bool) error {
+	lock, err := os.OpenFile(filepath.Join(f.dir, "lease.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("打开锁文件失败: %v", err)
+	}
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("加锁失败: %v", err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	path := filepath.Join(f.dir, "lease.json")
+	s := newState()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("读取租约文件失败: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, s); err != nil {
+			return fmt.Errorf("解析租约文件失败: %v", err)
+		}
+	}
+
+	if !fn(s, time.Now()) {
+		return nil
+	}
+
+	data, err = json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入租约文件失败: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Acquire 获取租约
+func (f *FileStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	var ok bool
+	err := f.update(func(s *state, now time.Time) bool {
+		ok = s.acquire(key, owner, ttl, now)
+		return ok
+	})
+	return ok, err
+}
+
+// Renew 续期租约
+func (f *FileStore) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	var ok bool
+	err := f.update(func(s *state, now time.Time) bool {
+		ok = s.renew(key, owner, ttl, now)
+		return ok
+	})
+	return ok, err
+}
+
+// Release 释放租约
+func (f *FileStore) Release(ctx context.Context, key, owner string) error {
+	return f.update(func(s *state, now time.Time) bool {
+		s.release(key, owner)
+		return true
+	})
+}
+
+// Owner 当前持有者
+func (f *FileStore) Owner(ctx context.Context, key string) (string, error) {
+	var owner string
+	err := f.update(func(s *state, now time.Time) bool {
+		owner = s.owner(key, now)
+		return false
+	})
+	return owner, err
+}
+
+// Heartbeat 上报副本存活
+func (f *FileStore) Heartbeat(ctx context.Context, replicaID string, ttl time.Duration) error {
+	return f.update(func(s *state, now time.Time) bool {
+		s.Replicas[replicaID] = now.Add(ttl)
+		return true
+	})
+}
+
+// Replicas 存活副本
+func (f *FileStore) Replicas(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := f.update(func(s *state, now time.Time) bool {
+		ids = s.replicas(now)
+		return true
+	})
+	return ids, err
+}
+
+// PutSession 保存会话定义
+func (f *FileStore) PutSession(ctx context.Context, spec SessionSpec) error {
+	return f.update(func(s *state, now time.Time) bool {
+		s.Sessions[spec.Key] = spec
+		return true
+	})
+}
+
+// DeleteSession 删除会话定义
+func (f *FileStore) DeleteSession(ctx context.Context, key string) error {
+	found := false
+	err := f.update(func(s *state, now time.Time) bool {
+		found = s.deleteSession(key)
+		return found
+	})
+	if err == nil && !found {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Session 单个会话定义
+func (f *FileStore) Session(ctx context.Context, key string) (SessionSpec, error) {
+	var spec SessionSpec
+	var found error
+	err := f.update(func(s *state, now time.Time) bool {
+		spec, found = s.session(key)
+		return false
+	})
+	if err != nil {
+		return SessionSpec{}, err
+	}
+	return spec, found
+}
+
+// PutStatus 上报会话运行状态
+func (f *FileStore) PutStatus(ctx context.Context, status SessionStatus) error {
+	return f.update(func(s *state, now time.Time) bool {
+		return s.putStatus(status)
+	})
+}
+
+// Statuses 所有会话的运行状态
+func (f *FileStore) Statuses(ctx context.Context) (map[string]SessionStatus, error) {
+	var statuses map[string]SessionStatus
+	err := f.update(func(s *state, now time.Time) bool {
+		statuses = s.statuses()
+		return false
+	})
+	return statuses, err
+}
+
+// Sessions 所有会话定义
+func (f *FileStore) Sessions(ctx context.Context) ([]SessionSpec, error) {
+	var specs []SessionSpec
+	err := f.update(func(s *state, now time.Time) bool {
+		specs = s.sessions()
+		return false
+	})
+	return specs, err
+}
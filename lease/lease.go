@@ -0,0 +1,588 @@
+package lease
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("会话不存在")
+
+// 会话类型
+const (
+	KindLive  = "live"  // 直播间评论（抖音同时监听账号私信）
+	KindVideo = "video" // 短视频评论
+	KindDM    = "dm"    // 私信
+)
+
+// AllVideos 短视频会话的视频ID为该值时监听账号最近发布的视频
+const AllVideos = "*"
+
+// SessionSpec 需要在某个副本上运行的渠道会话
+type SessionSpec struct {
+	Key         string    `json:"key"`
+	TenantID    string    `json:"tenant_id,omitempty"`
+	Platform    string    `json:"platform"`       // douyin
+	Kind        string    `json:"kind,omitempty"` // live, video, dm，为空表示 live
+	AccountID   string    `json:"account_id"`     // 授权账号 open_id
+	RoomID      string    `json:"room_id,omitempty"`
+	VideoID     string    `json:"video_id,omitempty"`
+	AccessToken string    `json:"access_token"`
+	Paused      bool      `json:"paused,omitempty"` // 暂停的会话保留定义但不运行
+	Source      string    `json:"source,omitempty"` // 创建来源，自动发现的会话为 discovery，配置文件中的直播间为 config
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SessionKind 会话类型，兼容未记录类型的旧会话
+func (s SessionSpec) SessionKind() string {
+	if s.Kind == "" {
+		return KindLive
+	}
+	return s.Kind
+}
+
+// SessionKey 会话键：同一租户同一账号的同一直播间只运行一份，不同租户的会话键互不冲突
+func SessionKey(tenantID, platform, accountID, roomID string) string {
+	return tenantID + ":" + platform + ":" + accountID + ":" + roomID
+}
+
+// SessionKeyFor 按会话类型生成会话键，直播间沿用 SessionKey 的格式
+func SessionKeyFor(tenantID, platform, kind, accountID, target string) string {
+	switch kind {
+	case KindVideo:
+		return SessionKey(tenantID, platform, accountID, "video:"+target)
+	case KindDM:
+		return SessionKey(tenantID, platform, accountID, "dm")
+	default:
+		return SessionKey(tenantID, platform, accountID, target)
+	}
+}
+
+// SessionID 会话键的短哈希，用作接口中的会话ID
+func SessionID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// 会话运行状态
+const (
+	StatePending = "pending" // 等待副本接管
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateError   = "error" // 启动失败或最近一次调用出错
+)
+
+// SessionStatus 会话运行状态，由持有租约的副本定期上报
+type SessionStatus struct {
+	Key         string                      `json:"key"`
+	Owner       string                      `json:"owner"`
+	State       string                      `json:"state"`
+	Counters    map[string]int64            `json:"counters,omitempty"`
+	Breakdown   map[string]map[string]int64 `json:"breakdown,omitempty"` // 按目标（如视频ID）细分的计数
+	LastError   string                      `json:"last_error,omitempty"`
+	LastErrorAt *time.Time                  `json:"last_error_at,omitempty"`
+	UpdatedAt   time.Time                   `json:"updated_at"`
+}
+
+// Store 租约存储，多副本共享
+type Store interface {
+	// Acquire 获取租约，已由owner持有时续期
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Renew 续期，仅owner持有时成功
+	Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Release 释放owner持有的租约
+	Release(ctx context.Context, key, owner string) error
+	// Owner 当前持有者，无人持有时返回空
+	Owner(ctx context.Context, key string) (string, error)
+
+	// Heartbeat 上报副本存活
+	Heartbeat(ctx context.Context, replicaID string, ttl time.Duration) error
+	// Replicas 存活的副本列表
+	Replicas(ctx context.Context) ([]string, error)
+
+	// PutSession 保存会话定义
+	PutSession(ctx context.Context, spec SessionSpec) error
+	// DeleteSession 删除会话定义
+	DeleteSession(ctx context.Context, key string) error
+	// Session 单个会话定义
+	Session(ctx context.Context, key string) (SessionSpec, error)
+	// Sessions 所有会话定义
+	Sessions(ctx context.Context) ([]SessionSpec, error)
+
+	// PutStatus 上报会话运行状态
+	PutStatus(ctx context.Context, status SessionStatus) error
+	// Statuses 所有会话的运行状态
+	Statuses(ctx context.Context) (map[string]SessionStatus, error)
+}
+
+// Runner 在本副本上启动/停止会话
+type Runner interface {
+	StartSession(spec SessionSpec) error
+	StopSession(key string)
+	// SessionStatus 本副本上运行的会话状态，会话不在本副本时返回false
+	SessionStatus(key string) (SessionStatus, bool)
+}
+
+// Cipher 令牌加密，会话定义中的 access_token 以密文保存在共享存储
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
+// DefaultTTL 默认租约时长，副本崩溃后最多经过该时长由其他副本接管
+const DefaultTTL = 15 * time.Second
+
+// Coordinator 会话分片协调器
+// 每个副本定期：上报心跳、续期已持有的租约、释放超出公平份额的会话、
+// 抢占无人持有的会话，保证每个 (账号, 直播间) 只在一个副本上运行
+type Coordinator struct {
+	store     Store
+	runner    Runner
+	cipher    Cipher
+	replicaID string
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	held    map[string]time.Time // 持有的会话及最近一次成功获取或续期租约的时间（发起请求时）
+	trigger chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewCoordinator 创建协调器
+func NewCoordinator(store Store, runner Runner, replicaID string, ttl time.Duration) *Coordinator {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Coordinator{
+		store:     store,
+		runner:    runner,
+		replicaID: replicaID,
+		ttl:       ttl,
+		held:      make(map[string]time.Time),
+		trigger:   make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+// SetCipher 设置令牌加密，需在 Start 之前调用
+func (c *Coordinator) SetCipher(cipher Cipher) {
+	c.cipher = cipher
+}
+
+// ReplicaID 本副本ID
+func (c *Coordinator) ReplicaID() string {
+	return c.replicaID
+}
+
+// Start 启动协调循环
+func (c *Coordinator) Start() {
+	log.Printf("🗳️ 会话协调器启动: replica=%s, ttl=%s", c.replicaID, c.ttl)
+	go c.loop()
+}
+
+// Stop 停止协调循环，停止本地会话并释放租约以便其他副本立即接管
+func (c *Coordinator) Stop() {
+	close(c.done)
+	<-c.stopped
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.held {
+		c.runner.StopSession(key)
+		if err := c.store.Release(ctx, key, c.replicaID); err != nil {
+			log.Printf("❌ 释放租约失败: key=%s, err=%v", key, err)
+		}
+		delete(c.held, key)
+	}
+}
+
+// Submit 提交会话定义，由某个副本接管运行
+func (c *Coordinator) Submit(ctx context.Context, spec SessionSpec) error {
+	if err := c.put(ctx, spec); err != nil {
+		return err
+	}
+	c.Reconcile()
+	return nil
+}
+
+// Sync 以 specs 为准同步来源为 source 的会话定义：提交 specs 中的会话（保留已有会话的暂停状态），
+// 删除该来源下不在 specs 中的会话，用于配置文件中的直播间
+func (c *Coordinator) Sync(ctx context.Context, source string, specs []SessionSpec) error {
+	existing, err := c.store.Sessions(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]SessionSpec, len(existing))
+	for _, spec := range existing {
+		current[spec.Key] = spec
+	}
+
+	keep := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		spec.Source = source
+		if prev, ok := current[spec.Key]; ok {
+			spec.Paused = prev.Paused
+			spec.CreatedAt = prev.CreatedAt
+		}
+		if err := c.put(ctx, spec); err != nil {
+			return err
+		}
+		keep[spec.Key] = true
+	}
+	for _, spec := range existing {
+		if spec.Source == source && !keep[spec.Key] {
+			if err := c.store.DeleteSession(ctx, spec.Key); err != nil {
+				return err
+			}
+		}
+	}
+	c.Reconcile()
+	return nil
+}
+
+// put 加密令牌后保存会话定义
+func (c *Coordinator) put(ctx context.Context, spec SessionSpec) error {
+	if spec.CreatedAt.IsZero() {
+		spec.CreatedAt = time.Now()
+	}
+	if c.cipher != nil {
+		token, err := c.cipher.Encrypt(spec.AccessToken)
+		if err != nil {
+			return err
+		}
+		spec.AccessToken = token
+	}
+	return c.store.PutSession(ctx, spec)
+}
+
+// Update 修改会话定义（如暂停/恢复），持有者会在下一轮按新定义处理
+func (c *Coordinator) Update(ctx context.Context, key string, fn func(spec *SessionSpec)) (SessionSpec, error) {
+	spec, err := c.store.Session(ctx, key)
+	if err != nil {
+		return SessionSpec{}, err
+	}
+	fn(&spec)
+	spec.Key = key
+	if err := c.store.PutSession(ctx, spec); err != nil {
+		return SessionSpec{}, err
+	}
+	c.Reconcile()
+	return spec, nil
+}
+
+// Session 单个会话定义，令牌保持密文
+func (c *Coordinator) Session(ctx context.Context, key string) (SessionSpec, error) {
+	return c.store.Session(ctx, key)
+}
+
+// Sessions 所有会话定义，令牌保持密文
+func (c *Coordinator) Sessions(ctx context.Context) ([]SessionSpec, error) {
+	return c.store.Sessions(ctx)
+}
+
+// Statuses 所有会话的运行状态，超过租约时长未更新的状态视为过期并丢弃；
+// 暂停时上报的状态不再更新，保留最后的计数和错误直到会话恢复或删除
+func (c *Coordinator) Statuses(ctx context.Context) (map[string]SessionStatus, error) {
+	statuses, err := c.store.Statuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, status := range statuses {
+		if status.State != StatePaused && time.Since(status.UpdatedAt) > c.ttl {
+			delete(statuses, key)
+		}
+	}
+	return statuses, nil
+}
+
+// Remove 删除会话定义，持有者会在下一轮停止运行
+func (c *Coordinator) Remove(ctx context.Context, key string) error {
+	if err := c.store.DeleteSession(ctx, key); err != nil {
+		return err
+	}
+	c.Reconcile()
+	return nil
+}
+
+// TryLock 获取名为 name 的任务锁，ttl 内只有一个副本能获得，用于周期任务只在一个副本上执行
+func (c *Coordinator) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return c.store.Acquire(ctx, "job:"+name, c.replicaID, ttl)
+}
+
+// Owner 会话当前运行在哪个副本
+func (c *Coordinator) Owner(ctx context.Context, key string) (string, error) {
+	return c.store.Owner(ctx, key)
+}
+
+// Held 本副本持有的会话
+func (c *Coordinator) Held() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.held))
+	for key := range c.held {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reconcile 立即触发一轮协调
+func (c *Coordinator) Reconcile() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// loop 协调循环，间隔为租约时长的1/3
+func (c *Coordinator) loop() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(c.ttl / 3)
+	defer ticker.Stop()
+
+	c.reconcile()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.reconcile()
+		case <-c.trigger:
+			c.reconcile()
+		}
+	}
+}
+
+// reconcile 执行一轮协调
+func (c *Coordinator) reconcile() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.ttl/3)
+	defer cancel()
+
+	if err := c.store.Heartbeat(ctx, c.replicaID, c.ttl); err != nil {
+		log.Printf("❌ 上报副本心跳失败: %v", err)
+		c.expire(ctx)
+		return
+	}
+	replicas, err := c.store.Replicas(ctx)
+	if err != nil {
+		log.Printf("❌ 获取副本列表失败: %v", err)
+		c.expire(ctx)
+		return
+	}
+	specs, err := c.store.Sessions(ctx)
+	if err != nil {
+		log.Printf("❌ 获取会话列表失败: %v", err)
+		c.expire(ctx)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wanted := make(map[string]SessionSpec, len(specs))
+	paused := make(map[string]bool)
+	for _, spec := range specs {
+		if spec.Paused {
+			paused[spec.Key] = true
+			continue
+		}
+		wanted[spec.Key] = spec
+	}
+
+	// 1. 续期已持有的租约；会话已删除、已暂停或续期失败的停止运行
+	failed := make(map[string]bool)
+	for key, renewed := range c.held {
+		if _, ok := wanted[key]; !ok {
+			if paused[key] {
+				c.pause(ctx, key)
+				continue
+			}
+			c.drop(ctx, key, "会话已删除")
+			continue
+		}
+		now := time.Now()
+		ok, err := c.store.Renew(ctx, key, c.replicaID, c.ttl)
+		if err != nil {
+			// 存储暂时不可用时保持运行，但必须在租约过期、其他副本接管之前停止
+			failed[key] = true
+			if c.expiring(renewed) {
+				c.drop(ctx, key, "续期租约持续失败，租约即将过期")
+				continue
+			}
+			log.Printf("⚠️ 续期租约失败: key=%s, err=%v", key, err)
+			continue
+		}
+		if !ok {
+			log.Printf("⚠️ 租约已被其他副本持有，停止会话: %s", key)
+			c.runner.StopSession(key)
+			delete(c.held, key)
+			continue
+		}
+		c.held[key] = now
+	}
+
+	// 2. 计算公平份额，新副本加入时释放多余的会话
+	share := fairShare(len(wanted), len(replicas))
+	if len(c.held) > share {
+		for _, key := range c.byPreference(c.heldKeys(), true)[:len(c.held)-share] {
+			c.drop(ctx, key, "副本扩容再平衡")
+		}
+	}
+
+	// 3. 在份额内抢占无人持有的会话，优先选择与本副本哈希最匹配的；本轮续期失败的不重新获取
+	var candidates []string
+	for key := range wanted {
+		if _, held := c.held[key]; !held && !failed[key] {
+			candidates = append(candidates, key)
+		}
+	}
+	for _, key := range c.byPreference(candidates, false) {
+		if len(c.held) >= share {
+			break
+		}
+		acquired := time.Now()
+		ok, err := c.store.Acquire(ctx, key, c.replicaID, c.ttl)
+		if err != nil {
+			log.Printf("❌ 获取租约失败: key=%s, err=%v", key, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := c.start(wanted[key]); err != nil {
+			log.Printf("❌ 启动会话失败，释放租约: key=%s, err=%v", key, err)
+			c.store.Release(ctx, key, c.replicaID)
+			now := time.Now()
+			c.store.PutStatus(ctx, SessionStatus{
+				Key:         key,
+				Owner:       c.replicaID,
+				State:       StateError,
+				LastError:   err.Error(),
+				LastErrorAt: &now,
+				UpdatedAt:   now,
+			})
+			continue
+		}
+		c.held[key] = acquired
+		log.Printf("✅ 本副本接管会话: %s", key)
+	}
+
+	// 4. 上报本副本运行的会话状态
+	for key := range c.held {
+		status, ok := c.runner.SessionStatus(key)
+		if !ok {
+			continue
+		}
+		status.Key = key
+		status.Owner = c.replicaID
+		status.UpdatedAt = time.Now()
+		if err := c.store.PutStatus(ctx, status); err != nil {
+			log.Printf("⚠️ 上报会话状态失败: key=%s, err=%v", key, err)
+		}
+	}
+}
+
+// expire 无法访问存储时停止租约即将过期的会话：其他副本会在租约过期后接管，
+// 本副本必须在此之前停止，避免两个副本同时回复同一条评论
+func (c *Coordinator) expire(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, renewed := range c.held {
+		if c.expiring(renewed) {
+			c.drop(ctx, key, "无法访问租约存储，租约即将过期")
+		}
+	}
+}
+
+// expiring 租约是否可能在下一轮协调前过期：续期时间从发起请求时算起，
+// 早于存储记录的续期时间，据此判断不会晚于其他副本看到的过期时间
+func (c *Coordinator) expiring(renewed time.Time) bool {
+	return time.Since(renewed)+c.ttl/3 >= c.ttl
+}
+
+// start 解密令牌后启动会话
+func (c *Coordinator) start(spec SessionSpec) error {
+	if c.cipher != nil {
+		token, err := c.cipher.Decrypt(spec.AccessToken)
+		if err != nil {
+			return err
+		}
+		spec.AccessToken = token
+	}
+	return c.runner.StartSession(spec)
+}
+
+// drop 停止会话并释放租约
+func (c *Coordinator) drop(ctx context.Context, key, reason string) {
+	log.Printf("🔀 释放会话: key=%s, 原因=%s", key, reason)
+	c.runner.StopSession(key)
+	if err := c.store.Release(ctx, key, c.replicaID); err != nil {
+		log.Printf("❌ 释放租约失败: key=%s, err=%v", key, err)
+	}
+	delete(c.held, key)
+}
+
+// pause 停止已暂停的会话，保留最后的计数以便恢复前查询
+func (c *Coordinator) pause(ctx context.Context, key string) {
+	status, _ := c.runner.SessionStatus(key)
+	c.drop(ctx, key, "会话已暂停")
+	status.Key = key
+	status.Owner = ""
+	status.State = StatePaused
+	status.UpdatedAt = time.Now()
+	if err := c.store.PutStatus(ctx, status); err != nil {
+		log.Printf("⚠️ 上报会话状态失败: key=%s, err=%v", key, err)
+	}
+}
+
+// heldKeys 持有的会话键
+func (c *Coordinator) heldKeys() []string {
+	keys := make([]string, 0, len(c.held))
+	for key := range c.held {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// byPreference 按会合哈希排序：本副本权重高的在前，reverse为true时权重低的在前
+func (c *Coordinator) byPreference(keys []string, reverse bool) []string {
+	sort.Slice(keys, func(i, j int) bool {
+		wi, wj := weight(c.replicaID, keys[i]), weight(c.replicaID, keys[j])
+		if reverse {
+			return wi < wj
+		}
+		return wi > wj
+	})
+	return keys
+}
+
+// weight 会合哈希权重
+func weight(replicaID, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(replicaID))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// fairShare 每个副本应运行的会话数上限
+func fairShare(sessions, replicas int) int {
+	if replicas <= 0 {
+		replicas = 1
+	}
+	return (sessions + replicas - 1) / replicas
+}
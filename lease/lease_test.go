@@ -0,0 +1,324 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTTL = 300 * time.Millisecond
+
+// fakeRunner 记录本副本运行的会话
+type fakeRunner struct {
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{running: make(map[string]bool)}
+}
+
+func (r *fakeRunner) StartSession(spec SessionSpec) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running[spec.Key] = true
+	return nil
+}
+
+func (r *fakeRunner) StopSession(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, key)
+}
+
+func (r *fakeRunner) SessionStatus(key string) (SessionStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.running[key] {
+		return SessionStatus{}, false
+	}
+	return SessionStatus{Key: key, State: StateRunning}, true
+}
+
+func (r *fakeRunner) isRunning(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[key]
+}
+
+func (r *fakeRunner) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.running)
+}
+
+var errStoreDown = errors.New("存储不可用")
+
+// flakyStore 按方法名模拟存储调用失败
+type flakyStore struct {
+	Store
+	mu      sync.Mutex
+	failing map[string]bool
+}
+
+func newFlakyStore(store Store) *flakyStore {
+	return &flakyStore{Store: store, failing: make(map[string]bool)}
+}
+
+func (f *flakyStore) fail(methods ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, method := range methods {
+		f.failing[method] = true
+	}
+}
+
+func (f *flakyStore) err(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing[method] {
+		return errStoreDown
+	}
+	return nil
+}
+
+func (f *flakyStore) Heartbeat(ctx context.Context, replicaID string, ttl time.Duration) error {
+	if err := f.err("Heartbeat"); err != nil {
+		return err
+	}
+	return f.Store.Heartbeat(ctx, replicaID, ttl)
+}
+
+func (f *flakyStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if err := f.err("Acquire"); err != nil {
+		return false, err
+	}
+	return f.Store.Acquire(ctx, key, owner, ttl)
+}
+
+func (f *flakyStore) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if err := f.err("Renew"); err != nil {
+		return false, err
+	}
+	return f.Store.Renew(ctx, key, owner, ttl)
+}
+
+func (f *flakyStore) Release(ctx context.Context, key, owner string) error {
+	if err := f.err("Release"); err != nil {
+		return err
+	}
+	return f.Store.Release(ctx, key, owner)
+}
+
+func putSessions(t *testing.T, store Store, n int) []string {
+	t.Helper()
+	keys := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		key := SessionKey("tenant", "douyin", fmt.Sprintf("account-%d", i), "room")
+		if err := store.PutSession(context.Background(), SessionSpec{Key: key, Platform: "douyin"}); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// assertSingleOwner 每个会话最多在一个副本上运行
+func assertSingleOwner(t *testing.T, keys []string, runners ...*fakeRunner) {
+	t.Helper()
+	for _, key := range keys {
+		owners := 0
+		for _, r := range runners {
+			if r.isRunning(key) {
+				owners++
+			}
+		}
+		if owners > 1 {
+			t.Fatalf("会话 %s 同时在 %d 个副本上运行", key, owners)
+		}
+	}
+}
+
+func TestCoordinatorRebalancesWithoutDoubleRunning(t *testing.T) {
+	store := NewMemoryStore()
+	keys := putSessions(t, store, 4)
+	ra, rb := newFakeRunner(), newFakeRunner()
+	a := NewCoordinator(store, ra, "replica-a", testTTL)
+	b := NewCoordinator(store, rb, "replica-b", testTTL)
+
+	a.reconcile()
+	if ra.count() != 4 {
+		t.Fatalf("单副本应运行全部会话，实际 %d", ra.count())
+	}
+
+	// 新副本加入：租约仍由 a 持有，b 不能抢占
+	b.reconcile()
+	assertSingleOwner(t, keys, ra, rb)
+	if rb.count() != 0 {
+		t.Fatalf("租约未释放前 b 不应运行会话，实际 %d", rb.count())
+	}
+
+	// a 释放超出公平份额的会话，b 接管
+	a.reconcile()
+	assertSingleOwner(t, keys, ra, rb)
+	b.reconcile()
+	assertSingleOwner(t, keys, ra, rb)
+	if ra.count() != 2 || rb.count() != 2 {
+		t.Fatalf("再平衡后应各运行2个会话，实际 a=%d b=%d", ra.count(), rb.count())
+	}
+}
+
+func TestCoordinatorStopHandsOverImmediately(t *testing.T) {
+	store := NewMemoryStore()
+	keys := putSessions(t, store, 1)
+	ra, rb := newFakeRunner(), newFakeRunner()
+	a := NewCoordinator(store, ra, "replica-a", testTTL)
+	b := NewCoordinator(store, rb, "replica-b", testTTL)
+
+	a.Start()
+	deadline := time.Now().Add(time.Second)
+	for !ra.isRunning(keys[0]) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Stop()
+	if ra.isRunning(keys[0]) {
+		t.Fatal("Stop 后应停止本地会话")
+	}
+
+	b.reconcile()
+	if !rb.isRunning(keys[0]) {
+		t.Fatal("租约已释放，b 应立即接管")
+	}
+}
+
+func TestCoordinatorCrashFailoverAfterTTL(t *testing.T) {
+	store := NewMemoryStore()
+	keys := putSessions(t, store, 1)
+	ra, rb := newFakeRunner(), newFakeRunner()
+	a := NewCoordinator(store, ra, "replica-a", testTTL)
+	b := NewCoordinator(store, rb, "replica-b", testTTL)
+
+	a.reconcile()
+	// a 崩溃后不再续期，租约过期前 b 不能接管
+	b.reconcile()
+	if rb.isRunning(keys[0]) {
+		t.Fatal("租约过期前 b 不应接管")
+	}
+
+	time.Sleep(testTTL)
+	b.reconcile()
+	if !rb.isRunning(keys[0]) {
+		t.Fatal("租约过期后 b 应接管")
+	}
+}
+
+// failover 让 a 持有会话后按 methods 模拟存储故障，a 和 b 持续协调直到 b 接管，
+// 期间同一会话不能同时在两个副本上运行
+func failover(t *testing.T, methods ...string) {
+	t.Helper()
+	store := NewMemoryStore()
+	keys := putSessions(t, store, 1)
+	flaky := newFlakyStore(store)
+	ra, rb := newFakeRunner(), newFakeRunner()
+	a := NewCoordinator(flaky, ra, "replica-a", testTTL)
+	b := NewCoordinator(store, rb, "replica-b", testTTL)
+
+	a.reconcile()
+	if !ra.isRunning(keys[0]) {
+		t.Fatal("a 应接管会话")
+	}
+	flaky.fail(methods...)
+
+	// 故障刚发生时保持运行
+	a.reconcile()
+	if !ra.isRunning(keys[0]) {
+		t.Fatal("存储短暂不可用时不应立即停止会话")
+	}
+
+	deadline := time.Now().Add(3 * testTTL)
+	for !rb.isRunning(keys[0]) && time.Now().Before(deadline) {
+		time.Sleep(testTTL / 10)
+		a.reconcile()
+		b.reconcile()
+		assertSingleOwner(t, keys, ra, rb)
+	}
+	if ra.isRunning(keys[0]) {
+		t.Fatal("续期持续失败时 a 应在租约过期前停止会话")
+	}
+	if !rb.isRunning(keys[0]) {
+		t.Fatal("租约过期后 b 应接管")
+	}
+}
+
+func TestCoordinatorStopsBeforeExpiryWhenRenewFails(t *testing.T) {
+	failover(t, "Renew", "Acquire", "Release")
+}
+
+func TestCoordinatorStopsBeforeExpiryWhenHeartbeatFails(t *testing.T) {
+	failover(t, "Heartbeat", "Renew", "Acquire", "Release")
+}
+
+func TestCoordinatorSync(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	c := NewCoordinator(store, newFakeRunner(), "replica-a", testTTL)
+
+	other := SessionSpec{Key: SessionKey("tenant", "douyin", "account", "room"), Platform: "douyin"}
+	if err := store.PutSession(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	specs := []SessionSpec{
+		{Key: SessionKeyFor("tenant", "douyin", KindLive, "", "room-1"), Platform: "douyin", RoomID: "room-1"},
+		{Key: SessionKeyFor("tenant", "douyin", KindLive, "", "room-2"), Platform: "douyin", RoomID: "room-2"},
+	}
+	if err := c.Sync(ctx, "config", specs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Update(ctx, specs[0].Key, func(spec *SessionSpec) { spec.Paused = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 配置删除了 room-2：同步后删除，保留 room-1 的暂停状态和其他来源的会话
+	if err := c.Sync(ctx, "config", specs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	room1, err := store.Session(ctx, specs[0].Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !room1.Paused || room1.Source != "config" {
+		t.Fatalf("room-1 = %+v, 应保留暂停状态并记录来源", room1)
+	}
+	if _, err := store.Session(ctx, specs[1].Key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("room-2 已从配置删除，Session = %v", err)
+	}
+	if _, err := store.Session(ctx, other.Key); err != nil {
+		t.Fatalf("其他来源的会话不应被删除: %v", err)
+	}
+}
+
+func TestCoordinatorKeepsPausedStatus(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	keys := putSessions(t, store, 1)
+	c := NewCoordinator(store, newFakeRunner(), "replica-a", testTTL)
+
+	c.reconcile()
+	if _, err := c.Update(ctx, keys[0], func(spec *SessionSpec) { spec.Paused = true }); err != nil {
+		t.Fatal(err)
+	}
+	c.reconcile()
+
+	// 暂停后状态不再上报，超过租约时长仍保留最后的状态
+	time.Sleep(testTTL + 50*time.Millisecond)
+	statuses, err := c.Statuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, ok := statuses[keys[0]]; !ok || status.State != StatePaused {
+		t.Fatalf("statuses = %+v, 应保留暂停会话的状态", statuses)
+	}
+}
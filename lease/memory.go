@@ -0,0 +1,232 @@
+package lease
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// state 租约与副本状态，内存存储与文件存储共用
+type state struct {
+	Leases   map[string]leaseRecord   `json:"leases"`
+	Replicas map[string]time.Time     `json:"replicas"`
+	Sessions map[string]SessionSpec   `json:"sessions"`
+	Statuses map[string]SessionStatus `json:"statuses"`
+}
+
+// leaseRecord 租约记录
+type leaseRecord struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newState() *state {
+	return &state{
+		Leases:   make(map[string]leaseRecord),
+		Replicas: make(map[string]time.Time),
+		Sessions: make(map[string]SessionSpec),
+		Statuses: make(map[string]SessionStatus),
+	}
+}
+
+// acquire 获取或续期租约
+func (s *state) acquire(key, owner string, ttl time.Duration, now time.Time) bool {
+	rec, ok := s.Leases[key]
+	if ok && rec.Owner != owner && now.Before(rec.ExpiresAt) {
+		return false
+	}
+	s.Leases[key] = leaseRecord{Owner: owner, ExpiresAt: now.Add(ttl)}
+	return true
+}
+
+// renew 仅owner持有且未过期时续期
+func (s *state) renew(key, owner string, ttl time.Duration, now time.Time) bool {
+	rec, ok := s.Leases[key]
+	if !ok || rec.Owner != owner || !now.Before(rec.ExpiresAt) {
+		return false
+	}
+	s.Leases[key] = leaseRecord{Owner: owner, ExpiresAt: now.Add(ttl)}
+	return true
+}
+
+// release 释放owner持有的租约
+func (s *state) release(key, owner string) {
+	if rec, ok := s.Leases[key]; ok && rec.Owner == owner {
+		delete(s.Leases, key)
+	}
+}
+
+// owner 当前持有者
+func (s *state) owner(key string, now time.Time) string {
+	rec, ok := s.Leases[key]
+	if !ok || !now.Before(rec.ExpiresAt) {
+		return ""
+	}
+	return rec.Owner
+}
+
+// replicas 存活副本，顺带清理过期副本
+func (s *state) replicas(now time.Time) []string {
+	ids := make([]string, 0, len(s.Replicas))
+	for id, expiresAt := range s.Replicas {
+		if !now.Before(expiresAt) {
+			delete(s.Replicas, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// session 单个会话定义
+func (s *state) session(key string) (SessionSpec, error) {
+	spec, ok := s.Sessions[key]
+	if !ok {
+		return SessionSpec{}, ErrNotFound
+	}
+	return spec, nil
+}
+
+// deleteSession 删除会话定义及其运行状态
+func (s *state) deleteSession(key string) bool {
+	if _, ok := s.Sessions[key]; !ok {
+		return false
+	}
+	delete(s.Sessions, key)
+	delete(s.Statuses, key)
+	return true
+}
+
+// putStatus 保存运行状态，会话已删除时忽略
+func (s *state) putStatus(status SessionStatus) bool {
+	if _, ok := s.Sessions[status.Key]; !ok {
+		return false
+	}
+	s.Statuses[status.Key] = status
+	return true
+}
+
+// statuses 运行状态副本
+func (s *state) statuses() map[string]SessionStatus {
+	statuses := make(map[string]SessionStatus, len(s.Statuses))
+	for key, status := range s.Statuses {
+		statuses[key] = status
+	}
+	return statuses
+}
+
+// sessions 会话定义，按创建时间排序
+func (s *state) sessions() []SessionSpec {
+	specs := make([]SessionSpec, 0, len(s.Sessions))
+	for _, spec := range s.Sessions {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].CreatedAt.Before(specs[j].CreatedAt)
+	})
+	return specs
+}
+
+// MemoryStore 进程内租约存储，适用于单实例部署
+type MemoryStore struct {
+	mu    sync.Mutex
+	state *state
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{state: newState()}
+}
+
+// Acquire 获取租约
+func (m *MemoryStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.acquire(key, owner, ttl, time.Now()), nil
+}
+
+// Renew 续期租约
+func (m *MemoryStore) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.renew(key, owner, ttl, time.Now()), nil
+}
+
+// Release 释放租约
+func (m *MemoryStore) Release(ctx context.Context, key, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.release(key, owner)
+	return nil
+}
+
+// Owner 当前持有者
+func (m *MemoryStore) Owner(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.owner(key, time.Now()), nil
+}
+
+// Heartbeat 上报副本存活
+func (m *MemoryStore) Heartbeat(ctx context.Context, replicaID string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Replicas[replicaID] = time.Now().Add(ttl)
+	return nil
+}
+
+// Replicas 存活副本
+func (m *MemoryStore) Replicas(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.replicas(time.Now()), nil
+}
+
+// PutSession 保存会话定义
+func (m *MemoryStore) PutSession(ctx context.Context, spec SessionSpec) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Sessions[spec.Key] = spec
+	return nil
+}
+
+// DeleteSession 删除会话定义
+func (m *MemoryStore) DeleteSession(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.state.deleteSession(key) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Session 单个会话定义
+func (m *MemoryStore) Session(ctx context.Context, key string) (SessionSpec, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.session(key)
+}
+
+// PutStatus 上报会话运行状态
+func (m *MemoryStore) PutStatus(ctx context.Context, status SessionStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.putStatus(status)
+	return nil
+}
+
+// Statuses 所有会话的运行状态
+func (m *MemoryStore) Statuses(ctx context.Context) (map[string]SessionStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.statuses(), nil
+}
+
+// Sessions 所有会话定义
+func (m *MemoryStore) Sessions(ctx context.Context) ([]SessionSpec, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.sessions(), nil
+}
@@ -0,0 +1,187 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript 已持有则续期，否则仅在无人持有时获取
+var acquireScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  return 1
+end
+if owner then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// renewScript 仅持有者可续期
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+  return 1
+end
+return 0
+`)
+
+// releaseScript 仅持有者可释放
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// RedisStore 基于Redis的租约存储，适用于多主机部署
+// 租约为带过期时间的字符串键，副本列表为以过期时间为分数的有序集合，会话定义存于哈希表
+type RedisStore struct {
+	client   redis.UniversalClient
+	prefix   string
+	replicas string
+	sessions string
+	statuses string
+}
+
+// NewRedisStore 创建Redis存储
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{
+		client:   client,
+		prefix:   prefix + "lease:",
+		replicas: prefix + "replicas",
+		sessions: prefix + "sessions",
+		statuses: prefix + "session_status",
+	}
+}
+
+// Acquire 获取租约
+func (r *RedisStore) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	n, err := acquireScript.Run(ctx, r.client, []string{r.prefix + key}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Renew 续期租约
+func (r *RedisStore) Renew(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	n, err := renewScript.Run(ctx, r.client, []string{r.prefix + key}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+// Release 释放租约
+func (r *RedisStore) Release(ctx context.Context, key, owner string) error {
+	return releaseScript.Run(ctx, r.client, []string{r.prefix + key}, owner).Err()
+}
+
+// Owner 当前持有者
+func (r *RedisStore) Owner(ctx context.Context, key string) (string, error) {
+	owner, err := r.client.Get(ctx, r.prefix+key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return owner, err
+}
+
+// Heartbeat 上报副本存活
+func (r *RedisStore) Heartbeat(ctx context.Context, replicaID string, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	return r.client.ZAdd(ctx, r.replicas, redis.Z{Score: float64(expiresAt), Member: replicaID}).Err()
+}
+
+// Replicas 存活副本，顺带清理过期副本
+func (r *RedisStore) Replicas(ctx context.Context) ([]string, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := r.client.ZRemRangeByScore(ctx, r.replicas, "-inf", "("+now).Err(); err != nil {
+		return nil, err
+	}
+	return r.client.ZRange(ctx, r.replicas, 0, -1).Result()
+}
+
+// PutSession 保存会话定义
+func (r *RedisStore) PutSession(ctx context.Context, spec SessionSpec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, r.sessions, spec.Key, data).Err()
+}
+
+// DeleteSession 删除会话定义
+func (r *RedisStore) DeleteSession(ctx context.Context, key string) error {
+	n, err := r.client.HDel(ctx, r.sessions, key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return r.client.HDel(ctx, r.statuses, key).Err()
+}
+
+// Session 单个会话定义
+func (r *RedisStore) Session(ctx context.Context, key string) (SessionSpec, error) {
+	value, err := r.client.HGet(ctx, r.sessions, key).Result()
+	if err == redis.Nil {
+		return SessionSpec{}, ErrNotFound
+	}
+	if err != nil {
+		return SessionSpec{}, err
+	}
+	var spec SessionSpec
+	if err := json.Unmarshal([]byte(value), &spec); err != nil {
+		return SessionSpec{}, err
+	}
+	return spec, nil
+}
+
+// PutStatus 上报会话运行状态，会话已删除时忽略
+func (r *RedisStore) PutStatus(ctx context.Context, status SessionStatus) error {
+	exists, err := r.client.HExists(ctx, r.sessions, status.Key).Result()
+	if err != nil || !exists {
+		return err
+	}
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return r.client.HSet(ctx, r.statuses, status.Key, data).Err()
+}
+
+// Statuses 所有会话的运行状态
+func (r *RedisStore) Statuses(ctx context.Context) (map[string]SessionStatus, error) {
+	values, err := r.client.HGetAll(ctx, r.statuses).Result()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]SessionStatus, len(values))
+	for key, value := range values {
+		var status SessionStatus
+		if err := json.Unmarshal([]byte(value), &status); err != nil {
+			continue
+		}
+		statuses[key] = status
+	}
+	return statuses, nil
+}
+
+// Sessions 所有会话定义
+func (r *RedisStore) Sessions(ctx context.Context) ([]SessionSpec, error) {
+	values, err := r.client.HGetAll(ctx, r.sessions).Result()
+	if err != nil {
+		return nil, err
+	}
+	s := newState()
+	for key, value := range values {
+		var spec SessionSpec
+		if err := json.Unmarshal([]byte(value), &spec); err != nil {
+			continue
+		}
+		s.Sessions[key] = spec
+	}
+	return s.sessions(), nil
+}
@@ -0,0 +1,196 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// GCRAStore GCRA（通用信元速率算法）状态存储，多实例共享同一存储即共享配额
+type GCRAStore interface {
+	// Take 为key预占一个令牌：emission为令牌间隔，burst为突发容量，
+	// 需要等待超过maxWait时不预占并返回allowed=false，wait为最早可用的等待时长
+	Take(ctx context.Context, key string, emission time.Duration, burst int, maxWait time.Duration) (allowed bool, wait time.Duration, err error)
+}
+
+// storeTimeout 单次访问共享存储的超时，超时视为不可用
+const storeTimeout = 100 * time.Millisecond
+
+// retryInterval 共享存储不可用后重新尝试的间隔
+const retryInterval = 5 * time.Second
+
+// DistributedLimiter 基于共享存储的按键限流器
+// 存储不可用时降级到本地限流器，并在retryInterval后重新尝试
+type DistributedLimiter struct {
+	store    GCRAStore
+	prefix   string
+	emission time.Duration
+	burst    int
+	fallback KeyedRateLimiter
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// NewDistributedLimiter 创建分布式限流器，fallback为存储不可用时使用的本地限流器
+func NewDistributedLimiter(store GCRAStore, prefix string, limit Limit, burst int, fallback KeyedRateLimiter) *DistributedLimiter {
+	emission := time.Duration(0)
+	if limit != Inf && limit > 0 {
+		emission = time.Duration(float64(time.Second) / float64(limit))
+	}
+	return &DistributedLimiter{
+		store:    store,
+		prefix:   prefix,
+		emission: emission,
+		burst:    burst,
+		fallback: fallback,
+	}
+}
+
+// Allow 检查键是否允许请求
+func (d *DistributedLimiter) Allow(key string) bool {
+	if d.emission == 0 {
+		return true
+	}
+	if d.degraded() {
+		return d.fallback.Allow(key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	allowed, _, err := d.store.Take(ctx, d.prefix+key, d.emission, d.burst, 0)
+	if err != nil {
+		d.markDown(err)
+		return d.fallback.Allow(key)
+	}
+	return allowed
+}
+
+// Wait 等待直到键允许请求
+func (d *DistributedLimiter) Wait(ctx context.Context, key string) error {
+	if d.emission == 0 {
+		return nil
+	}
+	if d.degraded() {
+		return d.fallback.Wait(ctx, key)
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+	allowed, wait, err := d.store.Take(storeCtx, d.prefix+key, d.emission, d.burst, maxWait(ctx))
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		d.markDown(err)
+		return d.fallback.Wait(ctx, key)
+	}
+	if !allowed {
+		return context.DeadlineExceeded
+	}
+	if wait == 0 {
+		return nil
+	}
+
+	// 令牌已在共享存储中预占，取消时不归还（GCRA无法安全回退其他实例之后的预占）
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// degraded 是否处于降级状态
+func (d *DistributedLimiter) degraded() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.downUntil.IsZero() {
+		return false
+	}
+	if time.Now().Before(d.downUntil) {
+		return true
+	}
+	d.downUntil = time.Time{}
+	log.Printf("🔄 重新尝试共享限流存储")
+	return false
+}
+
+// markDown 标记存储不可用
+func (d *DistributedLimiter) markDown(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.downUntil.IsZero() {
+		log.Printf("⚠️ 共享限流存储不可用，降级为本地限流 %s: %v", retryInterval, err)
+	}
+	d.downUntil = time.Now().Add(retryInterval)
+}
+
+// MemoryStore 进程内GCRA存储，用于测试和单实例部署
+type MemoryStore struct {
+	mu  sync.Mutex
+	tat map[string]time.Time
+	now func() time.Time
+}
+
+// NewMemoryStore 创建进程内GCRA存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tat: make(map[string]time.Time),
+		now: time.Now,
+	}
+}
+
+// Take 实现 GCRAStore，算法与Redis脚本一致
+func (m *MemoryStore) Take(ctx context.Context, key string, emission time.Duration, burst int, maxWait time.Duration) (bool, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	allowed, wait, tat := gcra(now, m.tat[key], emission, burst, maxWait)
+	if allowed {
+		m.tat[key] = tat
+	}
+
+	// 顺带清理已补满的键
+	if len(m.tat) > 1024 {
+		for k, t := range m.tat {
+			if t.Before(now) {
+				delete(m.tat, k)
+			}
+		}
+	}
+	return allowed, wait, nil
+}
+
+// gcra 计算GCRA预占结果，返回是否允许、等待时长和新的理论到达时间
+func gcra(now, tat time.Time, emission time.Duration, burst int, maxWait time.Duration) (bool, time.Duration, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+	tolerance := time.Duration(int64(emission) * int64(burst))
+	if burst > 0 && tolerance/time.Duration(burst) != emission {
+		tolerance = time.Duration(math.MaxInt64)
+	}
+
+	newTat := tat.Add(emission)
+	allowAt := newTat.Add(-tolerance)
+	wait := allowAt.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxWait {
+		return false, wait, tat
+	}
+	return true, wait, newTat
+}
@@ -0,0 +1,176 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newFakeStore 使用手动时钟的进程内GCRA存储
+func newFakeStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+// failingStore 总是返回错误的存储，模拟Redis不可用
+type failingStore struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *failingStore) Take(ctx context.Context, key string, emission time.Duration, burst int, maxWait time.Duration) (bool, time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return false, 0, errors.New("connection refused")
+}
+
+func TestDistributedLimiterBurst(t *testing.T) {
+	store, _ := newFakeStore()
+	d := NewDistributedLimiter(store, "test:", 1, 3, NewKeyedLimiter(Inf, 1, 0))
+
+	for i := 0; i < 3; i++ {
+		if !d.Allow("tenant-1") {
+			t.Fatalf("第%d个请求应在突发容量内", i+1)
+		}
+	}
+	if d.Allow("tenant-1") {
+		t.Fatal("突发容量用完后应拒绝")
+	}
+	if !d.Allow("tenant-2") {
+		t.Fatal("不同键的配额互不影响")
+	}
+}
+
+func TestDistributedLimiterRefill(t *testing.T) {
+	store, clock := newFakeStore()
+	d := NewDistributedLimiter(store, "test:", 2, 1, NewKeyedLimiter(Inf, 1, 0))
+
+	if !d.Allow("key") || d.Allow("key") {
+		t.Fatal("突发容量为1时应只放行一个请求")
+	}
+	clock.Advance(250 * time.Millisecond)
+	if d.Allow("key") {
+		t.Fatal("每秒2个令牌，250ms 后不应补充")
+	}
+	clock.Advance(250 * time.Millisecond)
+	if !d.Allow("key") {
+		t.Fatal("500ms 后应补充一个令牌")
+	}
+
+	// 空闲再久也不超过突发容量
+	clock.Advance(time.Hour)
+	if !d.Allow("key") || d.Allow("key") {
+		t.Fatal("空闲后令牌不应超过突发容量")
+	}
+}
+
+func TestDistributedLimiterSharedQuota(t *testing.T) {
+	store, _ := newFakeStore()
+	replicaA := NewDistributedLimiter(store, "shared:", 1, 2, NewKeyedLimiter(Inf, 1, 0))
+	replicaB := NewDistributedLimiter(store, "shared:", 1, 2, NewKeyedLimiter(Inf, 1, 0))
+
+	if !replicaA.Allow("coze") || !replicaB.Allow("coze") {
+		t.Fatal("两个副本共享突发容量")
+	}
+	if replicaA.Allow("coze") || replicaB.Allow("coze") {
+		t.Fatal("共享配额用完后两个副本都应拒绝")
+	}
+}
+
+func TestDistributedLimiterWaitMaxWait(t *testing.T) {
+	store, _ := newFakeStore()
+	d := NewDistributedLimiter(store, "test:", Every(time.Hour), 1, NewKeyedLimiter(Inf, 1, 0))
+	d.Allow("key")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, 截止前无法获得令牌应返回 context.DeadlineExceeded", err)
+	}
+}
+
+func TestGCRAWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	emission := 100 * time.Millisecond
+
+	allowed, wait, tat := gcra(now, time.Time{}, emission, 1, 0)
+	if !allowed || wait != 0 {
+		t.Fatalf("首个请求应立即放行: allowed=%v, wait=%v", allowed, wait)
+	}
+	allowed, wait, next := gcra(now, tat, emission, 1, time.Second)
+	if !allowed || wait != emission {
+		t.Fatalf("第二个请求应等待一个间隔: allowed=%v, wait=%v", allowed, wait)
+	}
+	if allowed, _, unchanged := gcra(now, next, emission, 1, emission); allowed || !unchanged.Equal(next) {
+		t.Fatal("超过最长等待时不应预占")
+	}
+}
+
+func TestDistributedLimiterFallback(t *testing.T) {
+	store := &failingStore{}
+	fallback := NewKeyedLimiter(Every(time.Hour), 1, 0)
+	d := NewDistributedLimiter(store, "test:", 10, 10, fallback)
+
+	// 存储出错时使用本地限流器，而不是放行或拒绝全部请求
+	if !d.Allow("key") {
+		t.Fatal("降级后应按本地限流器放行")
+	}
+	if d.Allow("key") {
+		t.Fatal("降级后应按本地限流器拒绝")
+	}
+	if store.calls != 1 {
+		t.Fatalf("存储调用 %d 次，标记不可用后 retryInterval 内不应再访问存储", store.calls)
+	}
+
+	// 降级期间 Wait 同样使用本地限流器
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, 应由本地限流器判定超时", err)
+	}
+
+	// retryInterval 之后重新尝试存储
+	d.mu.Lock()
+	d.downUntil = time.Now().Add(-time.Millisecond)
+	d.mu.Unlock()
+	d.Allow("key")
+	if store.calls != 2 {
+		t.Fatalf("存储调用 %d 次，retryInterval 之后应重新尝试存储", store.calls)
+	}
+}
+
+func TestDistributedLimiterInf(t *testing.T) {
+	store := &failingStore{}
+	d := NewDistributedLimiter(store, "test:", Inf, 1, NewKeyedLimiter(Inf, 1, 0))
+	for i := 0; i < 10; i++ {
+		if !d.Allow("key") {
+			t.Fatal("Inf 不应限流")
+		}
+	}
+	if store.calls != 0 {
+		t.Fatal("Inf 不应访问存储")
+	}
+}
@@ -2,6 +2,8 @@ package limiter
 
 import (
 	"context"
+	"math"
+	"sync"
 	"time"
 )
 
@@ -11,53 +13,366 @@ type RateLimiter interface {
 	Wait(ctx context.Context) error
 }
 
-// rateLimiter 令牌桶限流器
-type rateLimiter struct {
-	limiter chan time.Time
+// KeyedRateLimiter 按键限流的限流器接口，键通常为 租户:接口 或 账号:接口
+type KeyedRateLimiter interface {
+	Allow(key string) bool
+	Wait(ctx context.Context, key string) error
 }
 
-// NewRateLimiter 创建新的限流器
-func NewRateLimiter(rate int, burst int) RateLimiter {
-	rl := &rateLimiter{
-		limiter: make(chan time.Time, burst),
+// Limit 每秒产生的令牌数，支持小数（如 0.2 表示每5秒一个）
+type Limit float64
+
+// Inf 不限流
+const Inf = Limit(math.MaxFloat64)
+
+// Every 根据令牌间隔计算Limit
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
 	}
+	return Limit(float64(time.Second) / float64(interval))
+}
 
-	// 启动令牌生成器
-	go rl.tokenGenerator(rate)
+// bucket 令牌桶，按时间差惰性补充令牌，不需要后台goroutine
+type bucket struct {
+	limit    Limit
+	burst    int
+	tokens   float64
+	last     time.Time
+	lastUsed time.Time
+}
 
-	return rl
+// newBucket 创建满令牌的桶
+func newBucket(limit Limit, burst int, now time.Time) *bucket {
+	return &bucket{
+		limit:    limit,
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     now,
+		lastUsed: now,
+	}
 }
 
-// Allow 检查是否允许请求
-func (rl *rateLimiter) Allow() bool {
-	select {
-	case <-rl.limiter:
-		return true
-	default:
-		return false
+// advance 补充到now为止的令牌
+func (b *bucket) advance(now time.Time) {
+	if now.Before(b.last) {
+		return
+	}
+	if b.limit == Inf {
+		b.tokens = float64(b.burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(b.limit)
+		if b.tokens > float64(b.burst) {
+			b.tokens = float64(b.burst)
+		}
+	}
+	b.last = now
+}
+
+// reserve 预占一个令牌，返回需要等待的时长；ok为false表示永远无法获得
+func (b *bucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	b.advance(now)
+	b.lastUsed = now
+
+	if b.limit == Inf {
+		return 0, true
+	}
+	if b.burst <= 0 || b.limit <= 0 {
+		if b.tokens >= 1 {
+			b.tokens--
+			return 0, true
+		}
+		return 0, false
+	}
+
+	tokens := b.tokens - 1
+	var wait time.Duration
+	if tokens < 0 {
+		wait = time.Duration(-tokens / float64(b.limit) * float64(time.Second))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	b.tokens = tokens
+	return wait, true
+}
+
+// refund 归还一个令牌
+func (b *bucket) refund() {
+	b.tokens++
+	if b.tokens > float64(b.burst) {
+		b.tokens = float64(b.burst)
+	}
+}
+
+// Reservation 令牌预占结果
+type Reservation struct {
+	ok     bool
+	delay  time.Duration
+	cancel func()
+}
+
+// OK 是否成功预占
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay 预占成功后需要等待的时长
+func (r *Reservation) Delay() time.Duration {
+	return r.delay
+}
+
+// Cancel 放弃预占，归还令牌
+func (r *Reservation) Cancel() {
+	if r.ok && r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
 	}
 }
 
+// Limiter 单个令牌桶限流器
+type Limiter struct {
+	mu     sync.Mutex
+	bucket *bucket
+}
+
+// NewRateLimiter 创建新的限流器，rate为每秒令牌数
+func NewRateLimiter(rate int, burst int) RateLimiter {
+	return NewLimiter(Limit(rate), burst)
+}
+
+// NewLimiter 创建支持小数速率的限流器
+func NewLimiter(limit Limit, burst int) *Limiter {
+	return &Limiter{bucket: newBucket(limit, burst, time.Now())}
+}
+
+// Allow 检查是否允许请求
+func (rl *Limiter) Allow() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	_, ok := rl.bucket.reserve(time.Now(), 0)
+	return ok
+}
+
+// Reserve 预占一个令牌
+func (rl *Limiter) Reserve() *Reservation {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.reserveLocked(time.Now(), time.Duration(math.MaxInt64))
+}
+
 // Wait 等待直到允许请求
-func (rl *rateLimiter) Wait(ctx context.Context) error {
-	select {
-	case <-rl.limiter:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
+func (rl *Limiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	r := rl.reserveLocked(time.Now(), maxWait(ctx))
+	rl.mu.Unlock()
+	return waitReservation(ctx, r)
+}
+
+// reserveLocked 在锁内预占令牌
+func (rl *Limiter) reserveLocked(now time.Time, max time.Duration) *Reservation {
+	delay, ok := rl.bucket.reserve(now, max)
+	return &Reservation{
+		ok:    ok,
+		delay: delay,
+		cancel: func() {
+			rl.mu.Lock()
+			defer rl.mu.Unlock()
+			rl.bucket.refund()
+		},
+	}
+}
+
+// KeyedLimiter 按键分桶的限流器
+// 桶在首次使用时创建，空闲超过idleTTL后被回收，Close停止回收goroutine
+type KeyedLimiter struct {
+	mu        sync.Mutex
+	limit     Limit
+	burst     int
+	overrides map[string]keyLimit
+	buckets   map[string]*bucket
+	idleTTL   time.Duration
+	done      chan struct{}
+	once      sync.Once
+}
+
+// keyLimit 单个键的限流参数
+type keyLimit struct {
+	limit Limit
+	burst int
+}
+
+// NewKeyedLimiter 创建按键限流器，idleTTL<=0 时不回收空闲桶
+func NewKeyedLimiter(limit Limit, burst int, idleTTL time.Duration) *KeyedLimiter {
+	k := &KeyedLimiter{
+		limit:     limit,
+		burst:     burst,
+		overrides: make(map[string]keyLimit),
+		buckets:   make(map[string]*bucket),
+		idleTTL:   idleTTL,
+		done:      make(chan struct{}),
+	}
+	if idleTTL > 0 {
+		go k.evictLoop()
+	}
+	return k
+}
+
+// SetKeyLimit 设置单个键的限流参数，已存在的桶立即生效
+func (k *KeyedLimiter) SetKeyLimit(key string, limit Limit, burst int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.overrides[key] = keyLimit{limit: limit, burst: burst}
+	if b, ok := k.buckets[key]; ok {
+		b.advance(time.Now())
+		b.limit = limit
+		b.burst = burst
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+}
+
+// Allow 检查键是否允许请求
+func (k *KeyedLimiter) Allow(key string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	_, ok := k.bucketFor(key, now).reserve(now, 0)
+	return ok
+}
+
+// Reserve 为键预占一个令牌，返回需要等待的时长
+func (k *KeyedLimiter) Reserve(key string) *Reservation {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.reserveLocked(key, time.Duration(math.MaxInt64))
+}
+
+// Wait 等待直到键允许请求，ctx截止前无法获得令牌时立即返回错误
+func (k *KeyedLimiter) Wait(ctx context.Context, key string) error {
+	k.mu.Lock()
+	r := k.reserveLocked(key, maxWait(ctx))
+	k.mu.Unlock()
+	return waitReservation(ctx, r)
+}
+
+// For 获取绑定到某个键的 RateLimiter
+func (k *KeyedLimiter) For(key string) RateLimiter {
+	return &boundLimiter{keyed: k, key: key}
+}
+
+// Len 当前桶数量
+func (k *KeyedLimiter) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.buckets)
+}
+
+// Close 停止空闲桶回收
+func (k *KeyedLimiter) Close() {
+	k.once.Do(func() {
+		close(k.done)
+	})
+}
+
+// reserveLocked 在锁内为键预占令牌
+func (k *KeyedLimiter) reserveLocked(key string, max time.Duration) *Reservation {
+	now := time.Now()
+	b := k.bucketFor(key, now)
+	delay, ok := b.reserve(now, max)
+	return &Reservation{
+		ok:    ok,
+		delay: delay,
+		cancel: func() {
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			b.refund()
+		},
+	}
+}
+
+// bucketFor 获取或创建键对应的桶
+func (k *KeyedLimiter) bucketFor(key string, now time.Time) *bucket {
+	b, ok := k.buckets[key]
+	if !ok {
+		limit, burst := k.limit, k.burst
+		if o, ok := k.overrides[key]; ok {
+			limit, burst = o.limit, o.burst
+		}
+		b = newBucket(limit, burst, now)
+		k.buckets[key] = b
 	}
+	return b
 }
 
-// tokenGenerator 令牌生成器
-func (rl *rateLimiter) tokenGenerator(rate int) {
-	ticker := time.NewTicker(time.Second / time.Duration(rate))
+// evictLoop 定期回收空闲的桶
+func (k *KeyedLimiter) evictLoop() {
+	ticker := time.NewTicker(k.idleTTL / 2)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
 		select {
-		case rl.limiter <- time.Now():
-		default:
-			// 桶已满，丢弃令牌
+		case <-k.done:
+			return
+		case now := <-ticker.C:
+			k.mu.Lock()
+			for key, b := range k.buckets {
+				// 桶已补满且空闲超时，回收后重新创建的行为一致
+				b.advance(now)
+				if now.Sub(b.lastUsed) > k.idleTTL && b.tokens >= float64(b.burst) {
+					delete(k.buckets, key)
+				}
+			}
+			k.mu.Unlock()
+		}
+	}
+}
+
+// boundLimiter 绑定键的限流器
+type boundLimiter struct {
+	keyed *KeyedLimiter
+	key   string
+}
+
+// Allow 检查是否允许请求
+func (b *boundLimiter) Allow() bool {
+	return b.keyed.Allow(b.key)
+}
+
+// Wait 等待直到允许请求
+func (b *boundLimiter) Wait(ctx context.Context) error {
+	return b.keyed.Wait(ctx, b.key)
+}
+
+// maxWait ctx允许的最长等待时间
+func maxWait(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline)
+	}
+	return time.Duration(math.MaxInt64)
+}
+
+// waitReservation 等待预占的令牌生效
+func waitReservation(ctx context.Context, r *Reservation) error {
+	if !r.OK() {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		return context.DeadlineExceeded
+	}
+	if r.Delay() == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(r.Delay())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
 	}
 }
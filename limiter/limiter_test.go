@@ -0,0 +1,205 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketBurstAndRefill(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	b := newBucket(10, 2, start)
+
+	for i := 0; i < 2; i++ {
+		if _, ok := b.reserve(start, 0); !ok {
+			t.Fatalf("第%d个请求应在突发容量内", i+1)
+		}
+	}
+	if _, ok := b.reserve(start, 0); ok {
+		t.Fatal("突发容量用完后应拒绝")
+	}
+
+	// 每秒10个令牌，50ms 只补充半个
+	if _, ok := b.reserve(start.Add(50*time.Millisecond), 0); ok {
+		t.Fatal("补充不足一个令牌时应拒绝")
+	}
+	if _, ok := b.reserve(start.Add(100*time.Millisecond), 0); !ok {
+		t.Fatal("100ms 后应补充一个令牌")
+	}
+
+	// 长时间空闲后令牌不超过突发容量
+	b.advance(start.Add(time.Hour))
+	if b.tokens != 2 {
+		t.Fatalf("tokens = %v, 应封顶为 2", b.tokens)
+	}
+}
+
+func TestBucketFractionalLimit(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	b := newBucket(Every(5*time.Second), 1, start)
+
+	if _, ok := b.reserve(start, 0); !ok {
+		t.Fatal("首个请求应放行")
+	}
+	if _, ok := b.reserve(start.Add(4*time.Second), 0); ok {
+		t.Fatal("每5秒一个令牌，4秒后应拒绝")
+	}
+	if _, ok := b.reserve(start.Add(5*time.Second), 0); !ok {
+		t.Fatal("5秒后应放行")
+	}
+}
+
+func TestBucketReserveWait(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	b := newBucket(10, 1, start)
+	b.reserve(start, 0)
+
+	wait, ok := b.reserve(start, time.Second)
+	if !ok || wait != 100*time.Millisecond {
+		t.Fatalf("reserve = (%v, %v), 应等待 100ms", wait, ok)
+	}
+	// 已预占的令牌计入等待，下一个需要等 200ms
+	if wait, ok := b.reserve(start, 150*time.Millisecond); ok || wait != 200*time.Millisecond {
+		t.Fatalf("reserve = (%v, %v), 应超过最长等待而拒绝", wait, ok)
+	}
+
+	b.refund()
+	b.refund()
+	b.refund()
+	if b.tokens > 1 {
+		t.Fatalf("归还后 tokens = %v, 不应超过突发容量", b.tokens)
+	}
+}
+
+func TestBucketInf(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	b := newBucket(Inf, 1, start)
+	for i := 0; i < 100; i++ {
+		if _, ok := b.reserve(start, 0); !ok {
+			t.Fatal("Inf 不应限流")
+		}
+	}
+}
+
+func TestKeyedLimiterKeysAreIndependent(t *testing.T) {
+	k := NewKeyedLimiter(Every(time.Hour), 1, 0)
+	defer k.Close()
+
+	if !k.Allow("tenant-1:coze") || k.Allow("tenant-1:coze") {
+		t.Fatal("tenant-1 应只放行突发容量内的请求")
+	}
+	if !k.Allow("tenant-2:coze") {
+		t.Fatal("tenant-2 不应受 tenant-1 影响")
+	}
+	if k.Len() != 2 {
+		t.Fatalf("Len = %d, 应为 2", k.Len())
+	}
+}
+
+func TestKeyedLimiterOverrides(t *testing.T) {
+	k := NewKeyedLimiter(Every(time.Hour), 1, 0)
+	defer k.Close()
+
+	// 桶创建前设置：按覆盖参数创建
+	k.SetKeyLimit("vip", Every(time.Hour), 3)
+	for i := 0; i < 3; i++ {
+		if !k.Allow("vip") {
+			t.Fatalf("vip 第%d个请求应放行", i+1)
+		}
+	}
+	if k.Allow("vip") {
+		t.Fatal("vip 超出突发容量后应拒绝")
+	}
+
+	// 桶创建后调小：剩余令牌按新的突发容量封顶
+	k.SetKeyLimit("shrink", Every(time.Hour), 5)
+	k.Allow("shrink")
+	k.SetKeyLimit("shrink", Every(time.Hour), 1)
+	if !k.Allow("shrink") || k.Allow("shrink") {
+		t.Fatal("调小突发容量后应只剩 1 个令牌")
+	}
+
+	// 其他键仍使用默认参数
+	if !k.Allow("default") || k.Allow("default") {
+		t.Fatal("未覆盖的键应使用默认参数")
+	}
+}
+
+func TestKeyedLimiterEvictsIdleBuckets(t *testing.T) {
+	k := NewKeyedLimiter(1000, 1, 20*time.Millisecond)
+	defer k.Close()
+
+	k.Allow("idle")
+	// 每小时一个令牌的键空闲后仍未补满，不能回收，否则重建时会多给令牌
+	k.SetKeyLimit("drained", Every(time.Hour), 1)
+	k.Allow("drained")
+
+	deadline := time.Now().Add(time.Second)
+	for k.Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	k.mu.Lock()
+	_, idle := k.buckets["idle"]
+	_, drained := k.buckets["drained"]
+	k.mu.Unlock()
+	if idle {
+		t.Fatal("补满且空闲超时的桶应被回收")
+	}
+	if !drained {
+		t.Fatal("未补满的桶不应被回收")
+	}
+}
+
+func TestKeyedLimiterWaitCancelRefunds(t *testing.T) {
+	k := NewKeyedLimiter(1, 1, 0)
+	defer k.Close()
+	k.Allow("key")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if err := k.Wait(ctx, "key"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, 应返回 context.Canceled", err)
+	}
+
+	// 取消的等待归还了令牌，下一个预占只需等一个令牌的时间
+	r := k.Reserve("key")
+	defer r.Cancel()
+	if !r.OK() || r.Delay() > time.Second {
+		t.Fatalf("Reserve delay = %v, 取消的等待应归还令牌", r.Delay())
+	}
+}
+
+func TestKeyedLimiterWaitDeadline(t *testing.T) {
+	k := NewKeyedLimiter(Every(time.Hour), 1, 0)
+	defer k.Close()
+	k.Allow("key")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := k.Wait(ctx, "key"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, 应返回 context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Fatalf("截止前无法获得令牌时应立即返回，实际等待 %v", elapsed)
+	}
+}
+
+func TestKeyedLimiterWait(t *testing.T) {
+	k := NewKeyedLimiter(20, 1, 0)
+	defer k.Close()
+	k.Allow("key")
+
+	start := time.Now()
+	if err := k.Wait(context.Background(), "key"); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("每秒20个令牌应等待约50ms，实际 %v", elapsed)
+	}
+}
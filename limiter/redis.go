@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript Redis GCRA脚本，使用Redis服务器时间避免各实例时钟偏差
+// KEYS[1] 键；ARGV[1] 令牌间隔(微秒)；ARGV[2] 突发容量；ARGV[3] 最长等待(微秒)
+// 返回 {是否允许, 等待微秒}
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local max_wait = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission
+local wait = new_tat - emission * burst - now
+if wait < 0 then
+	wait = 0
+end
+if wait > max_wait then
+	return {0, wait}
+end
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000) + 1)
+return {1, wait}
+`)
+
+// RedisStore 基于Redis的GCRA存储
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore 创建Redis GCRA存储
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// NewRedisClient 根据 REDIS_URL 创建客户端，如 redis://:password@host:6379/0
+func NewRedisClient(redisURL string) (*redis.Client, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析REDIS_URL失败: %v", err)
+	}
+	opts.DialTimeout = time.Second
+	opts.ReadTimeout = storeTimeout
+	opts.WriteTimeout = storeTimeout
+	return redis.NewClient(opts), nil
+}
+
+// Take 实现 GCRAStore
+func (r *RedisStore) Take(ctx context.Context, key string, emission time.Duration, burst int, maxWait time.Duration) (bool, time.Duration, error) {
+	res, err := gcraScript.Run(ctx, r.client, []string{key},
+		emission.Microseconds(), burst, maxWait.Microseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("限流脚本返回格式错误: %v", res)
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Microsecond, nil
+}
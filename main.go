@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"os"
@@ -11,78 +13,246 @@ import (
 	"syscall"
 	"time"
 
+	"live-im-proxy/analytics"
+	"live-im-proxy/auth"
+	"live-im-proxy/audit"
+	"live-im-proxy/broadcast"
+	"live-im-proxy/catalog"
 	"live-im-proxy/channel"
+	appconfig "live-im-proxy/config"
+	"live-im-proxy/discovery"
+	"live-im-proxy/gifts"
+	"live-im-proxy/handoff"
 	"live-im-proxy/health"
+	"live-im-proxy/history"
+	"live-im-proxy/knowledge"
+	"live-im-proxy/lease"
 	"live-im-proxy/limiter"
+	"live-im-proxy/monitor"
 	"live-im-proxy/oauth"
+	"live-im-proxy/openapi"
 	"live-im-proxy/pipeline"
-)
+	"live-im-proxy/policy"
+	"live-im-proxy/replycache"
+	"live-im-proxy/safety"
+	"live-im-proxy/secrets"
+	"live-im-proxy/session"
+	"live-im-proxy/spam"
+	"live-im-proxy/welcome"
 
-type Config struct {
-	Port        string
-	Channels    []string
-	CozeAPI     string
-	CozeToken   string
-	NBAPI       string
-	NBToken     string
-	RedisURL    string
-	DouyinAppID string
-	DouyinAppSecret string
-	RedirectURI string
-}
+	"github.com/redis/go-redis/v9"
+)
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_PATH"), "配置文件路径（.yaml/.json），环境变量优先于文件")
+	flag.Parse()
+
 	// 加载配置
-	config := &Config{
-		Port:        getEnv("PORT", "8080"),
-		Channels:    []string{"douyin", "kuaishou", "wechat", "xiaohongshu"},
-		CozeAPI:     getEnv("COZE_API", "https://api.coze.com/open/v1"),
-		CozeToken:   getEnv("COZE_TOKEN", ""),
-		NBAPI:       getEnv("NB_API", ""),
-		NBToken:     getEnv("NB_TOKEN", ""),
-		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
-		DouyinAppID: getEnv("DOUYIN_APP_ID", ""),
-		DouyinAppSecret: getEnv("DOUYIN_APP_SECRET", ""),
-		RedirectURI: getEnv("REDIRECT_URI", "http://localhost:8080/oauth/callback"),
+	config, err := appconfig.Load(*configPath)
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
 	}
 
 	log.Printf("🚀 LinkBot-AI 渠道代理服务启动中...")
-	log.Printf("📡 端口: %s", config.Port)
-	log.Printf("🎯 支持渠道: %v", config.Channels)
-	log.Printf("🔑 DouyinAppID: %s", config.DouyinAppID)
-	log.Printf("🔑 DouyinAppSecret: %s", maskSecret(config.DouyinAppSecret))
-	log.Printf("🔗 RedirectURI: %s", config.RedirectURI)
+	if *configPath != "" {
+		log.Printf("📄 配置文件: %s", *configPath)
+	}
+	log.Printf("📡 端口: %s", config.Server.Port)
+	log.Printf("🎯 支持渠道: %v", config.ChannelTypes())
+	log.Printf("🔑 DouyinAppID: %s", config.Providers.Douyin.AppID)
+	log.Printf("🔑 DouyinAppSecret: %s", secrets.Redact(config.Providers.Douyin.AppSecret))
+	log.Printf("🔗 RedirectURI: %s", config.Providers.Douyin.RedirectURI)
 
-	// 初始化限流器
-	cozeLimiter := limiter.NewRateLimiter(10, 20) // 10 QPS, 突发 20
+	// 初始化限流器：多实例通过Redis共享配额，Redis不可用时按副本数均分降级为本地限流
+	var redisClient *redis.Client
+	var redisStore limiter.GCRAStore
+	if config.Redis.URL != "" {
+		client, err := limiter.NewRedisClient(config.Redis.URL)
+		if err != nil {
+			log.Fatalf("❌ 初始化Redis失败: %v", err)
+		}
+		defer client.Close()
+		redisClient = client
+		redisStore = limiter.NewRedisStore(client)
+	}
+	newLimiter := func(name string, rate appconfig.Rate) limiter.KeyedRateLimiter {
+		limit, burst := limiter.Limit(rate.Rate), rate.Burst
+		replicas := config.Server.ReplicaCount
+		local := limiter.NewKeyedLimiter(limit/limiter.Limit(replicas), max(1, burst/replicas), 10*time.Minute)
+		if redisStore == nil {
+			return local
+		}
+		return limiter.NewDistributedLimiter(redisStore, "linkbot:ratelimit:"+name+":", limit, burst, local)
+	}
+	cozeLimiter := newLimiter("coze", config.Limits.Coze)       // 每个租户
+	douyinLimiter := newLimiter("douyin", config.Limits.Douyin) // 抖音开放平台应用
+	channel.SetDouyinRateLimiter(douyinLimiter)
+	channel.SetDouyinApp(config.Providers.Douyin.AppID, config.Providers.Douyin.AppSecret)
+	for _, ch := range config.Channels {
+		channel.SetPolling(ch.Type, channel.Polling{
+			LiveComments:    ch.Polling.LiveComments.Std(),
+			PrivateMessages: ch.Polling.PrivateMessages.Std(),
+			VideoComments:   ch.Polling.VideoComments.Std(),
+			Simulate:        ch.Polling.Simulate.Std(),
+		})
+	}
 
 	// 初始化管道
-	pipeline := pipeline.NewPipeline(config.CozeAPI, config.CozeToken, config.NBAPI, config.NBToken, cozeLimiter)
+	providers := config.Providers
+	pipeline := pipeline.NewPipeline(providers.Coze.API, providers.Coze.Token, providers.NocoBase.API, providers.NocoBase.Token, cozeLimiter)
+	pipeline.SetTenantID(config.DefaultTenant)
+	pipeline.SetCozeBotID(providers.Coze.BotID)
+	pipeline.SetFallbackConfig(config.Reply.FallbackConfig())
+
+	// API密钥：管理API和 /ws 实时监控共用，租户密钥只能访问本租户
+	keyStore, err := auth.NewStore(config.Server.APIKeyPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化API密钥存储失败: %v", err)
+	}
+	keyStore.SetBootstrapKey(config.Server.AdminAPIKey)
+
+	// 初始化实时监控、统计和审计（订阅管道事件总线）
+	eventBus := pipeline.Bus()
+	monitorHub := monitor.NewHub(keyStore, config.Server.AdminOrigin)
+	monitorHub.Subscribe(eventBus)
+	stats := analytics.NewCollector()
+	stats.Subscribe(eventBus)
+	if config.Server.AuditLogPath != "" {
+		auditLogger, err := audit.NewLogger(config.Server.AuditLogPath)
+		if err != nil {
+			log.Fatalf("❌ 初始化审计日志失败: %v", err)
+		}
+		defer auditLogger.Close()
+		auditLogger.Subscribe(eventBus)
+	}
+
+	// 初始化会话记录
+	historyStore, err := history.NewStore(config.Server.HistoryPath, history.DefaultMaxPerUser, time.Duration(config.Server.HistoryDays)*24*time.Hour)
+	if err != nil {
+		log.Fatalf("❌ 初始化会话记录失败: %v", err)
+	}
+	defer historyStore.Close()
+	historyStore.Subscribe(eventBus)
+	pipeline.SetHistory(historyStore)
+
+	// 初始化回复缓存：同一直播间的相同问题复用AI回复，商品目录、知识库或回复规则变更时清空
+	replyCache := replycache.NewCache(config.Reply.CacheConfig())
+	pipeline.SetReplyCache(replyCache)
+
+	// 初始化商品目录，回复时识别评论提到的商品
+	productCatalog, err := catalog.NewStore(config.Server.CatalogPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化商品目录失败: %v", err)
+	}
+	productCatalog.SetOnChange(replyCache.Invalidate)
+	pipeline.SetCatalog(productCatalog)
+
+	// 初始化本地知识库，常见问题直接用审核过的答案回复
+	knowledgeBase, err := knowledge.NewBase(config.Server.KnowledgePath, config.Reply.Knowledge)
+	if err != nil {
+		log.Fatalf("❌ 初始化知识库失败: %v", err)
+	}
+	knowledgeBase.SetOnChange(replyCache.Invalidate)
+	pipeline.SetKnowledge(knowledgeBase)
+
+	// 初始化回复内容安全过滤
+	safetyFilter := safety.NewFilter(config.Reply.Safety)
+	pipeline.SetSafetyFilter(safetyFilter)
+
+	// 初始化入站反垃圾检测
+	spamDetector, err := spam.NewDetector(config.Server.SpamListPath, config.Reply.SpamConfig())
+	if err != nil {
+		log.Fatalf("❌ 初始化反垃圾检测失败: %v", err)
+	}
+	pipeline.SetSpamDetector(spamDetector)
+
+	// 初始化评论回复限流策略
+	replyPolicy := policy.NewPolicy(config.Reply.Policy, pipeline.ReplyAggregated)
+	pipeline.SetReplyPolicy(replyPolicy)
+
+	// 初始化人机切换（私信转人工）
+	handoffManager, err := handoff.NewManager(config.Reply.HandoffConfig(), pipeline, config.Server.HandoffPath)
+	if err != nil {
+		log.Fatalf("❌ 初始化人机切换失败: %v", err)
+	}
+	pipeline.SetHandoff(handoffManager)
+
+	// 初始化礼物和点赞处理（收入统计、合并感谢）
+	giftTracker := gifts.NewTracker(config.Reply.GiftsConfig(), pipeline.ThankGifts)
+	pipeline.SetGiftTracker(giftTracker)
+
+	// 初始化进场欢迎和关注感谢
+	greeter := welcome.NewGreeter(config.Reply.WelcomeConfig(), pipeline.Welcome, pipeline.WelcomeProfile)
+	pipeline.SetGreeter(greeter)
 
 	// 初始化渠道管理器
 	channelManager := channel.NewManager(pipeline)
+	channelManager.SetMonitor(monitorHub)
 
-	// 暂不自动启动渠道，等待OAuth授权后动态启动
-	// 启动渠道连接（已禁用，等待OAuth授权）
-	// for _, channelType := range config.Channels {
-	// 	go func(ch string) {
-	// 		if err := channelManager.StartChannel(ch); err != nil {
-	// 			log.Printf("❌ 启动渠道 %s 失败: %v", ch, err)
-	// 		}
-	// 	}(channelType)
-	// }
+	// 初始化授权账号存储（令牌信封加密后持久化）
+	keyRing, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("❌ 加载加密密钥失败: %v", err)
+	}
+	accountStore, err := oauth.NewAccountStore(config.Server.AccountPath, keyRing, config.DefaultTenant)
+	if err != nil {
+		log.Fatalf("❌ 初始化账号存储失败: %v", err)
+	}
 
-	// 初始化OAuth
-	douyinOAuth := oauth.NewDouyinOAuth(config.DouyinAppID, config.DouyinAppSecret, config.RedirectURI)
+	// 初始化会话分片：每个 (账号, 直播间) 通过租约只在一个副本上运行
+	var leaseStore lease.Store
+	switch config.Lease.Backend {
+	case "redis":
+		if redisClient == nil {
+			log.Fatalf("❌ LEASE_BACKEND=redis 需要配置 REDIS_URL")
+		}
+		leaseStore = lease.NewRedisStore(redisClient, "linkbot:")
+	case "file":
+		fileStore, err := lease.NewFileStore(config.Lease.Dir)
+		if err != nil {
+			log.Fatalf("❌ 初始化租约存储失败: %v", err)
+		}
+		leaseStore = fileStore
+	default:
+		leaseStore = lease.NewMemoryStore()
+	}
+	coordinator := lease.NewCoordinator(leaseStore, channelManager, config.Server.ReplicaID, lease.DefaultTTL)
+	coordinator.SetCipher(keyRing)
+	coordinator.Start()
+
+	// 短视频自动发现：按策略为授权账号的视频创建评论监听会话
+	discoveryJob := discovery.NewJob(coordinator, accountStore, config.Discovery.DiscoveryConfig())
+	discoveryJob.Start()
+
+	// 直播间定时公屏消息：只向本副本运行的直播间发送
+	broadcaster := broadcast.NewScheduler(config.Broadcast.BroadcastConfig(config.DefaultTenant), func(channelType, roomID string) (broadcast.Room, bool) {
+		return channelManager.LiveRoom(channelType, roomID)
+	})
+	broadcaster.Subscribe(eventBus)
 
-	// 账号存储结构
-	type AccountInfo struct {
-		Token    *oauth.OAuthToken
-		UserInfo *oauth.UserInfo
+	// 配置中启用的直播间同样经租约协调器运行，多副本时只在一个副本上监听；
+	// 绑定的账号尚未授权时先跳过，OAuth授权完成后重新提交
+	syncConfigRooms := func() {
+		specs, pending := config.SessionSpecs(func(accountID string) (string, bool) {
+			account, ok := accountStore.Owned(accountID, config.DefaultTenant)
+			if !ok || account.Token == nil || account.Token.AccessToken == "" {
+				return "", false
+			}
+			return account.Token.AccessToken, true
+		})
+		for _, room := range pending {
+			log.Printf("⏳ 直播间 %s 绑定的账号 %s 尚未授权，授权后开始监听", room.ID, room.AccountID)
+		}
+		syncCtx, cancelSync := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelSync()
+		if err := coordinator.Sync(syncCtx, appconfig.SessionSource, specs); err != nil {
+			log.Printf("❌ 提交配置中的直播间失败: %v", err)
+		}
 	}
-	
-	// 存储账号信息（临时使用内存存储）
-	accountStore := make(map[string]*AccountInfo)
+	syncConfigRooms()
+
+	// 初始化OAuth
+	douyinOAuth := oauth.NewDouyinOAuth(providers.Douyin.AppID, providers.Douyin.AppSecret, providers.Douyin.RedirectURI)
 
 	// 设置路由
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -110,8 +280,8 @@ func main() {
         
         <div class="status">
             <h3>✅ 服务状态</h3>
-            <p>服务运行正常，端口: ` + config.Port + `</p>
-            <p>支持渠道: ` + fmt.Sprintf("%v", config.Channels) + `</p>
+            <p>服务运行正常，端口: ` + config.Server.Port + `</p>
+            <p>支持渠道: ` + fmt.Sprintf("%v", config.ChannelTypes()) + `</p>
         </div>
         
         <div class="endpoints">
@@ -125,11 +295,32 @@ func main() {
             <div class="endpoint">
                 <span class="method">GET</span> <span class="url">/oauth/douyin/whitelist</span> - 抖音白名单授权
             </div>
+            <div class="endpoint">
+                <span class="method">POST</span> <span class="url">/api/v1/sessions</span> - 渠道会话管理（直播间/短视频/私信，接口描述: /api/v1/openapi.json）
+            </div>
             <div class="endpoint">
                 <span class="method">POST</span> <span class="url">/api/channel/douyin/start</span> - 启动抖音监听
             </div>
             <div class="endpoint">
-                <span class="method">GET</span> <span class="url">/ws</span> - WebSocket连接
+                <span class="method">POST</span> <span class="url">/api/channel/douyin/stop</span> - 停止抖音监听
+            </div>
+            <div class="endpoint">
+                <span class="method">GET</span> <span class="url">/api/handoff/conversations</span> - 人工接管会话列表
+            </div>
+            <div class="endpoint">
+                <span class="method">GET</span> <span class="url">/api/conversations/history</span> - 会话记录查询
+            </div>
+            <div class="endpoint">
+                <span class="method">GET</span> <span class="url">/api/safety/audit</span> - 回复内容安全审计
+            </div>
+            <div class="endpoint">
+                <span class="method">GET</span> <span class="url">/api/spam/blocklist</span> - 黑名单管理（白名单: /api/spam/allowlist）
+            </div>
+            <div class="endpoint">
+                <span class="method">POST</span> <span class="url">/api/admin/keys</span> - API密钥管理（/api/ 下接口需携带 Authorization: Bearer 密钥）
+            </div>
+            <div class="endpoint">
+                <span class="method">GET</span> <span class="url">/ws</span> - 实时监控WebSocket（事件/回复/发送结果，需携带API密钥）
             </div>
         </div>
         
@@ -145,6 +336,30 @@ func main() {
 	
 	http.HandleFunc("/health", health.Handler)
 	http.HandleFunc("/ws", channelManager.WebSocketHandler)
+
+	// 管理API：/api/ 下的接口都需要API密钥，只读接口需要 viewer，写操作需要 operator，密钥管理需要 admin
+	authenticator := auth.NewAuthenticator(keyStore)
+	defer authenticator.Close()
+
+	api := http.NewServeMux()
+	http.Handle("/api/", authenticator.Middleware(api))
+	keyStore.RegisterHandlers(api)
+	handoffManager.RegisterHandlers(api)
+	api.HandleFunc("/api/conversations/history", historyStore.Handler)
+	api.HandleFunc("/api/safety/audit", safetyFilter.AuditHandler)
+	spamDetector.RegisterHandlers(api)
+
+	// 版本化接口：路由同时用于生成 OpenAPI 描述
+	v1 := openapi.NewRouter("live-im-proxy API", "v1")
+	session.NewService(coordinator, accountStore, config.DefaultTenant).RegisterRoutes(v1)
+	discoveryJob.RegisterRoutes(v1)
+	giftTracker.RegisterRoutes(v1)
+	broadcaster.RegisterRoutes(v1)
+	catalog.NewService(productCatalog, config.DefaultTenant).RegisterRoutes(v1)
+	knowledge.NewService(knowledgeBase, config.DefaultTenant).RegisterRoutes(v1)
+	replyCache.RegisterRoutes(v1)
+	v1.Handle(openapi.Route{Method: "GET", Path: "/api/v1/openapi.json", Summary: "OpenAPI 描述", HandlerFunc: v1.SpecHandler})
+	v1.Register(api)
 	
 	// OAuth授权路由 - 支持JSON和重定向两种方式
 	http.HandleFunc("/oauth/douyin", func(w http.ResponseWriter, r *http.Request) {
@@ -167,48 +382,16 @@ func main() {
 	
 	// 白名单授权路由
 	http.HandleFunc("/oauth/douyin/whitelist", func(w http.ResponseWriter, r *http.Request) {
-		whitelistOAuth := oauth.NewDouyinOAuth(config.DouyinAppID, config.DouyinAppSecret, config.RedirectURI, "trial.whitelist")
+		whitelistOAuth := oauth.NewDouyinOAuth(providers.Douyin.AppID, providers.Douyin.AppSecret, providers.Douyin.RedirectURI, "trial.whitelist")
 		authURL := whitelistOAuth.GetAuthURL()
 		http.Redirect(w, r, authURL, http.StatusFound)
 	})
 	
-	// API: 测试模拟事件
-	http.HandleFunc("/api/test/simulate", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		log.Printf("🎬 启动模拟事件测试")
-
-		douyinChannel, err := channel.NewDouyinChannel(pipeline)
-		if err != nil {
-			http.Error(w, "创建渠道失败: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// 启动模拟事件（传入空的access_token使用模拟模式）
-		err = douyinChannel.Start("123456789", "")
-		if err != nil {
-			http.Error(w, "启动渠道失败: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		log.Printf("✅ 模拟事件已启动")
-
-		response := map[string]interface{}{
-			"success": true,
-			"message": "模拟事件已启动，请观察日志中的事件处理流程",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	})
-
 	http.HandleFunc("/oauth/callback", func(w http.ResponseWriter, r *http.Request) {
 		code := r.URL.Query().Get("code")
 		state := r.URL.Query().Get("state")
 		
-		log.Printf("📨 收到OAuth回调: code=%s, state=%s", code, state)
+		log.Printf("📨 收到OAuth回调: code=%s, state=%s", secrets.Redact(code), state)
 		
 		if code == "" {
 			http.Error(w, "缺少授权码", http.StatusBadRequest)
@@ -235,12 +418,14 @@ func main() {
 				openID = "whitelist_" + state
 			}
 			
-			accountStore[openID] = &AccountInfo{
-				Token:    token,
-				UserInfo: nil,
+			if err := accountStore.Put(&oauth.Account{OpenID: openID, Token: token}); err != nil {
+				log.Printf("❌ 保存账号信息失败: %v", err)
+				http.Error(w, "保存授权信息失败", http.StatusInternalServerError)
+				return
 			}
 			
 			log.Printf("✅ 白名单授权成功: open_id=%s", openID)
+			syncConfigRooms()
 			
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.Write([]byte(fmt.Sprintf(`
@@ -248,20 +433,34 @@ func main() {
 				<p>OpenID: %s</p>
 				<p>授权已完成，你可以关闭此页面</p>
 				<p><a href="/oauth/douyin">点击这里进行用户授权</a></p>
-			`, openID)))
+			`, html.EscapeString(openID))))
 			return
 		}
 		
-		// 保存账号信息到内存（key为open_id）
-		accountStore[userInfo.OpenID] = &AccountInfo{
-			Token:    token,
-			UserInfo: userInfo,
+		// 保存账号信息（key为open_id），令牌只保存在服务端
+		if err := accountStore.Put(&oauth.Account{OpenID: userInfo.OpenID, Token: token, UserInfo: userInfo}); err != nil {
+			log.Printf("❌ 保存账号信息失败: %v", err)
+			http.Error(w, "保存授权信息失败", http.StatusInternalServerError)
+			return
 		}
 		log.Printf("✅ 保存账号信息: open_id=%s, nickname=%s", userInfo.OpenID, userInfo.Nickname)
+		syncConfigRooms()
 		
-		// 返回HTML页面，显示授权信息并通知父窗口
+		// 返回HTML页面，只向管理后台来源通知授权结果，不包含任何令牌
+		notify, _ := json.Marshal(map[string]interface{}{
+			"type": "DOUYIN_AUTH_SUCCESS",
+			"data": map[string]interface{}{
+				"open_id":    userInfo.OpenID,
+				"nickname":   userInfo.Nickname,
+				"expires_in": token.ExpiresIn,
+				"avatar":     userInfo.Avatar,
+			},
+		})
+		targetOrigin, _ := json.Marshal(config.Server.AdminOrigin)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		html := fmt.Sprintf(`
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		page := fmt.Sprintf(`
 			<!DOCTYPE html>
 			<html>
 			<head>
@@ -274,51 +473,48 @@ func main() {
 					<h3>授权信息：</h3>
 					<p><strong>OpenID:</strong> %s</p>
 					<p><strong>昵称:</strong> %s</p>
-					<p><strong>Access Token:</strong> %s</p>
 					<p><strong>有效期:</strong> %d秒</p>
 				</div>
 				<p>授权已完成，你可以关闭此页面</p>
 				<script>
-					// 通知父窗口授权成功
+					// 通知父窗口授权成功（仅限管理后台来源，未配置时仅同源）
 					if (window.opener) {
-						window.opener.postMessage({
-							type: 'DOUYIN_AUTH_SUCCESS',
-							data: {
-								"open_id": "%s",
-								"nickname": "%s", 
-								"access_token": "%s",
-								"expires_in": %d,
-								"avatar": "%s"
-							}
-						}, '*');
+						window.opener.postMessage(%s, %s || window.location.origin);
 					}
 				</script>
 			</body>
 			</html>
-		`, userInfo.OpenID, userInfo.Nickname, token.AccessToken, token.ExpiresIn,
-		   userInfo.OpenID, userInfo.Nickname, token.AccessToken, token.ExpiresIn, userInfo.Avatar)
-		w.Write([]byte(html))
+		`, html.EscapeString(userInfo.OpenID), html.EscapeString(userInfo.Nickname), token.ExpiresIn, notify, targetOrigin)
+		w.Write([]byte(page))
 	})
 	
 	// API: 启动抖音渠道监听
-	http.HandleFunc("/api/channel/douyin/start", func(w http.ResponseWriter, r *http.Request) {
+	api.HandleFunc("/api/channel/douyin/start", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 		
-		// 解析请求参数
+		// 解析请求参数，租户密钥的 tenant_id 已由鉴权中间件限定
 		openID := r.FormValue("open_id")
 		roomID := r.FormValue("room_id")
+		tenantID := r.FormValue("tenant_id")
+		if tenantID == "" {
+			tenantID = config.DefaultTenant
+		}
 		
 		if openID == "" || roomID == "" {
 			http.Error(w, "缺少参数: open_id 或 room_id", http.StatusBadRequest)
 			return
 		}
+		if !auth.CanAccessTenant(r, tenantID) {
+			http.Error(w, "无权访问该租户", http.StatusForbidden)
+			return
+		}
 		
-		// 从存储中获取access_token
-		accountInfo, exists := accountStore[openID]
-		if !exists {
+		// 从存储中获取access_token，只能使用本租户授权的账号
+		accountInfo, exists := accountStore.Owned(openID, tenantID)
+		if !exists || accountInfo.Token == nil {
 			http.Error(w, "未找到账号信息，请先授权", http.StatusNotFound)
 			return
 		}
@@ -327,39 +523,112 @@ func main() {
 		
 		log.Printf("🎯 启动抖音渠道监听: open_id=%s, room_id=%s", openID, roomID)
 		
-		// 创建抖音渠道实例
-		douyinChannel, err := channel.NewDouyinChannel(pipeline)
-		if err != nil {
-			http.Error(w, "创建渠道失败: "+err.Error(), http.StatusInternalServerError)
-			return
+		// 提交会话，由持有租约的副本启动渠道监听
+		spec := lease.SessionSpec{
+			Key:         lease.SessionKey(tenantID, "douyin", openID, roomID),
+			TenantID:    tenantID,
+			Platform:    "douyin",
+			AccountID:   openID,
+			RoomID:      roomID,
+			AccessToken: token.AccessToken,
 		}
-		
-		// 将channel设置为pipeline的回复发送器
-		pipeline.SetReplySender(douyinChannel)
-		
-		// 启动渠道监听
-		err = douyinChannel.Start(roomID, token.AccessToken)
-		if err != nil {
-			log.Printf("❌ 启动渠道失败: %v", err)
+		if err := coordinator.Submit(r.Context(), spec); err != nil {
+			log.Printf("❌ 提交会话失败: %v", err)
 			http.Error(w, "启动渠道失败: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 		
-		log.Printf("✅ 抖音渠道启动成功")
+		log.Printf("✅ 抖音会话已提交: %s", spec.Key)
 		
 		// 返回成功
 		response := map[string]interface{}{
 			"success": true,
 			"message": "渠道启动成功",
+			"session": spec.Key,
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	})
 	
-	http.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+	// API: 停止抖音渠道监听
+	api.HandleFunc("/api/channel/douyin/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		
+		openID := r.FormValue("open_id")
+		roomID := r.FormValue("room_id")
+		if openID == "" || roomID == "" {
+			http.Error(w, "缺少参数: open_id 或 room_id", http.StatusBadRequest)
+			return
+		}
+		
+		// 其他租户的会话按不存在处理
+		tenantID := r.FormValue("tenant_id")
+		if tenantID == "" {
+			tenantID = config.DefaultTenant
+		}
+		key := lease.SessionKey(tenantID, "douyin", openID, roomID)
+		spec, err := coordinator.Session(r.Context(), key)
+		if err == lease.ErrNotFound || (err == nil && !auth.CanAccessTenant(r, spec.TenantID)) {
+			http.Error(w, lease.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "停止渠道失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := coordinator.Remove(r.Context(), key); err != nil {
+			if err == lease.ErrNotFound {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, "停止渠道失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		
+		log.Printf("✅ 抖音会话已删除: %s", key)
+		
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "渠道已停止",
+		})
+	})
+	
+	api.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		// 租户密钥只能看到本租户的统计
+		counters := stats.Snapshot()
+		if tenantID := r.URL.Query().Get("tenant_id"); tenantID != "" {
+			filtered := counters[:0]
+			for _, c := range counters {
+				if c.TenantID == tenantID {
+					filtered = append(filtered, c)
+				}
+			}
+			counters = filtered
+		}
+		// 事件总线、垃圾评论和AI熔断是全局状态，只返回给可访问全部租户的密钥
+		if key, ok := auth.FromContext(r.Context()); ok && key.TenantID != auth.AllTenants {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":    "running",
+				"sessions":  channelManager.GetSessionStatus(key.TenantID),
+				"analytics": counters,
+				"timestamp": time.Now().Unix(),
+			})
+			return
+		}
 		status := map[string]interface{}{
 			"status":    "running",
-			"channels":  channelManager.GetChannelStatus(),
+			"replica":   coordinator.ReplicaID(),
+			"sessions":  channelManager.GetSessionStatus(""),
+			"monitor_clients": monitorHub.ClientCount(),
+			"analytics": counters,
+			"bus":       eventBus.Stats(),
+			"spam":      spamDetector.Stats(),
+			"ai_breaker": pipeline.BreakerState(),
 			"timestamp": time.Now().Unix(),
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -368,17 +637,46 @@ func main() {
 
 	// 启动 HTTP 服务器
 	server := &http.Server{
-		Addr:    ":" + config.Port,
+		Addr:    ":" + config.Server.Port,
 		Handler: nil,
 	}
 
 	go func() {
-		log.Printf("🌐 HTTP 服务器启动: http://localhost:%s", config.Port)
+		log.Printf("🌐 HTTP 服务器启动: http://localhost:%s", config.Server.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("❌ HTTP 服务器启动失败: %v", err)
 		}
 	}()
 
+	// SIGHUP 热加载回复规则、视频发现策略和定时消息，其余配置需重启生效
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			next, err := appconfig.Load(*configPath)
+			if err != nil {
+				log.Printf("❌ 重新加载配置失败，继续使用当前配置: %v", err)
+				continue
+			}
+			safetyFilter.SetConfig(next.Reply.Safety)
+			replyPolicy.SetConfig(next.Reply.Policy)
+			spamDetector.SetConfig(next.Reply.SpamConfig())
+			handoffManager.SetConfig(next.Reply.HandoffConfig())
+			giftTracker.SetConfig(next.Reply.GiftsConfig())
+			greeter.SetConfig(next.Reply.WelcomeConfig())
+			knowledgeBase.SetConfig(next.Reply.Knowledge)
+			replyCache.SetConfig(next.Reply.CacheConfig())
+			pipeline.SetFallbackConfig(next.Reply.FallbackConfig())
+			replyCache.Invalidate("")
+			discoveryJob.SetConfig(next.Discovery.DiscoveryConfig())
+			broadcaster.SetConfig(next.Broadcast.BroadcastConfig(next.DefaultTenant))
+			if changed := config.StructuralChanges(next); len(changed) > 0 {
+				log.Printf("⚠️ 以下配置变更需重启后生效: %v", changed)
+			}
+			log.Printf("🔄 回复规则、视频发现策略和定时消息已重新加载")
+		}
+	}()
+
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -394,22 +692,20 @@ func main() {
 		log.Printf("❌ 服务器关闭失败: %v", err)
 	}
 
+	// 释放会话租约，由其他副本立即接管
+	discoveryJob.Stop()
+	broadcaster.Close()
+	coordinator.Stop()
+
 	// 关闭渠道连接
 	channelManager.StopAll()
+	handoffManager.Close()
+	giftTracker.Close()
+	greeter.Close()
+	replyCache.Close()
+	replyPolicy.Close()
+	eventBus.Close()
+	monitorHub.Close()
 
 	log.Println("✅ 服务器已关闭")
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
-
-func maskSecret(secret string) string {
-	if len(secret) < 8 {
-		return "***"
-	}
-	return secret[:4] + "..." + secret[len(secret)-4:]
-}
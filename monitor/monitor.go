@@ -0,0 +1,448 @@
+package monitor
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"live-im-proxy/auth"
+	"live-im-proxy/bus"
+)
+
+const (
+	// writeWait 单条消息写超时
+	writeWait = 10 * time.Second
+	// pongWait 等待客户端pong的超时
+	pongWait = 60 * time.Second
+	// pingPeriod 发送ping的间隔，必须小于pongWait
+	pingPeriod = (pongWait * 9) / 10
+	// sendBuffer 每个客户端的发送缓冲，写满视为慢消费者并断开
+	sendBuffer = 256
+	// keyProtocol 浏览器无法设置请求头，通过 Sec-WebSocket-Protocol: api-key, <密钥> 传递API密钥
+	keyProtocol = "api-key"
+)
+
+// keyCheckInterval 复核连接密钥的间隔，密钥吊销后在该时间内断开连接
+var keyCheckInterval = 30 * time.Second
+
+// 推送消息类型
+const (
+	TypeEvent      = "event"       // 收到的直播间/私信事件
+	TypeReply      = "reply"       // 生成的回复
+	TypeSendResult = "send_result" // 回复发送结果
+	TypeLead       = "lead"        // 线索已推送到CRM
+)
+
+// Message 监控推送消息
+type Message struct {
+	Type      string      `json:"type"`
+	TenantID  string      `json:"tenant_id,omitempty"`
+	Channel   string      `json:"channel,omitempty"`
+	RoomID    string      `json:"room_id,omitempty"`
+	Data      interface{} `json:"data"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// ReplyData 回复消息数据
+type ReplyData struct {
+	EventID string `json:"event_id"`
+	UserID  string `json:"user_id"`
+	Content string `json:"content"`
+}
+
+// SendResultData 发送结果数据
+type SendResultData struct {
+	EventID string `json:"event_id"`
+	UserID  string `json:"user_id"`
+	Content string `json:"content"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Filter 订阅过滤条件，空字段表示不过滤
+type Filter struct {
+	TenantID string   `json:"tenant_id"`
+	Channel  string   `json:"channel"`
+	RoomID   string   `json:"room_id"`
+	Types    []string `json:"types"`
+}
+
+// Match 判断消息是否符合过滤条件
+func (f *Filter) Match(msg *Message) bool {
+	if f.TenantID != "" && f.TenantID != msg.TenantID {
+		return false
+	}
+	if f.Channel != "" && f.Channel != msg.Channel {
+		return false
+	}
+	if f.RoomID != "" && f.RoomID != msg.RoomID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == msg.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// clientCommand 客户端发送的控制指令
+type clientCommand struct {
+	Action string `json:"action"` // subscribe
+	Filter
+}
+
+// client WebSocket客户端
+type client struct {
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan []byte
+	keyID  string // 连接使用的密钥，定期复核是否已吊销
+	tenant string // 密钥所属租户，租户密钥只能订阅本租户
+	mu     sync.RWMutex
+	filter Filter
+}
+
+// scope 将过滤条件限定在客户端可访问的租户内，订阅其他租户时返回 false
+func (c *client) scope(filter *Filter) bool {
+	if c.tenant == auth.AllTenants {
+		return true
+	}
+	if filter.TenantID != "" && filter.TenantID != c.tenant {
+		return false
+	}
+	filter.TenantID = c.tenant
+	return true
+}
+
+// Hub 实时监控中心，向已连接的运营后台推送事件和回复
+type Hub struct {
+	keys        *auth.Store
+	adminOrigin string
+	upgrader    websocket.Upgrader
+	clients     map[*client]bool
+	mu          sync.RWMutex
+}
+
+// NewHub 创建监控中心：连接使用与管理API相同的API密钥鉴权，租户密钥只能订阅本租户；
+// 浏览器连接只接受同源和 adminOrigin（管理后台地址）
+func NewHub(keys *auth.Store, adminOrigin string) *Hub {
+	if keys.Empty() {
+		log.Printf("⚠️ 没有可用的API密钥，/ws 监控连接将被全部拒绝")
+	}
+	h := &Hub{
+		keys:        keys,
+		adminOrigin: strings.TrimSuffix(adminOrigin, "/"),
+		clients:     make(map[*client]bool),
+	}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    []string{keyProtocol},
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+// ServeWS 处理 /ws 连接升级，API密钥通过 Authorization: Bearer、X-API-Key 头
+// 或 Sec-WebSocket-Protocol: api-key, <密钥> 传递
+// 支持的查询参数: tenant_id, channel, room_id, types（逗号分隔）
+func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	key, err := h.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	c := &client{
+		hub:    h,
+		send:   make(chan []byte, sendBuffer),
+		keyID:  key.ID,
+		tenant: key.TenantID,
+		filter: Filter{
+			TenantID: q.Get("tenant_id"),
+			Channel:  q.Get("channel"),
+			RoomID:   q.Get("room_id"),
+			Types:    splitList(q.Get("types")),
+		},
+	}
+	if !c.scope(&c.filter) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("❌ WebSocket升级失败: %v", err)
+		return
+	}
+	c.conn = conn
+
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+
+	log.Printf("🔌 监控客户端已连接: %s (tenant=%s, channel=%s, room=%s)",
+		r.RemoteAddr, c.filter.TenantID, c.filter.Channel, c.filter.RoomID)
+
+	go c.writePump()
+	go c.readPump()
+}
+
+// authenticate 校验连接的API密钥，不接受查询参数中的密钥（会出现在访问日志中）
+func (h *Hub) authenticate(r *http.Request) (auth.Key, error) {
+	raw := r.Header.Get("X-API-Key")
+	if bearer := r.Header.Get("Authorization"); raw == "" && strings.HasPrefix(bearer, "Bearer ") {
+		raw = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	if raw == "" {
+		protocols := websocket.Subprotocols(r)
+		if len(protocols) == 2 && protocols[0] == keyProtocol {
+			raw = protocols[1]
+		}
+	}
+	if raw == "" {
+		return auth.Key{}, auth.ErrInvalidKey
+	}
+	return h.keys.Authenticate(raw)
+}
+
+// checkOrigin 浏览器连接只接受同源和管理后台地址，非浏览器客户端没有 Origin 头
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if h.adminOrigin != "" && origin == h.adminOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// Broadcast 推送消息给所有匹配过滤条件的客户端
+func (h *Hub) Broadcast(msg *Message) {
+	if msg.Timestamp == 0 {
+		msg.Timestamp = time.Now().Unix()
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("❌ 序列化监控消息失败: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	var slow []*client
+	for c := range h.clients {
+		c.mu.RLock()
+		match := c.filter.Match(msg)
+		c.mu.RUnlock()
+		if !match {
+			continue
+		}
+		select {
+		case c.send <- data:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	// 发送缓冲已满的慢客户端直接断开，避免拖慢整体推送
+	for _, c := range slow {
+		log.Printf("⚠️ 监控客户端消费过慢，断开连接: %s", c.conn.RemoteAddr())
+		h.remove(c)
+	}
+}
+
+// ClientCount 当前连接的客户端数量
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Subscribe 订阅事件总线，将管道消息转发给监控客户端
+func (h *Hub) Subscribe(b *bus.Bus) *bus.Subscription {
+	return b.Subscribe("monitor", 0, h.handleBus)
+}
+
+// handleBus 将总线消息转换为监控推送消息
+func (h *Hub) handleBus(msg *bus.Message) {
+	evt := msg.Event
+	if evt == nil {
+		return
+	}
+
+	out := &Message{
+		TenantID:  evt.TenantID,
+		Channel:   evt.Channel,
+		RoomID:    evt.RoomID,
+		Timestamp: msg.Timestamp,
+	}
+
+	content := ""
+	if msg.Reply != nil {
+		content = msg.Reply.Content
+	}
+
+	switch msg.Topic {
+	case bus.TopicEventReceived:
+		out.Type = TypeEvent
+		out.Data = evt
+	case bus.TopicReplyGenerated:
+		out.Type = TypeReply
+		out.Data = &ReplyData{
+			EventID: evt.ID,
+			UserID:  evt.UserID,
+			Content: content,
+		}
+	case bus.TopicReplySent, bus.TopicReplyFailed:
+		out.Type = TypeSendResult
+		out.Data = &SendResultData{
+			EventID: evt.ID,
+			UserID:  evt.UserID,
+			Content: content,
+			Success: msg.Topic == bus.TopicReplySent,
+			Error:   msg.Error,
+		}
+	case bus.TopicLeadPushed:
+		out.Type = TypeLead
+		out.Data = evt
+	default:
+		return
+	}
+
+	h.Broadcast(out)
+}
+
+// Close 断开所有客户端
+func (h *Hub) Close() {
+	h.mu.Lock()
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		h.remove(c)
+	}
+}
+
+// remove 移除客户端并关闭发送通道
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+	h.mu.Unlock()
+}
+
+// readPump 读取客户端指令，处理订阅变更和心跳
+func (c *client) readPump() {
+	defer func() {
+		c.hub.remove(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(4096)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("❌ 监控客户端读取失败: %v", err)
+			}
+			return
+		}
+
+		var cmd clientCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			log.Printf("⚠️ 无法解析监控客户端指令: %v", err)
+			continue
+		}
+
+		switch cmd.Action {
+		case "subscribe":
+			if !c.scope(&cmd.Filter) {
+				log.Printf("🔒 监控订阅被拒绝: tenant=%s 不属于密钥租户 %s", cmd.TenantID, c.tenant)
+				continue
+			}
+			c.mu.Lock()
+			c.filter = cmd.Filter
+			c.mu.Unlock()
+			log.Printf("🔔 监控订阅已更新: tenant=%s, channel=%s, room=%s, types=%v",
+				cmd.TenantID, cmd.Channel, cmd.RoomID, cmd.Types)
+		default:
+			log.Printf("⚠️ 未知的监控指令: %s", cmd.Action)
+		}
+	}
+}
+
+// writePump 向客户端写消息并定时发送ping，密钥吊销后断开连接
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	keyCheck := time.NewTicker(keyCheckInterval)
+	defer func() {
+		ticker.Stop()
+		keyCheck.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-keyCheck.C:
+			if c.hub.keys.Active(c.keyID) {
+				continue
+			}
+			log.Printf("🔒 监控客户端的API密钥已吊销，断开连接: %s", c.conn.RemoteAddr())
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "API密钥已吊销"))
+			return
+		}
+	}
+}
+
+// splitList 拆分逗号分隔的参数
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package monitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"live-im-proxy/auth"
+)
+
+// newTestHub 启动挂载 /ws 的测试服务，返回密钥存储和 ws 地址
+func newTestHub(t *testing.T) (*Hub, *auth.Store, string) {
+	t.Helper()
+	keys, err := auth.NewStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.SetBootstrapKey("admin-secret")
+	hub := NewHub(keys, "")
+	server := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return hub, keys, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dial 使用密钥连接，返回连接和握手响应
+func dial(t *testing.T, url, key string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if key != "" {
+		header.Set("X-API-Key", key)
+	}
+	conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// waitClients 等待监控中心登记指定数量的客户端
+func waitClients(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.ClientCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("ClientCount = %d, 应为 %d", hub.ClientCount(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServeWSRequiresKey(t *testing.T) {
+	_, keys, url := newTestHub(t)
+	raw, _, err := keys.Create("tenant-a", "运营", auth.RoleViewer, 0, 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, resp, err := dial(t, url, ""); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("没有密钥的连接应返回401: %v", err)
+	}
+	if _, resp, err := dial(t, url, "lbk_bad_secret"); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("无效密钥的连接应返回401: %v", err)
+	}
+	if _, resp, err := dial(t, url+"?tenant_id=tenant-b", raw); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("租户密钥订阅其他租户应返回403: %v", err)
+	}
+
+	// 浏览器通过子协议传递密钥
+	header := http.Header{"Sec-WebSocket-Protocol": {keyProtocol + ", " + raw}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("子协议传递的密钥应通过鉴权: %v", err)
+	}
+	conn.Close()
+}
+
+func TestBroadcastFiltersByKeyTenant(t *testing.T) {
+	hub, keys, url := newTestHub(t)
+	raw, _, err := keys.Create("tenant-a", "运营", auth.RoleViewer, 0, 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenantConn, _, err := dial(t, url, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminConn, _, err := dial(t, url, "admin-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitClients(t, hub, 2)
+
+	hub.Broadcast(&Message{Type: TypeEvent, TenantID: "tenant-b", Data: "b"})
+	hub.Broadcast(&Message{Type: TypeEvent, TenantID: "tenant-a", Data: "a"})
+
+	if got := readData(t, tenantConn); got != "a" {
+		t.Fatalf("租户密钥只应收到本租户消息，收到 %q", got)
+	}
+	if first, second := readData(t, adminConn), readData(t, adminConn); first != "b" || second != "a" {
+		t.Fatalf("管理员密钥应收到全部租户消息，收到 %q, %q", first, second)
+	}
+}
+
+func TestRevokedKeyDisconnects(t *testing.T) {
+	interval := keyCheckInterval
+	keyCheckInterval = 20 * time.Millisecond
+	t.Cleanup(func() { keyCheckInterval = interval })
+
+	hub, keys, url := newTestHub(t)
+	raw, key, err := keys.Create("tenant-a", "运营", auth.RoleViewer, 0, 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := dial(t, url, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitClients(t, hub, 1)
+
+	if _, err := keys.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Fatalf("密钥吊销后应断开连接: %v", err)
+	}
+	waitClients(t, hub, 0)
+}
+
+// readData 读取一条推送消息的 data 字段
+func readData(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg struct {
+		Data string `json:"data"`
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.Data
+}
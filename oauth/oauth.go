@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"live-im-proxy/secrets"
 )
 
 // DouyinOAuth 抖音OAuth授权
@@ -117,7 +119,7 @@ func (o *DouyinOAuth) ExchangeCodeForToken(code string) (*OAuthToken, error) {
 		return nil, fmt.Errorf("获取访问令牌失败: token为空，可能是授权码无效")
 	}
 
-	log.Printf("✅ 成功获取访问令牌: %s", result.Data.AccessToken)
+	log.Printf("✅ 成功获取访问令牌: open_id=%s, access_token=%s", result.Data.OpenID, secrets.Redact(result.Data.AccessToken))
 	return &result.Data, nil
 }
 
@@ -126,7 +128,7 @@ func (o *DouyinOAuth) GetUserInfo(accessToken string) (*UserInfo, error) {
 	// 构建请求URL
 	reqURL := fmt.Sprintf("https://open.douyin.com/oauth/userinfo/?access_token=%s", accessToken)
 	
-	log.Printf("🔍 请求用户信息URL: %s", reqURL)
+	log.Printf("🔍 请求用户信息URL: %s", secrets.RedactURL(reqURL))
 
 	// 发送请求
 	resp, err := http.Get(reqURL)
@@ -138,7 +140,7 @@ func (o *DouyinOAuth) GetUserInfo(accessToken string) (*UserInfo, error) {
 	// 读取原始响应以便调试
 	body := make([]byte, 4096)
 	n, _ := resp.Body.Read(body)
-	log.Printf("📥 用户信息API原始响应: %s", string(body[:n]))
+	log.Printf("📥 用户信息API原始响应: %s", secrets.RedactText(string(body[:n])))
 	
 	// 用读取的body创建一个新的reader
 	reader := strings.NewReader(string(body[:n]))
@@ -201,7 +203,7 @@ func (o *DouyinOAuth) RefreshToken(refreshToken string) (*OAuthToken, error) {
 		return nil, fmt.Errorf("刷新访问令牌失败: %s", result.Error.Message)
 	}
 
-	log.Printf("✅ 成功刷新访问令牌: %s", result.Data.AccessToken)
+	log.Printf("✅ 成功刷新访问令牌: open_id=%s, access_token=%s", result.Data.OpenID, secrets.Redact(result.Data.AccessToken))
 	return &result.Data, nil
 }
 
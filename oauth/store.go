@@ -0,0 +1,192 @@
+package oauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"live-im-proxy/secrets"
+)
+
+// Account 已授权账号
+type Account struct {
+	OpenID    string      `json:"open_id"`
+	TenantID  string      `json:"tenant_id,omitempty"` // 所属租户，未记录时属于默认租户
+	Token     *OAuthToken `json:"token"`
+	UserInfo  *UserInfo   `json:"user_info,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// AccountStore 授权账号存储，持久化时令牌以信封加密保存
+type AccountStore struct {
+	path          string
+	keys          *secrets.KeyRing
+	defaultTenant string
+	accounts      map[string]*Account
+	mu            sync.RWMutex
+}
+
+// NewAccountStore 创建账号存储，path为空时只保存在内存；
+// 加载时会把旧主密钥加密或明文保存的令牌用当前主密钥重新加密，未记录租户的账号归入 defaultTenant
+func NewAccountStore(path string, keys *secrets.KeyRing, defaultTenant string) (*AccountStore, error) {
+	s := &AccountStore{
+		path:          path,
+		keys:          keys,
+		defaultTenant: defaultTenant,
+		accounts:      make(map[string]*Account),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取账号存储失败: %v", err)
+	}
+
+	var stored []*Account
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("解析账号存储失败: %v", err)
+	}
+
+	rotate := false
+	for _, account := range stored {
+		if account.Token == nil {
+			continue
+		}
+		if keys.NeedsRotation(account.Token.AccessToken) || keys.NeedsRotation(account.Token.RefreshToken) {
+			rotate = true
+		}
+		token, err := s.decrypt(account.Token)
+		if err != nil {
+			log.Printf("⚠️ 跳过无法解密的账号令牌: open_id=%s, err=%v", account.OpenID, err)
+			continue
+		}
+		account.Token = token
+		if account.TenantID == "" {
+			account.TenantID = defaultTenant
+		}
+		s.accounts[account.OpenID] = account
+	}
+
+	if rotate {
+		if err := s.save(); err != nil {
+			return nil, err
+		}
+		log.Printf("🔐 账号令牌已使用主密钥 %s 重新加密", keys.Primary())
+	}
+	log.Printf("📒 已加载 %d 个授权账号", len(s.accounts))
+	return s, nil
+}
+
+// Put 保存账号，未指定租户时归入默认租户
+func (s *AccountStore) Put(account *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if account.TenantID == "" {
+		account.TenantID = s.defaultTenant
+	}
+	account.UpdatedAt = time.Now()
+	s.accounts[account.OpenID] = account
+	return s.save()
+}
+
+// Get 获取账号
+func (s *AccountStore) Get(openID string) (*Account, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.accounts[openID]
+	return account, ok
+}
+
+// Owned 租户名下的账号，账号不存在或属于其他租户时返回false
+func (s *AccountStore) Owned(openID, tenantID string) (*Account, bool) {
+	account, ok := s.Get(openID)
+	if !ok || account.TenantID != tenantID {
+		return nil, false
+	}
+	return account, true
+}
+
+// List 所有账号，按 open_id 排序
+func (s *AccountStore) List() []*Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].OpenID < accounts[j].OpenID })
+	return accounts
+}
+
+// save 加密令牌后写入文件，调用方需持有锁
+func (s *AccountStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	stored := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		copied := *account
+		if account.Token != nil {
+			token, err := s.encrypt(account.Token)
+			if err != nil {
+				return fmt.Errorf("加密令牌失败: %v", err)
+			}
+			copied.Token = token
+		}
+		stored = append(stored, &copied)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].OpenID < stored[j].OpenID })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建账号存储目录失败: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入账号存储失败: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// encrypt 返回令牌副本，access_token 和 refresh_token 为密文
+func (s *AccountStore) encrypt(token *OAuthToken) (*OAuthToken, error) {
+	copied := *token
+	var err error
+	if copied.AccessToken, err = s.keys.Encrypt(token.AccessToken); err != nil {
+		return nil, err
+	}
+	if copied.RefreshToken, err = s.keys.Encrypt(token.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+// decrypt 返回解密后的令牌副本
+func (s *AccountStore) decrypt(token *OAuthToken) (*OAuthToken, error) {
+	copied := *token
+	var err error
+	if copied.AccessToken, err = s.keys.Decrypt(token.AccessToken); err != nil {
+		return nil, err
+	}
+	if copied.RefreshToken, err = s.keys.Decrypt(token.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
@@ -0,0 +1,331 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Param 查询参数说明
+type Param struct {
+	Name        string
+	Description string
+}
+
+// Route 接口路由及其文档描述
+//
+// Path 中的 {name} 为路径参数，处理函数中通过 PathParam 读取；
+// Request/Response 为请求体和响应 data 字段的示例值，仅用于生成文档
+type Route struct {
+	Method      string
+	Path        string
+	Summary     string
+	Tag         string
+	Query       []Param
+	Request     interface{}
+	Response    interface{}
+	Status      int // 成功状态码，默认200
+	HandlerFunc http.HandlerFunc
+}
+
+// Router 按方法和路径模板分发请求，并根据注册的路由生成 OpenAPI 描述
+type Router struct {
+	title   string
+	version string
+	routes  []Route
+}
+
+// NewRouter 创建路由
+func NewRouter(title, version string) *Router {
+	return &Router{title: title, version: version}
+}
+
+// Handle 注册路由
+func (rt *Router) Handle(route Route) {
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+	rt.routes = append(rt.routes, route)
+}
+
+// Paths 注册过的路径模板对应的前缀，用于挂载到 http.ServeMux
+func (rt *Router) Paths() []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, route := range rt.routes {
+		prefix := route.Path
+		if i := strings.Index(prefix, "{"); i >= 0 {
+			prefix = prefix[:i]
+		}
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// Register 将所有路由挂载到 mux
+func (rt *Router) Register(mux *http.ServeMux) {
+	for _, prefix := range rt.Paths() {
+		mux.Handle(prefix, rt)
+	}
+}
+
+type paramsKey struct{}
+
+// PathParam 读取路径参数
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// ServeHTTP 分发请求：路径匹配但方法不匹配返回405，无匹配返回404
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, route := range rt.routes {
+		params, ok := match(route.Path, r.URL.Path)
+		if !ok {
+			continue
+		}
+		if route.Method != r.Method {
+			allowed = append(allowed, route.Method)
+			continue
+		}
+		if len(params) > 0 {
+			r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+		}
+		route.HandlerFunc(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// match 按路径模板匹配，返回路径参数
+func match(pattern, path string) (map[string]string, bool) {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	xs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ps) != len(xs) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, p := range ps {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if xs[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[p[1:len(p)-1]] = xs[i]
+			continue
+		}
+		if p != xs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// SpecHandler 输出 OpenAPI 描述
+func (rt *Router) SpecHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(rt.Spec())
+}
+
+// Spec 根据注册的路由生成 OpenAPI 3.0 描述
+func (rt *Router) Spec() map[string]interface{} {
+	g := &generator{schemas: make(map[string]interface{})}
+	paths := make(map[string]map[string]interface{})
+
+	for _, route := range rt.routes {
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+		}
+		if route.Tag != "" {
+			op["tags"] = []string{route.Tag}
+		}
+
+		var params []map[string]interface{}
+		for _, seg := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				params = append(params, map[string]interface{}{
+					"name": seg[1 : len(seg)-1], "in": "path", "required": true,
+					"schema": map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		for _, q := range route.Query {
+			params = append(params, map[string]interface{}{
+				"name": q.Name, "in": "query", "description": q.Description,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if route.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": g.schema(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		// 响应统一为 {"success": true, "data": ...}
+		envelope := map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"success": map[string]interface{}{"type": "boolean"},
+			},
+		}
+		if route.Response != nil {
+			envelope["properties"].(map[string]interface{})["data"] = g.schema(reflect.TypeOf(route.Response))
+		}
+		op["responses"] = map[string]interface{}{
+			strconv.Itoa(route.Status): map[string]interface{}{
+				"description": http.StatusText(route.Status),
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": envelope},
+				},
+			},
+			"default": map[string]interface{}{"description": "错误信息（纯文本）"},
+		}
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = make(map[string]interface{})
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": rt.title, "version": rt.version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+		"security": []map[string]interface{}{{"apiKey": []string{}}},
+	}
+}
+
+// operationID 由方法和路径生成操作ID，如 GET /api/v1/sessions/{id} -> get_sessions_id
+func operationID(route Route) string {
+	var parts []string
+	parts = append(parts, strings.ToLower(route.Method))
+	for _, seg := range strings.Split(strings.Trim(route.Path, "/"), "/") {
+		if seg == "api" || (len(seg) == 2 && seg[0] == 'v') {
+			continue
+		}
+		parts = append(parts, strings.Trim(seg, "{}"))
+	}
+	return strings.Join(parts, "_")
+}
+
+// generator 通过反射生成 JSON Schema，具名结构体放入 components
+type generator struct {
+	schemas map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *generator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = nil // 先占位，防止递归类型无限展开
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// object 结构体的 properties 按 json 标签生成，omitempty 以外的字段视为必填
+func (g *generator) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.schema(f.Type)
+		if desc := f.Tag.Get("doc"); desc != "" {
+			prop = withDescription(prop, desc)
+		}
+		props[name] = prop
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// withDescription 为字段附加说明，$ref 不能带兄弟字段，需包一层 allOf
+func withDescription(schema map[string]interface{}, desc string) map[string]interface{} {
+	if _, ok := schema["$ref"]; ok {
+		return map[string]interface{}{"allOf": []interface{}{schema}, "description": desc}
+	}
+	schema["description"] = desc
+	return schema
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+
+	"live-im-proxy/event"
+	"live-im-proxy/intent"
+	"live-im-proxy/replycache"
+)
+
+// SetReplyCache 设置回复缓存，同一直播间的相同问题在有效期内复用AI回复
+func (p *Pipeline) SetReplyCache(cache *replycache.Cache) {
+	p.cache = cache
+}
+
+// cacheKey 事件的缓存键，带上处理事件时识别的意图。AI请求带有该用户的对话记录时，
+// 回复只适用于该用户，既不复用其他人的回复，也不写入缓存
+func (p *Pipeline) cacheKey(evt *event.Event) (replycache.Key, bool) {
+	if p.cache == nil {
+		return replycache.Key{}, false
+	}
+	key, ok := replycache.KeyFor(evt)
+	if !ok || len(p.buildChatHistory(evt)) > 0 {
+		return replycache.Key{}, false
+	}
+	key.Intent = intent.Of(evt)
+	return key, true
+}
+
+// cachedReply 有效期内缓存的回复
+func (p *Pipeline) cachedReply(evt *event.Event) (string, bool) {
+	key, ok := p.cacheKey(evt)
+	if !ok {
+		return "", false
+	}
+	answer, ok := p.cache.Get(key)
+	if ok {
+		log.Printf("♻️ 复用缓存回复: question=%s", key.Question)
+	}
+	return answer, ok
+}
+
+// cachedAIReply 生成AI回复，没有对话记录的公开评论的回复写入缓存，并发的相同问题只调用一次AI；
+// cached 表示回复来自缓存或同一问题的并发生成
+func (p *Pipeline) cachedAIReply(ctx context.Context, evt *event.Event) (answer string, cached bool, err error) {
+	key, ok := p.cacheKey(evt)
+	if !ok {
+		answer, err = p.generateAIReply(ctx, evt)
+		return answer, false, err
+	}
+
+	answer, cached, err = p.cache.Do(ctx, key, func() (string, error) {
+		return p.generateAIReply(ctx, evt)
+	})
+	if cached {
+		log.Printf("♻️ 复用缓存回复: question=%s", key.Question)
+	}
+	return answer, cached, err
+}
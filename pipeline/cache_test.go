@@ -0,0 +1,46 @@
+package pipeline
+
+import (
+	"testing"
+
+	"live-im-proxy/event"
+	"live-im-proxy/history"
+	"live-im-proxy/intent"
+	"live-im-proxy/replycache"
+)
+
+func TestCacheKeySkipsUsersWithHistory(t *testing.T) {
+	store, err := history.NewStore("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	cache := replycache.NewCache(replycache.DefaultConfig())
+	defer cache.Close()
+	p := &Pipeline{cache: cache, history: store}
+
+	comment := func(userID string) *event.Event {
+		evt := event.NewEvent(event.TypeComment, "douyin", "room", userID, "昵称")
+		evt.TenantID = "tenant"
+		evt.Content = "多少钱"
+		return evt
+	}
+
+	evt := comment("new-user")
+	evt.SetMetadata(intent.MetadataKey, intent.Price)
+	key, ok := p.cacheKey(evt)
+	if !ok {
+		t.Fatal("没有对话记录的公开评论应使用缓存")
+	}
+	if key.Intent != intent.Price {
+		t.Fatalf("缓存键应带上意图，实际 %q", key.Intent)
+	}
+
+	store.Add(history.Entry{TenantID: "tenant", Channel: "douyin", UserID: "regular", Direction: history.DirectionInbound, Content: "有红色吗"})
+	if _, ok := p.cacheKey(comment("regular")); ok {
+		t.Fatal("AI请求带有用户对话记录时不应读写缓存")
+	}
+	if _, ok := p.cacheKey(comment("new-user")); !ok {
+		t.Fatal("其他用户的对话记录不应影响缓存")
+	}
+}
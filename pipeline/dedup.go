@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// dedupTTL 幂等键保留时长，平台重复投递通常发生在数分钟内
+const dedupTTL = 10 * time.Minute
+
+// dedup 按幂等键去重，过期的键在记录新键时顺带清理
+type dedup struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+func newDedup(ttl time.Duration) *dedup {
+	return &dedup{ttl: ttl, seen: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// duplicate 键在有效期内出现过返回true，否则记录该键
+func (d *dedup) duplicate(key string) bool {
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if at, ok := d.seen[key]; ok && now.Sub(at) < d.ttl {
+		return true
+	}
+	d.seen[key] = now
+
+	if now.Sub(d.lastSweep) >= d.ttl {
+		for k, at := range d.seen {
+			if now.Sub(at) >= d.ttl {
+				delete(d.seen, k)
+			}
+		}
+		d.lastSweep = now
+	}
+	return false
+}
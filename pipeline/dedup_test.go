@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupDuplicate(t *testing.T) {
+	d := newDedup(50 * time.Millisecond)
+
+	if d.duplicate("tenant|douyin:comment:1") {
+		t.Fatal("首次出现的键不应视为重复")
+	}
+	if !d.duplicate("tenant|douyin:comment:1") {
+		t.Fatal("有效期内重复投递应视为重复")
+	}
+	if d.duplicate("other|douyin:comment:1") {
+		t.Fatal("不同租户的相同幂等键不应视为重复")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if d.duplicate("tenant|douyin:comment:1") {
+		t.Fatal("过期后应重新处理")
+	}
+	// 记录新键时清理过期的键
+	d.mu.Lock()
+	_, stale := d.seen["other|douyin:comment:1"]
+	d.mu.Unlock()
+	if stale {
+		t.Fatal("过期的键应被清理")
+	}
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"live-im-proxy/breaker"
+	"live-im-proxy/event"
+)
+
+// 回复来源层级，按降级链顺序
+const (
+	TierCache   = "cache"   // 复用缓存的AI回复
+	TierFAQ     = "faq"     // 知识库审核过的答案
+	TierAI      = "ai"      // AI实时生成
+	TierRules   = "rules"   // 商品FAQ、商品信息模板和关键词回复
+	TierDefault = "default" // 兜底回复
+)
+
+// defaultReply 兜底回复
+const defaultReply = "感谢您的关注，欢迎咨询！"
+
+// errAIUnavailable AI熔断中，不调用AI直接降级
+var errAIUnavailable = errors.New("AI 服务熔断中")
+
+// FallbackConfig 回复降级链配置
+type FallbackConfig struct {
+	Budget   time.Duration            // 生成一条回复的延迟预算，AI超时后降级到规则回复
+	Channels map[string]time.Duration // 按渠道覆盖延迟预算
+	Breaker  breaker.Config           // AI连续失败时熔断
+}
+
+// DefaultFallbackConfig 默认配置：3秒内生成回复，AI连续失败5次后熔断30秒
+func DefaultFallbackConfig() FallbackConfig {
+	return FallbackConfig{
+		Budget:  3 * time.Second,
+		Breaker: breaker.DefaultConfig(),
+	}
+}
+
+// SetFallbackConfig 更新延迟预算和AI熔断配置
+func (p *Pipeline) SetFallbackConfig(config FallbackConfig) {
+	p.fallbackMu.Lock()
+	p.fallback = config
+	p.fallbackMu.Unlock()
+	p.aiBreaker.SetConfig(config.Breaker)
+}
+
+// BreakerState AI熔断器状态
+func (p *Pipeline) BreakerState() string {
+	return p.aiBreaker.State()
+}
+
+// budgetFor 渠道生成回复的延迟预算
+func (p *Pipeline) budgetFor(channel string) time.Duration {
+	p.fallbackMu.RLock()
+	defer p.fallbackMu.RUnlock()
+	if budget, ok := p.fallback.Channels[channel]; ok && budget > 0 {
+		return budget
+	}
+	return p.fallback.Budget
+}
+
+// generateReply 按降级链生成回复：缓存 → 知识库 → AI → 规则 → 兜底，返回回复和产生回复的层级。
+// 整条链受渠道的延迟预算约束，AI失败、超时或熔断时降级到规则回复
+func (p *Pipeline) generateReply(evt *event.Event) (answer, tier string) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.budgetFor(evt.Channel))
+	defer cancel()
+
+	if answer, ok := p.cachedReply(evt); ok {
+		return answer, TierCache
+	}
+
+	if answer := p.knowledgeAnswer(evt); answer != "" {
+		return answer, TierFAQ
+	}
+
+	if p.cozeAPI != "" && p.cozeToken != "" {
+		answer, cached, err := p.cachedAIReply(ctx, evt)
+		switch {
+		case errors.Is(err, errAIUnavailable):
+			// 熔断期间直接降级，熔断和恢复时已记录日志
+		case err != nil:
+			log.Printf("❌ AI 生成回复失败，降级到规则回复: %v", err)
+		case cached:
+			return answer, TierCache
+		case answer != "":
+			return answer, TierAI
+		}
+	}
+
+	if answer, ok := p.ruleReply(evt); ok {
+		return answer, TierRules
+	}
+	return defaultReply, TierDefault
+}
+
+// callAI 经熔断器调用AI，失败和超时计入熔断
+func (p *Pipeline) callAI(request func() (string, error)) (string, error) {
+	if !p.aiBreaker.Allow() {
+		return "", errAIUnavailable
+	}
+	answer, err := request()
+	if err != nil {
+		if p.aiBreaker.Failure() {
+			log.Printf("🔌 AI 服务熔断: %v", err)
+		}
+		return "", err
+	}
+	if p.aiBreaker.Success() {
+		log.Printf("🔌 AI 服务恢复")
+	}
+	return answer, nil
+}
@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"live-im-proxy/breaker"
+	"live-im-proxy/event"
+	"live-im-proxy/limiter"
+)
+
+// newCozeServer 模拟 Coze 对话接口，延迟 delay 后返回 body
+func newCozeServer(t *testing.T, delay time.Duration, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newFallbackPipeline(t *testing.T, cozeAPI string) *Pipeline {
+	t.Helper()
+	keyed := limiter.NewKeyedLimiter(100, 100, time.Minute)
+	t.Cleanup(keyed.Close)
+	p := NewPipeline(cozeAPI, "token", "", "", keyed)
+	p.cozeBotID = "bot"
+	return p
+}
+
+func comment(channel, content string) *event.Event {
+	evt := event.NewEvent(event.TypeComment, channel, "room", "user", "张三")
+	evt.TenantID = "tenant"
+	evt.Content = content
+	return evt
+}
+
+func TestBudgetFor(t *testing.T) {
+	p := NewPipeline("", "", "", "", nil)
+	p.SetFallbackConfig(FallbackConfig{
+		Budget:   time.Second,
+		Channels: map[string]time.Duration{"kuaishou": 500 * time.Millisecond, "wechat": 0},
+	})
+	cases := map[string]time.Duration{
+		"kuaishou": 500 * time.Millisecond,
+		"wechat":   time.Second, // 0 不覆盖全局预算
+		"douyin":   time.Second,
+	}
+	for channel, want := range cases {
+		if got := p.budgetFor(channel); got != want {
+			t.Errorf("budgetFor(%s) = %v, 应为 %v", channel, got, want)
+		}
+	}
+}
+
+func TestGenerateReplyChannelBudget(t *testing.T) {
+	server := newCozeServer(t, 100*time.Millisecond, `{"content":"下单后24小时内发货"}`)
+	p := newFallbackPipeline(t, server.URL)
+	p.SetFallbackConfig(FallbackConfig{
+		Budget:   2 * time.Second,
+		Channels: map[string]time.Duration{"kuaishou": 20 * time.Millisecond},
+		Breaker:  breaker.DefaultConfig(),
+	})
+
+	if answer, tier := p.generateReply(comment("douyin", "什么时候发货")); tier != TierAI || answer != "下单后24小时内发货" {
+		t.Fatalf("预算内应使用AI回复，实际 %s: %s", tier, answer)
+	}
+
+	start := time.Now()
+	answer, tier := p.generateReply(comment("kuaishou", "什么时候发货"))
+	if tier != TierRules || answer != p.getReplyByKeyword("发货") {
+		t.Fatalf("超出渠道预算应降级到规则回复，实际 %s: %s", tier, answer)
+	}
+	if elapsed := time.Since(start); elapsed > 80*time.Millisecond {
+		t.Fatalf("应在渠道预算内返回，实际耗时 %v", elapsed)
+	}
+
+	if answer, tier := p.generateReply(comment("kuaishou", "今天天气不错")); tier != TierDefault || answer != defaultReply {
+		t.Fatalf("规则未命中时应使用兜底回复，实际 %s: %s", tier, answer)
+	}
+}
+
+func TestRequestCozeMissingContent(t *testing.T) {
+	server := newCozeServer(t, 0, `{"code":0,"messages":[]}`)
+	p := newFallbackPipeline(t, server.URL)
+	p.SetFallbackConfig(FallbackConfig{
+		Budget:  time.Second,
+		Breaker: breaker.Config{Failures: 1, Cooldown: time.Hour},
+	})
+
+	if _, err := p.requestCoze(context.Background(), []byte(`{}`)); err == nil {
+		t.Fatal("响应缺少回复内容时应返回错误")
+	}
+
+	answer, tier := p.generateReply(comment("douyin", "什么时候发货"))
+	if tier != TierRules || answer != p.getReplyByKeyword("发货") {
+		t.Fatalf("AI没有回复内容时应降级到规则回复，实际 %s: %s", tier, answer)
+	}
+	if state := p.BreakerState(); state != breaker.StateOpen {
+		t.Fatalf("缺少回复内容应计入熔断，实际 %s", state)
+	}
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"live-im-proxy/event"
+	"live-im-proxy/knowledge"
+)
+
+// SetKnowledge 设置本地知识库，常见问题直接用审核过的答案回复，其余作为AI的参考资料
+func (p *Pipeline) SetKnowledge(base *knowledge.Base) {
+	p.knowledge = base
+}
+
+// knowledgeAnswer 知识库中置信度足够直接回复的答案
+func (p *Pipeline) knowledgeAnswer(evt *event.Event) string {
+	if p.knowledge == nil {
+		return ""
+	}
+	result := p.knowledge.Lookup(evt.TenantID, evt.Content)
+	if result.Answer == nil {
+		return ""
+	}
+	fmt.Printf("📚 知识库命中: question=%s, confidence=%.2f\n", result.Answer.Entry.Question, result.Answer.Confidence)
+	return result.Answer.Entry.Answer
+}
+
+// aiQuery AI请求的问题，附上评论提到的商品信息和知识库参考资料，让回答以审核过的内容为准
+func (p *Pipeline) aiQuery(evt *event.Event) string {
+	var sections []string
+	if product, ok := p.matchProduct(evt); ok {
+		sections = append(sections, "【商品信息】\n"+product.Facts())
+	}
+	if p.knowledge != nil {
+		if result := p.knowledge.Lookup(evt.TenantID, evt.Content); len(result.Context) > 0 {
+			var b strings.Builder
+			b.WriteString("【参考资料】")
+			for _, hit := range result.Context {
+				fmt.Fprintf(&b, "\n问：%s 答：%s", hit.Entry.Question, hit.Entry.Answer)
+			}
+			sections = append(sections, b.String())
+		}
+	}
+	if len(sections) == 0 {
+		return evt.Content
+	}
+	return strings.Join(sections, "\n\n") + "\n\n【用户问题】\n" + evt.Content
+}
@@ -2,15 +2,33 @@ package pipeline
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"live-im-proxy/breaker"
+	"live-im-proxy/bus"
+	"live-im-proxy/catalog"
 	"live-im-proxy/event"
+	"live-im-proxy/gifts"
+	"live-im-proxy/handoff"
+	"live-im-proxy/history"
+	"live-im-proxy/intent"
+	"live-im-proxy/knowledge"
 	"live-im-proxy/limiter"
+	"live-im-proxy/policy"
+	"live-im-proxy/reply"
+	"live-im-proxy/replycache"
+	"live-im-proxy/safety"
+	"live-im-proxy/spam"
+	"live-im-proxy/welcome"
 )
 
 // ReplySender 回复发送器接口
@@ -20,20 +38,52 @@ type ReplySender interface {
 	SendPrivateMessage(conversationID, userID, content string) error
 }
 
+// roomSender 能向直播间发送公屏消息的回复发送器（各渠道的 SendMessage）
+type roomSender interface {
+	SendMessage(content string) error
+}
+
+// roomReplier 以回复评论的方式发送公屏消息的回复发送器，优先回复 userID 最近的评论
+type roomReplier interface {
+	SendRoomReply(userID, content string) error
+}
+
+// MetadataSessionKey 事件元数据中记录来源会话的键，回复经该会话的渠道发出
+const MetadataSessionKey = "session_key"
+
 // Pipeline 数据处理管道
 type Pipeline struct {
 	cozeAPI     string
 	cozeToken   string
 	nbAPI       string
 	nbToken     string
-	limiter     limiter.RateLimiter
+	limiter     limiter.KeyedRateLimiter // 按租户限制Coze调用
 	httpClient  *http.Client
 	replySender ReplySender // 回复发送器（可选）
+	senders     map[string]ReplySender // 按会话注册的回复发送器
+	sendersMu   sync.RWMutex
+	events      *bus.Bus    // 事件总线
+	handoff     *handoff.Manager // 人机切换（可选）
+	history     *history.Store   // 会话记录（可选），为AI提供上下文
+	safety      *safety.Filter   // 回复内容安全过滤（可选）
+	spam        *spam.Detector   // 入站反垃圾检测（可选）
+	policy      *policy.Policy   // 评论回复限流策略（可选）
+	dedup       *dedup           // 按幂等键丢弃重复投递的事件
+	gifts       *gifts.Tracker   // 礼物、点赞统计和感谢（可选）
+	greeter     *welcome.Greeter // 进场欢迎和关注感谢（可选）
+	catalog     *catalog.Store   // 商品目录（可选），为回复提供商品信息
+	knowledge   *knowledge.Base  // 本地知识库（可选），高置信度问题直接回答
+	cache       *replycache.Cache // 回复缓存（可选），相同问题复用AI回复
+	fallback    FallbackConfig    // 延迟预算和AI熔断配置
+	fallbackMu  sync.RWMutex
+	aiBreaker   *breaker.Breaker  // AI连续失败时熔断，直接降级到规则回复
+	tenantID    string      // 默认租户ID
+	cozeBotID   string      // Coze Bot ID
 }
 
 // NewPipeline 创建新的管道
-func NewPipeline(cozeAPI, cozeToken, nbAPI, nbToken string, limiter limiter.RateLimiter) *Pipeline {
-	return &Pipeline{
+func NewPipeline(cozeAPI, cozeToken, nbAPI, nbToken string, limiter limiter.KeyedRateLimiter) *Pipeline {
+	p := &Pipeline{
 		cozeAPI:    cozeAPI,
 		cozeToken:  cozeToken,
 		nbAPI:      nbAPI,
@@ -41,7 +91,20 @@ func NewPipeline(cozeAPI, cozeToken, nbAPI, nbToken string, limiter limiter.Rate
 		limiter:    limiter,
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		replySender: nil, // 可选，后续可以通过SetReplySender设置
+		senders:    make(map[string]ReplySender),
+		tenantID:   "tenant-1",
+		events:     bus.New(),
+		dedup:      newDedup(dedupTTL),
+		fallback:   DefaultFallbackConfig(),
+		aiBreaker:  breaker.New(breaker.DefaultConfig()),
 	}
+
+	// 推送到 NocoBase CRM（独立的可靠订阅，CRM 过慢时等待而不丢线索）
+	if nbAPI != "" && nbToken != "" {
+		p.events.SubscribeReliable("crm", 0, p.handleCRM, bus.TopicEventReceived)
+	}
+
+	return p
 }
 
 // SetReplySender 设置回复发送器
@@ -49,77 +112,326 @@ func (p *Pipeline) SetReplySender(sender ReplySender) {
 	p.replySender = sender
 }
 
+// RegisterReplySender 注册会话的回复发送器，带有该会话键的事件通过它回复
+func (p *Pipeline) RegisterReplySender(sessionKey string, sender ReplySender) {
+	p.sendersMu.Lock()
+	defer p.sendersMu.Unlock()
+	p.senders[sessionKey] = sender
+}
+
+// UnregisterReplySender 注销会话的回复发送器
+func (p *Pipeline) UnregisterReplySender(sessionKey string) {
+	p.sendersMu.Lock()
+	defer p.sendersMu.Unlock()
+	delete(p.senders, sessionKey)
+}
+
+// senderFor 事件对应的回复发送器：优先使用来源会话的发送器，否则使用全局发送器
+func (p *Pipeline) senderFor(evt *event.Event) ReplySender {
+	if key, ok := evt.Metadata[MetadataSessionKey].(string); ok && key != "" {
+		p.sendersMu.RLock()
+		sender, exists := p.senders[key]
+		p.sendersMu.RUnlock()
+		if exists {
+			return sender
+		}
+	}
+	return p.replySender
+}
+
+// SetTenantID 设置默认租户ID，未携带租户的事件归入该租户
+func (p *Pipeline) SetTenantID(tenantID string) {
+	p.tenantID = tenantID
+}
+
+// SetCozeBotID 设置Coze Bot ID
+func (p *Pipeline) SetCozeBotID(botID string) {
+	p.cozeBotID = botID
+}
+
+// SetHandoff 设置人机切换管理器，启用后私信会话可由人工接管
+func (p *Pipeline) SetHandoff(m *handoff.Manager) {
+	p.handoff = m
+}
+
+// SetHistory 设置会话记录，启用后AI回复会带上该用户最近的对话
+func (p *Pipeline) SetHistory(store *history.Store) {
+	p.history = store
+}
+
+// SetSafetyFilter 设置回复内容安全过滤器
+func (p *Pipeline) SetSafetyFilter(filter *safety.Filter) {
+	p.safety = filter
+}
+
+// SetSpamDetector 设置入站反垃圾检测器
+func (p *Pipeline) SetSpamDetector(detector *spam.Detector) {
+	p.spam = detector
+}
+
+// SetReplyPolicy 设置评论回复限流策略
+func (p *Pipeline) SetReplyPolicy(pol *policy.Policy) {
+	p.policy = pol
+}
+
+// SetGiftTracker 设置礼物和点赞处理器
+func (p *Pipeline) SetGiftTracker(tracker *gifts.Tracker) {
+	p.gifts = tracker
+}
+
+// SetGreeter 设置进场欢迎和关注感谢处理器
+func (p *Pipeline) SetGreeter(greeter *welcome.Greeter) {
+	p.greeter = greeter
+}
+
+// Bus 获取事件总线，供监控、统计、审计等订阅
+func (p *Pipeline) Bus() *bus.Bus {
+	return p.events
+}
+
 // ProcessEvent 处理事件
 func (p *Pipeline) ProcessEvent(evt *event.Event) error {
+	if evt.TenantID == "" {
+		evt.TenantID = p.tenantID
+	}
+
+	// 同一条平台消息重复投递（轮询重叠、重连补发、会话迁移）只处理一次
+	if evt.IdempotencyKey != "" && p.dedup.duplicate(evt.TenantID+"|"+evt.IdempotencyKey) {
+		fmt.Printf("🔁 重复事件，跳过: key=%s\n", evt.IdempotencyKey)
+		return nil
+	}
+
 	// 打印事件信息
 	fmt.Printf("📨 处理事件: type=%s, user=%s, content=%s\n", evt.Type, evt.Nickname, evt.Content)
+
+	// 反垃圾检测：丢弃的事件不进入管道，标记的事件只记录不回复
+	if p.spam != nil {
+		verdict := p.spam.Inspect(evt)
+		if verdict.Spam() {
+			fmt.Printf("🚫 垃圾消息: reason=%s, action=%s, user=%s\n", verdict.Reason, verdict.Action, evt.UserID)
+			if verdict.Action == spam.ActionDrop {
+				return nil
+			}
+			evt.SetMetadata(spam.MetadataKey, verdict.Reason)
+		}
+	}
+
+	// 识别评论和私信的意图，用于转人工和回复缓存
+	if evt.Content != "" && intent.Of(evt) == "" {
+		if name := intent.Classify(evt.Content); name != "" {
+			evt.SetMetadata(intent.MetadataKey, name)
+		}
+	}
+
+	p.events.Publish(&bus.Message{Topic: bus.TopicEventReceived, Event: evt})
+
+	// 礼物、点赞、进场和关注只统计和合并感谢，不走评论回复流程
+	if p.gifts != nil {
+		p.gifts.Observe(evt)
+	}
+	if p.greeter != nil {
+		p.greeter.Observe(evt)
+	}
 	
 	// 异步处理，避免阻塞
 	go func() {
-		// 只处理评论事件
-		if evt.Type != "comment" || evt.Content == "" {
+		// 只处理直播间评论、短视频评论和私信事件
+		if (evt.Type != event.TypeComment && evt.Type != event.TypeVideoComment && evt.Type != event.TypePrivateMessage) || evt.Content == "" {
 			return
 		}
 
-		// 1. 尝试获取AI回复
-		reply := ""
-		if p.cozeAPI != "" && p.cozeToken != "" {
-			var err error
-			reply, err = p.generateAIReply(evt)
-			if err != nil {
-				fmt.Printf("❌ AI 生成回复失败: %v\n", err)
-			}
-		}
-		
-		// 2. 如果没有AI回复，使用默认回复
-		if reply == "" {
-			reply = p.generateDefaultReply(evt.Content)
+		// 被标记为垃圾的消息不自动回复
+		if _, isSpam := evt.Metadata[spam.MetadataKey]; isSpam {
+			return
 		}
-		
-		// 3. 发送回复
-		if reply != "" {
-			fmt.Printf("✅ 生成回复: %s\n", reply)
-			p.sendReply(evt, reply)
+
+		// 私信会话已转人工时，机器人不再回复
+		if evt.Type == event.TypePrivateMessage && p.handoff != nil {
+			decision := p.handoff.HandleMessage(evt, p.calculateScore(evt))
+			if decision.Notice != "" {
+				p.deliverReply(evt, decision.Notice, reply.PurposeNotice)
+			}
+			if !decision.BotReply {
+				return
+			}
 		}
 
-		// 4. 推送到 NocoBase CRM
-		if p.nbAPI != "" && p.nbToken != "" {
-			if err := p.pushToNocoBase(evt); err != nil {
-				fmt.Printf("❌ 推送到 NocoBase 失败: %v\n", err)
+		// 公开评论按限流策略决定是否回复，避免刷屏
+		if evt.Type != event.TypePrivateMessage && p.policy != nil {
+			decision := p.policy.Evaluate(evt, p.calculateScore(evt))
+			if !decision.Allow {
+				fmt.Printf("⏸️ 跳过回复: reason=%s, user=%s\n", decision.Reason, evt.Nickname)
+				return
 			}
 		}
+
+		// 按降级链生成回复：缓存 → 知识库 → AI → 规则 → 兜底
+		answer, tier := p.generateReply(evt)
+		fmt.Printf("✅ 生成回复: tier=%s, %s\n", tier, answer)
+		if err := p.deliverAnswer(evt, answer, tier); err == nil && p.policy != nil {
+			p.policy.RecordAnswer(evt, answer)
+		}
 	}()
 
 	return nil
 }
 
-// generateAIReply 生成AI回复
-func (p *Pipeline) generateAIReply(evt *event.Event) (string, error) {
+// deliverReply 发送机器人回复并发布回复相关主题，purpose 区分同一事件的不同回复
+func (p *Pipeline) deliverReply(evt *event.Event, content, purpose string) error {
+	return p.publishAndSend(evt, p.botReply(evt, content, purpose))
+}
+
+// deliverAnswer 发送对事件的回答，记录产生回答的降级层级
+func (p *Pipeline) deliverAnswer(evt *event.Event, content, tier string) error {
+	r := p.botReply(evt, content, reply.PurposeAnswer)
+	r.Tier = tier
+	return p.publishAndSend(evt, r)
+}
+
+// botReply 创建机器人回复
+func (p *Pipeline) botReply(evt *event.Event, content, purpose string) *reply.Reply {
+	r := reply.NewReply(evt.Channel, evt.RoomID, evt.UserID, content)
+	r.Source = "bot"
+	r.IdempotencyKey = reply.IdempotencyKey(evt.IdempotencyKey, purpose)
+	return r
+}
+
+// ThankGifts 发送礼物、点赞感谢，作为 gifts.SendFunc 使用
+func (p *Pipeline) ThankGifts(evt *event.Event, content string) error {
+	return p.deliverReply(evt, content, reply.PurposeThanks)
+}
+
+// Welcome 发送进场欢迎和关注感谢，作为 welcome.SendFunc 使用
+func (p *Pipeline) Welcome(evt *event.Event, content string) error {
+	return p.deliverReply(evt, content, reply.PurposeWelcome)
+}
+
+// welcomeHistory 判断老朋友时查看的历史记录条数
+const welcomeHistory = 20
+
+// WelcomeProfile 按会话记录查询用户画像，作为 welcome.ProfileFunc 使用
+func (p *Pipeline) WelcomeProfile(tenantID, channel, userID string) welcome.Profile {
+	if p.history == nil {
+		return welcome.Profile{}
+	}
+	entries := p.history.Recent(tenantID, channel, userID, welcomeHistory)
+	profile := welcome.Profile{Returning: len(entries) > 0}
+	for _, entry := range entries {
+		if entry.Direction == history.DirectionInbound {
+			profile.Score += p.calculateScore(&event.Event{Type: entry.Type, Content: entry.Content})
+		}
+	}
+	return profile
+}
+
+// maxAggregateNames 合并回复中最多@的用户数
+const maxAggregateNames = 5
+
+// ReplyAggregated 合并回复相似问题，作为 policy.AggregateFunc 使用
+func (p *Pipeline) ReplyAggregated(evt *event.Event, names []string, total int, answer string) {
+	if len(names) > maxAggregateNames {
+		names = names[:maxAggregateNames]
+	}
+	mention := strings.Join(names, " @")
+	if total > len(names) {
+		mention = fmt.Sprintf("%s 等%d位", mention, total)
+	}
+	p.deliverReply(evt, fmt.Sprintf("@%s 同问的朋友看这里：%s", mention, answer), reply.PurposeAggregate)
+}
+
+// SendAgentReply 发送人工客服回复，走与机器人相同的发送链路
+func (p *Pipeline) SendAgentReply(evt *event.Event, content string) error {
+	r := reply.NewReply(evt.Channel, evt.RoomID, evt.UserID, content)
+	r.Source = "agent"
+	return p.publishAndSend(evt, r)
+}
+
+// publishAndSend 发布 reply.generated，经内容安全检查后发送，并发布 reply.sent 或 reply.failed
+func (p *Pipeline) publishAndSend(evt *event.Event, r *reply.Reply) error {
+	p.events.Publish(&bus.Message{Topic: bus.TopicReplyGenerated, Event: evt, Reply: r})
+
+	if p.safety != nil {
+		result := p.safety.Check(evt, r.Content)
+		if result.Action != safety.ActionPass {
+			p.events.Publish(&bus.Message{Topic: bus.TopicReplyFiltered, Event: evt, Reply: r, Data: result})
+		}
+		if result.Action == safety.ActionBlock {
+			err := fmt.Errorf("回复被内容安全拦截")
+			fmt.Printf("🛡️ %v: %s\n", err, r.Content)
+			p.events.Publish(&bus.Message{Topic: bus.TopicReplyFailed, Event: evt, Reply: r, Error: err.Error()})
+			return err
+		}
+		if result.Content != r.Content {
+			filtered := *r
+			filtered.Content = result.Content
+			r = &filtered
+		}
+	}
+
+	if err := p.sendReply(evt, r.Content); err != nil {
+		// 渠道不支持的公屏消息直接跳过，不计为发送失败
+		if !errors.Is(err, reply.ErrRoomMessageUnsupported) {
+			p.events.Publish(&bus.Message{Topic: bus.TopicReplyFailed, Event: evt, Reply: r, Error: err.Error()})
+		}
+		return err
+	}
+	p.events.Publish(&bus.Message{Topic: bus.TopicReplySent, Event: evt, Reply: r})
+	return nil
+}
+
+// handleCRM CRM订阅者：评论事件推送到 NocoBase 后发布 lead.pushed
+func (p *Pipeline) handleCRM(msg *bus.Message) {
+	evt := msg.Event
+	if evt == nil || evt.Type != event.TypeComment || evt.Content == "" {
+		return
+	}
+	if _, isSpam := evt.Metadata[spam.MetadataKey]; isSpam {
+		return
+	}
+
+	if err := p.pushToNocoBase(evt); err != nil {
+		fmt.Printf("❌ 推送到 NocoBase 失败: %v\n", err)
+		return
+	}
+	p.events.Publish(&bus.Message{Topic: bus.TopicLeadPushed, Event: evt})
+}
+
+// generateAIReply 生成AI回复，请求随 ctx 取消；限流和配置错误不计入熔断
+func (p *Pipeline) generateAIReply(ctx context.Context, evt *event.Event) (string, error) {
 	// 检查限流
-	if !p.limiter.Allow() {
+	if !p.limiter.Allow(cozeLimitKey(evt)) {
 		return "", fmt.Errorf("Coze API 限流")
 	}
 
-	// 从环境变量获取 Bot ID
-	botID := os.Getenv("COZE_BOT_ID")
+	botID := p.cozeBotID
 	if botID == "" {
-		return "", fmt.Errorf("COZE_BOT_ID 未配置，请在环境变量中设置")
+		return "", fmt.Errorf("Coze Bot ID 未配置，请设置 providers.coze.bot_id 或环境变量 COZE_BOT_ID")
 	}
 
-	// 构建请求
+	// 构建请求，附上商品信息和知识库参考资料
 	reqBody := map[string]interface{}{
 		"bot_id": botID,
 		"user":   evt.UserID,
-		"query":  evt.Content,
+		"query":  p.aiQuery(evt),
 		"stream": false,
 	}
+	if chatHistory := p.buildChatHistory(evt); len(chatHistory) > 0 {
+		reqBody["chat_history"] = chatHistory
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return "", err
 	}
 
-	req, err := http.NewRequest("POST", p.cozeAPI+"/bot/chat", bytes.NewBuffer(jsonData))
+	return p.callAI(func() (string, error) {
+		return p.requestCoze(ctx, jsonData)
+	})
+}
+
+// requestCoze 调用 Coze 对话接口
+func (p *Pipeline) requestCoze(ctx context.Context, jsonData []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.cozeAPI+"/bot/chat", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", err
 	}
@@ -145,93 +457,133 @@ func (p *Pipeline) generateAIReply(evt *event.Event) (string, error) {
 		return "", err
 	}
 
-	// 提取回复内容（根据实际Coze API响应格式调整）
-	reply := "感谢您的咨询，我会尽快为您处理"
-	if content, ok := cozeResp["content"].(string); ok {
-		reply = content
+	// 提取回复内容（根据实际Coze API响应格式调整），缺少内容时按失败处理，降级到规则回复
+	content, _ := cozeResp["content"].(string)
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("Coze API 响应缺少回复内容")
+	}
+	return content, nil
+}
+
+// historyTurns AI上下文携带的最近对话条数
+const historyTurns = 10
+
+// buildChatHistory 从会话记录构建Coze chat_history（不含当前消息）
+func (p *Pipeline) buildChatHistory(evt *event.Event) []map[string]string {
+	if p.history == nil {
+		return nil
 	}
 
-	return reply, nil
+	var chatHistory []map[string]string
+	for _, entry := range p.history.Recent(evt.TenantID, evt.Channel, evt.UserID, historyTurns+1) {
+		if entry.EventID == evt.ID && entry.Direction == history.DirectionInbound {
+			continue
+		}
+		role := "user"
+		if entry.Direction == history.DirectionOutbound {
+			role = "assistant"
+		}
+		chatHistory = append(chatHistory, map[string]string{
+			"role":         role,
+			"content":      entry.Content,
+			"content_type": "text",
+		})
+	}
+	if len(chatHistory) > historyTurns {
+		chatHistory = chatHistory[len(chatHistory)-historyTurns:]
+	}
+	return chatHistory
 }
 
-// sendReply 发送回复
-func (p *Pipeline) sendReply(evt *event.Event, reply string) {
-	fmt.Printf("📤 准备发送回复到 %s: %s\n", evt.Channel, reply)
-	
-	// 根据事件类型和渠道发送回复
-	if evt.Channel == "douyin" {
-		// 抖音渠道：根据事件类型选择发送方式
-		if evt.Type == "video_comment" {
-			// 短视频评论回复
-			commentID, ok := evt.Metadata["comment_id"].(string)
-			if !ok || commentID == "" {
-				fmt.Printf("❌ 无法获取评论ID，跳过回复\n")
-				return
-			}
-			
-			videoID := evt.VideoID
-			if videoID == "" {
-				videoID = evt.RoomID // 兼容处理
-			}
-			
-			// 如果有回复发送器，使用它发送回复
-			if p.replySender != nil {
-				if err := p.replySender.SendVideoCommentReply(videoID, commentID, reply); err != nil {
-					fmt.Printf("❌ 发送视频评论回复失败: %v\n", err)
-					return
-				}
-				fmt.Printf("✅ 已发送回复: 用户=%s, 内容=%s\n", evt.Nickname, reply)
-			} else {
-				fmt.Printf("⚠️ 回复发送器未设置，仅记录日志: 评论ID=%s, 内容=%s\n", commentID, reply)
-			}
-		} else if evt.Type == "comment" {
-			// 直播间评论回复
-			commentID, ok := evt.Metadata["comment_id"].(string)
-			if !ok || commentID == "" {
-				fmt.Printf("❌ 无法获取评论ID，跳过回复\n")
-				return
-			}
-			
-			// 如果有回复发送器，使用它发送回复
-			if p.replySender != nil {
-				if err := p.replySender.SendLiveCommentReply(evt.RoomID, commentID, reply); err != nil {
-					fmt.Printf("❌ 发送直播间评论回复失败: %v\n", err)
-					return
-				}
-				fmt.Printf("✅ 已发送回复: 用户=%s, 内容=%s\n", evt.Nickname, reply)
-			} else {
-				fmt.Printf("⚠️ 回复发送器未设置，仅记录日志: 评论ID=%s, 内容=%s\n", commentID, reply)
-			}
-		} else if evt.Type == "private_message" {
-			// 私信回复
-			conversationID, ok1 := evt.Metadata["conversation_id"].(string)
-			userID := evt.UserID
-			if !ok1 || conversationID == "" {
-				fmt.Printf("❌ 无法获取会话ID，跳过回复\n")
-				return
-			}
-			
-			// 如果有回复发送器，使用它发送回复
-			if p.replySender != nil {
-				if err := p.replySender.SendPrivateMessage(conversationID, userID, reply); err != nil {
-					fmt.Printf("❌ 发送私信回复失败: %v\n", err)
-					return
-				}
-				fmt.Printf("✅ 已发送私信回复: 用户=%s, 内容=%s\n", evt.Nickname, reply)
-			} else {
-				fmt.Printf("⚠️ 回复发送器未设置，仅记录日志: 会话ID=%s, 内容=%s\n", conversationID, reply)
-			}
+// errNoSender 事件所属会话没有可用的回复发送器
+var errNoSender = errors.New("回复发送器未设置")
+
+// sendReply 发送回复，没有可用的发送渠道时返回错误，不把未发出的回复记为已发送
+func (p *Pipeline) sendReply(evt *event.Event, reply string) error {
+	log.Printf("📤 准备发送回复到 %s: %s", evt.Channel, reply)
+	sender := p.senderFor(evt)
+
+	// 礼物、点赞、进场、关注没有可回复的消息，发到直播间公屏
+	switch evt.Type {
+	case event.TypeGift, event.TypeLike, event.TypeEnter, event.TypeFollow:
+		return p.sendRoomMessage(sender, evt, reply)
+	}
+
+	// 目前只有抖音渠道接入了开放平台的回复接口
+	if evt.Channel != "douyin" {
+		return fmt.Errorf("渠道 %s 暂不支持发送回复", evt.Channel)
+	}
+	if sender == nil {
+		log.Printf("⚠️ %v，回复未发送: 用户=%s, 内容=%s", errNoSender, evt.Nickname, reply)
+		return errNoSender
+	}
+
+	var err error
+	switch evt.Type {
+	case event.TypeVideoComment:
+		// 短视频评论回复
+		commentID := evt.CommentID()
+		if commentID == "" {
+			return fmt.Errorf("无法获取评论ID")
+		}
+		videoID := evt.VideoID
+		if videoID == "" {
+			videoID = evt.RoomID // 兼容处理
 		}
+		// 楼中楼追问的回复挂在一级评论下，@提问的用户
+		if evt.IsNestedReply() && evt.Nickname != "" {
+			reply = "@" + evt.Nickname + " " + reply
+		}
+		err = sender.SendVideoCommentReply(videoID, commentID, reply)
+	case event.TypeComment:
+		// 直播间评论回复
+		commentID := evt.CommentID()
+		if commentID == "" {
+			return fmt.Errorf("无法获取评论ID")
+		}
+		err = sender.SendLiveCommentReply(evt.RoomID, commentID, reply)
+	case event.TypePrivateMessage:
+		// 私信回复
+		conversationID := evt.ConversationID()
+		if conversationID == "" {
+			return fmt.Errorf("无法获取会话ID")
+		}
+		err = sender.SendPrivateMessage(conversationID, evt.UserID, reply)
+	default:
+		return fmt.Errorf("事件类型 %s 不支持回复", evt.Type)
+	}
+	if err != nil {
+		log.Printf("❌ 发送回复失败: type=%s, 用户=%s, %v", evt.Type, evt.Nickname, err)
+		return err
+	}
+	log.Printf("✅ 已发送回复: type=%s, 用户=%s, 内容=%s", evt.Type, evt.Nickname, reply)
+	return nil
+}
+
+// sendRoomMessage 发送直播间公屏消息，支持回复评论的渠道回复事件用户最近的评论
+func (p *Pipeline) sendRoomMessage(sender ReplySender, evt *event.Event, content string) error {
+	var err error
+	if replier, ok := sender.(roomReplier); ok {
+		err = replier.SendRoomReply(evt.UserID, content)
+	} else if room, ok := sender.(roomSender); ok {
+		err = room.SendMessage(content)
 	} else {
-		// 其他渠道的回复逻辑
-		fmt.Printf("✅ 已发送回复: 用户=%s, 内容=%s\n", evt.Nickname, reply)
+		return fmt.Errorf("渠道 %s: %w", evt.Channel, reply.ErrRoomMessageUnsupported)
 	}
+	if err != nil {
+		if !errors.Is(err, reply.ErrRoomMessageUnsupported) {
+			log.Printf("❌ 发送直播间消息失败: %v", err)
+		}
+		return err
+	}
+	log.Printf("✅ 已发送直播间消息: 直播间=%s, 内容=%s", evt.RoomID, content)
+	return nil
 }
 
 // pushToCoze 推送到 Coze AI
 func (p *Pipeline) pushToCoze(evt *event.Event) error {
 	// 检查限流
-	if !p.limiter.Allow() {
+	if !p.limiter.Allow(cozeLimitKey(evt)) {
 		return fmt.Errorf("Coze API 限流")
 	}
 
@@ -282,7 +634,9 @@ func (p *Pipeline) pushToCoze(evt *event.Event) error {
 func (p *Pipeline) pushToNocoBase(evt *event.Event) error {
 	// 构建线索数据
 	leadData := map[string]interface{}{
-		"tenant_id": "tenant-1", // 从环境变量获取
+		"event_id":        evt.ID,
+		"idempotency_key": evt.DedupKey(),
+		"tenant_id": evt.TenantID,
 		"uid":       evt.UserID,
 		"nick":      evt.Nickname,
 		"channel":   evt.Channel,
@@ -302,6 +656,8 @@ func (p *Pipeline) pushToNocoBase(evt *event.Event) error {
 
 	req.Header.Set("Authorization", "Bearer "+p.nbToken)
 	req.Header.Set("Content-Type", "application/json")
+	// 同一条平台消息重复推送时幂等键不变，CRM 据此去重
+	req.Header.Set("Idempotency-Key", evt.DedupKey())
 
 	// 发送请求
 	resp, err := p.httpClient.Do(req)
@@ -333,6 +689,11 @@ func (p *Pipeline) calculateScore(evt *event.Event) int {
 		if containsKeywords(evt.Content, []string{"价格", "购买", "咨询", "多少钱"}) {
 			score += 3
 		}
+	case "private_message":
+		score = 4
+		if containsKeywords(evt.Content, []string{"价格", "购买", "咨询", "多少钱"}) {
+			score += 3
+		}
 	case "follow":
 		score = 5
 	case "gift":
@@ -342,11 +703,21 @@ func (p *Pipeline) calculateScore(evt *event.Event) int {
 	return score
 }
 
-// generateDefaultReply 生成默认回复
-func (p *Pipeline) generateDefaultReply(content string) string {
+// ruleReply 规则回复，评论提到商品时优先用商品FAQ和商品信息回答，其次按关键词回复；
+// 没有命中任何规则时返回 false
+func (p *Pipeline) ruleReply(evt *event.Event) (string, bool) {
+	content := evt.Content
+	product, hasProduct := p.matchProduct(evt)
+	if hasProduct {
+		if answer, ok := product.Answer(content); ok {
+			return answer, true
+		}
+	}
+
 	// 简单的关键词匹配回复
 	keywords := []string{
 		"价格", "多少钱", "优惠", "折扣", "便宜",
+		"库存", "有货", "现货",
 		"购买", "买", "下单", "订购",
 		"好用", "质量", "好", "坏",
 		"发货", "快递", "几天", "到货",
@@ -358,13 +729,18 @@ func (p *Pipeline) generateDefaultReply(content string) string {
 		if len(content) >= len(keyword) {
 			for i := 0; i <= len(content)-len(keyword); i++ {
 				if content[i:i+len(keyword)] == keyword {
-					return p.getReplyByKeyword(keyword)
+					if hasProduct {
+						if answer, ok := productReply(keyword, product); ok {
+							return answer, true
+						}
+					}
+					return p.getReplyByKeyword(keyword), true
 				}
 			}
 		}
 	}
 	
-	return "感谢您的关注，欢迎咨询！"
+	return "", false
 }
 
 // getReplyByKeyword 根据关键词返回回复
@@ -406,3 +782,9 @@ func containsKeywords(content string, keywords []string) bool {
 	}
 	return false
 }
+
+// cozeLimitKey Coze调用的限流键，按租户隔离配额
+func cozeLimitKey(evt *event.Event) string {
+	return evt.TenantID + ":coze"
+}
+
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"testing"
+
+	"live-im-proxy/event"
+	"live-im-proxy/intent"
+)
+
+// roomReplySender 记录公屏消息的回复发送器
+type roomReplySender struct {
+	users    []string
+	contents []string
+}
+
+func (s *roomReplySender) SendVideoCommentReply(videoID, commentID, content string) error {
+	return nil
+}
+func (s *roomReplySender) SendLiveCommentReply(roomID, commentID, content string) error {
+	return nil
+}
+func (s *roomReplySender) SendPrivateMessage(conversationID, userID, content string) error {
+	return nil
+}
+func (s *roomReplySender) SendRoomReply(userID, content string) error {
+	s.users = append(s.users, userID)
+	s.contents = append(s.contents, content)
+	return nil
+}
+
+func TestThankGiftsRepliesToGifter(t *testing.T) {
+	p := NewPipeline("", "", "", "", nil)
+	sender := &roomReplySender{}
+	p.RegisterReplySender("session", sender)
+
+	evt := event.NewEvent(event.TypeGift, "douyin", "room", "gifter", "张三")
+	evt.SetMetadata(MetadataSessionKey, "session")
+	if err := p.ThankGifts(evt, "感谢 张三 送出的 玫瑰"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.users) != 1 || sender.users[0] != "gifter" || sender.contents[0] != "感谢 张三 送出的 玫瑰" {
+		t.Fatalf("users = %v, contents = %v, 应回复送礼用户", sender.users, sender.contents)
+	}
+}
+
+func TestWelcomeSendsRoomMessage(t *testing.T) {
+	p := NewPipeline("", "", "", "", nil)
+	sender := &roomReplySender{}
+	p.RegisterReplySender("session", sender)
+
+	evt := event.NewEvent(event.TypeEnter, "douyin", "room", "guest", "李四")
+	evt.SetMetadata(MetadataSessionKey, "session")
+	if err := p.Welcome(evt, "欢迎 李四 来到直播间～"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.contents) != 1 || sender.users[0] != "guest" {
+		t.Fatalf("users = %v, contents = %v, 欢迎消息应发到直播间", sender.users, sender.contents)
+	}
+}
+
+func TestProcessEventTagsIntent(t *testing.T) {
+	p := NewPipeline("", "", "", "", nil)
+	evt := event.NewEvent(event.TypePrivateMessage, "douyin", "", "user", "张三")
+	evt.SetContent("收到的杯子坏了，能退款吗")
+	if err := p.ProcessEvent(evt); err != nil {
+		t.Fatal(err)
+	}
+	if got := intent.Of(evt); got != intent.AfterSales {
+		t.Fatalf("intent = %q, 应识别为售后", got)
+	}
+}
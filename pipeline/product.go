@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"live-im-proxy/catalog"
+	"live-im-proxy/event"
+)
+
+// SetCatalog 设置商品目录，回复时识别评论提到的商品并带上价格、库存、优惠等信息
+func (p *Pipeline) SetCatalog(store *catalog.Store) {
+	p.catalog = store
+}
+
+// matchProduct 评论提到的商品
+func (p *Pipeline) matchProduct(evt *event.Event) (catalog.Product, bool) {
+	if p.catalog == nil {
+		return catalog.Product{}, false
+	}
+	return p.catalog.Match(evt.TenantID, evt.Content)
+}
+
+// 带商品信息的关键词回复模板，占位符见 catalog.Product.Render
+const (
+	priceTemplate     = "{name} 现在只要 {price}，点击左下角小黄车即可下单～"
+	discountTemplate  = "{name} 现在只要 {price}，原价 {original_price}，点击左下角小黄车即可下单～"
+	promotionTemplate = "{name} 当前优惠：{promotions}，下单即可享受～"
+	stockTemplate     = "{name} {stock}，点击左下角小黄车即可下单～"
+	soldOutTemplate   = "{name} 暂时售罄了，补货后会第一时间通知大家～"
+)
+
+// productReply 按关键词生成带商品信息的回复，商品缺少相应信息时返回 false
+func productReply(keyword string, product catalog.Product) (string, bool) {
+	var template string
+	switch keyword {
+	case "价格", "多少钱", "便宜", "折扣":
+		switch {
+		case product.Price > 0 && product.OriginalPrice > product.Price:
+			template = discountTemplate
+		case product.Price > 0:
+			template = priceTemplate
+		}
+	case "优惠":
+		switch {
+		case len(product.Promotions) > 0:
+			template = promotionTemplate
+		case product.Price > 0:
+			template = priceTemplate
+		}
+	case "购买", "买", "下单", "订购", "库存", "有货", "现货":
+		switch {
+		case product.Stock != nil && *product.Stock == 0:
+			template = soldOutTemplate
+		case product.Stock != nil:
+			template = stockTemplate
+		case product.Price > 0:
+			template = priceTemplate
+		}
+	}
+	if template == "" {
+		return "", false
+	}
+	return product.Render(template), true
+}
@@ -0,0 +1,394 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+// 拒绝原因
+const (
+	ReasonCooldown   = "user_cooldown" // 用户冷却中
+	ReasonRoomLimit  = "room_limit"    // 直播间回复数达到上限
+	ReasonAggregated = "aggregated"    // 相似问题已合并回复
+	ReasonQuietHours = "quiet_hours"   // 静默时段
+	ReasonSampled    = "sampled_out"   // 低意向评论未被抽中
+)
+
+// QuietHours 静默时段，格式 "23:00"，支持跨零点
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Rules 回复限流规则，时间单位为秒，0表示不启用
+type Rules struct {
+	UserCooldownSec     int          `json:"user_cooldown_sec"`      // 同一用户两次回复的最小间隔
+	RoomMaxPerMinute    int          `json:"room_max_per_minute"`    // 每个直播间/视频每分钟最多回复数
+	SimilarFirstN       int          `json:"similar_first_n"`        // 相似问题只单独回答前N次
+	SimilarWindowSec    int          `json:"similar_window_sec"`     // 相似问题统计窗口，超出后合并回复
+	QuietHours          []QuietHours `json:"quiet_hours"`            // 静默时段，不自动回复评论
+	LowIntentScore      int          `json:"low_intent_score"`       // 线索评分低于该值视为低意向
+	LowIntentSampleRate float64      `json:"low_intent_sample_rate"` // 低意向评论的回复概率 0-1
+}
+
+// DefaultRules 默认规则
+func DefaultRules() Rules {
+	return Rules{
+		UserCooldownSec:     30,
+		RoomMaxPerMinute:    20,
+		SimilarFirstN:       3,
+		SimilarWindowSec:    60,
+		LowIntentScore:      4,
+		LowIntentSampleRate: 0.5,
+	}
+}
+
+// Config 回复策略配置
+// Overrides 的键按优先级依次匹配 "tenant/channel"、"tenant/*"、"*/channel"
+type Config struct {
+	Default   Rules            `json:"default"`
+	Overrides map[string]Rules `json:"overrides"`
+	Timezone  string           `json:"timezone"` // 静默时段使用的时区，默认 Asia/Shanghai
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		Default:   DefaultRules(),
+		Overrides: map[string]Rules{},
+		Timezone:  "Asia/Shanghai",
+	}
+}
+
+// LoadConfig 从JSON文件加载配置，path为空时返回默认配置
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("读取回复策略配置失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("解析回复策略配置失败: %v", err)
+	}
+	return config, config.Validate()
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	rules := map[string]Rules{"default": c.Default}
+	for key, r := range c.Overrides {
+		if !strings.Contains(key, "/") {
+			return fmt.Errorf("回复策略覆盖键格式应为 tenant/channel: %q", key)
+		}
+		rules[key] = r
+	}
+	for name, r := range rules {
+		if r.LowIntentSampleRate < 0 || r.LowIntentSampleRate > 1 {
+			return fmt.Errorf("回复策略 %s 的 low_intent_sample_rate 应在0-1之间", name)
+		}
+		for _, q := range r.QuietHours {
+			if _, err := parseClock(q.Start); err != nil {
+				return fmt.Errorf("回复策略 %s 的静默时段无效: %v", name, err)
+			}
+			if _, err := parseClock(q.End); err != nil {
+				return fmt.Errorf("回复策略 %s 的静默时段无效: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Decision 策略判定结果
+type Decision struct {
+	Allow  bool
+	Reason string
+}
+
+// AggregateFunc 合并回复回调：对最后一条相似提问回复，names为最近被合并的用户昵称，total为被合并的总人数
+type AggregateFunc func(evt *event.Event, names []string, total int, answer string)
+
+// maxPending 每组相似问题等待合并回复的最多评论数，超出后只计数
+const maxPending = 20
+
+// similarGroup 相似问题分组
+type similarGroup struct {
+	count    int
+	first    time.Time
+	answer   string
+	pending  []*event.Event // 最近的待合并评论
+	merged   int            // 待合并的总人数，包括超出maxPending未保留的
+	lastSeen time.Time
+}
+
+// Policy 回复限流策略
+type Policy struct {
+	mu        sync.Mutex
+	config    Config
+	location  *time.Location
+	users     map[string]time.Time   // 用户最后一次被回复的时间
+	rooms     map[string][]time.Time // 直播间近一分钟的回复时间
+	similar   map[string]*similarGroup
+	aggregate AggregateFunc
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewPolicy 创建回复策略，aggregate为空时超出的相似问题直接丢弃
+func NewPolicy(config Config, aggregate AggregateFunc) *Policy {
+	p := &Policy{
+		users:     make(map[string]time.Time),
+		rooms:     make(map[string][]time.Time),
+		similar:   make(map[string]*similarGroup),
+		aggregate: aggregate,
+		done:      make(chan struct{}),
+	}
+	p.SetConfig(config)
+	go p.flushLoop()
+	return p
+}
+
+// SetConfig 更新配置
+func (p *Policy) SetConfig(config Config) {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil || config.Timezone == "" {
+		location = time.FixedZone("CST", 8*3600)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+	p.location = location
+}
+
+// Close 停止合并回复
+func (p *Policy) Close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+// Evaluate 判断是否允许自动回复该评论，允许时占用冷却和直播间配额
+// score为事件的线索评分，用于低意向抽样
+func (p *Policy) Evaluate(evt *event.Event, score int) Decision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rules := p.rulesFor(evt.TenantID, evt.Channel)
+	now := time.Now()
+
+	if p.inQuietHours(rules, now) {
+		return Decision{Reason: ReasonQuietHours}
+	}
+
+	userKey := evt.TenantID + "/" + evt.Channel + "/" + evt.UserID
+	if rules.UserCooldownSec > 0 {
+		if last, ok := p.users[userKey]; ok && now.Sub(last) < time.Duration(rules.UserCooldownSec)*time.Second {
+			return Decision{Reason: ReasonCooldown}
+		}
+	}
+
+	roomKey := roomKeyOf(evt)
+	if p.roomFull(rules, roomKey, now) {
+		return Decision{Reason: ReasonRoomLimit}
+	}
+
+	if rules.SimilarFirstN > 0 && rules.SimilarWindowSec > 0 {
+		key := roomKey + "/" + Normalize(evt.Content)
+		group, ok := p.similar[key]
+		if !ok || now.Sub(group.first) > time.Duration(rules.SimilarWindowSec)*time.Second {
+			group = &similarGroup{first: now}
+			p.similar[key] = group
+		}
+		group.count++
+		group.lastSeen = now
+		if group.count > rules.SimilarFirstN {
+			if len(group.pending) >= maxPending {
+				group.pending = append(group.pending[:0], group.pending[1:]...)
+			}
+			group.pending = append(group.pending, evt)
+			group.merged++
+			return Decision{Reason: ReasonAggregated}
+		}
+	}
+
+	if rules.LowIntentSampleRate < 1 && score < rules.LowIntentScore && rand.Float64() >= rules.LowIntentSampleRate {
+		return Decision{Reason: ReasonSampled}
+	}
+
+	p.users[userKey] = now
+	p.rooms[roomKey] = append(p.rooms[roomKey], now)
+	return Decision{Allow: true}
+}
+
+// RecordAnswer 记录相似问题的回复内容，用于之后的合并回复
+func (p *Policy) RecordAnswer(evt *event.Event, answer string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := roomKeyOf(evt) + "/" + Normalize(evt.Content)
+	if group, ok := p.similar[key]; ok {
+		group.answer = answer
+	}
+}
+
+// flushLoop 定期发送合并回复并清理过期状态
+func (p *Policy) flushLoop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.flush(now)
+		}
+	}
+}
+
+// flush 发送合并回复并清理过期状态；合并回复同样占用直播间配额，直播间达到上限时留到下次发送
+func (p *Policy) flush(now time.Time) {
+	type batch struct {
+		evt    *event.Event
+		names  []string
+		total  int
+		answer string
+	}
+	var batches []batch
+
+	p.mu.Lock()
+	for key, group := range p.similar {
+		if len(group.pending) > 0 && group.answer != "" && p.aggregate != nil {
+			evt := group.pending[len(group.pending)-1]
+			roomKey := roomKeyOf(evt)
+			if !p.roomFull(p.rulesFor(evt.TenantID, evt.Channel), roomKey, now) {
+				names := make([]string, 0, len(group.pending))
+				for _, pending := range group.pending {
+					names = append(names, pending.Nickname)
+				}
+				batches = append(batches, batch{evt: evt, names: names, total: group.merged, answer: group.answer})
+				p.rooms[roomKey] = append(p.rooms[roomKey], now)
+				group.pending = nil
+				group.merged = 0
+			}
+		}
+		if now.Sub(group.lastSeen) > 10*time.Minute {
+			delete(p.similar, key)
+		}
+	}
+	for key, last := range p.users {
+		if now.Sub(last) > time.Hour {
+			delete(p.users, key)
+		}
+	}
+	for key := range p.rooms {
+		if len(p.recentReplies(key, now)) == 0 {
+			delete(p.rooms, key)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, b := range batches {
+		log.Printf("🧮 合并回复相似问题: %d 位用户, 内容=%s", b.total, b.answer)
+		p.aggregate(b.evt, b.names, b.total, b.answer)
+	}
+}
+
+// roomFull 直播间近一分钟的回复数是否达到上限，调用方持有锁
+func (p *Policy) roomFull(rules Rules, roomKey string, now time.Time) bool {
+	recent := p.recentReplies(roomKey, now)
+	return rules.RoomMaxPerMinute > 0 && len(recent) >= rules.RoomMaxPerMinute
+}
+
+// recentReplies 清理并返回直播间近一分钟的回复时间，调用方持有锁
+func (p *Policy) recentReplies(roomKey string, now time.Time) []time.Time {
+	recent := p.rooms[roomKey][:0]
+	for _, t := range p.rooms[roomKey] {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	p.rooms[roomKey] = recent
+	return recent
+}
+
+// rulesFor 按租户和渠道获取规则
+func (p *Policy) rulesFor(tenantID, channel string) Rules {
+	for _, key := range []string{tenantID + "/" + channel, tenantID + "/*", "*/" + channel} {
+		if rules, ok := p.config.Overrides[key]; ok {
+			return rules
+		}
+	}
+	return p.config.Default
+}
+
+// inQuietHours 是否处于静默时段
+func (p *Policy) inQuietHours(rules Rules, now time.Time) bool {
+	local := now.In(p.location)
+	minutes := local.Hour()*60 + local.Minute()
+	for _, q := range rules.QuietHours {
+		start, err1 := parseClock(q.Start)
+		end, err2 := parseClock(q.End)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if start <= end {
+			if minutes >= start && minutes < end {
+				return true
+			}
+		} else if minutes >= start || minutes < end {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock 解析 "HH:MM" 为当天分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// roomKeyOf 直播间配额和相似问题的统计键
+func roomKeyOf(evt *event.Event) string {
+	return evt.TenantID + "/" + evt.Channel + "/" + roomOf(evt)
+}
+
+// roomOf 直播间评论按房间统计，短视频评论按视频统计
+func roomOf(evt *event.Event) string {
+	if evt.RoomID != "" {
+		return evt.RoomID
+	}
+	return evt.VideoID
+}
+
+// Normalize 归一化问题文本，用于相似问题判定：
+// 去掉空白、标点、语气词并统一大小写
+func Normalize(content string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(content) {
+		if strings.ContainsRune(" \t\n,.!?;:，。！？；：~～、…\"'“”‘’()（）", r) {
+			continue
+		}
+		if strings.ContainsRune("吗呢啊呀吧哈嘛哦", r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
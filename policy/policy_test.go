@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+// aggregation 合并回复回调的一次调用
+type aggregation struct {
+	evt    *event.Event
+	names  []string
+	total  int
+	answer string
+}
+
+// newTestPolicy 创建不启动定时合并的策略，由测试直接调用 flush
+func newTestPolicy(rules Rules) (*Policy, *[]aggregation) {
+	var calls []aggregation
+	config := DefaultConfig()
+	config.Default = rules
+	p := NewPolicy(config, func(evt *event.Event, names []string, total int, answer string) {
+		calls = append(calls, aggregation{evt: evt, names: names, total: total, answer: answer})
+	})
+	p.Close()
+	return p, &calls
+}
+
+func comment(userID, content string) *event.Event {
+	evt := event.NewEvent(event.TypeComment, "douyin", "room", userID, "用户"+userID)
+	evt.TenantID = "tenant"
+	evt.Content = content
+	return evt
+}
+
+func TestUserCooldownAndRoomLimit(t *testing.T) {
+	p, _ := newTestPolicy(Rules{UserCooldownSec: 30, RoomMaxPerMinute: 2, LowIntentSampleRate: 1})
+
+	if d := p.Evaluate(comment("u1", "多少钱"), 10); !d.Allow {
+		t.Fatalf("第一条评论应允许回复: %+v", d)
+	}
+	if d := p.Evaluate(comment("u1", "有红色吗"), 10); d.Reason != ReasonCooldown {
+		t.Fatalf("冷却中的用户应被拒绝: %+v", d)
+	}
+	if d := p.Evaluate(comment("u2", "发什么快递"), 10); !d.Allow {
+		t.Fatalf("其他用户应允许回复: %+v", d)
+	}
+	if d := p.Evaluate(comment("u3", "能便宜点吗"), 10); d.Reason != ReasonRoomLimit {
+		t.Fatalf("直播间达到上限后应拒绝: %+v", d)
+	}
+}
+
+func TestPendingIsBounded(t *testing.T) {
+	p, calls := newTestPolicy(Rules{SimilarFirstN: 1, SimilarWindowSec: 60, LowIntentSampleRate: 1})
+
+	first := comment("u0", "多少钱？")
+	if d := p.Evaluate(first, 10); !d.Allow {
+		t.Fatalf("第一次提问应单独回答: %+v", d)
+	}
+	for i := 1; i <= 50; i++ {
+		if d := p.Evaluate(comment(fmt.Sprintf("u%d", i), "多少钱"), 10); d.Reason != ReasonAggregated {
+			t.Fatalf("相似问题应合并: %+v", d)
+		}
+	}
+
+	group := p.similar[roomKeyOf(first)+"/"+Normalize(first.Content)]
+	if len(group.pending) != maxPending || group.merged != 50 {
+		t.Fatalf("待合并评论应限制在 %d 条: pending=%d, merged=%d", maxPending, len(group.pending), group.merged)
+	}
+
+	p.RecordAnswer(first, "99元")
+	p.flush(time.Now())
+	if len(*calls) != 1 {
+		t.Fatalf("应发送一条合并回复，实际 %d 条", len(*calls))
+	}
+	call := (*calls)[0]
+	if call.total != 50 || len(call.names) != maxPending || call.evt.UserID != "u50" || call.answer != "99元" {
+		t.Fatalf("合并回复参数不正确: total=%d, names=%d, user=%s", call.total, len(call.names), call.evt.UserID)
+	}
+	if len(group.pending) != 0 || group.merged != 0 {
+		t.Fatal("发送后应清空待合并评论")
+	}
+}
+
+func TestAggregatedReplyCountsAgainstRoomLimit(t *testing.T) {
+	p, calls := newTestPolicy(Rules{RoomMaxPerMinute: 2, SimilarFirstN: 1, SimilarWindowSec: 60, LowIntentSampleRate: 1})
+
+	first := comment("u1", "多少钱")
+	p.Evaluate(first, 10)
+	p.RecordAnswer(first, "99元")
+	p.Evaluate(comment("u2", "多少钱"), 10)
+
+	// 直播间配额已用完时合并回复留到下次
+	p.Evaluate(comment("u3", "发什么快递"), 10)
+	now := time.Now()
+	p.flush(now)
+	if len(*calls) != 0 {
+		t.Fatal("直播间达到上限时不应发送合并回复")
+	}
+
+	p.flush(now.Add(time.Minute))
+	if len(*calls) != 1 {
+		t.Fatalf("配额恢复后应发送合并回复，实际 %d 条", len(*calls))
+	}
+	if replies := p.rooms[roomKeyOf(first)]; len(replies) != 1 {
+		t.Fatalf("合并回复应占用直播间配额: %v", replies)
+	}
+}
+
+func TestFlushPrunesIdleRooms(t *testing.T) {
+	p, _ := newTestPolicy(Rules{RoomMaxPerMinute: 10, LowIntentSampleRate: 1})
+
+	for i := 0; i < 3; i++ {
+		evt := comment("u1", "多少钱")
+		evt.RoomID = fmt.Sprintf("room-%d", i)
+		p.Evaluate(evt, 10)
+	}
+	if len(p.rooms) != 3 {
+		t.Fatalf("应记录3个直播间，实际 %d", len(p.rooms))
+	}
+
+	p.flush(time.Now().Add(2 * time.Minute))
+	if len(p.rooms) != 0 {
+		t.Fatalf("一分钟内没有回复的直播间应被清理，剩余 %d", len(p.rooms))
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	p, _ := newTestPolicy(Rules{LowIntentSampleRate: 1})
+	p.location = time.UTC
+
+	rules := Rules{QuietHours: []QuietHours{{Start: "23:00", End: "07:00"}}}
+	night := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !p.inQuietHours(rules, night) || p.inQuietHours(rules, day) {
+		t.Fatal("跨零点的静默时段判断错误")
+	}
+}
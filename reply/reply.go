@@ -2,26 +2,44 @@ package reply
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"time"
+
+	"live-im-proxy/ids"
+)
+
+// 机器人回复的用途，同一事件不同用途的回复幂等键不同
+const (
+	PurposeAnswer    = "answer"    // 对事件本身的回答
+	PurposeAggregate = "aggregate" // 合并回复同问用户
+	PurposeNotice    = "notice"    // 转人工等提示
+	PurposeThanks    = "thanks"    // 礼物、点赞感谢
+	PurposeWelcome   = "welcome"   // 进场欢迎、关注感谢
 )
 
+// ErrRoomMessageUnsupported 渠道不能向直播间发送公屏消息（礼物感谢、进场欢迎、定时消息），
+// 调用方应跳过，不计为发送成功或失败
+var ErrRoomMessageUnsupported = errors.New("渠道不支持发送直播间公屏消息")
+
 // Reply 回复消息结构
 type Reply struct {
-	ID        string `json:"id"`
-	Channel   string `json:"channel"`
-	RoomID    string `json:"room_id"`
-	UserID    string `json:"user_id"`
-	Content   string `json:"content"`
-	Type      string `json:"type"` // text, image, video
-	Timestamp int64  `json:"timestamp"`
+	ID             string `json:"id"`
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // 由事件幂等键和回复用途生成，重试发送时不变
+	Channel        string `json:"channel"`
+	RoomID         string `json:"room_id"`
+	UserID         string `json:"user_id"`
+	Content        string `json:"content"`
+	Type           string `json:"type"`             // text, image, video
+	Source         string `json:"source,omitempty"` // bot, agent
+	Tier           string `json:"tier,omitempty"`   // 机器人回答的来源层级：cache, faq, ai, rules, default
+	Timestamp      int64  `json:"timestamp"`
 }
 
 // NewReply 创建新的回复
 func NewReply(channel, roomID, userID, content string) *Reply {
 	return &Reply{
-		ID:        fmt.Sprintf("reply_%d", time.Now().UnixNano()),
+		ID:        ids.New(),
 		Channel:   channel,
 		RoomID:    roomID,
 		UserID:    userID,
@@ -31,10 +49,18 @@ func NewReply(channel, roomID, userID, content string) *Reply {
 	}
 }
 
+// IdempotencyKey 由事件幂等键和回复用途生成回复的幂等键，事件没有幂等键时返回空
+func IdempotencyKey(eventKey, purpose string) string {
+	if eventKey == "" {
+		return ""
+	}
+	return eventKey + ":reply:" + purpose
+}
+
 // Send 发送回复
 func (r *Reply) Send() error {
 	log.Printf("📤 发送回复: %s -> %s (%s)", r.Channel, r.UserID, r.Content)
-	
+
 	// 这里应该调用对应渠道的API发送消息
 	// 目前只是模拟发送
 	return nil
@@ -0,0 +1,19 @@
+package reply
+
+import "testing"
+
+func TestIdempotencyKey(t *testing.T) {
+	if got := IdempotencyKey("", "answer"); got != "" {
+		t.Fatalf("事件没有幂等键时应返回空，实际 %q", got)
+	}
+	key := IdempotencyKey("douyin:comment:123", "answer")
+	if key != IdempotencyKey("douyin:comment:123", "answer") {
+		t.Fatal("重试发送时幂等键应不变")
+	}
+	if key == IdempotencyKey("douyin:comment:123", "thanks") {
+		t.Fatal("同一事件不同用途的回复应区分")
+	}
+	if key == IdempotencyKey("douyin:comment:456", "answer") {
+		t.Fatal("不同事件的回复应区分")
+	}
+}
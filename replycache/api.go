@@ -0,0 +1,54 @@
+package replycache
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/openapi"
+)
+
+// RegisterRoutes 注册回复缓存API
+//
+//	GET    /api/v1/reply-cache?tenant_id=   各租户的缓存命中统计（本副本）
+//	DELETE /api/v1/reply-cache?tenant_id=   清空缓存的回复，未指定租户时清空全部
+func (c *Cache) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/reply-cache", Tag: "reply-cache",
+		Summary:  "回复缓存命中统计（本副本）",
+		Query:    []openapi.Param{{Name: "tenant_id", Description: "按租户过滤"}},
+		Response: []Stats{}, HandlerFunc: c.handleStats,
+	})
+	router.Handle(openapi.Route{
+		Method: "DELETE", Path: "/api/v1/reply-cache", Tag: "reply-cache",
+		Summary:     "清空缓存的回复（本副本），商品目录和知识库变更时会自动清空",
+		Query:       []openapi.Param{{Name: "tenant_id", Description: "租户，未指定时清空全部"}},
+		HandlerFunc: c.handleInvalidate,
+	})
+}
+
+// handleStats 缓存统计，租户密钥只能看到本租户
+func (c *Cache) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := make([]Stats, 0)
+	for _, item := range c.Stats(r.URL.Query().Get("tenant_id")) {
+		if auth.CanAccessTenant(r, item.TenantID) {
+			stats = append(stats, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": stats})
+}
+
+// handleInvalidate 清空缓存，租户密钥的 tenant_id 由鉴权中间件限定为本租户
+func (c *Cache) handleInvalidate(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant_id")
+	c.Invalidate(tenantID)
+	log.Printf("🧹 回复缓存已清空: tenant=%s", tenantID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
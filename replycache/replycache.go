@@ -0,0 +1,272 @@
+package replycache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/policy"
+)
+
+// Config 回复缓存配置
+type Config struct {
+	Enabled    bool
+	TTL        time.Duration // 缓存的回复在该时长内复用
+	MaxEntries int           // 缓存条数上限，超出时淘汰最早的回复
+}
+
+// DefaultConfig 默认配置：同一直播间的相同问题2分钟内复用回复
+func DefaultConfig() Config {
+	return Config{
+		Enabled:    true,
+		TTL:        2 * time.Minute,
+		MaxEntries: 10000,
+	}
+}
+
+// Key 缓存键：同一租户、同一直播间（短视频为同一视频）、同一意图的同一归一化问题
+type Key struct {
+	TenantID string
+	Channel  string
+	Room     string
+	Question string
+	Intent   string // 归一化会去掉标点和语气词，意图不同的问题不共用回复
+}
+
+// KeyFor 事件的缓存键，只缓存公开评论；私信带有会话上下文，不复用
+func KeyFor(evt *event.Event) (Key, bool) {
+	if evt.Type != event.TypeComment && evt.Type != event.TypeVideoComment {
+		return Key{}, false
+	}
+	room := evt.RoomID
+	if room == "" {
+		room = evt.VideoID
+	}
+	question := policy.Normalize(evt.Content)
+	if question == "" {
+		return Key{}, false
+	}
+	return Key{TenantID: evt.TenantID, Channel: evt.Channel, Room: room, Question: question}, true
+}
+
+// Stats 租户的缓存统计
+type Stats struct {
+	TenantID  string `json:"tenant_id"`
+	Entries   int    `json:"entries"`   // 当前缓存的回复数
+	Hits      int64  `json:"hits"`      // 直接复用缓存的次数
+	Coalesced int64  `json:"coalesced"` // 等待同一问题正在生成的回复的次数
+	Misses    int64  `json:"misses"`    // 需要生成回复的次数
+}
+
+// entry 缓存的回复
+type entry struct {
+	answer  string
+	created time.Time
+}
+
+// call 正在生成的回复，相同问题的并发请求等待同一结果
+type call struct {
+	done       chan struct{}
+	answer     string
+	err        error
+	generation uint64
+}
+
+// Cache 回复缓存：相同问题在有效期内复用最近的回复，
+// 正在生成时相同问题等待同一次生成，避免直播间刷屏提问时重复调用AI
+type Cache struct {
+	mu         sync.Mutex
+	config     Config
+	entries    map[Key]*entry
+	inflight   map[Key]*call
+	stats      map[string]*Stats
+	generation uint64 // 每次清空时递增，清空前开始生成的回复不写入缓存
+	done       chan struct{}
+	once       sync.Once
+}
+
+// NewCache 创建回复缓存
+func NewCache(config Config) *Cache {
+	c := &Cache{
+		config:   config,
+		entries:  make(map[Key]*entry),
+		inflight: make(map[Key]*call),
+		stats:    make(map[string]*Stats),
+		done:     make(chan struct{}),
+	}
+	go c.cleanupLoop()
+	return c
+}
+
+// SetConfig 更新配置，关闭缓存时清空已缓存的回复
+func (c *Cache) SetConfig(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+	if !config.Enabled {
+		c.entries = make(map[Key]*entry)
+		c.generation++
+	}
+}
+
+// Close 停止过期清理
+func (c *Cache) Close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// Get 返回有效期内缓存的回复，不触发生成
+func (c *Cache) Get(key Key) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.config.Enabled {
+		return "", false
+	}
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.created) >= c.config.TTL {
+		return "", false
+	}
+	c.statsFor(key.TenantID).Hits++
+	return e.answer, true
+}
+
+// Do 返回缓存的回复，没有时调用 generate 生成并缓存；
+// cached 表示回复来自缓存或同一问题的并发生成。生成失败或回复为空时不缓存；
+// 等待并发生成时 ctx 取消则返回 ctx.Err()，不影响正在进行的生成
+func (c *Cache) Do(ctx context.Context, key Key, generate func() (string, error)) (answer string, cached bool, err error) {
+	c.mu.Lock()
+	if !c.config.Enabled {
+		c.mu.Unlock()
+		answer, err = generate()
+		return answer, false, err
+	}
+	stats := c.statsFor(key.TenantID)
+	if e, ok := c.entries[key]; ok && time.Since(e.created) < c.config.TTL {
+		stats.Hits++
+		c.mu.Unlock()
+		return e.answer, true, nil
+	}
+	if inflight, ok := c.inflight[key]; ok {
+		stats.Coalesced++
+		c.mu.Unlock()
+		select {
+		case <-inflight.done:
+			return inflight.answer, inflight.err == nil && inflight.answer != "", inflight.err
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		}
+	}
+	stats.Misses++
+	current := &call{done: make(chan struct{}), generation: c.generation}
+	c.inflight[key] = current
+	c.mu.Unlock()
+
+	current.answer, current.err = generate()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if current.err == nil && current.answer != "" && c.config.Enabled && current.generation == c.generation {
+		c.entries[key] = &entry{answer: current.answer, created: time.Now()}
+		c.evict()
+	}
+	c.mu.Unlock()
+	close(current.done)
+
+	return current.answer, false, current.err
+}
+
+// Invalidate 清空租户缓存的回复，tenantID 为空时清空全部；
+// 商品目录、知识库或回复规则变更后调用，避免复用过时的回复
+func (c *Cache) Invalidate(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if tenantID == "" {
+		c.entries = make(map[Key]*entry)
+		return
+	}
+	for key := range c.entries {
+		if key.TenantID == tenantID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Stats 各租户的缓存统计，tenantID 为空时返回全部
+func (c *Cache) Stats(tenantID string) []Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make(map[string]int)
+	for key := range c.entries {
+		entries[key.TenantID]++
+	}
+	result := make([]Stats, 0, len(c.stats))
+	for id, stats := range c.stats {
+		if tenantID != "" && id != tenantID {
+			continue
+		}
+		item := *stats
+		item.Entries = entries[id]
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].TenantID < result[j].TenantID })
+	return result
+}
+
+// statsFor 租户的统计计数，需在锁内调用
+func (c *Cache) statsFor(tenantID string) *Stats {
+	stats, ok := c.stats[tenantID]
+	if !ok {
+		stats = &Stats{TenantID: tenantID}
+		c.stats[tenantID] = stats
+	}
+	return stats
+}
+
+// evict 超出条数上限时淘汰过期和最早的回复，需在锁内调用
+func (c *Cache) evict() {
+	if c.config.MaxEntries <= 0 || len(c.entries) <= c.config.MaxEntries {
+		return
+	}
+	c.removeExpired(time.Now())
+	for len(c.entries) > c.config.MaxEntries {
+		var oldest Key
+		var oldestAt time.Time
+		for key, e := range c.entries {
+			if oldestAt.IsZero() || e.created.Before(oldestAt) {
+				oldest, oldestAt = key, e.created
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// removeExpired 删除过期的回复，需在锁内调用
+func (c *Cache) removeExpired(now time.Time) {
+	for key, e := range c.entries {
+		if now.Sub(e.created) >= c.config.TTL {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cleanupLoop 定期清理过期的回复
+func (c *Cache) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			c.removeExpired(now)
+			c.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package replycache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+func newTestCache(t *testing.T, config Config) *Cache {
+	t.Helper()
+	c := NewCache(config)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func answer(text string) func() (string, error) {
+	return func() (string, error) { return text, nil }
+}
+
+func statsOf(c *Cache, tenantID string) Stats {
+	for _, stats := range c.Stats(tenantID) {
+		return stats
+	}
+	return Stats{TenantID: tenantID}
+}
+
+func TestKeyFor(t *testing.T) {
+	evt := event.NewEvent(event.TypeComment, "douyin", "room", "u1", "昵称")
+	evt.TenantID = "t1"
+	evt.Content = "多少钱呀？"
+	key, ok := KeyFor(evt)
+	if !ok || key != (Key{TenantID: "t1", Channel: "douyin", Room: "room", Question: "多少钱"}) {
+		t.Fatalf("KeyFor = %+v, %v", key, ok)
+	}
+
+	video := event.NewEvent(event.TypeVideoComment, "douyin", "", "u1", "昵称")
+	video.VideoID = "v1"
+	video.Content = "多少钱"
+	if key, ok := KeyFor(video); !ok || key.Room != "v1" {
+		t.Fatalf("短视频评论应按视频缓存: %+v", key)
+	}
+
+	private := event.NewEvent(event.TypePrivateMessage, "douyin", "", "u1", "昵称")
+	private.Content = "多少钱"
+	if _, ok := KeyFor(private); ok {
+		t.Fatal("私信不应缓存")
+	}
+	evt.Content = "？？"
+	if _, ok := KeyFor(evt); ok {
+		t.Fatal("归一化后为空的问题不应缓存")
+	}
+}
+
+func TestDoCachesAnswer(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	ctx := context.Background()
+	key := Key{TenantID: "t1", Room: "room", Question: "多少钱", Intent: "price"}
+
+	if _, _, err := c.Do(ctx, key, func() (string, error) { return "", errors.New("timeout") }); err == nil {
+		t.Fatal("生成失败应返回错误")
+	}
+	if got, cached, _ := c.Do(ctx, key, answer("")); got != "" || cached {
+		t.Fatal("空回复不应视为缓存")
+	}
+
+	if got, cached, err := c.Do(ctx, key, answer("99元")); err != nil || got != "99元" || cached {
+		t.Fatalf("首次生成 = %q, %v, %v", got, cached, err)
+	}
+	if got, cached, _ := c.Do(ctx, key, answer("不应调用")); got != "99元" || !cached {
+		t.Fatalf("应复用缓存的回复，实际 %q", got)
+	}
+	if got, ok := c.Get(key); !ok || got != "99元" {
+		t.Fatal("Get 应返回缓存的回复")
+	}
+
+	other := key
+	other.Intent = "logistics"
+	if _, ok := c.Get(other); ok {
+		t.Fatal("意图不同的问题不应共用回复")
+	}
+
+	stats := statsOf(c, "t1")
+	if stats.Entries != 1 || stats.Hits != 2 || stats.Misses != 3 {
+		t.Fatalf("统计错误: %+v", stats)
+	}
+}
+
+func TestDoExpiresAndEvicts(t *testing.T) {
+	c := newTestCache(t, Config{Enabled: true, TTL: 20 * time.Millisecond, MaxEntries: 2})
+	ctx := context.Background()
+
+	for _, question := range []string{"a", "b", "c"} {
+		c.Do(ctx, Key{TenantID: "t1", Question: question}, answer(question))
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := c.Get(Key{TenantID: "t1", Question: "a"}); ok {
+		t.Fatal("超出条数上限时应淘汰最早的回复")
+	}
+	if _, ok := c.Get(Key{TenantID: "t1", Question: "c"}); !ok {
+		t.Fatal("最新的回复不应被淘汰")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get(Key{TenantID: "t1", Question: "c"}); ok {
+		t.Fatal("过期的回复不应复用")
+	}
+}
+
+func TestDoCoalescesConcurrentCalls(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	ctx := context.Background()
+	key := Key{TenantID: "t1", Room: "room", Question: "多少钱"}
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go c.Do(ctx, key, func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		close(started)
+		<-release
+		return "99元", nil
+	})
+	<-started
+
+	const waiters = 5
+	var wg sync.WaitGroup
+	results := make(chan string, waiters)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, cached, err := c.Do(ctx, key, func() (string, error) {
+				atomic.AddInt32(&calls, 1)
+				return "重复生成", nil
+			})
+			if err != nil || !cached {
+				t.Errorf("等待的请求应共用生成结果: cached=%v err=%v", cached, err)
+			}
+			results <- got
+		}()
+	}
+	waitFor(t, func() bool { return statsOf(c, "t1").Coalesced == waiters })
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for got := range results {
+		if got != "99元" {
+			t.Fatalf("等待的请求应拿到同一回复，实际 %q", got)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("相同问题并发时只应生成一次，实际 %d", n)
+	}
+}
+
+func TestDoWaiterHonorsContext(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	key := Key{TenantID: "t1", Question: "多少钱"}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	leader := make(chan string)
+	go func() {
+		got, _, _ := c.Do(context.Background(), key, func() (string, error) {
+			close(started)
+			<-release
+			return "99元", nil
+		})
+		leader <- got
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.Do(ctx, key, answer("不应调用")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("等待时 ctx 超时应返回 ctx.Err()，实际 %v", err)
+	}
+
+	close(release)
+	if got := <-leader; got != "99元" {
+		t.Fatalf("等待方取消不应影响正在进行的生成，实际 %q", got)
+	}
+	if got, ok := c.Get(key); !ok || got != "99元" {
+		t.Fatal("生成完成后应写入缓存")
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	ctx := context.Background()
+	t1 := Key{TenantID: "t1", Question: "多少钱"}
+	t2 := Key{TenantID: "t2", Question: "多少钱"}
+	c.Do(ctx, t1, answer("99元"))
+	c.Do(ctx, t2, answer("199元"))
+
+	c.Invalidate("t1")
+	if _, ok := c.Get(t1); ok {
+		t.Fatal("清空后不应复用租户的回复")
+	}
+	if _, ok := c.Get(t2); !ok {
+		t.Fatal("清空不应影响其他租户")
+	}
+
+	c.Invalidate("")
+	if _, ok := c.Get(t2); ok {
+		t.Fatal("未指定租户时应清空全部")
+	}
+}
+
+func TestInvalidateDuringGeneration(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	key := Key{TenantID: "t1", Question: "多少钱"}
+
+	got, _, err := c.Do(context.Background(), key, func() (string, error) {
+		// 生成期间商品目录变更，旧价格不应写入缓存
+		c.Invalidate("t1")
+		return "99元", nil
+	})
+	if err != nil || got != "99元" {
+		t.Fatalf("生成结果仍应返回给调用方: %q, %v", got, err)
+	}
+	if _, ok := c.Get(key); ok {
+		t.Fatal("清空前开始生成的回复不应写入缓存")
+	}
+
+	c.Do(context.Background(), key, answer("89元"))
+	if got, ok := c.Get(key); !ok || got != "89元" {
+		t.Fatal("清空后生成的回复应正常缓存")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	c := newTestCache(t, DefaultConfig())
+	ctx := context.Background()
+	key := Key{TenantID: "t1", Question: "多少钱"}
+	c.Do(ctx, key, answer("99元"))
+
+	config := DefaultConfig()
+	config.Enabled = false
+	c.SetConfig(config)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("关闭缓存后不应复用回复")
+	}
+	if got, cached, _ := c.Do(ctx, key, answer("89元")); got != "89元" || cached {
+		t.Fatal("关闭缓存时每次都应生成")
+	}
+
+	c.SetConfig(DefaultConfig())
+	if _, ok := c.Get(key); ok {
+		t.Fatal("关闭期间生成的回复不应缓存")
+	}
+}
+
+// waitFor 等待条件成立，超时则失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
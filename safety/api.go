@@ -0,0 +1,32 @@
+package safety
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// AuditHandler 内容安全审计记录API
+//
+//	GET /api/safety/audit?tenant_id=&limit=
+func (f *Filter) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "limit 格式错误", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    f.Audit(r.URL.Query().Get("tenant_id"), limit),
+	})
+}
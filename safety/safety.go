@@ -0,0 +1,401 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+// 处置动作，按严重程度从低到高
+const (
+	ActionPass    = "pass"    // 通过
+	ActionFlag    = "flag"    // 放行但记录
+	ActionRewrite = "rewrite" // 改写后放行
+	ActionBlock   = "block"   // 拦截不发送
+)
+
+// 联系方式策略
+const (
+	ContactAllow = "allow" // 允许
+	ContactStrip = "strip" // 删除联系方式后发送
+	ContactBlock = "block" // 整条拦截
+)
+
+// DefaultAdWords 广告法极限用语
+var DefaultAdWords = []string{
+	"最好", "最佳", "最优", "最强", "最低价", "最便宜", "全网最低", "史上最低",
+	"第一", "唯一", "首个", "首选", "顶级", "极品", "国家级", "世界级",
+	"绝对", "100%", "万能", "永久", "史无前例", "独一无二",
+}
+
+// DefaultAdExceptions 包含极限词的日常用语，出现在这些短语中的极限词不算命中
+var DefaultAdExceptions = []string{
+	"第一次", "第一时间", "第一天", "第一步", "第一眼", "第一单",
+	"最好看", "最好先", "最好是", "最好还是", "最好能", "最好不要",
+	"唯一的问题", "唯一的缺点", "唯一的区别", "唯一需要",
+	"首个工作日",
+}
+
+// WordList 敏感词库
+type WordList struct {
+	Name         string            `json:"name"`
+	Words        []string          `json:"words"`
+	Action       string            `json:"action"`       // flag, rewrite, block
+	Replacements map[string]string `json:"replacements"` // rewrite时的替换词，未配置则用*遮盖
+	Exceptions   []string          `json:"exceptions"`   // 例外短语，如“第一次”中的“第一”不算命中
+}
+
+// Policy 渠道策略
+type Policy struct {
+	ContactInfo map[string]string `json:"contact_info"` // 事件类型 -> allow/strip/block
+	MaxLength   int               `json:"max_length"`   // 最大字数，超出截断，0表示不限
+}
+
+// Config 内容安全配置
+type Config struct {
+	WordLists []WordList        `json:"word_lists"`
+	Default   Policy            `json:"default"`
+	Channels  map[string]Policy `json:"channels"` // 按渠道覆盖默认策略
+}
+
+// DefaultConfig 默认配置：极限词改写，公开评论删除联系方式，私信允许
+func DefaultConfig() Config {
+	return Config{
+		WordLists: []WordList{
+			{Name: "广告法极限词", Words: DefaultAdWords, Action: ActionRewrite, Exceptions: DefaultAdExceptions},
+		},
+		Default: Policy{
+			ContactInfo: map[string]string{
+				"comment":         ContactStrip,
+				"video_comment":   ContactStrip,
+				"private_message": ContactAllow,
+			},
+			MaxLength: 100,
+		},
+		Channels: map[string]Policy{},
+	}
+}
+
+// LoadConfig 从JSON文件加载配置，path为空时返回默认配置
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("读取内容安全配置失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("解析内容安全配置失败: %v", err)
+	}
+	return config, config.Validate()
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	for _, list := range c.WordLists {
+		switch list.Action {
+		case ActionFlag, ActionRewrite, ActionBlock:
+		default:
+			return fmt.Errorf("词库 %s 的动作无效: %q", list.Name, list.Action)
+		}
+	}
+	policies := map[string]Policy{"default": c.Default}
+	for channel, policy := range c.Channels {
+		policies[channel] = policy
+	}
+	for name, policy := range policies {
+		for eventType, action := range policy.ContactInfo {
+			switch action {
+			case ContactAllow, ContactStrip, ContactBlock:
+			default:
+				return fmt.Errorf("策略 %s 的联系方式处理无效: %s=%q", name, eventType, action)
+			}
+		}
+	}
+	return nil
+}
+
+// 联系方式识别规则
+var contactPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"phone", regexp.MustCompile(`1[3-9]\d[\s-]?\d{4}[\s-]?\d{4}`)},
+	{"wechat", regexp.MustCompile(`(?i)(微信|威信|薇信|v信|vx|wx|wechat|加v|加微)\s*(号)?\s*[:：]?\s*[a-zA-Z][-_a-zA-Z0-9]{5,19}`)},
+	{"qq", regexp.MustCompile(`(?i)(qq|扣扣)\s*(号)?\s*[:：]?\s*[1-9]\d{4,11}`)},
+}
+
+// Hit 命中记录
+type Hit struct {
+	Rule   string `json:"rule"`
+	Match  string `json:"match"`
+	Action string `json:"action"`
+}
+
+// Result 检查结果
+type Result struct {
+	Content string `json:"content"` // 处置后的内容
+	Action  string `json:"action"`  // 最终动作
+	Hits    []Hit  `json:"hits,omitempty"`
+}
+
+// Record 审计记录
+type Record struct {
+	Time     time.Time `json:"time"`
+	TenantID string    `json:"tenant_id"`
+	Channel  string    `json:"channel"`
+	Type     string    `json:"type"`
+	EventID  string    `json:"event_id"`
+	UserID   string    `json:"user_id"`
+	Original string    `json:"original"`
+	Result
+}
+
+// auditSize 内存中保留的审计记录数
+const auditSize = 1000
+
+// Filter 回复内容安全过滤器
+type Filter struct {
+	mu     sync.RWMutex
+	config Config
+	audit  []Record
+	next   int
+	full   bool
+}
+
+// NewFilter 创建过滤器
+func NewFilter(config Config) *Filter {
+	return &Filter{
+		config: config,
+		audit:  make([]Record, auditSize),
+	}
+}
+
+// SetConfig 更新配置
+func (f *Filter) SetConfig(config Config) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+}
+
+// Check 检查回复内容，非pass的结果写入审计记录
+func (f *Filter) Check(evt *event.Event, content string) Result {
+	f.mu.RLock()
+	config := f.config
+	f.mu.RUnlock()
+
+	result := Result{Content: content, Action: ActionPass}
+
+	// 1. 敏感词（长词优先，避免“全网最低价”只命中“最低价”）
+	for _, list := range config.WordLists {
+		for _, word := range sortedWords(list.Words) {
+			if word == "" {
+				continue
+			}
+			positions := matchWord(result.Content, word, list.Exceptions)
+			if len(positions) == 0 {
+				continue
+			}
+			result.hit(Hit{Rule: list.Name, Match: word, Action: list.Action})
+			if list.Action == ActionRewrite {
+				replacement, ok := list.Replacements[word]
+				if !ok {
+					replacement = strings.Repeat("*", len([]rune(word)))
+				}
+				result.Content = replaceAt(result.Content, positions, len(word), replacement)
+			}
+		}
+	}
+
+	policy := config.policyFor(evt.Channel)
+
+	// 2. 联系方式
+	contactAction := policy.ContactInfo[evt.Type]
+	if contactAction != "" && contactAction != ContactAllow {
+		for _, cp := range contactPatterns {
+			for _, match := range cp.pattern.FindAllString(result.Content, -1) {
+				if contactAction == ContactBlock {
+					result.hit(Hit{Rule: "contact:" + cp.name, Match: match, Action: ActionBlock})
+					continue
+				}
+				result.hit(Hit{Rule: "contact:" + cp.name, Match: match, Action: ActionRewrite})
+				result.Content = strings.ReplaceAll(result.Content, match, "")
+			}
+		}
+		result.Content = strings.TrimSpace(result.Content)
+	}
+
+	// 3. 长度
+	if runes := []rune(result.Content); policy.MaxLength > 0 && len(runes) > policy.MaxLength {
+		result.hit(Hit{Rule: "max_length", Match: fmt.Sprintf("%d>%d", len(runes), policy.MaxLength), Action: ActionRewrite})
+		result.Content = string(runes[:policy.MaxLength])
+	}
+
+	if result.Content == "" && result.Action != ActionPass {
+		result.Action = ActionBlock
+	}
+
+	if result.Action != ActionPass {
+		f.record(evt, content, result)
+		log.Printf("🛡️ 回复内容安全处置: action=%s, event=%s, hits=%d", result.Action, evt.ID, len(result.Hits))
+	}
+	return result
+}
+
+// Audit 获取最近的审计记录，按时间倒序，tenantID为空时不过滤
+func (f *Filter) Audit(tenantID string, limit int) []Record {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	count := f.next
+	if f.full {
+		count = auditSize
+	}
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+
+	result := make([]Record, 0, limit)
+	for i := 0; i < count && len(result) < limit; i++ {
+		idx := (f.next - 1 - i + auditSize) % auditSize
+		if tenantID != "" && f.audit[idx].TenantID != tenantID {
+			continue
+		}
+		result = append(result, f.audit[idx])
+	}
+	return result
+}
+
+// record 写入审计记录（环形缓冲）
+func (f *Filter) record(evt *event.Event, original string, result Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.audit[f.next] = Record{
+		Time:     time.Now(),
+		TenantID: evt.TenantID,
+		Channel:  evt.Channel,
+		Type:     evt.Type,
+		EventID:  evt.ID,
+		UserID:   evt.UserID,
+		Original: original,
+		Result:   result,
+	}
+	f.next = (f.next + 1) % auditSize
+	if f.next == 0 {
+		f.full = true
+	}
+}
+
+// policyFor 获取渠道策略，渠道未配置的项使用默认值
+func (c *Config) policyFor(channel string) Policy {
+	policy, ok := c.Channels[channel]
+	if !ok {
+		return c.Default
+	}
+	if policy.ContactInfo == nil {
+		policy.ContactInfo = c.Default.ContactInfo
+	}
+	if policy.MaxLength == 0 {
+		policy.MaxLength = c.Default.MaxLength
+	}
+	return policy
+}
+
+// matchWord 查找词在内容中的位置（字节偏移），跳过落在例外短语内的位置
+func matchWord(content, word string, exceptions []string) []int {
+	var excluded [][2]int
+	for _, exception := range exceptions {
+		if !strings.Contains(exception, word) {
+			continue
+		}
+		for _, start := range indexAll(content, exception) {
+			excluded = append(excluded, [2]int{start, start + len(exception)})
+		}
+	}
+
+	var positions []int
+	for _, start := range indexAll(content, word) {
+		end := start + len(word)
+		covered := false
+		for _, span := range excluded {
+			if span[0] <= start && end <= span[1] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			positions = append(positions, start)
+		}
+	}
+	return positions
+}
+
+// indexAll 子串所有不重叠出现的位置
+func indexAll(s, sub string) []int {
+	var result []int
+	for offset := 0; ; {
+		i := strings.Index(s[offset:], sub)
+		if i < 0 {
+			return result
+		}
+		result = append(result, offset+i)
+		offset += i + len(sub)
+	}
+}
+
+// replaceAt 替换指定位置上长度为n的内容，positions升序
+func replaceAt(s string, positions []int, n int, replacement string) string {
+	var b strings.Builder
+	last := 0
+	for _, start := range positions {
+		b.WriteString(s[last:start])
+		b.WriteString(replacement)
+		last = start + n
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// sortedWords 按长度降序排列词表
+func sortedWords(words []string) []string {
+	sorted := make([]string, len(words))
+	copy(sorted, words)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len([]rune(sorted[i])) > len([]rune(sorted[j]))
+	})
+	return sorted
+}
+
+// hit 记录命中并提升最终动作
+func (r *Result) hit(h Hit) {
+	r.Hits = append(r.Hits, h)
+	if severity(h.Action) > severity(r.Action) {
+		r.Action = h.Action
+	}
+}
+
+// severity 动作严重程度
+func severity(action string) int {
+	switch action {
+	case ActionFlag:
+		return 1
+	case ActionRewrite:
+		return 2
+	case ActionBlock:
+		return 3
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,89 @@
+package safety
+
+import (
+	"testing"
+
+	"live-im-proxy/event"
+)
+
+func newEvent(eventType string) *event.Event {
+	evt := event.NewEvent(eventType, "douyin", "room", "u1", "昵称")
+	evt.TenantID = "tenant"
+	return evt
+}
+
+func TestAdWordsRewrittenInContext(t *testing.T) {
+	f := NewFilter(DefaultConfig())
+
+	cases := []struct {
+		content string
+		want    string
+		action  string
+	}{
+		{"这款是全网最低", "这款是****", ActionRewrite},
+		{"我们的面料最好，销量第一", "我们的面料**，销量**", ActionRewrite},
+		{"第一次购买可以领券", "第一次购买可以领券", ActionPass},
+		{"这件颜色最好看，您最好先量一下尺码", "这件颜色最好看，您最好先量一下尺码", ActionPass},
+		{"唯一的问题是需要预售", "唯一的问题是需要预售", ActionPass},
+		{"第一次买就选销量第一的", "第一次买就选销量**的", ActionRewrite},
+	}
+	for _, c := range cases {
+		result := f.Check(newEvent(event.TypePrivateMessage), c.content)
+		if result.Content != c.want || result.Action != c.action {
+			t.Errorf("Check(%q) = %q/%s, 期望 %q/%s", c.content, result.Content, result.Action, c.want, c.action)
+		}
+	}
+}
+
+func TestWordListActions(t *testing.T) {
+	config := DefaultConfig()
+	config.WordLists = []WordList{
+		{Name: "替换", Words: []string{"最便宜"}, Action: ActionRewrite, Replacements: map[string]string{"最便宜": "实惠"}},
+		{Name: "违禁", Words: []string{"代购"}, Action: ActionBlock},
+	}
+	f := NewFilter(config)
+
+	result := f.Check(newEvent(event.TypePrivateMessage), "这款最便宜")
+	if result.Content != "这款实惠" || result.Action != ActionRewrite {
+		t.Fatalf("应使用配置的替换词: %+v", result)
+	}
+	result = f.Check(newEvent(event.TypePrivateMessage), "可以代购")
+	if result.Action != ActionBlock {
+		t.Fatalf("命中拦截词应拦截: %+v", result)
+	}
+	if records := f.Audit("tenant", 0); len(records) != 2 || records[0].Original != "可以代购" {
+		t.Fatalf("处置结果应写入审计记录: %+v", records)
+	}
+}
+
+func TestContactInfoPolicy(t *testing.T) {
+	f := NewFilter(DefaultConfig())
+
+	result := f.Check(newEvent(event.TypeComment), "详情加微信 abc12345 咨询")
+	if result.Action != ActionRewrite || result.Content != "详情加 咨询" {
+		t.Fatalf("公开评论应删除联系方式: %+v", result)
+	}
+	result = f.Check(newEvent(event.TypePrivateMessage), "客服电话 13800138000")
+	if result.Action != ActionPass {
+		t.Fatalf("私信应允许联系方式: %+v", result)
+	}
+
+	config := DefaultConfig()
+	config.Channels = map[string]Policy{"douyin": {ContactInfo: map[string]string{"comment": ContactBlock}}}
+	f.SetConfig(config)
+	result = f.Check(newEvent(event.TypeComment), "电话 13800138000")
+	if result.Action != ActionBlock {
+		t.Fatalf("渠道策略应覆盖默认策略: %+v", result)
+	}
+}
+
+func TestMaxLength(t *testing.T) {
+	config := DefaultConfig()
+	config.Default.MaxLength = 5
+	f := NewFilter(config)
+
+	result := f.Check(newEvent(event.TypePrivateMessage), "一二三四五六七")
+	if result.Content != "一二三四五" || result.Action != ActionRewrite {
+		t.Fatalf("超长回复应截断: %+v", result)
+	}
+}
@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// sensitiveKeys 需要脱敏的参数名
+var sensitiveKeys = []string{
+	"access_token", "refresh_token", "client_secret", "app_secret",
+	"token", "code", "password", "secret", "authorization",
+}
+
+var (
+	jsonPattern   = regexp.MustCompile(`"(` + strings.Join(sensitiveKeys, "|") + `)"(\s*:\s*)"([^"]*)"`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// Redact 脱敏凭证，只保留首尾各4位
+func Redact(secret string) string {
+	if len(secret) < 12 {
+		return "***"
+	}
+	return secret[:4] + "..." + secret[len(secret)-4:]
+}
+
+// RedactURL 脱敏URL查询参数中的凭证
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	for key, values := range query {
+		if isSensitive(key) {
+			for i := range values {
+				values[i] = Redact(values[i])
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// RedactText 脱敏日志文本：JSON 中的凭证字段和 Bearer 令牌
+func RedactText(text string) string {
+	text = jsonPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := jsonPattern.FindStringSubmatch(m)
+		return `"` + parts[1] + `"` + parts[2] + `"` + Redact(parts[3]) + `"`
+	})
+	return bearerPattern.ReplaceAllStringFunc(text, func(m string) string {
+		parts := bearerPattern.FindStringSubmatch(m)
+		return parts[1] + Redact(m[len(parts[1]):])
+	})
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,285 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// prefix 密文前缀，不带前缀的值视为旧版明文
+const prefix = "enc:v1:"
+
+// keySize 密钥长度（AES-256）
+const keySize = 32
+
+var (
+	// ErrUnknownKey 密文使用的主密钥不在密钥环中
+	ErrUnknownKey = errors.New("未知的主密钥")
+	// ErrMalformed 密文格式错误
+	ErrMalformed = errors.New("密文格式错误")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// KeyRing 主密钥环，实现信封加密：
+// 每次加密生成随机数据密钥加密明文，再用主密钥加密数据密钥，
+// 轮换主密钥时只需重新加密数据密钥，旧主密钥保留在密钥环中用于解密
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	primary string
+}
+
+// NewKeyRing 创建密钥环，primary 为加密使用的主密钥ID
+func NewKeyRing(primary string, keys map[string][]byte) (*KeyRing, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("主密钥 %q 不存在", primary)
+	}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("无效的密钥ID %q，只能包含字母、数字、_ 和 -", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("密钥 %q 长度应为 %d 字节，实际 %d", id, keySize, len(key))
+		}
+	}
+	return &KeyRing{keys: keys, primary: primary}, nil
+}
+
+// ParseKeys 解析密钥列表 "id:base64,id:base64"，第一个为主密钥，也支持按行分隔
+func ParseKeys(spec string) (*KeyRing, error) {
+	keys := make(map[string][]byte)
+	primary := ""
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item == "" || strings.HasPrefix(item, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("密钥格式应为 id:base64")
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("密钥 %q 不是有效的 base64: %v", id, err)
+		}
+		if _, exists := keys[id]; exists {
+			return nil, fmt.Errorf("重复的密钥ID %q", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	if primary == "" {
+		return nil, fmt.Errorf("未配置任何密钥")
+	}
+	return NewKeyRing(primary, keys)
+}
+
+// Load 从环境变量加载密钥环：SECRETS_KEYS 或 SECRETS_KEY_FILE，
+// 都未配置时生成临时密钥，重启后已保存的令牌将无法解密
+func Load() (*KeyRing, error) {
+	if spec := os.Getenv("SECRETS_KEYS"); spec != "" {
+		return ParseKeys(spec)
+	}
+	if path := os.Getenv("SECRETS_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取密钥文件失败: %v", err)
+		}
+		return ParseKeys(string(data))
+	}
+
+	log.Printf("⚠️ 未配置 SECRETS_KEYS/SECRETS_KEY_FILE，使用临时密钥，重启后已保存的令牌将无法解密")
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing("ephemeral", map[string][]byte{"ephemeral": key})
+}
+
+// GenerateKey 生成随机主密钥
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Primary 当前主密钥ID
+func (k *KeyRing) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Encrypt 加密字符串，空串原样返回
+func (k *KeyRing) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	k.mu.RLock()
+	keyID, kek := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+
+	dek, err := GenerateKey()
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(kek, dek, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	payload, err := seal(dek, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + keyID + ":" + encode(wrapped) + ":" + encode(payload), nil
+}
+
+// Decrypt 解密字符串，不带密文前缀的值视为明文原样返回
+func (k *KeyRing) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	keyID, wrapped, payload, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	k.mu.RLock()
+	kek, ok := k.keys[keyID]
+	k.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	plaintext, err := open(dek, payload, nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %v", err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation 密文是否需要用当前主密钥重新加密（包括旧版明文）
+func (k *KeyRing) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyID, _, _, err := parse(value)
+	return err == nil && keyID != k.Primary()
+}
+
+// Rotate 用当前主密钥重新加密数据密钥，明文部分不变；旧版明文会被加密
+func (k *KeyRing) Rotate(value string) (string, error) {
+	if !k.NeedsRotation(value) {
+		return value, nil
+	}
+	if !IsEncrypted(value) {
+		return k.Encrypt(value)
+	}
+
+	keyID, wrapped, payload, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	k.mu.RLock()
+	oldKEK, ok := k.keys[keyID]
+	primary, newKEK := k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	dek, err := open(oldKEK, wrapped, []byte(keyID))
+	if err != nil {
+		return "", fmt.Errorf("解密数据密钥失败: %v", err)
+	}
+	rewrapped, err := seal(newKEK, dek, []byte(primary))
+	if err != nil {
+		return "", err
+	}
+	return prefix + primary + ":" + encode(rewrapped) + ":" + encode(payload), nil
+}
+
+// IsEncrypted 是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// parse 拆分密文：主密钥ID、加密后的数据密钥、加密后的明文
+func parse(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, payload, nil
+}
+
+// seal AES-GCM 加密，输出 nonce+密文
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open AES-GCM 解密
+func open(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,400 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"live-im-proxy/auth"
+	"live-im-proxy/channel"
+	"live-im-proxy/lease"
+	"live-im-proxy/oauth"
+	"live-im-proxy/openapi"
+)
+
+// Service 渠道会话管理API
+//
+// 会话定义保存在租约存储中，由持有租约的副本通过 channel.Manager 运行，
+// 运行状态、计数和最近错误由持有者定期上报
+type Service struct {
+	coordinator   *lease.Coordinator
+	accounts      *oauth.AccountStore
+	defaultTenant string
+}
+
+// NewService 创建会话管理服务
+func NewService(coordinator *lease.Coordinator, accounts *oauth.AccountStore, defaultTenant string) *Service {
+	return &Service{
+		coordinator:   coordinator,
+		accounts:      accounts,
+		defaultTenant: defaultTenant,
+	}
+}
+
+// Session 会话详情
+type Session struct {
+	ID          string                      `json:"id"`
+	Key         string                      `json:"key"`
+	TenantID    string                      `json:"tenant_id"`
+	Platform    string                      `json:"platform"`
+	Kind        string                      `json:"kind" doc:"live、video 或 dm"`
+	AccountID   string                      `json:"account_id,omitempty"`
+	RoomID      string                      `json:"room_id,omitempty"`
+	VideoID     string                      `json:"video_id,omitempty"`
+	Source      string                      `json:"source,omitempty" doc:"discovery 表示由视频自动发现创建"`
+	State       string                      `json:"state" doc:"pending、running、paused 或 error"`
+	Owner       string                      `json:"owner,omitempty" doc:"运行该会话的副本"`
+	Counters    map[string]int64            `json:"counters"`
+	Videos      map[string]map[string]int64 `json:"videos,omitempty" doc:"短视频会话按视频ID细分的计数：comments、replies、reply_errors、errors"`
+	LastError   string                      `json:"last_error,omitempty"`
+	LastErrorAt *time.Time                  `json:"last_error_at,omitempty"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	UpdatedAt   *time.Time                  `json:"updated_at,omitempty" doc:"最近一次状态上报时间"`
+}
+
+// CreateRequest 创建会话请求
+type CreateRequest struct {
+	TenantID  string `json:"tenant_id,omitempty" doc:"未指定时使用默认租户"`
+	Platform  string `json:"platform" doc:"douyin、kuaishou、wechat 或 xiaohongshu"`
+	Kind      string `json:"kind,omitempty" doc:"live（默认）、video 或 dm，短视频和私信会话仅支持抖音"`
+	AccountID string `json:"account_id,omitempty" doc:"授权账号 open_id，抖音必填"`
+	RoomID    string `json:"room_id,omitempty" doc:"直播间ID，live 会话必填"`
+	VideoID   string `json:"video_id,omitempty" doc:"视频ID，video 会话必填，* 表示账号最近发布的视频"`
+	Paused    bool   `json:"paused,omitempty" doc:"创建后先不运行"`
+}
+
+// Spec 校验请求并生成会话定义，抖音会话需要账号已授权
+func (s *Service) Spec(req CreateRequest) (lease.SessionSpec, error) {
+	if req.Kind == "" {
+		req.Kind = lease.KindLive
+	}
+	if req.TenantID == "" {
+		req.TenantID = s.defaultTenant
+	}
+	if req.Platform == "" {
+		return lease.SessionSpec{}, fmt.Errorf("缺少参数: platform")
+	}
+	if !channel.SupportsKind(req.Platform, req.Kind) {
+		return lease.SessionSpec{}, fmt.Errorf("渠道 %s 不支持 %s 会话", req.Platform, req.Kind)
+	}
+
+	var target string
+	switch req.Kind {
+	case lease.KindLive:
+		if req.RoomID == "" {
+			return lease.SessionSpec{}, fmt.Errorf("缺少参数: room_id")
+		}
+		target = req.RoomID
+	case lease.KindVideo:
+		if req.VideoID == "" {
+			return lease.SessionSpec{}, fmt.Errorf("缺少参数: video_id")
+		}
+		target = req.VideoID
+	}
+
+	spec := lease.SessionSpec{
+		Key:       lease.SessionKeyFor(req.TenantID, req.Platform, req.Kind, req.AccountID, target),
+		TenantID:  req.TenantID,
+		Platform:  req.Platform,
+		Kind:      req.Kind,
+		AccountID: req.AccountID,
+		RoomID:    req.RoomID,
+		VideoID:   req.VideoID,
+		Paused:    req.Paused,
+	}
+
+	if req.Platform == "douyin" && req.AccountID == "" {
+		return lease.SessionSpec{}, fmt.Errorf("缺少参数: account_id")
+	}
+	if req.AccountID != "" {
+		// 只能使用本租户授权的账号，其他租户的账号按未授权处理
+		account, ok := s.accounts.Owned(req.AccountID, req.TenantID)
+		if !ok || account.Token == nil {
+			return lease.SessionSpec{}, fmt.Errorf("未找到账号信息，请先授权: %s", req.AccountID)
+		}
+		spec.AccessToken = account.Token.AccessToken
+	}
+	return spec, nil
+}
+
+// Find 按会话ID查找会话定义
+func (s *Service) Find(r *http.Request, id string) (lease.SessionSpec, bool, error) {
+	specs, err := s.coordinator.Sessions(r.Context())
+	if err != nil {
+		return lease.SessionSpec{}, false, err
+	}
+	for _, spec := range specs {
+		if lease.SessionID(spec.Key) == id {
+			// 租户密钥只能访问本租户的会话
+			return spec, auth.CanAccessTenant(r, spec.TenantID), nil
+		}
+	}
+	return lease.SessionSpec{}, false, nil
+}
+
+// view 合并会话定义和运行状态
+func view(spec lease.SessionSpec, status lease.SessionStatus, reported bool) Session {
+	sess := Session{
+		ID:        lease.SessionID(spec.Key),
+		Key:       spec.Key,
+		TenantID:  spec.TenantID,
+		Platform:  spec.Platform,
+		Kind:      spec.SessionKind(),
+		AccountID: spec.AccountID,
+		RoomID:    spec.RoomID,
+		VideoID:   spec.VideoID,
+		Source:    spec.Source,
+		State:     lease.StatePending,
+		Counters:  map[string]int64{},
+		CreatedAt: spec.CreatedAt,
+	}
+	if reported {
+		sess.State = status.State
+		sess.Owner = status.Owner
+		sess.LastError = status.LastError
+		sess.LastErrorAt = status.LastErrorAt
+		updatedAt := status.UpdatedAt
+		sess.UpdatedAt = &updatedAt
+		if status.Counters != nil {
+			sess.Counters = status.Counters
+		}
+		sess.Videos = status.Breakdown
+	}
+	switch {
+	case spec.Paused:
+		sess.State = lease.StatePaused
+		sess.Owner = ""
+	case sess.State == lease.StatePaused:
+		// 已恢复但尚未被副本接管
+		sess.State = lease.StatePending
+	}
+	return sess
+}
+
+// Get 查询单个会话
+func (s *Service) Get(r *http.Request, spec lease.SessionSpec) (Session, error) {
+	statuses, err := s.coordinator.Statuses(r.Context())
+	if err != nil {
+		return Session{}, err
+	}
+	status, ok := statuses[spec.Key]
+	return view(spec, status, ok), nil
+}
+
+// RegisterRoutes 注册会话管理API
+//
+//	POST   /api/v1/sessions                创建会话
+//	GET    /api/v1/sessions                会话列表 ?tenant_id=&platform=&kind=&state=
+//	GET    /api/v1/sessions/{id}           会话详情
+//	POST   /api/v1/sessions/{id}/pause     暂停会话
+//	POST   /api/v1/sessions/{id}/resume    恢复会话
+//	DELETE /api/v1/sessions/{id}           停止并删除会话
+func (s *Service) RegisterRoutes(router *openapi.Router) {
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/sessions", Tag: "sessions",
+		Summary: "创建会话", Request: CreateRequest{}, Response: Session{},
+		Status: http.StatusCreated, HandlerFunc: s.handleCreate,
+	})
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/sessions", Tag: "sessions",
+		Summary: "会话列表",
+		Query: []openapi.Param{
+			{Name: "tenant_id", Description: "租户"},
+			{Name: "platform", Description: "渠道"},
+			{Name: "kind", Description: "会话类型"},
+			{Name: "state", Description: "运行状态"},
+		},
+		Response: []Session{}, HandlerFunc: s.handleList,
+	})
+	router.Handle(openapi.Route{
+		Method: "GET", Path: "/api/v1/sessions/{id}", Tag: "sessions",
+		Summary: "会话详情", Response: Session{}, HandlerFunc: s.handleGet,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/sessions/{id}/pause", Tag: "sessions",
+		Summary: "暂停会话，保留定义但停止运行", Response: Session{}, HandlerFunc: s.handlePause,
+	})
+	router.Handle(openapi.Route{
+		Method: "POST", Path: "/api/v1/sessions/{id}/resume", Tag: "sessions",
+		Summary: "恢复已暂停的会话", Response: Session{}, HandlerFunc: s.handleResume,
+	})
+	router.Handle(openapi.Route{
+		Method: "DELETE", Path: "/api/v1/sessions/{id}", Tag: "sessions",
+		Summary: "停止并删除会话", HandlerFunc: s.handleDelete,
+	})
+}
+
+// handleCreate 创建会话
+func (s *Service) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.TenantID != "" && !auth.CanAccessTenant(r, req.TenantID) {
+		http.Error(w, "无权访问该租户", http.StatusForbidden)
+		return
+	}
+
+	spec, err := s.Spec(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if existing, visible, err := s.Find(r, lease.SessionID(spec.Key)); err != nil {
+		http.Error(w, "查询会话失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if existing.Key != "" && visible {
+		http.Error(w, "会话已存在", http.StatusConflict)
+		return
+	}
+
+	if err := s.coordinator.Submit(r.Context(), spec); err != nil {
+		log.Printf("❌ 提交会话失败: %v", err)
+		http.Error(w, "创建会话失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("✅ 会话已创建: %s", spec.Key)
+
+	created, _, err := s.Find(r, lease.SessionID(spec.Key))
+	if err != nil || created.Key == "" {
+		created = spec
+	}
+	sess, err := s.Get(r, created)
+	if err != nil {
+		sess = view(created, lease.SessionStatus{}, false)
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"success": true, "data": sess})
+}
+
+// handleList 会话列表
+func (s *Service) handleList(w http.ResponseWriter, r *http.Request) {
+	specs, err := s.coordinator.Sessions(r.Context())
+	if err != nil {
+		http.Error(w, "查询会话失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	statuses, err := s.coordinator.Statuses(r.Context())
+	if err != nil {
+		http.Error(w, "查询会话状态失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	sessions := make([]Session, 0, len(specs))
+	for _, spec := range specs {
+		if !auth.CanAccessTenant(r, spec.TenantID) {
+			continue
+		}
+		status, ok := statuses[spec.Key]
+		sess := view(spec, status, ok)
+		if !matches(q.Get("tenant_id"), sess.TenantID) || !matches(q.Get("platform"), sess.Platform) ||
+			!matches(q.Get("kind"), sess.Kind) || !matches(q.Get("state"), sess.State) {
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": sessions})
+}
+
+// matches 空过滤条件匹配所有值
+func matches(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+// handleGet 会话详情
+func (s *Service) handleGet(w http.ResponseWriter, r *http.Request) {
+	spec, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	sess, err := s.Get(r, spec)
+	if err != nil {
+		http.Error(w, "查询会话状态失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": sess})
+}
+
+// handlePause 暂停会话
+func (s *Service) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// handleResume 恢复会话
+func (s *Service) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+// setPaused 修改会话暂停状态，持有者在下一轮协调时停止或重新接管
+func (s *Service) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	spec, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	spec, err := s.coordinator.Update(r.Context(), spec.Key, func(spec *lease.SessionSpec) {
+		spec.Paused = paused
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if paused {
+		log.Printf("⏸️ 会话已暂停: %s", spec.Key)
+	} else {
+		log.Printf("▶️ 会话已恢复: %s", spec.Key)
+	}
+
+	sess, err := s.Get(r, spec)
+	if err != nil {
+		http.Error(w, "查询会话状态失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true, "data": sess})
+}
+
+// handleDelete 停止并删除会话
+func (s *Service) handleDelete(w http.ResponseWriter, r *http.Request) {
+	spec, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := s.coordinator.Remove(r.Context(), spec.Key); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	log.Printf("🛑 会话已删除: %s", spec.Key)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+}
+
+// lookup 读取路径中的会话ID，不存在或无权访问时返回404
+func (s *Service) lookup(w http.ResponseWriter, r *http.Request) (lease.SessionSpec, bool) {
+	spec, visible, err := s.Find(r, openapi.PathParam(r, "id"))
+	if err != nil {
+		http.Error(w, "查询会话失败: "+err.Error(), http.StatusInternalServerError)
+		return lease.SessionSpec{}, false
+	}
+	if spec.Key == "" || !visible {
+		http.Error(w, "会话不存在", http.StatusNotFound)
+		return lease.SessionSpec{}, false
+	}
+	return spec, true
+}
+
+// writeError 存储错误转换为HTTP状态码
+func (s *Service) writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, lease.ErrNotFound) {
+		http.Error(w, "会话不存在", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,87 @@
+package spam
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// listRequest 名单操作请求
+type listRequest struct {
+	TenantID string `json:"tenant_id"`
+	UserID   string `json:"user_id"`
+	Reason   string `json:"reason"`
+}
+
+// RegisterHandlers 注册黑白名单管理API
+//
+//	GET    /api/spam/blocklist?tenant_id=   查询黑名单
+//	POST   /api/spam/blocklist              加入黑名单 {"tenant_id","user_id","reason"}
+//	DELETE /api/spam/blocklist?tenant_id=&user_id=
+//
+// 白名单 /api/spam/allowlist 同上
+func (d *Detector) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/api/spam/blocklist", d.listHandler(d.Blocklist, d.Block, d.Unblock))
+	mux.HandleFunc("/api/spam/allowlist", d.listHandler(d.Allowlist, d.Allow, d.Disallow))
+}
+
+// listHandler 名单通用处理器
+func (d *Detector) listHandler(
+	list func(tenantID string) []ListEntry,
+	add func(tenantID, userID, reason string) (ListEntry, error),
+	remove func(tenantID, userID string) (bool, error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"success": true,
+				"data":    list(r.URL.Query().Get("tenant_id")),
+			})
+		case "POST":
+			var req listRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "请求格式错误: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.TenantID == "" || req.UserID == "" {
+				http.Error(w, "缺少参数: tenant_id 或 user_id", http.StatusBadRequest)
+				return
+			}
+			entry, err := add(req.TenantID, req.UserID, req.Reason)
+			if err != nil {
+				http.Error(w, "保存名单失败: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"success": true,
+				"data":    entry,
+			})
+		case "DELETE":
+			tenantID := r.URL.Query().Get("tenant_id")
+			userID := r.URL.Query().Get("user_id")
+			if tenantID == "" || userID == "" {
+				http.Error(w, "缺少参数: tenant_id 或 user_id", http.StatusBadRequest)
+				return
+			}
+			removed, err := remove(tenantID, userID)
+			if err != nil {
+				http.Error(w, "保存名单失败: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !removed {
+				http.Error(w, "名单中不存在该用户", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,394 @@
+package spam
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+// 判定原因
+const (
+	ReasonBlocklist = "blocklist" // 黑名单用户
+	ReasonFlood     = "flood"     // 短时间内刷屏
+	ReasonDuplicate = "duplicate" // 重复发送相同内容
+	ReasonURL       = "url"       // 包含链接
+	ReasonAd        = "ad"        // 广告/引流话术
+)
+
+// 处置动作
+const (
+	ActionDrop = "drop" // 丢弃，不进入管道
+	ActionTag  = "tag"  // 在元数据中标记，不自动回复
+)
+
+// MetadataKey 标记写入 event.Metadata 的键
+const MetadataKey = "spam"
+
+// DefaultAdPatterns 默认广告/引流话术
+var DefaultAdPatterns = []string{
+	`(?i)(加|\+)\s*(v|vx|微|薇|威)`,
+	`刷单|兼职|日结|躺赚|招代理|免费领取|互粉|互关|点我头像|看我主页|私我`,
+	`(?i)(淘宝|拼多多|pdd)\s*(搜|店)`,
+}
+
+var urlPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|[a-z0-9-]+\.(com|cn|net|cc|top|xyz)(/\S*)?`)
+
+// Config 反垃圾配置
+type Config struct {
+	FloodWindow     time.Duration     // 刷屏统计窗口
+	FloodMax        int               // 窗口内单用户最大消息数
+	DuplicateWindow time.Duration     // 重复内容判定窗口
+	AdPatterns      []string          // 广告话术正则
+	Actions         map[string]string // 原因 -> 处置动作
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		FloodWindow:     10 * time.Second,
+		FloodMax:        5,
+		DuplicateWindow: time.Minute,
+		AdPatterns:      DefaultAdPatterns,
+		Actions: map[string]string{
+			ReasonBlocklist: ActionDrop,
+			ReasonFlood:     ActionDrop,
+			ReasonDuplicate: ActionTag,
+			ReasonURL:       ActionTag,
+			ReasonAd:        ActionTag,
+		},
+	}
+}
+
+// Verdict 判定结果，Reason为空表示正常
+type Verdict struct {
+	Reason string `json:"reason,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+// Spam 是否判定为垃圾消息
+func (v Verdict) Spam() bool {
+	return v.Reason != ""
+}
+
+// userState 单用户的近期消息
+type userState struct {
+	times    []time.Time
+	contents map[string]time.Time
+	lastSeen time.Time
+}
+
+// ListEntry 黑白名单条目
+type ListEntry struct {
+	TenantID  string    `json:"tenant_id"`
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// lists 持久化的黑白名单
+type lists struct {
+	Blocklist []ListEntry `json:"blocklist"`
+	Allowlist []ListEntry `json:"allowlist"`
+}
+
+// Detector 入站反垃圾检测，设置了路径时黑白名单以JSON文件持久化
+type Detector struct {
+	mu         sync.Mutex
+	path       string
+	config     Config
+	adPatterns []*regexp.Regexp
+	users      map[string]*userState
+	blocklist  map[string]ListEntry
+	allowlist  map[string]ListEntry
+	counts     map[string]int64
+	lastSweep  time.Time
+}
+
+// NewDetector 创建反垃圾检测器，path 为空时黑白名单只保存在内存
+func NewDetector(path string, config Config) (*Detector, error) {
+	d := &Detector{
+		path:      path,
+		users:     make(map[string]*userState),
+		blocklist: make(map[string]ListEntry),
+		allowlist: make(map[string]ListEntry),
+		counts:    make(map[string]int64),
+		lastSweep: time.Now(),
+	}
+	d.SetConfig(config)
+	if path == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取黑白名单失败: %v", err)
+	}
+
+	var saved lists
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("解析黑白名单失败: %v", err)
+	}
+	for _, entry := range saved.Blocklist {
+		d.blocklist[listKey(entry.TenantID, entry.UserID)] = entry
+	}
+	for _, entry := range saved.Allowlist {
+		d.allowlist[listKey(entry.TenantID, entry.UserID)] = entry
+	}
+	return d, nil
+}
+
+// SetConfig 更新配置，无效的正则会被忽略并记录日志
+func (d *Detector) SetConfig(config Config) {
+	patterns := make([]*regexp.Regexp, 0, len(config.AdPatterns))
+	for _, p := range config.AdPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log.Printf("⚠️ 忽略无效的广告话术规则 %q: %v", p, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = config
+	d.adPatterns = patterns
+}
+
+// Inspect 检查事件，返回判定结果
+func (d *Detector) Inspect(evt *event.Event) Verdict {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := listKey(evt.TenantID, evt.UserID)
+	if _, ok := d.allowlist[key]; ok {
+		return Verdict{}
+	}
+	if _, ok := d.blocklist[key]; ok {
+		return d.verdict(ReasonBlocklist)
+	}
+
+	if evt.Content == "" {
+		return Verdict{}
+	}
+
+	now := time.Now()
+	d.sweep(now)
+
+	userKey := evt.TenantID + "/" + evt.Channel + "/" + evt.UserID
+	state, ok := d.users[userKey]
+	if !ok {
+		state = &userState{contents: make(map[string]time.Time)}
+		d.users[userKey] = state
+	}
+	state.lastSeen = now
+
+	// 刷屏：窗口内消息数
+	cutoff := now.Add(-d.config.FloodWindow)
+	times := state.times[:0]
+	for _, t := range state.times {
+		if t.After(cutoff) {
+			times = append(times, t)
+		}
+	}
+	state.times = append(times, now)
+	if d.config.FloodMax > 0 && len(state.times) > d.config.FloodMax {
+		return d.verdict(ReasonFlood)
+	}
+
+	// 重复内容
+	normalized := normalize(evt.Content)
+	if last, ok := state.contents[normalized]; ok && now.Sub(last) < d.config.DuplicateWindow {
+		state.contents[normalized] = now
+		return d.verdict(ReasonDuplicate)
+	}
+	state.contents[normalized] = now
+
+	if urlPattern.MatchString(evt.Content) {
+		return d.verdict(ReasonURL)
+	}
+	for _, re := range d.adPatterns {
+		if re.MatchString(evt.Content) {
+			return d.verdict(ReasonAd)
+		}
+	}
+
+	return Verdict{}
+}
+
+// Block 加入黑名单
+func (d *Detector) Block(tenantID, userID, reason string) (ListEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry := ListEntry{TenantID: tenantID, UserID: userID, Reason: reason, CreatedAt: time.Now()}
+	d.blocklist[listKey(tenantID, userID)] = entry
+	delete(d.allowlist, listKey(tenantID, userID))
+	log.Printf("🚫 加入黑名单: tenant=%s, user=%s, 原因=%s", tenantID, userID, reason)
+	return entry, d.save()
+}
+
+// Unblock 移出黑名单
+func (d *Detector) Unblock(tenantID, userID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := listKey(tenantID, userID)
+	if _, ok := d.blocklist[key]; !ok {
+		return false, nil
+	}
+	delete(d.blocklist, key)
+	return true, d.save()
+}
+
+// Allow 加入白名单，白名单用户跳过所有检查
+func (d *Detector) Allow(tenantID, userID, reason string) (ListEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry := ListEntry{TenantID: tenantID, UserID: userID, Reason: reason, CreatedAt: time.Now()}
+	d.allowlist[listKey(tenantID, userID)] = entry
+	delete(d.blocklist, listKey(tenantID, userID))
+	log.Printf("✅ 加入白名单: tenant=%s, user=%s", tenantID, userID)
+	return entry, d.save()
+}
+
+// Disallow 移出白名单
+func (d *Detector) Disallow(tenantID, userID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := listKey(tenantID, userID)
+	if _, ok := d.allowlist[key]; !ok {
+		return false, nil
+	}
+	delete(d.allowlist, key)
+	return true, d.save()
+}
+
+// Blocklist 获取租户黑名单
+func (d *Detector) Blocklist(tenantID string) []ListEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return filterEntries(d.blocklist, tenantID)
+}
+
+// Allowlist 获取租户白名单
+func (d *Detector) Allowlist(tenantID string) []ListEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return filterEntries(d.allowlist, tenantID)
+}
+
+// Stats 各原因的命中次数
+func (d *Detector) Stats() map[string]int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make(map[string]int64, len(d.counts))
+	for reason, count := range d.counts {
+		stats[reason] = count
+	}
+	return stats
+}
+
+// verdict 根据原因生成判定并计数
+func (d *Detector) verdict(reason string) Verdict {
+	d.counts[reason]++
+	action := d.config.Actions[reason]
+	if action == "" {
+		action = ActionTag
+	}
+	return Verdict{Reason: reason, Action: action}
+}
+
+// sweep 清理长时间不活跃的用户状态
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < time.Minute {
+		return
+	}
+	d.lastSweep = now
+
+	idle := d.config.DuplicateWindow
+	if d.config.FloodWindow > idle {
+		idle = d.config.FloodWindow
+	}
+	for key, state := range d.users {
+		if now.Sub(state.lastSeen) > idle {
+			delete(d.users, key)
+			continue
+		}
+		for content, t := range state.contents {
+			if now.Sub(t) > d.config.DuplicateWindow {
+				delete(state.contents, content)
+			}
+		}
+	}
+}
+
+// save 持久化黑白名单，需在锁内调用
+func (d *Detector) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	saved := lists{
+		Blocklist: filterEntries(d.blocklist, ""),
+		Allowlist: filterEntries(d.allowlist, ""),
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0700); err != nil {
+		return fmt.Errorf("创建黑白名单存储目录失败: %v", err)
+	}
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("写入黑白名单失败: %v", err)
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// filterEntries 按租户筛选名单
+func filterEntries(entries map[string]ListEntry, tenantID string) []ListEntry {
+	result := make([]ListEntry, 0)
+	for _, entry := range entries {
+		if tenantID == "" || entry.TenantID == tenantID {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return listKey(result[i].TenantID, result[i].UserID) < listKey(result[j].TenantID, result[j].UserID)
+	})
+	return result
+}
+
+// normalize 归一化内容用于重复判定：去空白、标点和大小写
+func normalize(content string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(content) {
+		if strings.ContainsRune(" \t\n,.!?，。！？~～、…", r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// listKey 名单键
+func listKey(tenantID, userID string) string {
+	return tenantID + "/" + userID
+}
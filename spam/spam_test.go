@@ -0,0 +1,131 @@
+package spam
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+func comment(userID, content string) *event.Event {
+	evt := event.NewEvent(event.TypeComment, "douyin", "room", userID, "昵称")
+	evt.TenantID = "tenant"
+	evt.Content = content
+	return evt
+}
+
+func newTestDetector(t *testing.T, config Config) *Detector {
+	t.Helper()
+	d, err := NewDetector("", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestInspectRules(t *testing.T) {
+	d := newTestDetector(t, DefaultConfig())
+
+	cases := []struct {
+		userID  string
+		content string
+		reason  string
+	}{
+		{"u1", "这个多少钱", ""},
+		{"u2", "看我主页有惊喜", ReasonAd},
+		{"u3", "详情 www.example.com", ReasonURL},
+		{"u4", "有红色吗", ""},
+		{"u4", "有红色吗？", ReasonDuplicate},
+	}
+	for _, c := range cases {
+		if v := d.Inspect(comment(c.userID, c.content)); v.Reason != c.reason {
+			t.Errorf("Inspect(%q) = %q, 期望 %q", c.content, v.Reason, c.reason)
+		}
+	}
+	if stats := d.Stats(); stats[ReasonAd] != 1 || stats[ReasonDuplicate] != 1 {
+		t.Fatalf("命中次数统计错误: %v", stats)
+	}
+}
+
+func TestFloodDropped(t *testing.T) {
+	config := DefaultConfig()
+	config.FloodMax = 2
+	config.FloodWindow = time.Minute
+	d := newTestDetector(t, config)
+
+	d.Inspect(comment("u1", "一"))
+	d.Inspect(comment("u1", "二"))
+	v := d.Inspect(comment("u1", "三"))
+	if v.Reason != ReasonFlood || v.Action != ActionDrop {
+		t.Fatalf("刷屏应丢弃: %+v", v)
+	}
+}
+
+func TestBlockAndAllowLists(t *testing.T) {
+	d := newTestDetector(t, DefaultConfig())
+
+	if _, err := d.Block("tenant", "u1", "骚扰"); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.Inspect(comment("u1", "你好")); v.Reason != ReasonBlocklist || v.Action != ActionDrop {
+		t.Fatalf("黑名单用户应丢弃: %+v", v)
+	}
+	other := comment("u1", "你好")
+	other.TenantID = "other"
+	if v := d.Inspect(other); v.Spam() {
+		t.Fatalf("黑名单只对所属租户生效: %+v", v)
+	}
+
+	if _, err := d.Allow("tenant", "u1", "老客户"); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Blocklist("tenant")) != 0 {
+		t.Fatal("加入白名单应移出黑名单")
+	}
+	if v := d.Inspect(comment("u1", "看我主页")); v.Spam() {
+		t.Fatalf("白名单用户应跳过检查: %+v", v)
+	}
+	if removed, err := d.Disallow("tenant", "u1"); !removed || err != nil {
+		t.Fatalf("移出白名单失败: %v, %v", removed, err)
+	}
+	if removed, _ := d.Unblock("tenant", "u1"); removed {
+		t.Fatal("不在黑名单中的用户不应移出成功")
+	}
+}
+
+func TestListsPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spam", "lists.json")
+	d, err := NewDetector(path, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Block("tenant", "spammer", "广告"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Allow("tenant", "vip", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewDetector(path, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := reloaded.Blocklist("tenant"); len(list) != 1 || list[0].UserID != "spammer" || list[0].Reason != "广告" {
+		t.Fatalf("重启后应保留黑名单: %+v", list)
+	}
+	if list := reloaded.Allowlist("tenant"); len(list) != 1 || list[0].UserID != "vip" {
+		t.Fatalf("重启后应保留白名单: %+v", list)
+	}
+
+	if _, err := reloaded.Unblock("tenant", "spammer"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewDetector(path, DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Blocklist("")) != 0 {
+		t.Fatal("移出黑名单应写回文件")
+	}
+}
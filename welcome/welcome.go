@@ -0,0 +1,262 @@
+package welcome
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"live-im-proxy/event"
+	"live-im-proxy/reply"
+)
+
+// Config 进场欢迎和关注感谢配置
+type Config struct {
+	Enabled        bool
+	Window         time.Duration // 合并窗口，窗口内进场的用户合并为一条欢迎
+	MinInterval    time.Duration // 同一直播间两条欢迎消息的最小间隔，繁忙时其余用户合并到下一条
+	UserCooldown   time.Duration // 同一用户多久内重复进场不再欢迎
+	MaxNames       int           // 一条消息最多点名的用户数
+	Template       string        // 普通用户欢迎模板，{names} 为昵称列表
+	VIPTemplate    string        // 老朋友和高意向用户的点名欢迎模板
+	ScoreThreshold int           // 历史线索评分达到该值视为高意向用户，0表示不按评分区分
+	ThankFollows   bool          // 是否感谢新关注
+	FollowTemplate string        // 关注感谢模板
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		Window:         15 * time.Second,
+		MinInterval:    30 * time.Second,
+		UserCooldown:   time.Hour,
+		MaxNames:       5,
+		Template:       "欢迎 {names} 来到直播间～",
+		VIPTemplate:    "欢迎老朋友 {names} 回来！",
+		ScoreThreshold: 10,
+		ThankFollows:   true,
+		FollowTemplate: "感谢 {names} 的关注！",
+	}
+}
+
+// Profile 用户画像，用于判断是否点名欢迎
+type Profile struct {
+	Returning bool // 以前互动过
+	Score     int  // 历史线索评分
+}
+
+// ProfileFunc 查询用户画像
+type ProfileFunc func(tenantID, channel, userID string) Profile
+
+// SendFunc 发送欢迎消息，evt 为窗口内最后一条事件，用于定位直播间和发送渠道；
+// 渠道不支持公屏消息时返回 reply.ErrRoomMessageUnsupported
+type SendFunc func(evt *event.Event, content string) error
+
+// group 待发送的一组昵称，只保留点名所需的前几个
+type group struct {
+	names []string
+	total int
+}
+
+func (g *group) add(name string, max int) {
+	g.total++
+	if len(g.names) < max {
+		g.names = append(g.names, name)
+	}
+}
+
+func (g *group) render(template string) string {
+	names := strings.Join(g.names, " ")
+	if g.total > len(g.names) {
+		names = fmt.Sprintf("%s 等%d位", names, g.total)
+	}
+	return strings.ReplaceAll(template, "{names}", names)
+}
+
+// room 单个直播间的待发送欢迎
+type room struct {
+	vips      group
+	guests    group
+	followers group
+	since     time.Time    // 第一条待发送事件的时间
+	lastEvent *event.Event // 最后一条待发送事件
+	lastSent  time.Time
+}
+
+func (r *room) pending() bool {
+	return r.vips.total > 0 || r.guests.total > 0 || r.followers.total > 0
+}
+
+// Greeter 进场欢迎和关注感谢：按窗口合并，按直播间限频
+type Greeter struct {
+	mu      sync.Mutex
+	config  Config
+	send    SendFunc
+	profile ProfileFunc
+	rooms   map[string]*room
+	greeted map[string]time.Time // 用户最近一次被欢迎的时间
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewGreeter 创建欢迎处理器，profile 为空时不区分老朋友
+func NewGreeter(config Config, send SendFunc, profile ProfileFunc) *Greeter {
+	g := &Greeter{
+		config:  config,
+		send:    send,
+		profile: profile,
+		rooms:   make(map[string]*room),
+		greeted: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+	go g.flushLoop()
+	return g
+}
+
+// SetConfig 更新配置
+func (g *Greeter) SetConfig(config Config) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.config = config
+}
+
+// Close 停止发送欢迎
+func (g *Greeter) Close() {
+	g.once.Do(func() {
+		close(g.done)
+	})
+}
+
+// Observe 处理进场和关注事件，其他事件忽略
+func (g *Greeter) Observe(evt *event.Event) {
+	if evt.Type != event.TypeEnter && evt.Type != event.TypeFollow {
+		return
+	}
+	g.mu.Lock()
+	config := g.config
+	g.mu.Unlock()
+	if !config.Enabled || evt.Nickname == "" {
+		return
+	}
+
+	// 画像查询可能较慢，放在锁外
+	var profile Profile
+	if evt.Type == event.TypeEnter && g.profile != nil {
+		profile = g.profile(evt.TenantID, evt.Channel, evt.UserID)
+	}
+
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	userKey := evt.TenantID + "/" + evt.Channel + "/" + evt.RoomID + "/" + evt.UserID + "/" + evt.Type
+	if last, ok := g.greeted[userKey]; ok && now.Sub(last) < config.UserCooldown {
+		return
+	}
+
+	roomKey := evt.TenantID + "/" + evt.Channel + "/" + evt.RoomID
+	r, ok := g.rooms[roomKey]
+	if !ok {
+		r = &room{}
+		g.rooms[roomKey] = r
+	}
+
+	switch {
+	case evt.Type == event.TypeFollow:
+		if !config.ThankFollows {
+			return
+		}
+		r.followers.add(evt.Nickname, config.MaxNames)
+	case profile.Returning || (config.ScoreThreshold > 0 && profile.Score >= config.ScoreThreshold):
+		r.vips.add(evt.Nickname, config.MaxNames)
+	default:
+		r.guests.add(evt.Nickname, config.MaxNames)
+	}
+
+	g.greeted[userKey] = now
+	if r.lastEvent == nil {
+		r.since = now
+	}
+	r.lastEvent = evt
+}
+
+// flushLoop 定期发送到期的欢迎消息
+func (g *Greeter) flushLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.done:
+			return
+		case now := <-ticker.C:
+			g.flush(now)
+		}
+	}
+}
+
+// flush 每个直播间把待发送的欢迎合并为一条，受最小间隔限制
+func (g *Greeter) flush(now time.Time) {
+	type message struct {
+		evt     *event.Event
+		content string
+	}
+	var messages []message
+
+	g.mu.Lock()
+	config := g.config
+	for key, r := range g.rooms {
+		if !config.Enabled {
+			delete(g.rooms, key)
+			continue
+		}
+		if !r.pending() {
+			if now.Sub(r.lastSent) > config.MinInterval {
+				delete(g.rooms, key)
+			}
+			continue
+		}
+		if now.Sub(r.since) < config.Window || now.Sub(r.lastSent) < config.MinInterval {
+			continue
+		}
+
+		var parts []string
+		if r.vips.total > 0 {
+			parts = append(parts, r.vips.render(config.VIPTemplate))
+		}
+		if r.guests.total > 0 {
+			parts = append(parts, r.guests.render(config.Template))
+		}
+		if r.followers.total > 0 {
+			parts = append(parts, r.followers.render(config.FollowTemplate))
+		}
+		messages = append(messages, message{evt: r.lastEvent, content: strings.Join(parts, " ")})
+
+		r.vips, r.guests, r.followers = group{}, group{}, group{}
+		r.lastEvent = nil
+		r.lastSent = now
+	}
+	for key, at := range g.greeted {
+		if now.Sub(at) >= config.UserCooldown {
+			delete(g.greeted, key)
+		}
+	}
+	g.mu.Unlock()
+
+	if g.send == nil {
+		return
+	}
+	for _, m := range messages {
+		err := g.send(m.evt, m.content)
+		switch {
+		case errors.Is(err, reply.ErrRoomMessageUnsupported):
+			log.Printf("⏭️ 跳过欢迎: room=%s, %v", m.evt.RoomID, err)
+		case err != nil:
+			log.Printf("❌ 发送欢迎失败: room=%s, err=%v", m.evt.RoomID, err)
+		default:
+			log.Printf("👋 已发送欢迎: room=%s, 内容=%s", m.evt.RoomID, m.content)
+		}
+	}
+}
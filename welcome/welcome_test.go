@@ -0,0 +1,122 @@
+package welcome
+
+import (
+	"testing"
+	"time"
+
+	"live-im-proxy/event"
+)
+
+// recorder 记录发出的欢迎消息
+type recorder struct {
+	messages []string
+}
+
+func (r *recorder) send(evt *event.Event, content string) error {
+	r.messages = append(r.messages, content)
+	return nil
+}
+
+// newTestGreeter 创建不自动发送的欢迎处理器，由测试调用 flush
+func newTestGreeter(config Config, profile ProfileFunc) (*Greeter, *recorder) {
+	rec := &recorder{}
+	config.Enabled = true
+	g := NewGreeter(config, rec.send, profile)
+	g.Close()
+	return g, rec
+}
+
+func enter(userID, nickname string) *event.Event {
+	return event.NewEvent(event.TypeEnter, "douyin", "room", userID, nickname)
+}
+
+func TestFlushMergesWindow(t *testing.T) {
+	g, rec := newTestGreeter(DefaultConfig(), nil)
+	g.Observe(enter("u1", "张三"))
+	g.Observe(enter("u2", "李四"))
+	start := time.Now()
+
+	g.flush(start)
+	if len(rec.messages) != 0 {
+		t.Fatalf("窗口未结束时不应发送: %v", rec.messages)
+	}
+	g.flush(start.Add(15 * time.Second))
+	if len(rec.messages) != 1 || rec.messages[0] != "欢迎 张三 李四 来到直播间～" {
+		t.Fatalf("messages = %v, 窗口内进场的用户应合并为一条", rec.messages)
+	}
+}
+
+func TestFlushRespectsMinInterval(t *testing.T) {
+	g, rec := newTestGreeter(DefaultConfig(), nil)
+	g.Observe(enter("u1", "张三"))
+	sent := time.Now().Add(15 * time.Second)
+	g.flush(sent)
+
+	g.Observe(enter("u2", "李四"))
+	g.Observe(enter("u3", "王五"))
+	g.flush(sent.Add(15 * time.Second))
+	if len(rec.messages) != 1 {
+		t.Fatalf("messages = %v, 距上一条不足最小间隔时不应发送", rec.messages)
+	}
+	g.flush(sent.Add(30 * time.Second))
+	if len(rec.messages) != 2 || rec.messages[1] != "欢迎 李四 王五 来到直播间～" {
+		t.Fatalf("messages = %v, 间隔期间进场的用户应合并到下一条", rec.messages)
+	}
+}
+
+func TestObserveUserCooldown(t *testing.T) {
+	g, rec := newTestGreeter(DefaultConfig(), nil)
+	g.Observe(enter("u1", "张三"))
+	start := time.Now()
+	g.flush(start.Add(15 * time.Second))
+
+	g.Observe(enter("u1", "张三"))
+	g.Observe(event.NewEvent(event.TypeFollow, "douyin", "room", "u1", "张三"))
+	g.flush(start.Add(time.Minute))
+	if len(rec.messages) != 2 || rec.messages[1] != "感谢 张三 的关注！" {
+		t.Fatalf("messages = %v, 冷却期内重复进场不再欢迎，关注仍应感谢", rec.messages)
+	}
+
+	// 冷却结束后清理记录，再次进场重新欢迎
+	g.flush(start.Add(2 * time.Hour))
+	g.Observe(enter("u1", "张三"))
+	g.mu.Lock()
+	pending := g.rooms["/douyin/room"].guests.total
+	g.mu.Unlock()
+	if pending != 1 {
+		t.Fatalf("冷却结束后再次进场应欢迎, pending = %d", pending)
+	}
+}
+
+func TestFlushTruncatesNames(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxNames = 2
+	g, rec := newTestGreeter(config, nil)
+	for i, name := range []string{"张三", "李四", "王五"} {
+		g.Observe(enter(string(rune('a'+i)), name))
+	}
+	g.flush(time.Now().Add(15 * time.Second))
+	if len(rec.messages) != 1 || rec.messages[0] != "欢迎 张三 李四 等3位 来到直播间～" {
+		t.Fatalf("messages = %v, 超过点名上限时应显示 等N位", rec.messages)
+	}
+}
+
+func TestFlushSeparatesVIPs(t *testing.T) {
+	profiles := map[string]Profile{
+		"old": {Returning: true},
+		"hot": {Score: 12},
+		"low": {Score: 3},
+	}
+	g, rec := newTestGreeter(DefaultConfig(), func(tenantID, channel, userID string) Profile {
+		return profiles[userID]
+	})
+	g.Observe(enter("old", "老用户"))
+	g.Observe(enter("hot", "高意向"))
+	g.Observe(enter("low", "路人"))
+	g.flush(time.Now().Add(15 * time.Second))
+
+	want := "欢迎老朋友 老用户 高意向 回来！ 欢迎 路人 来到直播间～"
+	if len(rec.messages) != 1 || rec.messages[0] != want {
+		t.Fatalf("messages = %v, 应为 %q", rec.messages, want)
+	}
+}